@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// bookkeeping of a cached item
+type EntryMeta[TId any] struct {
+	Id           TId
+	Refreshed    time.Time
+	AccessCount  uint64        // reads since the item was cached, zero unless WithAccessTracking
+	LastAccessed time.Time     // last read, zero when never read (or without WithAccessTracking)
+	TTL          time.Duration // time to live of the item, 0 when it does not expire (see WithAdaptiveTTL)
+}
+
+// counts the reads of every cached item (Get, GetOrAdd hits, Load, ...) and keeps the time of
+// the last one, reported by GetMeta and Items (e.g. to find the entries never read).
+// Off by default, as it writes to the item on every read. Reads served by a ReadCache
+// without reaching the cache are not counted
+func WithAccessTracking[TId comparable, TObj any]() Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		t.trackAccess = true
+
+	}
+
+}
+
+// returns the bookkeeping of a cached item, without counting as a read
+// returns false when the id is not cached
+func (t *HeapedCache[TId, TObj]) GetMeta(id TId) (EntryMeta[TId], bool) {
+
+	id = t.key(id)
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	item := t.unexpired(t.mapItems[id])
+
+	if item == nil {
+		return EntryMeta[TId]{}, false
+	}
+
+	return t.meta(item), true
+
+}
+
+// returns the bookkeeping of every cached item, in heap order (not sorted)
+func (t *HeapedCache[TId, TObj]) Items() []EntryMeta[TId] {
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	result := make([]EntryMeta[TId], len(t.sliceItems))
+
+	for i, item := range t.sliceItems {
+		result[i] = t.meta(item)
+	}
+
+	return result
+
+}
+
+func (t *HeapedCache[TId, TObj]) meta(item *HeapedCacheItem[TId, TObj]) EntryMeta[TId] {
+
+	return EntryMeta[TId]{
+		Id:           item.Id,
+		Refreshed:    item.Refreshed,
+		AccessCount:  item.accesses,
+		LastAccessed: item.lastAccessed,
+		TTL:          t.ttlOf(item),
+	}
+
+}
+
+// parses an id typed by a person (e.g. in the admin handler of the Registry):
+// as JSON, or as a string when it is not valid JSON ("42" is the number 42 for int ids, the string "42" for string ids)
+func parseID[TId any](s string) (TId, error) {
+
+	var id TId
+
+	if err := json.Unmarshal([]byte(s), &id); err == nil {
+		return id, nil
+	}
+
+	err := json.Unmarshal([]byte(strconv.Quote(s)), &id)
+
+	return id, err
+
+}
+
+// returns the cached item of an id for the admin handler of the Registry, redacted, as a snapshot line,
+// without counting as a read; returns false when the id is not cached
+func (t *HeapedCache[TId, TObj]) lookupKey(s string) (any, bool, error) {
+
+	id, err := parseID[TId](s)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	id = t.key(id)
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	item := t.unexpired(t.mapItems[id])
+
+	if item == nil {
+		return nil, false, nil
+	}
+
+	return exportedItem[TId, TObj]{Id: t.redactedID(item.Id), Refreshed: item.Refreshed, Obj: t.redactedObj(item.obj)}, true, nil
+
+}
+
+// Remove for the admin handler of the Registry
+func (t *HeapedCache[TId, TObj]) removeKey(s string) (bool, error) {
+
+	id, err := parseID[TId](s)
+
+	if err != nil {
+		return false, err
+	}
+
+	return t.Remove(id), nil
+
+}
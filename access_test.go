@@ -0,0 +1,48 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestAccessTracking(t *testing.T) {
+
+    t.Log("validating TestAccessTracking")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache(10, 1, clock, WithAccessTracking[int, AccountTest]())
+
+    for i := range 5 {
+        heapedCache.Push(i, NewAccountTest(i))
+    }
+
+    clock.Advance(time.Minute)
+
+    heapedCache.Get(1)
+    heapedCache.Get(1)
+    heapedCache.GetOrAdd(2, func(id int) *AccountTest { return nil })
+    heapedCache.Load(3)
+
+    meta, ok := heapedCache.GetMeta(1)
+    require.True(t, ok)
+    require.Equal(t, uint64(2), meta.AccessCount)
+    require.Equal(t, clock.Now(), meta.LastAccessed)
+
+    _, ok = heapedCache.GetMeta(9)
+    require.False(t, ok)
+
+    neverRead := 0
+
+    for _, meta := range heapedCache.Items() {
+
+        if meta.AccessCount == 0 {
+            require.True(t, meta.LastAccessed.IsZero())
+            neverRead++
+        }
+
+    }
+
+    require.Equal(t, 2, neverRead)
+
+}
@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// returned by ContextAdapter.Get when the key is not cached
+var ErrNotFound = errors.New("heapedcache: not found")
+
+// adapter with the method set of context based cache abstractions (gocache-style stores,
+// ORM and HTTP client caches): untyped keys and values, errors instead of nil results.
+// Values may be given as TObj or *TObj; Get returns *TObj
+type ContextAdapter[TId comparable, TObj any] struct {
+	cache *HeapedCache[TId, TObj]
+}
+
+// returns the context based adapter of the cache
+func (t *HeapedCache[TId, TObj]) ContextAdapter() *ContextAdapter[TId, TObj] {
+
+	return &ContextAdapter[TId, TObj]{cache: t}
+
+}
+
+// returns the cached *TObj, or ErrNotFound
+func (a *ContextAdapter[TId, TObj]) Get(ctx context.Context, key any) (any, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	obj := a.cache.Get(key)
+
+	if obj == nil {
+		return nil, ErrNotFound
+	}
+
+	return obj, nil
+
+}
+
+// caches value (TObj or *TObj) under key (TId)
+func (a *ContextAdapter[TId, TObj]) Set(ctx context.Context, key any, value any) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	id, ok := key.(TId)
+
+	if !ok {
+		return fmt.Errorf("heapedcache: key of type %T, expected %T", key, id)
+	}
+
+	var obj *TObj
+
+	switch v := value.(type) {
+	case *TObj:
+		obj = v
+	case TObj:
+		obj = &v
+	default:
+		return fmt.Errorf("heapedcache: value of type %T, expected %T", value, obj)
+	}
+
+	_, err := a.cache.TryPush(id, obj)
+
+	return err
+
+}
+
+// removes the item of key (no error when it was not cached)
+func (a *ContextAdapter[TId, TObj]) Delete(ctx context.Context, key any) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if id, ok := key.(TId); ok {
+		a.cache.Remove(id)
+	}
+
+	return nil
+
+}
+
+// removes every item
+func (a *ContextAdapter[TId, TObj]) Clear(ctx context.Context) error {
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	a.cache.Clear()
+
+	return nil
+
+}
+
+// adapter with the method set of cost based caches (ristretto-style):
+// typed keys, Set with a cost and Del. Costs are ignored, as the cache is bounded by rows
+type CostAdapter[TId comparable, TObj any] struct {
+	cache *HeapedCache[TId, TObj]
+}
+
+// returns the cost based adapter of the cache
+func (t *HeapedCache[TId, TObj]) CostAdapter() *CostAdapter[TId, TObj] {
+
+	return &CostAdapter[TId, TObj]{cache: t}
+
+}
+
+func (a *CostAdapter[TId, TObj]) Get(id TId) (*TObj, bool) {
+
+	return a.cache.Load(id)
+
+}
+
+// caches obj, returning false when it was refused (overflow policy or shutdown)
+func (a *CostAdapter[TId, TObj]) Set(id TId, obj *TObj, cost int64) bool {
+
+	_, err := a.cache.TryPush(id, obj)
+	return err == nil && obj != nil
+
+}
+
+func (a *CostAdapter[TId, TObj]) Del(id TId) {
+
+	a.cache.Remove(id)
+
+}
+
+func (a *CostAdapter[TId, TObj]) Clear() {
+
+	a.cache.Clear()
+
+}
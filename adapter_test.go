@@ -0,0 +1,60 @@
+package utils
+
+import (
+    "context"
+    "github.com/stretchr/testify/require"
+    "testing"
+)
+
+func TestContextAdapter(t *testing.T) {
+
+    t.Log("validating TestContextAdapter")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+    adapter := heapedCache.ContextAdapter()
+    ctx := context.Background()
+
+    require.NoError(t, adapter.Set(ctx, 1, NewAccountTest(1)))
+    require.NoError(t, adapter.Set(ctx, 2, *NewAccountTest(2)))
+    require.Error(t, adapter.Set(ctx, "3", NewAccountTest(3)))
+    require.Error(t, adapter.Set(ctx, 3, "account"))
+
+    value, err := adapter.Get(ctx, 2)
+    require.NoError(t, err)
+    require.Equal(t, 2, value.(*AccountTest).Id)
+
+    _, err = adapter.Get(ctx, 3)
+    require.ErrorIs(t, err, ErrNotFound)
+
+    require.NoError(t, adapter.Delete(ctx, 1))
+    require.Equal(t, 1, heapedCache.Len())
+
+    cancelled, cancel := context.WithCancel(ctx)
+    cancel()
+    require.ErrorIs(t, adapter.Clear(cancelled), context.Canceled)
+    require.Equal(t, 1, heapedCache.Len())
+
+    require.NoError(t, adapter.Clear(ctx))
+    require.Equal(t, 0, heapedCache.Len())
+
+}
+
+func TestCostAdapter(t *testing.T) {
+
+    t.Log("validating TestCostAdapter")
+
+    heapedCache := NewHeapedCache(1, WithOverflowPolicy[int, AccountTest](RejectNew))
+    adapter := heapedCache.CostAdapter()
+
+    require.True(t, adapter.Set(1, NewAccountTest(1), 100))
+    require.False(t, adapter.Set(2, NewAccountTest(2), 1))
+
+    obj, ok := adapter.Get(1)
+    require.True(t, ok)
+    require.Equal(t, 1, obj.Id)
+
+    adapter.Del(1)
+    _, ok = adapter.Get(1)
+    require.False(t, ok)
+
+}
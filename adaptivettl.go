@@ -0,0 +1,49 @@
+package utils
+
+import "time"
+
+// bounds of WithAdaptiveTTL
+type adaptiveTTL struct {
+	min time.Duration
+	max time.Duration
+}
+
+// experimental: adapts the time to live of every item to how often it is read, so hot keys stay
+// cached longer and cold ones leave sooner, without tuning a ttl per class of keys as traffic shifts.
+// An item never read lives half of its ttl (the one of WithTTL, WithTTLRule or PushWithTTL) and
+// every read since it was cached adds another half, within minTTL and maxTTL. The ttl chosen for an
+// item is reported by GetMeta and Items. Enables WithAccessTracking; items without a ttl still
+// do not expire, and reads served by a ReadCache do not count
+func WithAdaptiveTTL[TId comparable, TObj any](minTTL time.Duration, maxTTL time.Duration) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		if minTTL <= 0 || maxTTL < minTTL {
+			return
+		}
+
+		t.adaptiveTTL = &adaptiveTTL{min: minTTL, max: maxTTL}
+		t.trackAccess = true
+
+		// items expire out of the refreshed order from the start
+		if t.expiries == nil {
+			t.expiries = &expiryWheel[TId, TObj]{cache: t}
+		}
+
+	}
+
+}
+
+// returns the time to live of an item read reads times, whose ttl would be ttl
+func (a *adaptiveTTL) adapt(ttl time.Duration, reads uint64) time.Duration {
+
+	step := max(ttl/2, 1)
+
+	// beyond max, without overflowing
+	if reads >= uint64(a.max/step) {
+		return a.max
+	}
+
+	return min(max(step*time.Duration(reads+1), a.min), a.max)
+
+}
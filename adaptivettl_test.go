@@ -0,0 +1,77 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestAdaptiveTTL(t *testing.T) {
+
+    t.Log("validating TestAdaptiveTTL")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache(10, 1, clock,
+        WithTTL[int, AccountTest](time.Hour),
+        WithAdaptiveTTL[int, AccountTest](20*time.Minute, 2*time.Hour))
+
+    heapedCache.Push(1, NewAccountTest(1))
+    heapedCache.Push(2, NewAccountTest(2))
+    heapedCache.PushWithTTL(3, NewAccountTest(3), 10*time.Minute)
+
+    // never read: half of the ttl, within the bounds
+    meta, ok := heapedCache.GetMeta(1)
+    require.True(t, ok)
+    require.Equal(t, 30*time.Minute, meta.TTL)
+
+    meta, _ = heapedCache.GetMeta(3)
+    require.Equal(t, 20*time.Minute, meta.TTL)
+
+    // every read adds half of the ttl, up to the maximum
+    for range 2 {
+        require.NotNil(t, heapedCache.Get(2))
+    }
+
+    meta, _ = heapedCache.GetMeta(2)
+    require.Equal(t, 90*time.Minute, meta.TTL)
+
+    for range 10 {
+        require.NotNil(t, heapedCache.Get(2))
+    }
+
+    meta, _ = heapedCache.GetMeta(2)
+    require.Equal(t, 2*time.Hour, meta.TTL)
+
+    remaining, ok := heapedCache.RemainingTTL(2)
+    require.True(t, ok)
+    require.Equal(t, 2*time.Hour, remaining)
+
+    // the cold items leave first, the hot one stays past the ttl of the cache
+    clock.Advance(30 * time.Minute)
+    require.Equal(t, 1, heapedCache.Len())
+
+    clock.Advance(time.Hour)
+    require.NotNil(t, heapedCache.Get(2))
+    require.NoError(t, heapedCache.CheckInvariants())
+
+    clock.Advance(30 * time.Minute)
+    require.Equal(t, 0, heapedCache.Len())
+
+}
+
+func TestAdaptiveTTLInvalid(t *testing.T) {
+
+    t.Log("validating TestAdaptiveTTLInvalid")
+
+    heapedCache := NewHeapedCache(10, WithAdaptiveTTL[int, AccountTest](time.Hour, time.Minute))
+    require.Nil(t, heapedCache.adaptiveTTL)
+
+    // items without a ttl do not expire
+    heapedCache = NewHeapedCache(10, WithAdaptiveTTL[int, AccountTest](time.Minute, time.Hour))
+    heapedCache.Push(1, NewAccountTest(1))
+
+    meta, ok := heapedCache.GetMeta(1)
+    require.True(t, ok)
+    require.Zero(t, meta.TTL)
+
+}
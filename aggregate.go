@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// kind of aggregate maintained by WithAggregate
+type AggregateKind int
+
+const (
+	AggregateSum AggregateKind = iota
+	AggregateCount
+	AggregateMin
+	AggregateMax
+)
+
+// aggregate over a projected field of the cached objects,
+// updated as items are added, replaced and removed
+type aggregate[TObj any] struct {
+	kind    AggregateKind
+	project func(obj *TObj) float64
+	value   float64
+	count   int
+	stale   bool // min/max lost its current value and must be recomputed
+	panics  *atomic.Uint64
+}
+
+// maintains an aggregate (sum, count, min or max) of project(obj) over the cached items,
+// queryable through Aggregate(name). project is not used by AggregateCount.
+// Sum and count are updated incrementally; min and max are recomputed
+// on the next query only when the item holding the current value leaves the cache
+func WithAggregate[TId comparable, TObj any](name string, kind AggregateKind, project func(obj *TObj) float64) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		if project == nil && kind != AggregateCount {
+			return
+		}
+
+		if t.aggregates == nil {
+			t.aggregates = make(map[string]*aggregate[TObj])
+		}
+
+		t.aggregates[name] = &aggregate[TObj]{kind: kind, project: project, panics: &t.panics}
+
+	}
+
+}
+
+func (a *aggregate[TObj]) add(obj *TObj) {
+
+	a.count++
+
+	switch a.kind {
+
+	case AggregateSum:
+		a.value += a.projected(obj)
+
+	case AggregateMin:
+		if value := a.projected(obj); !a.stale && (a.count == 1 || value < a.value) {
+			a.value = value
+		}
+
+	case AggregateMax:
+		if value := a.projected(obj); !a.stale && (a.count == 1 || value > a.value) {
+			a.value = value
+		}
+
+	}
+
+}
+
+// returns the projection of an object, 0 when project panics
+func (a *aggregate[TObj]) projected(obj *TObj) float64 {
+
+	var value float64
+
+	contain(a.panics, func() { value = a.project(obj) })
+
+	return value
+
+}
+
+func (a *aggregate[TObj]) remove(obj *TObj) {
+
+	a.count--
+
+	switch a.kind {
+
+	case AggregateSum:
+		a.value -= a.projected(obj)
+
+	case AggregateMin, AggregateMax:
+		if a.projected(obj) == a.value {
+			a.stale = true
+		}
+
+	}
+
+}
+
+// returns the current value of the aggregate registered with WithAggregate.
+// ok is false when there is no aggregate with that name,
+// or when it is a min/max and the cache is empty
+func (t *HeapedCache[TId, TObj]) Aggregate(name string) (value float64, ok bool) {
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	a := t.aggregates[name]
+
+	if a == nil {
+		return 0, false
+	}
+
+	switch a.kind {
+
+	case AggregateCount:
+		return float64(a.count), true
+
+	case AggregateSum:
+		return a.value, true
+
+	}
+
+	if a.count == 0 {
+		return 0, false
+	}
+
+	if a.stale {
+
+		a.value = math.Inf(1)
+
+		if a.kind == AggregateMax {
+			a.value = math.Inf(-1)
+		}
+
+		for _, item := range t.sliceItems {
+
+			value := a.projected(item.obj)
+
+			if (a.kind == AggregateMin && value < a.value) || (a.kind == AggregateMax && value > a.value) {
+				a.value = value
+			}
+
+		}
+
+		a.stale = false
+
+	}
+
+	return a.value, true
+
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+)
+
+func TestAggregate(t *testing.T) {
+
+    t.Log("validating TestAggregate")
+
+    id := func(obj *AccountTest) float64 { return float64(obj.Id) }
+
+    heapedCache := NewHeapedCache(5,
+        WithAggregate[int, AccountTest]("sum", AggregateSum, id),
+        WithAggregate[int, AccountTest]("count", AggregateCount, nil),
+        WithAggregate[int, AccountTest]("min", AggregateMin, id),
+        WithAggregate[int, AccountTest]("max", AggregateMax, id),
+    )
+
+    _, ok := heapedCache.Aggregate("min")
+    require.False(t, ok)
+
+    for i := 1; i <= 5; i++ {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    requireAggregate(t, heapedCache, "sum", 15)
+    requireAggregate(t, heapedCache, "count", 5)
+    requireAggregate(t, heapedCache, "min", 1)
+    requireAggregate(t, heapedCache, "max", 5)
+
+    // evicts 1
+    heapedCache.Push(6, NewAccountTest(6))
+
+    requireAggregate(t, heapedCache, "sum", 20)
+    requireAggregate(t, heapedCache, "count", 5)
+    requireAggregate(t, heapedCache, "min", 2)
+    requireAggregate(t, heapedCache, "max", 6)
+
+    // replaces the object of 6
+    heapedCache.Push(6, NewAccountTest(0))
+
+    requireAggregate(t, heapedCache, "sum", 14)
+    requireAggregate(t, heapedCache, "min", 0)
+    requireAggregate(t, heapedCache, "max", 5)
+
+    heapedCache.Remove(6)
+    heapedCache.Pop()
+
+    requireAggregate(t, heapedCache, "sum", 12)
+    requireAggregate(t, heapedCache, "count", 3)
+    requireAggregate(t, heapedCache, "min", 3)
+
+    _, ok = heapedCache.Aggregate("unknown")
+    require.False(t, ok)
+
+}
+
+func requireAggregate(t *testing.T, heapedCache *HeapedCache[int, AccountTest], name string, expected float64) {
+
+    t.Helper()
+
+    value, ok := heapedCache.Aggregate(name)
+
+    require.True(t, ok)
+    require.Equal(t, expected, value)
+
+}
@@ -0,0 +1,128 @@
+package utils
+
+// alternative ids of cached items (see Alias)
+type aliases[TId comparable] struct {
+	canonical map[TId]TId   // by alias
+	of        map[TId][]TId // aliases by canonical id
+}
+
+// registers aliasID as another id of the cached item of canonicalID, so Get, GetWithAge and
+// GetOrAdd find it under either id, e.g. an account looked up by number, UUID and email is
+// cached once. Other operations (Push, Remove...) do not resolve aliases.
+// The aliases of an item are forgotten when it leaves the cache, and an alias is forgotten
+// when an item is added under its id. An alias of an alias refers to the same canonical id.
+// returns false when canonicalID is not cached, or when aliasID is
+func (t *HeapedCache[TId, TObj]) Alias(aliasID TId, canonicalID TId) bool {
+
+	aliasID = t.key(aliasID)
+	canonicalID = t.key(canonicalID)
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	canonicalID = t.resolve(canonicalID).(TId)
+
+	if t.mapItems[canonicalID] == nil || t.mapItems[aliasID] != nil {
+		return false
+	}
+
+	if t.aliases == nil {
+		t.aliases = &aliases[TId]{canonical: make(map[TId]TId), of: make(map[TId][]TId)}
+	}
+
+	t.unalias(aliasID)
+
+	t.aliases.canonical[aliasID] = canonicalID
+	t.aliases.of[canonicalID] = append(t.aliases.of[canonicalID], aliasID)
+	t.aliased.Add(1)
+
+	return true
+
+}
+
+// forgets an alias registered with Alias
+// returns false when aliasID is not an alias
+func (t *HeapedCache[TId, TObj]) Unalias(aliasID TId) bool {
+
+	aliasID = t.key(aliasID)
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	return t.unalias(aliasID)
+
+}
+
+// returns the canonical id of an alias, or id itself
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) resolve(id any) any {
+
+	if t.aliases == nil {
+		return id
+	}
+
+	if key, ok := id.(TId); ok {
+
+		if canonical, found := t.aliases.canonical[key]; found {
+			return canonical
+		}
+
+	}
+
+	return id
+
+}
+
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) unalias(aliasID TId) bool {
+
+	if t.aliases == nil {
+		return false
+	}
+
+	canonical, found := t.aliases.canonical[aliasID]
+
+	if !found {
+		return false
+	}
+
+	delete(t.aliases.canonical, aliasID)
+	t.aliased.Add(-1)
+
+	others := t.aliases.of[canonical]
+
+	for i, other := range others {
+
+		if other == aliasID {
+			others = append(others[:i], others[i+1:]...)
+			break
+		}
+
+	}
+
+	if len(others) == 0 {
+		delete(t.aliases.of, canonical)
+	} else {
+		t.aliases.of[canonical] = others
+	}
+
+	return true
+
+}
+
+// forgets the aliases of an item leaving the cache
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) dropAliases(id TId) {
+
+	if t.aliases == nil {
+		return
+	}
+
+	for _, alias := range t.aliases.of[id] {
+		delete(t.aliases.canonical, alias)
+		t.aliased.Add(-1)
+	}
+
+	delete(t.aliases.of, id)
+
+}
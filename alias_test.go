@@ -0,0 +1,43 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+)
+
+func TestAlias(t *testing.T) {
+
+    t.Log("validating TestAlias")
+
+    heapedCache := NewHeapedCache(2, WithBloomFilter[int, AccountTest](100, 0.01, bloomHash()))
+
+    account := heapedCache.Push(1, NewAccountTest(1))
+
+    require.True(t, heapedCache.Alias(100, 1))
+    require.True(t, heapedCache.Alias(200, 100))
+    require.False(t, heapedCache.Alias(300, 2))
+    require.False(t, heapedCache.Alias(1, 1))
+
+    require.Same(t, account, heapedCache.Get(100))
+    require.Same(t, account, heapedCache.Get(200))
+    require.Same(t, account, heapedCache.GetOrAdd(100, func(id int) *AccountTest { return NewAccountTest(id) }))
+    require.Equal(t, 1, heapedCache.Len())
+
+    require.True(t, heapedCache.Unalias(200))
+    require.False(t, heapedCache.Unalias(200))
+    require.Nil(t, heapedCache.Get(200))
+
+    // pushing an alias id caches a separate item
+    other := heapedCache.Push(100, NewAccountTest(100))
+    require.Same(t, other, heapedCache.Get(100))
+    require.Same(t, account, heapedCache.Get(1))
+
+    // the aliases are forgotten when the item is evicted
+    require.True(t, heapedCache.Alias(300, 1))
+    heapedCache.Push(2, NewAccountTest(2))
+
+    require.Nil(t, heapedCache.Get(1))
+    require.Nil(t, heapedCache.Get(300))
+    require.Equal(t, int64(0), heapedCache.aliased.Load())
+
+}
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// mutating operation reported to the audit sink of WithAudit
+// Before and After are the versions of the item around the operation: every change of an id
+// gets a new version, unique in the cache, and an id that is not cached has version 0
+// (After is 0 for the items that left the cache, Before is 0 for the new ones)
+type AuditRecord[TId any] struct {
+	Time    time.Time
+	Op      string // OpPush, OpPop, OpRemove or OpEvict
+	Outcome string // OutcomeAdded, OutcomeUpdated, OutcomeRemoved, OutcomeEvicted, ...
+	Id      TId
+	Actor   any             // taken from the context of the operation, nil for the operations without one
+	Context context.Context // of the operation (trace ids, tenant...), nil for the operations without one
+	Before  uint64
+	After   uint64
+}
+
+// destination of the records of WithAudit
+type AuditSink[TId any] interface {
+	Audit(record AuditRecord[TId])
+}
+
+// adapter allowing a plain function to be used as an AuditSink
+type AuditSinkFunc[TId any] func(record AuditRecord[TId])
+
+func (f AuditSinkFunc[TId]) Audit(record AuditRecord[TId]) {
+
+	f(record)
+
+}
+
+// audit trail of the mutating operations
+type auditor[TId comparable] struct {
+	sink     AuditSink[TId]
+	actor    func(ctx context.Context) any
+	versions map[TId]uint64 // version of every cached id
+	version  uint64
+	pending  []AuditRecord[TId]
+	panics   *atomic.Uint64
+}
+
+// reports every mutating operation (items added, updated, patched, popped, leased, removed,
+// evicted or invalidated) to sink, with the versions of the item before and after it.
+// actor extracts who did it from the context of the operation (e.g. a user id stored
+// with context.WithValue), also given with the record; the context aware operations are
+// PushContext, RemoveContext, GetOrAddContext, Warm and RemoveIf, the others are reported
+// without a context nor an actor. actor may be nil.
+// sink is called after the cache lock is released, in order, on the goroutine that did
+// the operation (before it returns), so it may call back into the cache; its panics are contained,
+// as the ones of actor are
+func WithAudit[TId comparable, TObj any](sink AuditSink[TId], actor func(ctx context.Context) any) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		if sink != nil {
+			t.audit = &auditor[TId]{sink: sink, actor: actor, versions: make(map[TId]uint64), panics: &t.panics}
+		}
+
+	}
+
+}
+
+// outcomes of the mutating operations: true when the item is cached afterwards
+var auditedOutcomes = map[string]bool{
+	OutcomeAdded:       true,
+	OutcomeUpdated:     true,
+	OutcomePatched:     true,
+	OutcomeReturned:    true,
+	OutcomePopped:      false,
+	OutcomeLeased:      false,
+	OutcomeRemoved:     false,
+	OutcomeEvicted:     false,
+	OutcomeInvalidated: false,
+	OutcomeExpired:     false,
+}
+
+// queues the record of an operation when it changed the cache
+// must be called under the lock
+func (a *auditor[TId]) observe(op string, id TId, outcome string, now time.Time, ctx context.Context) {
+
+	cached, ok := auditedOutcomes[outcome]
+
+	if !ok {
+		return
+	}
+
+	record := AuditRecord[TId]{Time: now, Op: op, Outcome: outcome, Id: id, Context: ctx, Before: a.versions[id]}
+
+	if cached {
+		a.version++
+		a.versions[id] = a.version
+		record.After = a.version
+	} else {
+		delete(a.versions, id)
+	}
+
+	// an actor that panics leaves the record without one
+	if ctx != nil && a.actor != nil {
+		contain(a.panics, func() { record.Actor = a.actor(ctx) })
+	}
+
+	a.pending = append(a.pending, record)
+
+}
+
+// takes the queued records
+// must be called under the lock, right before releasing it
+func (t *HeapedCache[TId, TObj]) takeAuditRecords() []AuditRecord[TId] {
+
+	if t.audit == nil {
+		return nil
+	}
+
+	if len(t.audit.pending) == 0 {
+		return nil
+	}
+
+	records := t.audit.pending
+	t.audit.pending = nil
+
+	return records
+
+}
+
+// delivers the records of a critical section to the audit sink, once the lock is released
+func (t *HeapedCache[TId, TObj]) deliverAudit(records []AuditRecord[TId]) {
+
+	for _, record := range records {
+		contain(&t.panics, func() { t.audit.sink.Audit(record) })
+	}
+
+}
+
+// same as TryPush, with the context given to the audit sink (see WithAudit)
+// and to the eviction callbacks of the items it evicts (see OnEvictContext)
+func (t *HeapedCache[TId, TObj]) PushContext(ctx context.Context, id TId, item *TObj) (*TObj, error) {
+
+	if t.latencies != nil {
+		defer t.latencies.observe(OpPush, time.Now())
+	}
+
+	t.lock(OpPush)
+	defer t.unlock()
+
+	t.operationContext(ctx)
+
+	return t.push(id, item)
+
+}
+
+// same as Remove, with the context given to the audit sink (see WithAudit)
+// and to the eviction callbacks (see OnEvictContext)
+func (t *HeapedCache[TId, TObj]) RemoveContext(ctx context.Context, id TId) bool {
+
+	t.lock(OpRemove)
+	defer t.unlock()
+
+	t.operationContext(ctx)
+
+	return t.remove(id)
+
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+    "context"
+    "github.com/stretchr/testify/require"
+    "testing"
+)
+
+type auditActor struct{}
+
+func TestAudit(t *testing.T) {
+
+    t.Log("validating TestAudit")
+
+    var records []AuditRecord[int]
+
+    heapedCache := NewHeapedCache(2, WithAudit[int, AccountTest](AuditSinkFunc[int](func(record AuditRecord[int]) {
+        records = append(records, record)
+    }), func(ctx context.Context) any {
+        return ctx.Value(auditActor{})
+    }))
+
+    ctx := context.WithValue(context.Background(), auditActor{}, "alice")
+
+    _, err := heapedCache.PushContext(ctx, 1, NewAccountTest(1))
+    require.NoError(t, err)
+
+    heapedCache.Push(1, NewAccountTest(1))
+    heapedCache.Push(2, NewAccountTest(2))
+    heapedCache.Push(3, NewAccountTest(3))
+    heapedCache.Get(2)
+
+    require.False(t, heapedCache.RemoveContext(ctx, 1))
+    require.True(t, heapedCache.RemoveContext(ctx, 2))
+
+    require.Len(t, records, 6)
+
+    require.Equal(t, AuditRecord[int]{Time: records[0].Time, Op: OpPush, Outcome: OutcomeAdded, Id: 1, Actor: "alice", Context: ctx, Before: 0, After: 1}, records[0])
+    require.Equal(t, AuditRecord[int]{Time: records[1].Time, Op: OpPush, Outcome: OutcomeUpdated, Id: 1, Before: 1, After: 2}, records[1])
+    require.Equal(t, OutcomeAdded, records[2].Outcome)
+    require.Equal(t, OutcomeAdded, records[3].Outcome)
+
+    // item 1 made room for item 3
+    require.Equal(t, AuditRecord[int]{Time: records[4].Time, Op: OpEvict, Outcome: OutcomeEvicted, Id: 1, Before: 2, After: 0}, records[4])
+    require.Equal(t, AuditRecord[int]{Time: records[5].Time, Op: OpRemove, Outcome: OutcomeRemoved, Id: 2, Actor: "alice", Context: ctx, Before: 3, After: 0}, records[5])
+
+}
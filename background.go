@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// names of the background tasks
+const (
+	taskTrim     = "trim"
+	taskAudit    = "audit"
+	taskWatchdog = "watchdog"
+	taskReaper   = "reaper"
+	taskFix      = "fix"
+)
+
+// function run periodically by a background goroutine of the cache
+type backgroundTask struct {
+	name     string
+	interval time.Duration
+	fn       func()
+	ticker   *time.Ticker // created when the goroutine starts
+	timer    *fakeTimer   // instead of the goroutine and its ticker, on a deterministic cache
+	started  time.Time
+	lastRun  atomic.Int64 // unix nanoseconds of the end of the last run, 0 before the first one
+	stopped  atomic.Bool
+}
+
+// registers fn to be called every interval once the cache is created, until Close
+// meant to be used by options; name identifies the task (see resetInterval)
+func (t *HeapedCache[TId, TObj]) every(name string, interval time.Duration, fn func()) {
+
+	t.background = append(t.background, &backgroundTask{name: name, interval: interval, fn: fn})
+
+}
+
+// returns the background task of a given name, or nil
+func (t *HeapedCache[TId, TObj]) task(name string) *backgroundTask {
+
+	for _, task := range t.background {
+
+		if task.name == name {
+			return task
+		}
+
+	}
+
+	return nil
+
+}
+
+// starts the goroutines of the registered background tasks
+func (t *HeapedCache[TId, TObj]) startBackground() {
+
+	if len(t.background) == 0 {
+		return
+	}
+
+	t.done = make(chan struct{})
+
+	for _, task := range t.background {
+
+		task.started = t.now()
+
+		if t.fakeClock != nil {
+			task.timer = t.fakeClock.every(task.interval, func() { t.runTask(task) })
+			continue
+		}
+
+		task.ticker = time.NewTicker(task.interval)
+
+		go t.labeled(nil, task.name, func() {
+
+			defer task.stopped.Store(true)
+			defer task.ticker.Stop()
+
+			for {
+
+				select {
+				case <-t.done:
+					return
+				case <-task.ticker.C:
+					t.runTask(task)
+				}
+
+			}
+
+		})
+
+	}
+
+}
+
+// runs a background task once
+func (t *HeapedCache[TId, TObj]) runTask(task *backgroundTask) {
+
+	// a panicking run must not stop the task for good
+	contain(&t.panics, task.fn)
+	task.lastRun.Store(t.now().UnixNano())
+
+}
+
+// stops the background goroutines of the cache
+// and leaves its budget (WithBudget); the cache is still usable afterwards
+func (t *HeapedCache[TId, TObj]) Close() {
+
+	if t.done != nil {
+		t.closeOnce.Do(func() { close(t.done) })
+	}
+
+	for _, task := range t.background {
+
+		if task.timer != nil {
+			task.timer.stop()
+			task.stopped.Store(true)
+		}
+
+	}
+
+	t.lock(opOther)
+	budget := t.budget
+	t.budget = nil
+	t.unlock()
+
+	if budget != nil {
+		budget.leave(t)
+	}
+
+}
+
+// changes the interval of a running background task
+func (task *backgroundTask) resetInterval(interval time.Duration) {
+
+	task.interval = interval
+
+	if task.timer != nil {
+		task.timer.reset(interval)
+		return
+	}
+
+	task.ticker.Reset(interval)
+
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"container/heap"
+	"time"
+)
+
+// struct to represent a cached item returned by queries
+type Entry[TId any, TObj any] struct {
+	Id        TId
+	Obj       *TObj
+	Refreshed time.Time
+}
+
+// returns the items refreshed between from and to (both inclusive), oldest first.
+// The heap is walked from its root, skipping every subtree whose root was refreshed
+// after to, since nothing below it can be older
+func (t *HeapedCache[TId, TObj]) Between(from time.Time, to time.Time) []Entry[TId, TObj] {
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	var result []Entry[TId, TObj]
+
+	if len(t.sliceItems) == 0 {
+		return result
+	}
+
+	t.settle()
+
+	stack := []int{0}
+
+	for len(stack) > 0 {
+
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		item := t.sliceItems[i]
+
+		if item.Refreshed.After(to) {
+			continue
+		}
+
+		if !item.Refreshed.Before(from) {
+			result = append(result, Entry[TId, TObj]{Id: item.Id, Obj: item.obj, Refreshed: item.Refreshed})
+		}
+
+		for child := heapFirstChild(i); child < heapFirstChild(i)+heapArity && child < len(t.sliceItems); child++ {
+			stack = append(stack, child)
+		}
+
+	}
+
+	sortEntries(result)
+
+	return result
+
+}
+
+// returns the n oldest items, oldest first.
+// The heap is walked in order without being changed (see oldestEvictableIn), in O(n log n)
+func (t *HeapedCache[TId, TObj]) Oldest(n int) []Entry[TId, TObj] {
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	var result []Entry[TId, TObj]
+
+	if len(t.sliceItems) == 0 || n <= 0 {
+		return result
+	}
+
+	t.settle()
+
+	candidates := &heapPositions[TId, TObj]{items: t.sliceItems, positions: []int{0}}
+
+	for len(result) < n && candidates.Len() > 0 {
+
+		i := heap.Pop(candidates).(int)
+		item := t.sliceItems[i]
+
+		result = append(result, Entry[TId, TObj]{Id: item.Id, Obj: item.obj, Refreshed: item.Refreshed})
+
+		for child := heapFirstChild(i); child < heapFirstChild(i)+heapArity && child < len(t.sliceItems); child++ {
+			heap.Push(candidates, child)
+		}
+
+	}
+
+	return result
+
+}
+
+// Oldest for the admin handler of the Registry, redacted, as snapshot lines
+func (t *HeapedCache[TId, TObj]) oldest(n int) any {
+
+	result := []exportedItem[TId, TObj]{}
+
+	for _, entry := range t.Oldest(n) {
+		result = append(result, exportedItem[TId, TObj]{Id: t.redactedID(entry.Id), Refreshed: entry.Refreshed, Obj: t.redactedObj(entry.Obj)})
+	}
+
+	return result
+
+}
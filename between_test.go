@@ -0,0 +1,73 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestBetween(t *testing.T) {
+
+    t.Log("validating TestBetween")
+
+    start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+    clock := NewFakeClock(start)
+    heapedCache := NewHeapedCache(100, WithClock[int, AccountTest](clock.Now))
+
+    for i := range 100 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+        clock.Advance(time.Minute)
+
+    }
+
+    // refreshing 5 moves it out of the window
+    heapedCache.Push(5, NewAccountTest(5))
+
+    entries := heapedCache.Between(start.Add(3*time.Minute), start.Add(10*time.Minute))
+
+    ids := make([]int, len(entries))
+
+    for i, entry := range entries {
+        ids[i] = entry.Id
+        require.Equal(t, entry.Id, entry.Obj.Id)
+    }
+
+    require.Equal(t, []int{3, 4, 6, 7, 8, 9, 10}, ids)
+
+    require.Empty(t, heapedCache.Between(start.Add(-time.Hour), start.Add(-time.Minute)))
+    require.Equal(t, 100, len(heapedCache.Between(start, clock.Now())))
+
+}
+
+func TestOldest(t *testing.T) {
+
+    t.Log("validating TestOldest")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewHeapedCache(100, WithClock[int, AccountTest](clock.Now))
+
+    for i := range 100 {
+
+        heapedCache.Push((i*37)%100, NewAccountTest(i))
+        clock.Advance(time.Minute)
+
+    }
+
+    // refreshing the oldest one moves it to the end
+    heapedCache.Push(0, NewAccountTest(0))
+
+    entries := heapedCache.Oldest(5)
+
+    ids := make([]int, len(entries))
+
+    for i, entry := range entries {
+        ids[i] = entry.Id
+    }
+
+    require.Equal(t, []int{37, 74, 11, 48, 85}, ids)
+    require.Len(t, heapedCache.Oldest(1000), 100)
+    require.Empty(t, heapedCache.Oldest(0))
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
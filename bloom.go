@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+)
+
+// counting Bloom filter over the cached ids: it can tell for sure that an id
+// is not cached without taking the lock. Counters are updated under the cache
+// lock and read atomically, so they support removals as well
+type bloomFilter[TId any] struct {
+	counters []atomic.Uint32
+	hashes   uint64
+	hash     func(id TId) uint64
+}
+
+// puts a counting Bloom filter in front of Get and GetOrAdd, so lookups of ids
+// that are certainly not cached are answered without taking the lock
+// (ids cached as negative by CacheNilAsNegative count as cached).
+// The filter is sized for expectedItems ids with the given false positive rate
+// (the chance of taking the lock for an id that turns out not to be cached).
+// hash must spread the ids over the whole uint64 range (e.g. maphash or FNV)
+func WithBloomFilter[TId comparable, TObj any](expectedItems int, falsePositiveRate float64, hash func(id TId) uint64) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		if hash == nil || expectedItems <= 0 || falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+			return
+		}
+
+		size := math.Ceil(-float64(expectedItems) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+		hashes := math.Max(1, math.Round(size/float64(expectedItems)*math.Ln2))
+
+		t.bloom = &bloomFilter[TId]{
+			counters: make([]atomic.Uint32, uint64(size)),
+			hashes:   uint64(hashes),
+			hash:     hash,
+		}
+
+	}
+
+}
+
+// calls fn with the position of each counter of the id (double hashing)
+func (b *bloomFilter[TId]) positions(id TId, fn func(position uint64) bool) {
+
+	h1 := b.hash(id)
+	h2 := (h1>>33 | h1<<31) | 1
+	size := uint64(len(b.counters))
+
+	for i := range b.hashes {
+
+		if !fn((h1 + i*h2) % size) {
+			return
+		}
+
+	}
+
+}
+
+func (b *bloomFilter[TId]) add(id TId) {
+
+	b.positions(id, func(position uint64) bool {
+		b.counters[position].Add(1)
+		return true
+	})
+
+}
+
+func (b *bloomFilter[TId]) remove(id TId) {
+
+	b.positions(id, func(position uint64) bool {
+		b.counters[position].Add(^uint32(0))
+		return true
+	})
+
+}
+
+// returns false when the id is certainly not cached
+func (b *bloomFilter[TId]) mayContain(id TId) bool {
+
+	result := true
+
+	b.positions(id, func(position uint64) bool {
+		result = b.counters[position].Load() > 0
+		return result
+	})
+
+	return result
+
+}
+
+// returns true when the bloom filter guarantees that the id is not cached
+func (t *HeapedCache[TId, TObj]) certainlyMissing(id any) bool {
+
+	// shadows and the ghost list see the misses, which needs the lock,
+	// and aliases are not in the filter
+	if t.bloom == nil || len(t.shadows) > 0 || t.ghost != nil || t.aliased.Load() > 0 {
+		return false
+	}
+
+	key, ok := id.(TId)
+
+	return ok && !t.bloom.mayContain(key)
+
+}
+
+// GetOrAdd for ids known to be missing: fn runs before taking the lock,
+// and its result is only cached if no one else cached the id meanwhile
+func (t *HeapedCache[TId, TObj]) loadAndAdd(ctx context.Context, id TId, fn func(id TId) *TObj, opts EntryOptions) (*TObj, error) {
+
+	result, err := t.load(ctx, id, fn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	t.lock(OpGetOrAdd)
+	defer t.unlock()
+
+	t.operationContext(ctx)
+	t.touchKey(id)
+
+	if findItem := t.unexpired(t.mapItems[id]); findItem != nil {
+		t.itemRead(findItem)
+		return findItem.obj, nil
+	}
+
+	return t.addLoaded(id, result, opts)
+
+}
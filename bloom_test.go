@@ -0,0 +1,89 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "hash/maphash"
+    "testing"
+)
+
+func bloomHash() func(id int) uint64 {
+
+    seed := maphash.MakeSeed()
+
+    return func(id int) uint64 {
+        var h maphash.Hash
+        h.SetSeed(seed)
+        var buffer [8]byte
+        for i := range buffer {
+            buffer[i] = byte(id >> (8 * i))
+        }
+        h.Write(buffer[:])
+        return h.Sum64()
+    }
+
+}
+
+func TestBloomFilter(t *testing.T) {
+
+    t.Log("validating TestBloomFilter")
+
+    heapedCache := NewHeapedCache(100, WithBloomFilter[int, AccountTest](100, 0.01, bloomHash()))
+
+    for i := range 200 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    // evicted ids are gone from the filter too
+    for i := range 100 {
+
+        require.Nil(t, heapedCache.Get(i))
+
+    }
+
+    for i := 100; i < 200; i++ {
+
+        require.Equal(t, i, heapedCache.Get(i).Id)
+
+    }
+
+    heapedCache.Remove(150)
+    require.Nil(t, heapedCache.Get(150))
+
+    falsePositives := 0
+
+    for i := 1000; i < 11000; i++ {
+
+        if heapedCache.bloom.mayContain(i) {
+            falsePositives++
+        }
+
+    }
+
+    t.Log("false positives: ", falsePositives, " of 10000")
+    require.Less(t, falsePositives, 500)
+
+}
+
+func TestBloomFilterGetOrAdd(t *testing.T) {
+
+    t.Log("validating TestBloomFilterGetOrAdd")
+
+    heapedCache := NewHeapedCache(10, WithBloomFilter[int, AccountTest](10, 0.01, bloomHash()))
+
+    calls := 0
+    load := func(id int) *AccountTest {
+        calls++
+        return NewAccountTest(id)
+    }
+
+    first := heapedCache.GetOrAdd(1, load)
+    second := heapedCache.GetOrAdd(1, load)
+
+    require.Same(t, first, second)
+    require.Equal(t, 1, calls)
+    require.Nil(t, heapedCache.GetOrAdd(2, func(id int) *AccountTest { return nil }))
+    require.Equal(t, 1, heapedCache.Len())
+
+}
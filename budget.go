@@ -0,0 +1,270 @@
+package utils
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// gives every item a cost (e.g. its size in bytes), computed when it is added, replaced or patched.
+// The total is reported by Cost and Stats, and charged to the Budget the cache joined.
+// Without it, every item costs 1
+func WithCost[TId comparable, TObj any](cost func(id TId, obj *TObj) int64) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		t.costFn = cost
+
+	}
+
+}
+
+// sets the cost of a cached item, overriding the one computed by WithCost until the item
+// is replaced or patched (both compute it again), and charges the difference to the total
+// and to the budget. returns false when the id is not cached
+func (t *HeapedCache[TId, TObj]) UpdateCost(id TId, cost int64) bool {
+
+	id = t.key(id)
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	item := t.mapItems[id]
+
+	if item == nil {
+		return false
+	}
+
+	t.charge(cost - item.cost)
+	item.cost = cost
+
+	return true
+
+}
+
+// returns the total cost of the cached items (see WithCost)
+func (t *HeapedCache[TId, TObj]) Cost() int64 {
+
+	return t.cost.Load()
+
+}
+
+// returns the cost of an item according to WithCost
+func (t *HeapedCache[TId, TObj]) costOf(item *HeapedCacheItem[TId, TObj]) int64 {
+
+	if t.costFn == nil {
+		return 1
+	}
+
+	var cost int64
+
+	contain(&t.panics, func() { cost = t.costFn(item.Id, item.obj) })
+
+	return cost
+
+}
+
+// adds delta to the total cost of the cache and to its budget
+func (t *HeapedCache[TId, TObj]) charge(delta int64) {
+
+	t.cost.Add(delta)
+
+	if t.budget != nil {
+		t.budget.charge(delta)
+	}
+
+}
+
+// cost limit shared by several caches, which may hold different types.
+// When the combined cost of its caches exceeds the limit, every cache is asked to evict
+// (through its eviction policy) a part of the excess proportional to its own cost,
+// so the process-wide limit holds without fixing the share of each cache up front.
+// The limit is enforced by a goroutine of the Budget right after a cache goes over it,
+// so the combined cost can exceed it briefly; Close stops the goroutine
+type Budget struct {
+	max      int64
+	used     atomic.Int64
+	mu       sync.Mutex
+	members  []budgetMember
+	wake     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// what the Budget needs from a cache (HeapedCache of any type implements it)
+type budgetMember interface {
+	budgetCost() int64
+	releaseCost(cost int64) int
+}
+
+// conctructor of the Budget
+func NewBudget(maxCost int64) *Budget {
+
+	b := &Budget{
+		max:  maxCost,
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+
+	go b.run()
+
+	return b
+
+}
+
+// makes the cache share the budget b with the other caches that joined it
+// the cache leaves the budget when it is closed (Close)
+func WithBudget[TId comparable, TObj any](b *Budget) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		if b != nil {
+			t.budget = b
+			b.join(t)
+		}
+
+	}
+
+}
+
+// returns the combined cost of the caches of the budget
+func (b *Budget) Used() int64 {
+
+	return b.used.Load()
+
+}
+
+// returns the limit of the budget
+func (b *Budget) Max() int64 {
+
+	return b.max
+
+}
+
+// stops the goroutine enforcing the budget
+func (b *Budget) Close() {
+
+	b.stopOnce.Do(func() { close(b.done) })
+
+}
+
+// brings the combined cost back under the limit right away, returning the number of evicted items.
+// Every cache evicts a share of the excess proportional to its cost
+func (b *Budget) Enforce() int {
+
+	over := b.used.Load() - b.max
+
+	if over <= 0 {
+		return 0
+	}
+
+	b.mu.Lock()
+	members := append([]budgetMember(nil), b.members...)
+	b.mu.Unlock()
+
+	costs := make([]int64, len(members))
+	total := int64(0)
+
+	for i, member := range members {
+		costs[i] = member.budgetCost()
+		total += costs[i]
+	}
+
+	if total <= 0 {
+		return 0
+	}
+
+	evicted := 0
+
+	for i, member := range members {
+
+		// rounded up, so the shares add up to at least the excess
+		if share := (over*costs[i] + total - 1) / total; share > 0 {
+			evicted += member.releaseCost(share)
+		}
+
+	}
+
+	return evicted
+
+}
+
+func (b *Budget) join(member budgetMember) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.members = append(b.members, member)
+
+}
+
+// removes a cache from the budget, along with its cost
+func (b *Budget) leave(member budgetMember) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, m := range b.members {
+
+		if m == member {
+			b.members = append(b.members[:i], b.members[i+1:]...)
+			b.used.Add(-member.budgetCost())
+			return
+		}
+
+	}
+
+}
+
+// adds delta to the combined cost, waking the goroutine up when the limit is exceeded
+func (b *Budget) charge(delta int64) {
+
+	if b.used.Add(delta) > b.max && delta > 0 {
+
+		select {
+		case b.wake <- struct{}{}:
+		default:
+		}
+
+	}
+
+}
+
+// enforces the budget every time a cache goes over it
+func (b *Budget) run() {
+
+	for {
+
+		select {
+		case <-b.done:
+			return
+		case <-b.wake:
+			b.Enforce()
+		}
+
+	}
+
+}
+
+func (t *HeapedCache[TId, TObj]) budgetCost() int64 {
+
+	return t.cost.Load()
+
+}
+
+// evicts items until their cost adds up to at least cost (or the cache is empty)
+// returns the number of evicted items
+func (t *HeapedCache[TId, TObj]) releaseCost(cost int64) int {
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	target := t.cost.Load() - cost
+	evicted := 0
+
+	for t.cost.Load() > target && t.evict() {
+		evicted++
+	}
+
+	return evicted
+
+}
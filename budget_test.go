@@ -0,0 +1,103 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "strconv"
+    "testing"
+    "time"
+)
+
+func TestBudget(t *testing.T) {
+
+    t.Log("validating TestBudget")
+
+    budget := NewBudget(100)
+    defer budget.Close()
+
+    accounts := NewHeapedCache(1000, WithBudget[int, AccountTest](budget))
+    defer accounts.Close()
+
+    names := NewHeapedCache(1000, WithBudget[string, string](budget), WithCost(func(id string, obj *string) int64 {
+        return int64(len(*obj))
+    }))
+    defer names.Close()
+
+    for i := range 60 {
+
+        accounts.Push(i, NewAccountTest(i))
+
+    }
+
+    require.Equal(t, int64(60), accounts.Cost())
+    require.Equal(t, int64(60), budget.Used())
+
+    for i := range 20 {
+
+        name := "name"
+        names.Push(strconv.Itoa(i), &name)
+
+    }
+
+    // 140 for a budget of 100: both caches give back a share of the excess
+    require.Eventually(t, func() bool { return budget.Used() <= budget.Max() }, time.Second, time.Millisecond)
+
+    require.Equal(t, accounts.Cost()+names.Cost(), budget.Used())
+    require.Less(t, accounts.Len(), 60)
+    require.Less(t, names.Len(), 20)
+    require.Equal(t, int64(4*names.Len()), names.Stats().Cost)
+
+    // the oldest items were evicted
+    require.Nil(t, accounts.Get(0))
+    require.NotNil(t, accounts.Get(59))
+
+    // updates are charged the difference
+    longer := "a much longer name"
+    names.Push("19", &longer)
+    require.Equal(t, int64(4*(names.Len()-1)+len(longer)), names.Cost())
+
+    names.Close()
+
+    require.Equal(t, accounts.Cost(), budget.Used())
+    require.Equal(t, 0, budget.Enforce())
+
+}
+
+func TestUpdateCost(t *testing.T) {
+
+    t.Log("validating TestUpdateCost")
+
+    heapedCache := NewHeapedCache(10, WithCost(func(id int, obj *[]int) int64 {
+        return int64(len(*obj))
+    }))
+
+    values := []int{1, 2}
+    heapedCache.Push(1, &values)
+    require.Equal(t, int64(2), heapedCache.Cost())
+
+    // appended in place: patching computes the cost again
+    heapedCache.Patch(1, func(obj *[]int) {
+        *obj = append(*obj, 3, 4)
+    })
+    require.Equal(t, int64(4), heapedCache.Cost())
+
+    require.True(t, heapedCache.UpdateCost(1, 100))
+    require.False(t, heapedCache.UpdateCost(2, 100))
+    require.Equal(t, int64(100), heapedCache.Cost())
+    require.NoError(t, heapedCache.CheckInvariants())
+
+    // drift of the total is a broken invariant, fixed by Repair
+    heapedCache.cost.Add(1)
+    require.ErrorContains(t, heapedCache.CheckInvariants(), "total cost 101, the items add up to 100")
+
+    fixed, _ := heapedCache.Repair()
+    require.Equal(t, 1, fixed)
+    require.Equal(t, int64(100), heapedCache.Cost())
+
+    // replacing the object computes the cost again
+    heapedCache.Push(1, &values)
+    require.Equal(t, int64(4), heapedCache.Cost())
+
+    heapedCache.Remove(1)
+    require.Equal(t, int64(0), heapedCache.Cost())
+
+}
@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"slices"
+	"time"
+)
+
+// number of items handled by the bulk operations before releasing the lock
+// and checking their context, so other goroutines get to use the cache meanwhile
+const bulkChunk = 1024
+
+// pushes the items into the cache (as Push does, refreshed now) in chunks of bulkChunk,
+// releasing the lock and checking ctx between chunks, so a cancelled warm-up stops early
+// instead of holding the lock until the end. Items pushed before the cancellation stay cached.
+// returns the number of items pushed (the ones refused by the overflow policy are skipped)
+// and the error of ctx, or ErrShutdown
+func (t *HeapedCache[TId, TObj]) Warm(ctx context.Context, items map[TId]*TObj) (int, error) {
+
+	pushed := 0
+	pending := 0
+	locked := false
+
+	unlock := func() {
+
+		if locked {
+			t.unlock()
+			locked = false
+		}
+
+	}
+
+	defer unlock()
+
+	for id, obj := range items {
+
+		if pending == bulkChunk {
+			unlock()
+			pending = 0
+		}
+
+		if !locked {
+
+			if err := ctx.Err(); err != nil {
+				return pushed, err
+			}
+
+			t.lock(OpPush)
+			t.operationContext(ctx)
+			locked = true
+
+		}
+
+		pending++
+
+		result, err := t.push(id, obj)
+
+		if err == ErrShutdown {
+			return pushed, err
+		}
+
+		if result != nil {
+			pushed++
+		}
+
+	}
+
+	return pushed, nil
+
+}
+
+// removes the items for which fn returns true, returning how many were removed.
+// fn is called outside the lock on a copy of each item (so it may use the cache),
+// and an item is only removed when its object is still the one fn saw.
+// Items are handled in chunks of bulkChunk (see ForEach), checking ctx before each of them;
+// on cancellation, the error of ctx is returned with the items removed so far
+func (t *HeapedCache[TId, TObj]) RemoveIf(ctx context.Context, fn func(id TId, obj *TObj) bool) (int, error) {
+
+	items := t.pointers()
+	removed := 0
+
+	for start := 0; start < len(items); start += bulkChunk {
+
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+
+		t.lock(OpScan)
+
+		chunk := t.live(items[start:min(start+bulkChunk, len(items))])
+		entries := make([]Entry[TId, TObj], len(chunk))
+
+		for i, item := range chunk {
+			entries[i] = Entry[TId, TObj]{Id: item.Id, Obj: item.obj}
+		}
+
+		t.unlock()
+
+		matches := slices.DeleteFunc(entries, func(entry Entry[TId, TObj]) bool {
+			return !fn(entry.Id, entry.Obj)
+		})
+
+		if len(matches) == 0 {
+			continue
+		}
+
+		t.lock(OpRemove)
+		t.operationContext(ctx)
+
+		for _, match := range matches {
+
+			findItem := t.mapItems[match.Id]
+
+			if findItem == nil || findItem.obj != match.Obj {
+				continue
+			}
+
+			t.removeItem(findItem)
+			t.record(OpRemove, match.Id, OutcomeRemoved)
+			t.itemRemoved(findItem)
+			removed++
+
+		}
+
+		t.unlock()
+
+	}
+
+	return removed, nil
+
+}
+
+// same as ExportNDJSON, stopping with the error of ctx when it is done
+// (the items are still copied at once, so the export reflects a single moment)
+func (t *HeapedCache[TId, TObj]) ExportNDJSONContext(ctx context.Context, w io.Writer, project func(id TId, obj *TObj, refreshed time.Time) any) error {
+
+	_, err := t.exportNDJSON(ctx, w, project)
+	return err
+
+}
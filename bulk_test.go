@@ -0,0 +1,74 @@
+package utils
+
+import (
+    "bytes"
+    "context"
+    "github.com/stretchr/testify/require"
+    "testing"
+)
+
+func TestWarm(t *testing.T) {
+
+    t.Log("validating TestWarm")
+
+    heapedCache := NewHeapedCache[int, AccountTest](5000)
+
+    items := make(map[int]*AccountTest)
+
+    for i := range 3000 {
+        items[i] = NewAccountTest(i)
+    }
+
+    pushed, err := heapedCache.Warm(context.Background(), items)
+    require.NoError(t, err)
+    require.Equal(t, 3000, pushed)
+    require.Equal(t, 3000, heapedCache.Len())
+    require.NoError(t, heapedCache.CheckInvariants())
+
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+
+    pushed, err = NewHeapedCache[int, AccountTest](5000).Warm(ctx, items)
+    require.ErrorIs(t, err, context.Canceled)
+    require.Equal(t, 0, pushed)
+
+}
+
+func TestRemoveIf(t *testing.T) {
+
+    t.Log("validating TestRemoveIf")
+
+    heapedCache := NewHeapedCache[int, AccountTest](5000)
+
+    for i := range 3000 {
+        heapedCache.Push(i, NewAccountTest(i))
+    }
+
+    removed, err := heapedCache.RemoveIf(context.Background(), func(id int, obj *AccountTest) bool {
+        return obj.Id%2 == 0
+    })
+    require.NoError(t, err)
+    require.Equal(t, 1500, removed)
+    require.Equal(t, 1500, heapedCache.Len())
+    require.Nil(t, heapedCache.Get(2))
+    require.NotNil(t, heapedCache.Get(3))
+    require.NoError(t, heapedCache.CheckInvariants())
+
+    ctx, cancel := context.WithCancel(context.Background())
+
+    removed, err = heapedCache.RemoveIf(ctx, func(id int, obj *AccountTest) bool {
+        cancel()
+        return true
+    })
+    require.ErrorIs(t, err, context.Canceled)
+    require.Equal(t, bulkChunk, removed)
+    require.Equal(t, 1500-bulkChunk, heapedCache.Len())
+
+    ctx, cancel = context.WithCancel(context.Background())
+    cancel()
+
+    var buffer bytes.Buffer
+    require.ErrorIs(t, heapedCache.ExportNDJSONContext(ctx, &buffer, nil), context.Canceled)
+    require.Zero(t, buffer.Len())
+
+}
@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+)
+
+// set of operations shared by HeapedCache and any other implementation
+// (views, wrappers) meant to be used in its place
+type Cache[TId comparable, TObj any] interface {
+	Get(id any) *TObj
+	GetOrAdd(id TId, fn func(id TId) *TObj) *TObj
+	Push(id TId, item *TObj) *TObj
+	Remove(id TId) bool
+	Len() int
+}
+
+var _ Cache[int, struct{}] = (*HeapedCache[int, struct{}])(nil)
+
+// checks the internal consistency of the cache: map and slice with the same length,
+// every slice item indexed in the map with its own position and the heap order respected.
+// returns nil when everything is consistent
+func (t *HeapedCache[TId, TObj]) CheckInvariants() error {
+
+	t.lock(opOther)
+	defer t.uncheckedUnlock()
+
+	return t.checkInvariants()
+
+}
+
+func (t *HeapedCache[TId, TObj]) checkInvariants() error {
+
+	return errors.Join(t.discrepancies()...)
+
+}
+
+// returns every inconsistency found in the internal structures
+func (t *HeapedCache[TId, TObj]) discrepancies() []error {
+
+	var errs []error
+
+	if t.maxRows > 0 && len(t.sliceItems) > t.maxLen() {
+		errs = append(errs, fmt.Errorf("%d items exceed the capacity %d", len(t.sliceItems), t.maxLen()))
+	}
+
+	if err := t.costDrift(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := t.expiryDrift(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return append(errs, t.structuralDiscrepancies(0, len(t.sliceItems))...)
+
+}
+
+// returns an error when the total cost of the cache is not the sum of the costs of its items
+func (t *HeapedCache[TId, TObj]) costDrift() error {
+
+	sum := int64(0)
+
+	for _, item := range t.sliceItems {
+
+		if item != nil {
+			sum += item.cost
+		}
+
+	}
+
+	if total := t.cost.Load(); total != sum {
+		return fmt.Errorf("total cost %d, the items add up to %d", total, sum)
+	}
+
+	return nil
+
+}
+
+// returns an error when an item that expires is not where the expiry wheel has it
+func (t *HeapedCache[TId, TObj]) expiryDrift() error {
+
+	if t.expiries == nil {
+		return nil
+	}
+
+	count := 0
+
+	for _, item := range t.sliceItems {
+
+		if item == nil {
+			continue
+		}
+
+		if item.expirySlot == 0 {
+
+			if !t.expiresAt(item).IsZero() {
+				return fmt.Errorf("item %v expires but is not in the expiry wheel", item.Id)
+			}
+
+			continue
+
+		}
+
+		count++
+
+		if slot := t.expiries.slots[item.expirySlot-1]; item.expiryIndex >= len(slot) || slot[item.expiryIndex] != item {
+			return fmt.Errorf("item %v is not in slot %d of the expiry wheel", item.Id, item.expirySlot-1)
+		}
+
+	}
+
+	if count != t.expiries.count {
+		return fmt.Errorf("%d items in the expiry wheel, %d cached items are in it", t.expiries.count, count)
+	}
+
+	return nil
+
+}
+
+// returns the inconsistencies of the map and heap, leaving out the capacity, which
+// can be exceeded for a while on purpose (ApplyConfig shrinking maxRows, WithAsyncTrim).
+// Only the heap positions from..to-1 are checked
+func (t *HeapedCache[TId, TObj]) structuralDiscrepancies(from int, to int) []error {
+
+	var errs []error
+
+	if len(t.mapItems) != len(t.sliceItems) {
+		errs = append(errs, fmt.Errorf("map has %d items, slice has %d", len(t.mapItems), len(t.sliceItems)))
+	}
+
+	for i := from; i < to; i++ {
+
+		item := t.sliceItems[i]
+
+		if item == nil {
+			errs = append(errs, fmt.Errorf("nil item at position %d", i))
+			continue
+		}
+
+		if item.index != i {
+			errs = append(errs, fmt.Errorf("item %v at position %d has index %d", item.Id, i, item.index))
+		}
+
+		if t.mapItems[item.Id] != item {
+			errs = append(errs, fmt.Errorf("item %v at position %d is not the one in the map", item.Id, i))
+		}
+
+		// deferred fixes (WithDeferredFix) leave the heap order broken on purpose until settled
+		if parent := heapParent(i); i > 0 && !t.fixPending() && t.sliceItems[parent] != nil && t.sliceItems.Less(i, parent) {
+			errs = append(errs, fmt.Errorf("item %v at position %d is older than its parent", item.Id, i))
+		}
+
+	}
+
+	return errs
+
+}
@@ -0,0 +1,48 @@
+// test helpers for HeapedCache and for any other implementation of the Cache interface
+package cachetest
+
+import (
+	"testing"
+	"time"
+
+	utils "opensource/heapedcache"
+
+	"github.com/stretchr/testify/require"
+)
+
+// how long RequireEventuallyEvicted waits for the item to go away
+var EvictionTimeout = time.Second
+
+// implemented by caches able to verify their internal consistency
+type InvariantChecker interface {
+	CheckInvariants() error
+}
+
+// fails the test when the internal structures of the cache are inconsistent
+func RequireHeapValid(t testing.TB, cache InvariantChecker) {
+
+	t.Helper()
+
+	require.NoError(t, cache.CheckInvariants())
+
+}
+
+// fails the test when the item of the given id is still cached after EvictionTimeout
+func RequireEventuallyEvicted[TId comparable, TObj any](t testing.TB, cache utils.Cache[TId, TObj], id TId) {
+
+	t.Helper()
+
+	require.Eventually(t, func() bool { return cache.Get(id) == nil }, EvictionTimeout, EvictionTimeout/100, "item %v was not evicted", id)
+
+}
+
+// pushes the ids from 0 to n-1 in order, creating each object with factory
+func FillSequential[TObj any](cache utils.Cache[int, TObj], n int, factory func(id int) *TObj) {
+
+	for i := range n {
+
+		cache.Push(i, factory(i))
+
+	}
+
+}
@@ -0,0 +1,111 @@
+package cachetest_test
+
+import (
+    "github.com/stretchr/testify/require"
+    "math/rand"
+    utils "opensource/heapedcache"
+    "opensource/heapedcache/cachetest"
+    "testing"
+    "testing/quick"
+)
+
+type account struct {
+    Id int
+}
+
+func newAccount(id int) *account {
+
+    return &account{Id: id}
+
+}
+
+func TestHeapedCacheConformance(t *testing.T) {
+
+    cachetest.RunConformance(t, func(maxRows int) utils.Cache[int, account] {
+        return utils.NewHeapedCache[int, account](maxRows)
+    }, newAccount)
+
+}
+
+func TestHelpers(t *testing.T) {
+
+    heapedCache := utils.NewHeapedCache[int, account](5)
+
+    cachetest.FillSequential[account](heapedCache, 10, newAccount)
+    cachetest.RequireHeapValid(t, heapedCache)
+    cachetest.RequireEventuallyEvicted[int, account](t, heapedCache, 0)
+
+}
+
+func TestPropertyOps(t *testing.T) {
+
+    property := func(ops cachetest.Ops) bool {
+
+        heapedCache := utils.NewHeapedCache[int, account](8)
+
+        if err := cachetest.ApplyOps[account](heapedCache, 8, ops, newAccount); err != nil {
+            t.Log(err)
+            return false
+        }
+
+        if err := cachetest.CheckPopOrder[account](heapedCache); err != nil {
+            t.Log(err)
+            return false
+        }
+
+        return true
+
+    }
+
+    if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+        t.Fatal(err)
+    }
+
+}
+
+func TestGenerateOps(t *testing.T) {
+
+    ops := cachetest.GenerateOps(rand.New(rand.NewSource(1)), 100, 4)
+
+    require.Equal(t, 100, len(ops))
+
+    for _, op := range ops {
+
+        require.True(t, op.Id >= 0 && op.Id < 4)
+
+    }
+
+    require.Equal(t, ops, cachetest.GenerateOps(rand.New(rand.NewSource(1)), 100, 4))
+
+}
+
+func TestLinearizability(t *testing.T) {
+
+    heapedCache := utils.NewHeapedCache[int, int](16)
+
+    history := cachetest.RecordHistory(heapedCache, 8, 2000, 16, 1)
+
+    require.Equal(t, 16000, len(history))
+    require.NoError(t, cachetest.CheckLinearizable(history))
+
+}
+
+func TestLinearizabilityViolation(t *testing.T) {
+
+    // a read returning a value that was never written, after the only write finished
+    history := []cachetest.HistoryOp{
+        {Kind: cachetest.OpPush, Id: 1, Input: 10, Call: 1, Return: 2},
+        {Kind: cachetest.OpGet, Id: 1, Output: 20, Call: 3, Return: 4},
+    }
+
+    require.Error(t, cachetest.CheckLinearizable(history))
+
+    // a read overlapping a write may see either value
+    history = []cachetest.HistoryOp{
+        {Kind: cachetest.OpPush, Id: 1, Input: 10, Call: 1, Return: 4},
+        {Kind: cachetest.OpGet, Id: 1, Output: cachetest.Absent, Call: 2, Return: 3},
+    }
+
+    require.NoError(t, cachetest.CheckLinearizable(history))
+
+}
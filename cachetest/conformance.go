@@ -0,0 +1,138 @@
+package cachetest
+
+import (
+	"testing"
+
+	utils "opensource/heapedcache"
+
+	"github.com/stretchr/testify/require"
+)
+
+// runs the behaviour every Cache implementation must have.
+// newCache must return an empty cache holding at most maxRows items,
+// factory creates the object stored for each id.
+// When the cache implements InvariantChecker, its consistency is checked after every step
+func RunConformance[TObj any](t *testing.T, newCache func(maxRows int) utils.Cache[int, TObj], factory func(id int) *TObj) {
+
+	check := func(t *testing.T, cache utils.Cache[int, TObj]) {
+
+		t.Helper()
+
+		if checker, ok := cache.(InvariantChecker); ok {
+			RequireHeapValid(t, checker)
+		}
+
+	}
+
+	t.Run("PushAndGet", func(t *testing.T) {
+
+		cache := newCache(10)
+		FillSequential(cache, 5, factory)
+		check(t, cache)
+
+		require.Equal(t, 5, cache.Len())
+
+		for i := range 5 {
+			require.NotNil(t, cache.Get(i))
+		}
+
+		require.Nil(t, cache.Get(5))
+
+	})
+
+	t.Run("PushSameId", func(t *testing.T) {
+
+		cache := newCache(10)
+
+		for range 3 {
+			cache.Push(1, factory(1))
+			check(t, cache)
+		}
+
+		require.Equal(t, 1, cache.Len())
+
+	})
+
+	t.Run("OverFlowEvictsOldest", func(t *testing.T) {
+
+		cache := newCache(10)
+
+		for i := range 25 {
+			cache.Push(i, factory(i))
+			check(t, cache)
+		}
+
+		require.Equal(t, 10, cache.Len())
+
+		for i := range 15 {
+			require.Nil(t, cache.Get(i))
+		}
+
+		for i := 15; i < 25; i++ {
+			require.NotNil(t, cache.Get(i))
+		}
+
+	})
+
+	t.Run("RemoveAnyPosition", func(t *testing.T) {
+
+		for position := range 10 {
+
+			cache := newCache(10)
+			FillSequential(cache, 10, factory)
+
+			require.True(t, cache.Remove(position))
+			check(t, cache)
+
+			require.False(t, cache.Remove(position))
+			require.Nil(t, cache.Get(position))
+			require.Equal(t, 9, cache.Len())
+
+		}
+
+	})
+
+	t.Run("RemoveAll", func(t *testing.T) {
+
+		cache := newCache(10)
+		FillSequential(cache, 10, factory)
+
+		for i := range 10 {
+			require.True(t, cache.Remove(9-i))
+			check(t, cache)
+		}
+
+		require.Equal(t, 0, cache.Len())
+
+	})
+
+	t.Run("GetOrAddLoadsOnce", func(t *testing.T) {
+
+		cache := newCache(10)
+		calls := 0
+
+		load := func(id int) *TObj {
+			calls++
+			return factory(id)
+		}
+
+		first := cache.GetOrAdd(1, load)
+		second := cache.GetOrAdd(1, load)
+		check(t, cache)
+
+		require.NotNil(t, first)
+		require.Same(t, first, second)
+		require.Equal(t, 1, calls)
+
+	})
+
+	t.Run("GetOrAddNil", func(t *testing.T) {
+
+		cache := newCache(10)
+
+		require.Nil(t, cache.GetOrAdd(1, func(id int) *TObj { return nil }))
+		require.Equal(t, 0, cache.Len())
+
+	})
+
+}
@@ -0,0 +1,232 @@
+package cachetest
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	utils "opensource/heapedcache"
+)
+
+// value of an absent item in a recorded history
+const Absent = -1
+
+// operation recorded by RecordHistory.
+// Input is the pushed or loaded value, Output is the value read (Absent when nil),
+// or 1/0 for the result of Remove.
+// Call and Return are logical timestamps taken right before and right after the operation
+type HistoryOp struct {
+	Kind   OpKind
+	Id     int
+	Input  int
+	Output int
+	Call   int64
+	Return int64
+}
+
+// runs random Push/Get/GetOrAdd/Remove operations from several goroutines at once
+// and returns what each one observed. The cache must be big enough to hold keySpace
+// items, so nothing is evicted and every key behaves as a plain register
+func RecordHistory(cache utils.Cache[int, int], clients int, opsPerClient int, keySpace int, seed int64) []HistoryOp {
+
+	var clock atomic.Int64
+	var nextValue atomic.Int64
+
+	histories := make([][]HistoryOp, clients)
+
+	var wg sync.WaitGroup
+
+	wg.Add(clients)
+
+	for c := range clients {
+
+		go func() {
+
+			defer wg.Done()
+
+			r := rand.New(rand.NewSource(seed + int64(c)))
+			history := make([]HistoryOp, 0, opsPerClient)
+
+			for range opsPerClient {
+
+				op := HistoryOp{Kind: OpKind(r.Intn(4)), Id: r.Intn(keySpace), Input: int(nextValue.Add(1))}
+				op.Call = clock.Add(1)
+
+				switch op.Kind {
+
+				case OpPush:
+					value := op.Input
+					cache.Push(op.Id, &value)
+
+				case OpGet:
+					op.Output = valueOf(cache.Get(op.Id))
+
+				case OpGetOrAdd:
+					op.Output = valueOf(cache.GetOrAdd(op.Id, func(id int) *int {
+						value := op.Input
+						return &value
+					}))
+
+				case OpRemove:
+					if cache.Remove(op.Id) {
+						op.Output = 1
+					}
+
+				}
+
+				op.Return = clock.Add(1)
+				history = append(history, op)
+
+			}
+
+			histories[c] = history
+
+		}()
+
+	}
+
+	wg.Wait()
+
+	var result []HistoryOp
+
+	for _, history := range histories {
+		result = append(result, history...)
+	}
+
+	return result
+
+}
+
+func valueOf(value *int) int {
+
+	if value == nil {
+		return Absent
+	}
+
+	return *value
+
+}
+
+// checks whether the history could have been produced by a sequential cache
+// (one operation at a time, each taking effect between its call and its return).
+// Keys are independent, so each key is checked on its own.
+// returns nil when the history is linearizable
+func CheckLinearizable(history []HistoryOp) error {
+
+	byId := make(map[int][]HistoryOp)
+
+	for _, op := range history {
+		byId[op.Id] = append(byId[op.Id], op)
+	}
+
+	for id, ops := range byId {
+
+		if !linearizable(ops) {
+			return fmt.Errorf("history of id %d is not linearizable", id)
+		}
+
+	}
+
+	return nil
+
+}
+
+// applies an operation to the sequential model of a single key
+// returns the new state and whether the observed output matches the model
+func step(state int, op HistoryOp) (int, bool) {
+
+	switch op.Kind {
+
+	case OpPush:
+		return op.Input, true
+
+	case OpGet:
+		return state, op.Output == state
+
+	case OpGetOrAdd:
+		if state == Absent {
+			return op.Input, op.Output == op.Input
+		}
+		return state, op.Output == state
+
+	case OpRemove:
+		return Absent, (op.Output == 1) == (state != Absent)
+
+	}
+
+	return state, false
+
+}
+
+// depth first search for a valid order (Wing & Gong), memoizing the
+// (linearized set, state) pairs already known to lead nowhere
+func linearizable(ops []HistoryOp) bool {
+
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Call < ops[j].Call })
+
+	done := make([]uint64, (len(ops)+63)/64)
+	failed := make(map[string]struct{})
+
+	var search func(count int, state int) bool
+
+	search = func(count int, state int) bool {
+
+		if count == len(ops) {
+			return true
+		}
+
+		key := fmt.Sprint(done, state)
+
+		if _, ok := failed[key]; ok {
+			return false
+		}
+
+		// an operation can take effect next only if it was called
+		// before every pending operation returned
+		minReturn := int64(-1)
+
+		for i, op := range ops {
+
+			if done[i/64]&(1<<(i%64)) == 0 && (minReturn < 0 || op.Return < minReturn) {
+				minReturn = op.Return
+			}
+
+		}
+
+		for i, op := range ops {
+
+			if op.Call > minReturn {
+				break
+			}
+
+			if done[i/64]&(1<<(i%64)) != 0 {
+				continue
+			}
+
+			newState, ok := step(state, op)
+
+			if ok {
+
+				done[i/64] |= 1 << (i % 64)
+
+				if search(count+1, newState) {
+					return true
+				}
+
+				done[i/64] &^= 1 << (i % 64)
+
+			}
+
+		}
+
+		failed[key] = struct{}{}
+
+		return false
+
+	}
+
+	return search(0, Absent)
+
+}
@@ -0,0 +1,136 @@
+package cachetest
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"time"
+
+	utils "opensource/heapedcache"
+)
+
+// kind of operation in a generated sequence
+type OpKind int
+
+const (
+	OpPush OpKind = iota
+	OpGet
+	OpGetOrAdd
+	OpRemove
+)
+
+// number of distinct ids used by Ops.Generate
+var DefaultKeySpace = 32
+
+// operation applied to a cache by ApplyOps
+type Op struct {
+	Kind OpKind
+	Id   int
+}
+
+// sequence of operations, usable as an argument of testing/quick properties
+type Ops []Op
+
+// implements quick.Generator
+func (Ops) Generate(r *rand.Rand, size int) reflect.Value {
+
+	return reflect.ValueOf(GenerateOps(r, size, DefaultKeySpace))
+
+}
+
+// returns a random sequence of n valid operations over the ids from 0 to keySpace-1
+func GenerateOps(r *rand.Rand, n int, keySpace int) Ops {
+
+	ops := make(Ops, n)
+
+	for i := range ops {
+		ops[i] = Op{Kind: OpKind(r.Intn(4)), Id: r.Intn(keySpace)}
+	}
+
+	return ops
+
+}
+
+// applies the operations to the cache, returning an error as soon as an invariant breaks:
+// Len never exceeds maxRows, a pushed or loaded id is readable right away,
+// a removed id is not, and the cache stays consistent when it implements InvariantChecker
+func ApplyOps[TObj any](cache utils.Cache[int, TObj], maxRows int, ops Ops, factory func(id int) *TObj) error {
+
+	for i, op := range ops {
+
+		switch op.Kind {
+
+		case OpPush:
+			cache.Push(op.Id, factory(op.Id))
+
+			if cache.Get(op.Id) == nil {
+				return fmt.Errorf("op %d: id %d not found right after push", i, op.Id)
+			}
+
+		case OpGet:
+			cache.Get(op.Id)
+
+		case OpGetOrAdd:
+			if cache.GetOrAdd(op.Id, factory) == nil {
+				return fmt.Errorf("op %d: GetOrAdd of id %d returned nil", i, op.Id)
+			}
+
+		case OpRemove:
+			cache.Remove(op.Id)
+
+			if cache.Get(op.Id) != nil {
+				return fmt.Errorf("op %d: id %d found right after remove", i, op.Id)
+			}
+
+		}
+
+		if cache.Len() > maxRows {
+			return fmt.Errorf("op %d: len %d exceeds maxRows %d", i, cache.Len(), maxRows)
+		}
+
+		if checker, ok := cache.(InvariantChecker); ok {
+
+			if err := checker.CheckInvariants(); err != nil {
+				return fmt.Errorf("op %d: %w", i, err)
+			}
+
+		}
+
+	}
+
+	return nil
+
+}
+
+// implemented by caches that pop their items in refreshed order
+type Popper[TObj any] interface {
+	PopWithRefreshed() (*TObj, time.Time)
+	Len() int
+}
+
+// empties the cache, returning an error when an item comes out
+// older than the one popped before it, or nil
+func CheckPopOrder[TObj any](cache Popper[TObj]) error {
+
+	var previous time.Time
+
+	for cache.Len() > 0 {
+
+		obj, refreshed := cache.PopWithRefreshed()
+
+		if obj == nil {
+			return errors.New("nil object popped")
+		}
+
+		if refreshed.Before(previous) {
+			return fmt.Errorf("item refreshed at %v popped after one refreshed at %v", refreshed, previous)
+		}
+
+		previous = refreshed
+
+	}
+
+	return nil
+
+}
@@ -0,0 +1,119 @@
+package utils
+
+// implemented by caches able to report their evictions (HeapedCache does)
+type evictionNotifier[TId comparable, TObj any] interface {
+	OnEvict(fn func(id TId, obj *TObj))
+}
+
+// two level cache: a small, fast L1 in front of a bigger, slower L2.
+// Reads check L1 first, then L2, promoting L2 hits into L1; writes go to both levels,
+// and items evicted from L1 are demoted to L2 (when L1 reports its evictions).
+// L2 holds every item that L1 holds unless it evicted it on its own
+type Chained[TId comparable, TObj any] struct {
+	l1 Cache[TId, TObj]
+	l2 Cache[TId, TObj]
+}
+
+var _ Cache[int, struct{}] = (*Chained[int, struct{}])(nil)
+
+// chains l1 in front of l2
+// l1 must not be shared with another chain, as its evictions are demoted to l2
+func Chain[TId comparable, TObj any](l1 Cache[TId, TObj], l2 Cache[TId, TObj]) *Chained[TId, TObj] {
+
+	if notifier, ok := l1.(evictionNotifier[TId, TObj]); ok {
+		notifier.OnEvict(func(id TId, obj *TObj) { l2.Push(id, obj) })
+	}
+
+	return &Chained[TId, TObj]{l1: l1, l2: l2}
+
+}
+
+// returns the item from L1, or from L2 (promoting it to L1)
+// returns nil if neither level has it
+func (c *Chained[TId, TObj]) Get(id any) *TObj {
+
+	if obj := c.l1.Get(id); obj != nil {
+		return obj
+	}
+
+	obj := c.l2.Get(id)
+
+	if obj != nil {
+
+		if typedId, ok := id.(TId); ok {
+			c.l1.Push(typedId, obj)
+		}
+
+	}
+
+	return obj
+
+}
+
+// returns the item from either level; when both miss, fn is executed
+// and its result is placed on both levels
+func (c *Chained[TId, TObj]) GetOrAdd(id TId, fn func(id TId) *TObj) *TObj {
+
+	return c.l1.GetOrAdd(id, func(id TId) *TObj { return c.l2.GetOrAdd(id, fn) })
+
+}
+
+// places the item on both levels
+func (c *Chained[TId, TObj]) Push(id TId, item *TObj) *TObj {
+
+	c.l2.Push(id, item)
+	return c.l1.Push(id, item)
+
+}
+
+// removes the item from both levels
+func (c *Chained[TId, TObj]) Remove(id TId) bool {
+
+	removed := c.l1.Remove(id)
+	return c.l2.Remove(id) || removed
+
+}
+
+// returns the number of items in L2, which holds (nearly) everything L1 holds
+func (c *Chained[TId, TObj]) Len() int {
+
+	return c.l2.Len()
+
+}
+
+// moves an item into L1 ahead of its reads, e.g. the data of a user who just logged in
+// returns false when neither level has it
+func (c *Chained[TId, TObj]) Promote(id TId) bool {
+
+	if c.l1.Get(id) != nil {
+		return true
+	}
+
+	obj := c.l2.Get(id)
+
+	if obj == nil {
+		return false
+	}
+
+	c.l1.Push(id, obj)
+
+	return true
+
+}
+
+// moves an item out of L1, keeping it in L2, e.g. the data of a user who logged out
+// returns false when L1 does not have it
+func (c *Chained[TId, TObj]) Demote(id TId) bool {
+
+	obj := c.l1.Get(id)
+
+	if obj == nil {
+		return false
+	}
+
+	c.l2.Push(id, obj)
+	c.l1.Remove(id)
+
+	return true
+
+}
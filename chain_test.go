@@ -0,0 +1,157 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "strconv"
+    "sync"
+    "testing"
+)
+
+func TestChain(t *testing.T) {
+
+    t.Log("validating TestChain")
+
+    l1 := NewHeapedCache[int, AccountTest](2)
+    l2 := NewHeapedCache[int, AccountTest](100)
+    chain := Chain[int, AccountTest](l1, l2)
+
+    // a loaded item lands on both levels
+    chain.GetOrAdd(1, NewAccountTest)
+    require.NotNil(t, l1.Get(1))
+    require.NotNil(t, l2.Get(1))
+
+    // items evicted from L1 are demoted to L2
+    l2.Remove(1)
+    chain.Push(2, NewAccountTest(2))
+    chain.Push(3, NewAccountTest(3))
+
+    require.Nil(t, l1.Get(1))
+    require.NotNil(t, l2.Get(1))
+
+    // an L2 hit is promoted to L1
+    require.Equal(t, 1, chain.Get(1).Id)
+    require.NotNil(t, l1.Get(1))
+    require.Equal(t, 2, l1.Len())
+
+    loads := 0
+    chain.GetOrAdd(2, func(id int) *AccountTest { loads++; return NewAccountTest(id) })
+    require.Equal(t, 0, loads)
+
+    require.True(t, chain.Remove(1))
+    require.Nil(t, chain.Get(1))
+    require.Equal(t, 2, chain.Len())
+
+}
+
+func TestPromoteDemote(t *testing.T) {
+
+    t.Log("validating TestPromoteDemote")
+
+    l1 := NewHeapedCache[int, AccountTest](2)
+    l2 := NewHeapedCache[int, AccountTest](100)
+    chain := Chain[int, AccountTest](l1, l2)
+
+    l2.Push(1, NewAccountTest(1))
+
+    require.True(t, chain.Promote(1))
+    require.NotNil(t, l1.Get(1))
+    require.True(t, chain.Promote(1))
+    require.False(t, chain.Promote(2))
+
+    require.True(t, chain.Demote(1))
+    require.Nil(t, l1.Get(1))
+    require.NotNil(t, l2.Get(1))
+    require.False(t, chain.Demote(1))
+
+    // an item only in L1 is kept in L2 when demoted
+    l1.Push(3, NewAccountTest(3))
+
+    require.True(t, chain.Demote(3))
+    require.Equal(t, 3, l2.Get(3).Id)
+    require.Equal(t, 0, l1.Len())
+
+}
+
+func TestOnEvict(t *testing.T) {
+
+    t.Log("validating TestOnEvict")
+
+    heapedCache := NewHeapedCache[int, AccountTest](5)
+
+    var evicted []int
+    heapedCache.OnEvict(func(id int, obj *AccountTest) { evicted = append(evicted, id) })
+
+    for i := range 8 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    // removed and popped items are not reported
+    heapedCache.Remove(7)
+    heapedCache.Pop()
+
+    require.Equal(t, []int{0, 1, 2}, evicted)
+
+}
+
+func TestClearStreamsOnEvict(t *testing.T) {
+
+    t.Log("validating TestClearStreamsOnEvict")
+
+    heapedCache := NewHeapedCache[string, AccountTest](100)
+    users := Namespace(heapedCache, "user:")
+
+    var mu sync.Mutex
+    wiped := make(map[string]bool)
+
+    heapedCache.OnEvict(func(id string, obj *AccountTest) {
+
+        // called outside the lock, so the cache can be used meanwhile
+        heapedCache.Len()
+
+        mu.Lock()
+        wiped[id] = true
+        mu.Unlock()
+
+    })
+
+    for i := range 50 {
+
+        users.Push(strconv.Itoa(i), NewAccountTest(i))
+        heapedCache.Push("other:"+strconv.Itoa(i), NewAccountTest(i))
+
+    }
+
+    require.Equal(t, 50, users.Drop())
+    require.Len(t, wiped, 50)
+    require.True(t, wiped["user:0"])
+
+    require.Equal(t, 50, heapedCache.Clear())
+    require.Len(t, wiped, 100)
+    require.True(t, wiped["other:49"])
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
+
+func TestOnEvictCallsBack(t *testing.T) {
+
+    t.Log("validating TestOnEvictCallsBack")
+
+    heapedCache := NewHeapedCache(2, WithReentrancyDetection[int, AccountTest]())
+    evicted := NewHeapedCache[int, AccountTest](10)
+
+    heapedCache.OnEvict(func(id int, obj *AccountTest) {
+        // runs outside the lock, so the cache may be used
+        require.Equal(t, 2, heapedCache.Len())
+        evicted.Push(id, obj)
+    })
+
+    for i := range 5 {
+        heapedCache.Push(i, NewAccountTest(i))
+    }
+
+    require.Equal(t, 3, evicted.Len())
+    require.Equal(t, uint64(0), heapedCache.Stats().Panics)
+
+}
@@ -0,0 +1,198 @@
+package utils
+
+import (
+	"cmp"
+	"math/rand"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// uses now instead of time.Now to stamp the Refreshed time of the items
+// and the recorded operations
+func WithClock[TId comparable, TObj any](now func() time.Time) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		if now != nil {
+			t.now = now
+		}
+
+	}
+
+}
+
+// virtual clock that only moves when told to.
+// The background work of the caches of NewDeterministicHeapedCache (and of NewDeterministicDebouncer)
+// is driven by it: it runs when Advance reaches its time, on the goroutine calling Advance.
+// It is safe for concurrent use
+type FakeClock struct {
+	mu      sync.Mutex
+	current time.Time
+	timers  []*fakeTimer
+}
+
+// function called by a FakeClock once the virtual time reaches next
+type fakeTimer struct {
+	clock    *FakeClock
+	next     time.Time
+	interval time.Duration // 0 for a single call
+	fn       func()
+	stopped  atomic.Bool
+}
+
+// constructor of the FakeClock, starting at the given time
+func NewFakeClock(start time.Time) *FakeClock {
+
+	return &FakeClock{current: start}
+
+}
+
+// returns the current virtual time
+func (c *FakeClock) Now() time.Time {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.current
+
+}
+
+// moves the virtual time forward, then runs the background work that became due (background tasks
+// and reporters of the deterministic caches, debounced actions), in the order it became due.
+// As with a time.Ticker, a periodic task runs once however many of its intervals went by
+func (c *FakeClock) Advance(d time.Duration) {
+
+	c.mu.Lock()
+	c.current = c.current.Add(d)
+	due := c.due()
+	c.mu.Unlock()
+
+	for _, timer := range due {
+
+		if !timer.stopped.Load() {
+			timer.fn()
+		}
+
+	}
+
+}
+
+// returns the timers due at the current time in due order, rescheduling the periodic ones
+// and dropping the others
+// must be called under c.mu
+func (c *FakeClock) due() []*fakeTimer {
+
+	var due []*fakeTimer
+
+	kept := c.timers[:0]
+
+	for _, timer := range c.timers {
+
+		if timer.stopped.Load() {
+			continue
+		}
+
+		if !timer.next.After(c.current) {
+			due = append(due, timer)
+		}
+
+		if timer.next.After(c.current) || timer.interval > 0 {
+			kept = append(kept, timer)
+		}
+
+	}
+
+	clear(c.timers[len(kept):])
+	c.timers = kept
+
+	slices.SortStableFunc(due, func(a, b *fakeTimer) int { return a.next.Compare(b.next) })
+
+	for _, timer := range due {
+		timer.next = c.current.Add(timer.interval)
+	}
+
+	return due
+
+}
+
+// calls fn every interval of virtual time, from Advance, until stop
+func (c *FakeClock) every(interval time.Duration, fn func()) *fakeTimer {
+
+	return c.schedule(interval, interval, fn)
+
+}
+
+// calls fn once, from the Advance reaching delay from now, unless stopped before
+func (c *FakeClock) after(delay time.Duration, fn func()) *fakeTimer {
+
+	return c.schedule(delay, 0, fn)
+
+}
+
+// adds a timer calling fn delay from now, and then every interval when it is not 0
+func (c *FakeClock) schedule(delay time.Duration, interval time.Duration, fn func()) *fakeTimer {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	timer := &fakeTimer{clock: c, next: c.current.Add(delay), interval: interval, fn: fn}
+	c.timers = append(c.timers, timer)
+
+	return timer
+
+}
+
+// changes the interval of a periodic timer, the next call being an interval from now
+func (timer *fakeTimer) reset(interval time.Duration) {
+
+	timer.clock.mu.Lock()
+	defer timer.clock.mu.Unlock()
+
+	timer.interval = interval
+	timer.next = timer.clock.current.Add(interval)
+
+}
+
+// cancels the calls not made yet
+func (timer *fakeTimer) stop() {
+
+	timer.stopped.Store(true)
+
+}
+
+// conctructor of a HeapedCache driven by a virtual clock, so the Refreshed times, and therefore
+// the eviction order, are the same on every run of the same sequence of operations.
+// Its background tasks (WithAsyncTrim, WithUnreadReaper, WithDeferredFix, WithConsistencyAudit,
+// WithLockWatchdog) and StartReporter run from clock.Advance instead of goroutines with tickers
+// (the watchdog still measures how long the lock is held in real time).
+// The items the cache takes from maps, which Go iterates in a random order (expired leases,
+// dependents of PushWithDeps), are processed in an order drawn from seed: the same on every run
+// with the same seed, and a different one for another seed
+func NewDeterministicHeapedCache[TId comparable, TObj any](maxRows int, seed int64, clock *FakeClock, options ...Option[TId, TObj]) *HeapedCache[TId, TObj] {
+
+	deterministic := func(t *HeapedCache[TId, TObj]) {
+
+		t.now = clock.Now
+		t.fakeClock = clock
+		t.random = rand.New(rand.NewSource(seed))
+
+	}
+
+	return NewHeapedCache(maxRows, append([]Option[TId, TObj]{deterministic}, options...)...)
+
+}
+
+// orders keys taken from a map the way the cache processes them: as Go iterated them,
+// or for a deterministic cache, sorted by rank and then shuffled by the seeded source of the cache
+func seededOrder[K any](random *rand.Rand, keys []K, rank func(key K) uint64) {
+
+	if random == nil {
+		return
+	}
+
+	slices.SortFunc(keys, func(a, b K) int { return cmp.Compare(rank(a), rank(b)) })
+	random.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+
+}
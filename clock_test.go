@@ -0,0 +1,221 @@
+package utils
+
+import (
+    "fmt"
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestFakeClock(t *testing.T) {
+
+    t.Log("validating TestFakeClock")
+
+    start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+    clock := NewFakeClock(start)
+
+    require.Equal(t, start, clock.Now())
+
+    clock.Advance(time.Minute)
+
+    require.Equal(t, start.Add(time.Minute), clock.Now())
+
+}
+
+func TestDeterministicHeapedCache(t *testing.T) {
+
+    t.Log("validating TestDeterministicHeapedCache")
+
+    run := func() []RecordedOp[int] {
+
+        clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+        heapedCache := NewDeterministicHeapedCache(5, 1, clock, WithRecorder[int, AccountTest](100))
+
+        for i := range 20 {
+
+            clock.Advance(time.Second)
+            heapedCache.Push(i%7, NewAccountTest(i%7))
+
+        }
+
+        return heapedCache.RecentOps()
+
+    }
+
+    first := run()
+
+    require.Contains(t, recordedOps(first), OpEvict)
+    require.Equal(t, first, run())
+
+}
+
+func TestWithClockRefreshed(t *testing.T) {
+
+    t.Log("validating TestWithClockRefreshed")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewHeapedCache(5, WithClock[int, AccountTest](clock.Now))
+
+    heapedCache.Push(1, NewAccountTest(1))
+
+    _, refreshed := heapedCache.PopWithRefreshed()
+
+    require.Equal(t, clock.Now(), refreshed)
+
+}
+
+func TestGetWithAge(t *testing.T) {
+
+    t.Log("validating TestGetWithAge")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache[int, AccountTest](10, 1, clock)
+
+    heapedCache.Push(1, NewAccountTest(1))
+    clock.Advance(90 * time.Second)
+
+    obj, age, ok := heapedCache.GetWithAge(1)
+    require.True(t, ok)
+    require.Equal(t, 1, obj.Id)
+    require.Equal(t, 90*time.Second, age)
+
+    heapedCache.Push(1, NewAccountTest(1))
+
+    _, age, _ = heapedCache.GetWithAge(1)
+    require.Equal(t, time.Duration(0), age)
+
+    _, _, ok = heapedCache.GetWithAge(2)
+    require.False(t, ok)
+
+}
+
+func TestFakeClockTimers(t *testing.T) {
+
+    t.Log("validating TestFakeClockTimers")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+    var calls []string
+
+    every := clock.every(time.Minute, func() { calls = append(calls, "every") })
+    clock.after(90*time.Second, func() { calls = append(calls, "after") })
+    stopped := clock.after(time.Second, func() { calls = append(calls, "stopped") })
+    stopped.stop()
+
+    clock.Advance(time.Minute)
+    require.Equal(t, []string{"every"}, calls)
+
+    // due in the order they became due; a periodic timer runs once however long the advance
+    clock.Advance(10 * time.Minute)
+    require.Equal(t, []string{"every", "after", "every"}, calls)
+
+    every.reset(time.Hour)
+    clock.Advance(59 * time.Minute)
+    require.Len(t, calls, 3)
+
+    clock.Advance(time.Minute)
+    require.Len(t, calls, 4)
+
+    every.stop()
+    clock.Advance(time.Hour)
+    require.Len(t, calls, 4)
+    require.Empty(t, clock.timers)
+
+}
+
+func TestDeterministicBackground(t *testing.T) {
+
+    t.Log("validating TestDeterministicBackground")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache(5, 1, clock,
+        WithAsyncTrim[int, AccountTest](10, time.Minute),
+        WithDeferredFix[int, AccountTest](time.Hour))
+
+    for i := range 10 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    // nothing runs until the virtual clock reaches the interval
+    require.Equal(t, 10, heapedCache.Len())
+    require.Zero(t, heapedCache.Health().Tasks[taskTrim].LastRun)
+
+    clock.Advance(time.Minute)
+    require.Equal(t, 5, heapedCache.Len())
+    require.True(t, clock.Now().Equal(heapedCache.Health().Tasks[taskTrim].LastRun))
+
+    // the deferred fix runs from the clock as well
+    heapedCache.Push(5, NewAccountTest(5))
+    require.True(t, heapedCache.fixPending())
+
+    clock.Advance(time.Hour)
+    require.False(t, heapedCache.fixPending())
+
+    // closing stops the tasks
+    heapedCache.Close()
+
+    health := heapedCache.Health()
+    require.False(t, health.Tasks[taskTrim].Running)
+    require.True(t, health.Healthy)
+
+    for i := 10; i < 15; i++ {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    clock.Advance(time.Hour)
+    require.Equal(t, 10, heapedCache.Len())
+
+}
+
+func TestDeterministicSeed(t *testing.T) {
+
+    t.Log("validating TestDeterministicSeed")
+
+    // order in which leases expiring at the same time are returned
+    run := func(seed int64) []int {
+
+        clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+        heapedCache := NewDeterministicHeapedCache(20, seed, clock, WithRecorder[int, AccountTest](20))
+
+        for i := range 8 {
+
+            heapedCache.Push(i, NewAccountTest(i))
+
+        }
+
+        heapedCache.Lease(8, time.Minute)
+        clock.Advance(time.Minute)
+
+        var returned []int
+
+        for _, op := range heapedCache.RecentOps() {
+
+            if op.Outcome == OutcomeReturned {
+                returned = append(returned, op.Id)
+            }
+
+        }
+
+        require.Len(t, returned, 8)
+
+        return returned
+
+    }
+
+    orders := map[string]bool{}
+
+    for seed := range int64(10) {
+
+        first := run(seed)
+        require.Equal(t, first, run(seed))
+        orders[fmt.Sprint(first)] = true
+
+    }
+
+    require.Greater(t, len(orders), 1)
+
+}
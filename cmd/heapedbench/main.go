@@ -0,0 +1,229 @@
+// heapedbench runs a configurable workload against a HeapedCache and prints
+// throughput, latency percentiles, hit ratio and allocations, so configurations
+// can be compared on the target hardware.
+//
+//	go run ./cmd/heapedbench -size 100000 -keys 1000000 -dist zipf -reads 0.9 -goroutines 8 -duration 10s
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	utils "opensource/heapedcache"
+)
+
+type payload struct {
+	Id     int
+	Filler [200]byte
+}
+
+type config struct {
+	size       int
+	keys       int
+	dist       string
+	reads      float64
+	goroutines int
+	duration   time.Duration
+	policy     string
+	sample     int
+}
+
+// result of a single worker
+type result struct {
+	ops       int
+	hits      int
+	reads     int
+	latencies []time.Duration
+}
+
+func main() {
+
+	var c config
+
+	flag.IntVar(&c.size, "size", 100000, "maximum number of cached items")
+	flag.IntVar(&c.keys, "keys", 1000000, "number of distinct keys")
+	flag.StringVar(&c.dist, "dist", "uniform", "key distribution: uniform or zipf")
+	flag.Float64Var(&c.reads, "reads", 0.9, "ratio of reads (GetOrAdd) to writes (Push)")
+	flag.IntVar(&c.goroutines, "goroutines", runtime.GOMAXPROCS(0), "number of concurrent workers")
+	flag.DurationVar(&c.duration, "duration", 5*time.Second, "duration of the run")
+	flag.StringVar(&c.policy, "policy", "oldest", "eviction policy: oldest, lru, clock or arc")
+	flag.IntVar(&c.sample, "sample", 16, "measure the latency of one operation out of every n")
+	flag.Parse()
+
+	if err := run(c, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "heapedbench:", err)
+		os.Exit(1)
+	}
+
+}
+
+func run(c config, out io.Writer) error {
+
+	if c.size <= 0 || c.keys <= 0 || c.goroutines <= 0 || c.sample <= 0 {
+		return fmt.Errorf("size, keys, goroutines and sample must be positive")
+	}
+
+	if c.reads < 0 || c.reads > 1 {
+		return fmt.Errorf("reads must be between 0 and 1, got %v", c.reads)
+	}
+
+	var options []utils.Option[int, payload]
+
+	switch c.policy {
+	case "oldest":
+	case "lru":
+		options = append(options, utils.WithEvictionPolicy[int, payload](utils.NewLRUPolicy[int]()))
+	case "clock":
+		options = append(options, utils.WithEvictionPolicy[int, payload](utils.NewClockPolicy[int]()))
+	case "arc":
+		options = append(options, utils.WithEvictionPolicy[int, payload](utils.NewARCPolicy[int](c.size)))
+	default:
+		return fmt.Errorf("unknown policy %q", c.policy)
+	}
+
+	if c.dist != "uniform" && c.dist != "zipf" {
+		return fmt.Errorf("unknown distribution %q", c.dist)
+	}
+
+	cache := utils.NewHeapedCache(c.size, options...)
+	defer cache.Close()
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	results := make([]result, c.goroutines)
+	deadline := time.Now().Add(c.duration)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+
+	for w := range c.goroutines {
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			results[w] = work(cache, c, int64(w), deadline)
+		}()
+
+	}
+
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	var total result
+
+	for _, r := range results {
+		total.ops += r.ops
+		total.hits += r.hits
+		total.reads += r.reads
+		total.latencies = append(total.latencies, r.latencies...)
+	}
+
+	slices.Sort(total.latencies)
+
+	fmt.Fprintf(out, "size=%d keys=%d dist=%s reads=%.2f goroutines=%d policy=%s duration=%s\n",
+		c.size, c.keys, c.dist, c.reads, c.goroutines, c.policy, elapsed.Round(time.Millisecond))
+	fmt.Fprintf(out, "operations:  %d (%.0f ops/s)\n", total.ops, float64(total.ops)/elapsed.Seconds())
+
+	if total.reads > 0 {
+		fmt.Fprintf(out, "hit ratio:   %.2f%%\n", float64(total.hits)/float64(total.reads)*100)
+	}
+
+	fmt.Fprintf(out, "latency:     p50=%s p90=%s p99=%s p999=%s max=%s\n",
+		percentile(total.latencies, 0.5), percentile(total.latencies, 0.9),
+		percentile(total.latencies, 0.99), percentile(total.latencies, 0.999),
+		percentile(total.latencies, 1))
+
+	if total.ops > 0 {
+		fmt.Fprintf(out, "allocations: %.2f allocs/op, %.0f bytes/op\n",
+			float64(after.Mallocs-before.Mallocs)/float64(total.ops),
+			float64(after.TotalAlloc-before.TotalAlloc)/float64(total.ops))
+	}
+
+	fmt.Fprintf(out, "cached:      %d items\n", cache.Len())
+
+	return nil
+
+}
+
+// runs operations until the deadline, measuring one out of every c.sample
+func work(cache *utils.HeapedCache[int, payload], c config, seed int64, deadline time.Time) result {
+
+	random := rand.New(rand.NewSource(seed))
+	next := keys(random, c)
+
+	var r result
+
+	for r.ops%1024 != 0 || time.Now().Before(deadline) {
+
+		id := next()
+		read := random.Float64() < c.reads
+		measure := r.ops%c.sample == 0
+
+		var start time.Time
+
+		if measure {
+			start = time.Now()
+		}
+
+		if read {
+
+			hit := true
+			cache.GetOrAdd(id, func(id int) *payload { hit = false; return &payload{Id: id} })
+			r.reads++
+
+			if hit {
+				r.hits++
+			}
+
+		} else {
+
+			cache.Push(id, &payload{Id: id})
+
+		}
+
+		if measure {
+			r.latencies = append(r.latencies, time.Since(start))
+		}
+
+		r.ops++
+
+	}
+
+	return r
+
+}
+
+// returns the key generator of the configured distribution
+func keys(random *rand.Rand, c config) func() int {
+
+	if c.dist == "zipf" {
+		zipf := rand.NewZipf(random, 1.1, 1, uint64(c.keys-1))
+		return func() int { return int(zipf.Uint64()) }
+	}
+
+	return func() int { return random.Intn(c.keys) }
+
+}
+
+// returns the latency at the given quantile of sorted latencies
+func percentile(sorted []time.Duration, q float64) time.Duration {
+
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	return sorted[int(q*float64(len(sorted)-1))]
+
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+    "bytes"
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestRun(t *testing.T) {
+
+    t.Log("validating TestRun")
+
+    for _, policy := range []string{"oldest", "lru", "clock", "arc"} {
+
+        for _, dist := range []string{"uniform", "zipf"} {
+
+            var out bytes.Buffer
+
+            c := config{size: 100, keys: 1000, dist: dist, reads: 0.9, goroutines: 2, duration: 20 * time.Millisecond, policy: policy, sample: 4}
+            require.NoError(t, run(c, &out))
+
+            require.Contains(t, out.String(), "policy="+policy)
+            require.Contains(t, out.String(), "hit ratio:")
+            require.Contains(t, out.String(), "latency:     p50=")
+            require.Contains(t, out.String(), "cached:      100 items\n")
+
+        }
+
+    }
+
+}
+
+func TestRunInvalid(t *testing.T) {
+
+    t.Log("validating TestRunInvalid")
+
+    valid := config{size: 100, keys: 1000, dist: "uniform", reads: 0.9, goroutines: 1, duration: time.Millisecond, policy: "oldest", sample: 1}
+
+    for _, change := range []func(c *config){
+        func(c *config) { c.size = 0 },
+        func(c *config) { c.keys = -1 },
+        func(c *config) { c.goroutines = 0 },
+        func(c *config) { c.sample = 0 },
+        func(c *config) { c.reads = 1.5 },
+        func(c *config) { c.policy = "fifo" },
+        func(c *config) { c.dist = "normal" },
+    } {
+
+        c := valid
+        change(&c)
+
+        var out bytes.Buffer
+        require.Error(t, run(c, &out))
+        require.Empty(t, out.String())
+
+    }
+
+}
+
+func TestPercentile(t *testing.T) {
+
+    t.Log("validating TestPercentile")
+
+    sorted := []time.Duration{1, 2, 3, 4, 5}
+
+    require.Equal(t, time.Duration(0), percentile(nil, 0.5))
+    require.Equal(t, time.Duration(3), percentile(sorted, 0.5))
+    require.Equal(t, time.Duration(5), percentile(sorted, 1))
+
+}
@@ -0,0 +1,432 @@
+// heapedctl inspects cache snapshots: the files written by ExportNDJSON
+// without a projection (one {"id", "refreshed", "obj"} object per line),
+// and live caches through the admin handler of their Registry.
+//
+//	heapedctl stats snapshot.ndjson
+//	heapedctl oldest snapshot.ndjson 20
+//	heapedctl get snapshot.ndjson 42
+//	heapedctl diff before.ndjson after.ndjson
+//	heapedctl stats http://localhost:8080/debug/caches
+//	heapedctl oldest http://localhost:8080/debug/caches users 20
+//	heapedctl delete http://localhost:8080/debug/caches users 42
+//	heapedctl clear http://localhost:8080/debug/caches users
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	utils "opensource/heapedcache"
+)
+
+const usage = `usage:
+  heapedctl stats  FILE        number of items, age range and size of the objects
+  heapedctl oldest FILE [N]    the N oldest keys (default 10)
+  heapedctl get    FILE KEY    the object cached under KEY
+  heapedctl diff   FILE FILE   keys added, removed and changed between two snapshots
+
+live caches, through the admin handler of their Registry (URL is where it is mounted):
+  heapedctl stats   URL                statistics of every cache
+  heapedctl metrics URL                Prometheus metrics of every cache
+  heapedctl oldest  URL CACHE [N]      the N oldest keys of CACHE (default 10)
+  heapedctl get     URL CACHE KEY      the object cached under KEY in CACHE
+  heapedctl delete  URL CACHE KEY      removes KEY from CACHE
+  heapedctl clear   URL [CACHE]        removes every item of CACHE (of every cache without it)`
+
+// timeout of the requests to a live cache
+const requestTimeout = 10 * time.Second
+
+// id of a snapshot line, as typed on the command line (strings unquoted)
+type key string
+
+func (k *key) UnmarshalJSON(data []byte) error {
+
+	var s string
+
+	if err := json.Unmarshal(data, &s); err == nil {
+		*k = key(s)
+	} else {
+		*k = key(data)
+	}
+
+	return nil
+
+}
+
+type entry = utils.Entry[key, json.RawMessage]
+
+// returns the JSON of an object (null objects are decoded as nil pointers)
+func raw(obj *json.RawMessage) json.RawMessage {
+
+	if obj == nil {
+		return json.RawMessage("null")
+	}
+
+	return *obj
+
+}
+
+func main() {
+
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "heapedctl:", err)
+		os.Exit(1)
+	}
+
+}
+
+func run(args []string, out io.Writer) error {
+
+	if len(args) < 2 {
+		return errors.New(usage)
+	}
+
+	switch command := args[0]; {
+
+	case command == "stats" && len(args) == 2 && isURL(args[1]):
+		return request(http.MethodGet, args[1], "stats", nil, out)
+
+	case command == "metrics" && len(args) == 2 && isURL(args[1]):
+		return request(http.MethodGet, args[1], "metrics", nil, out)
+
+	case command == "clear" && (len(args) == 2 || len(args) == 3) && isURL(args[1]):
+
+		query := url.Values{}
+
+		if len(args) == 3 {
+			query.Set("cache", args[2])
+		}
+
+		return request(http.MethodPost, args[1], "clear", query, out)
+
+	case command == "oldest" && (len(args) == 3 || len(args) == 4) && isURL(args[1]):
+
+		n, err := numberOfKeys(args[3:])
+
+		if err != nil {
+			return err
+		}
+
+		return liveOldest(args[1], args[2], n, out)
+
+	case command == "get" && len(args) == 4 && isURL(args[1]):
+		return liveGet(args[1], args[2], args[3], out)
+
+	case command == "delete" && len(args) == 4 && isURL(args[1]):
+		return request(http.MethodDelete, args[1], "key", url.Values{"cache": {args[2]}, "id": {args[3]}}, out)
+
+	case command == "stats" && len(args) == 2:
+		return stats(args[1], out)
+
+	case command == "oldest" && (len(args) == 2 || len(args) == 3):
+
+		n, err := numberOfKeys(args[2:])
+
+		if err != nil {
+			return err
+		}
+
+		return oldest(args[1], n, out)
+
+	case command == "get" && len(args) == 3:
+		return get(args[1], args[2], out)
+
+	case command == "diff" && len(args) == 3:
+		return diff(args[1], args[2], out)
+
+	}
+
+	return errors.New(usage)
+
+}
+
+// returns the number of keys of the optional last argument of oldest (10 by default)
+func numberOfKeys(args []string) (int, error) {
+
+	if len(args) == 0 {
+		return 10, nil
+	}
+
+	n, err := strconv.Atoi(args[0])
+
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid number of keys %q", args[0])
+	}
+
+	return n, nil
+
+}
+
+// returns true when arg is the URL of a live cache rather than a file
+func isURL(arg string) bool {
+
+	return strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://")
+
+}
+
+// calls an endpoint of the admin handler mounted at base, copying the response to out
+func request(method string, base string, endpoint string, query url.Values, out io.Writer) error {
+
+	target, err := url.JoinPath(base, endpoint)
+
+	if err != nil {
+		return err
+	}
+
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, target, nil)
+
+	if err != nil {
+		return err
+	}
+
+	resp, err := (&http.Client{Timeout: requestTimeout}).Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", method, target, resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	_, err = io.Copy(out, resp.Body)
+
+	return err
+
+}
+
+// line of a snapshot, with the object left encoded: heapedctl does not know the type of the objects,
+// so it reads them as they are, whatever their format version
+type line struct {
+	Id        key             `json:"id"`
+	Refreshed time.Time       `json:"refreshed"`
+	Obj       json.RawMessage `json:"obj"`
+}
+
+// returns the entry of a snapshot line
+func (l line) entry() entry {
+
+	e := entry{Id: l.Id, Refreshed: l.Refreshed}
+
+	if l.Obj != nil {
+		e.Obj = &l.Obj
+	}
+
+	return e
+
+}
+
+// reads a snapshot file, oldest first
+func read(path string) ([]entry, error) {
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var entries []entry
+
+	decoder := json.NewDecoder(bufio.NewReader(file))
+
+	for number := 1; ; number++ {
+
+		var l line
+
+		if err := decoder.Decode(&l); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: snapshot item %d: %w", path, number, err)
+		}
+
+		entries = append(entries, l.entry())
+
+	}
+
+	slices.SortStableFunc(entries, func(a, b entry) int { return a.Refreshed.Compare(b.Refreshed) })
+
+	return entries, nil
+
+}
+
+func stats(path string, out io.Writer) error {
+
+	entries, err := read(path)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "items:   %d\n", len(entries))
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	size := 0
+
+	for _, e := range entries {
+		size += len(raw(e.Obj))
+	}
+
+	oldest, newest := entries[0].Refreshed, entries[len(entries)-1].Refreshed
+
+	fmt.Fprintf(out, "oldest:  %s\n", oldest.Format(time.RFC3339Nano))
+	fmt.Fprintf(out, "newest:  %s\n", newest.Format(time.RFC3339Nano))
+	fmt.Fprintf(out, "span:    %s\n", newest.Sub(oldest))
+	fmt.Fprintf(out, "objects: %d bytes of JSON (%d per item)\n", size, size/len(entries))
+
+	return nil
+
+}
+
+func oldest(path string, n int, out io.Writer) error {
+
+	entries, err := read(path)
+
+	if err != nil {
+		return err
+	}
+
+	printOldest(entries[:min(n, len(entries))], out)
+
+	return nil
+
+}
+
+// same as oldest, for a live cache
+func liveOldest(base string, cache string, n int, out io.Writer) error {
+
+	var buf bytes.Buffer
+
+	if err := request(http.MethodGet, base, "oldest", url.Values{"cache": {cache}, "n": {strconv.Itoa(n)}}, &buf); err != nil {
+		return err
+	}
+
+	var lines []line
+
+	if err := json.Unmarshal(buf.Bytes(), &lines); err != nil {
+		return err
+	}
+
+	entries := make([]entry, len(lines))
+
+	for i, l := range lines {
+		entries[i] = l.entry()
+	}
+
+	printOldest(entries, out)
+
+	return nil
+
+}
+
+func printOldest(entries []entry, out io.Writer) {
+
+	for _, e := range entries {
+		fmt.Fprintf(out, "%s\t%s\n", e.Refreshed.Format(time.RFC3339Nano), e.Id)
+	}
+
+}
+
+func get(path string, id string, out io.Writer) error {
+
+	entries, err := read(path)
+
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+
+		if string(e.Id) == id {
+			printEntry(e, out)
+			return nil
+		}
+
+	}
+
+	return fmt.Errorf("key %q not found in %s", id, path)
+
+}
+
+// same as get, for a live cache
+func liveGet(base string, cache string, id string, out io.Writer) error {
+
+	var buf bytes.Buffer
+
+	if err := request(http.MethodGet, base, "key", url.Values{"cache": {cache}, "id": {id}}, &buf); err != nil {
+		return err
+	}
+
+	var l line
+
+	if err := json.Unmarshal(buf.Bytes(), &l); err != nil {
+		return err
+	}
+
+	printEntry(l.entry(), out)
+
+	return nil
+
+}
+
+func printEntry(e entry, out io.Writer) {
+
+	fmt.Fprintf(out, "refreshed: %s\n%s\n", e.Refreshed.Format(time.RFC3339Nano), raw(e.Obj))
+
+}
+
+func diff(pathA string, pathB string, out io.Writer) error {
+
+	a, err := read(pathA)
+
+	if err != nil {
+		return err
+	}
+
+	b, err := read(pathB)
+
+	if err != nil {
+		return err
+	}
+
+	result := utils.DiffSnapshots(a, b, func(x *json.RawMessage, y *json.RawMessage) bool { return bytes.Equal(raw(x), raw(y)) })
+
+	for _, d := range result.Added {
+		fmt.Fprintf(out, "+ %s\t(refreshed %s)\n", d.Id, d.After.Format(time.RFC3339Nano))
+	}
+
+	for _, d := range result.Removed {
+		fmt.Fprintf(out, "- %s\t(refreshed %s)\n", d.Id, d.Before.Format(time.RFC3339Nano))
+	}
+
+	for _, d := range result.Changed {
+		fmt.Fprintf(out, "~ %s\t(refreshed %s later)\n", d.Id, d.AgeDelta())
+	}
+
+	fmt.Fprintf(out, "%d added, %d removed, %d changed\n", len(result.Added), len(result.Removed), len(result.Changed))
+
+	return nil
+
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+    "bytes"
+    "github.com/stretchr/testify/require"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    utils "opensource/heapedcache"
+)
+
+type account struct {
+    Name string
+}
+
+// writes a snapshot of the accounts of ids, refreshed one second apart from the oldest to the newest
+func writeSnapshot(t *testing.T, names map[int]string, ids ...int) string {
+
+    clock := utils.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    cache := utils.NewHeapedCache(10, utils.WithClock[int, account](clock.Now))
+
+    for _, id := range ids {
+        cache.Push(id, &account{Name: names[id]})
+        clock.Advance(time.Second)
+    }
+
+    var buf bytes.Buffer
+    require.NoError(t, cache.WriteSnapshot(&buf))
+
+    path := filepath.Join(t.TempDir(), "snapshot.ndjson")
+    require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+
+    return path
+
+}
+
+// runs heapedctl, returning what it printed
+func runCtl(t *testing.T, args ...string) (string, error) {
+
+    var out bytes.Buffer
+    err := run(args, &out)
+
+    return out.String(), err
+
+}
+
+func TestFiles(t *testing.T) {
+
+    t.Log("validating TestFiles")
+
+    names := map[int]string{1: "ann", 2: "bob", 3: "eve"}
+    before := writeSnapshot(t, names, 1, 2)
+
+    names[2] = "robert"
+    after := writeSnapshot(t, names, 3, 2)
+
+    out, err := runCtl(t, "stats", before)
+    require.NoError(t, err)
+    require.Contains(t, out, "items:   2\n")
+    require.Contains(t, out, "span:    1s\n")
+
+    out, err = runCtl(t, "oldest", before, "1")
+    require.NoError(t, err)
+    require.Equal(t, "2024-01-01T00:00:00Z\t1\n", out)
+
+    out, err = runCtl(t, "oldest", before)
+    require.NoError(t, err)
+    require.Equal(t, 2, strings.Count(out, "\n"))
+
+    out, err = runCtl(t, "get", after, "2")
+    require.NoError(t, err)
+    require.Equal(t, "refreshed: 2024-01-01T00:00:01Z\n{\"Name\":\"robert\"}\n", out)
+
+    _, err = runCtl(t, "get", after, "1")
+    require.ErrorContains(t, err, `key "1" not found`)
+
+    out, err = runCtl(t, "diff", before, after)
+    require.NoError(t, err)
+    require.Contains(t, out, "+ 3\t")
+    require.Contains(t, out, "- 1\t")
+    require.Contains(t, out, "1 added, 1 removed, 1 changed\n")
+
+    _, err = runCtl(t, "oldest", before, "x")
+    require.EqualError(t, err, `invalid number of keys "x"`)
+
+    _, err = runCtl(t, "stats", filepath.Join(t.TempDir(), "missing.ndjson"))
+    require.Error(t, err)
+
+    _, err = runCtl(t, "unknown", before)
+    require.EqualError(t, err, usage)
+
+}
+
+func TestLive(t *testing.T) {
+
+    t.Log("validating TestLive")
+
+    clock := utils.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    accounts := utils.NewHeapedCache(10, utils.WithClock[string, account](clock.Now))
+    others := utils.NewHeapedCache[int, account](10)
+
+    for _, name := range []string{"ann", "bob", "eve"} {
+        accounts.Push(name, &account{Name: strings.ToUpper(name)})
+        clock.Advance(time.Second)
+    }
+
+    others.Push(1, &account{Name: "ONE"})
+
+    registry := utils.NewRegistry()
+    require.NoError(t, registry.Register("accounts", accounts))
+    require.NoError(t, registry.Register("others", others))
+
+    server := httptest.NewServer(registry.Handler())
+    defer server.Close()
+
+    out, err := runCtl(t, "stats", server.URL)
+    require.NoError(t, err)
+    require.Contains(t, out, `"accounts":{`)
+
+    out, err = runCtl(t, "metrics", server.URL)
+    require.NoError(t, err)
+    require.Contains(t, out, "heapedcache_items{cache=\"accounts\"} 3\n")
+
+    out, err = runCtl(t, "oldest", server.URL, "accounts", "2")
+    require.NoError(t, err)
+    require.Equal(t, "2024-01-01T00:00:00Z\tann\n2024-01-01T00:00:01Z\tbob\n", out)
+
+    out, err = runCtl(t, "get", server.URL, "accounts", "bob")
+    require.NoError(t, err)
+    require.Equal(t, "refreshed: 2024-01-01T00:00:01Z\n{\"Name\":\"BOB\"}\n", out)
+
+    out, err = runCtl(t, "delete", server.URL, "accounts", "bob")
+    require.NoError(t, err)
+    require.Equal(t, "1\n", out)
+    require.Nil(t, accounts.Get("bob"))
+
+    out, err = runCtl(t, "delete", server.URL, "accounts", "bob")
+    require.NoError(t, err)
+    require.Equal(t, "0\n", out)
+
+    _, err = runCtl(t, "get", server.URL, "accounts", "bob")
+    require.ErrorContains(t, err, "404 Not Found")
+
+    _, err = runCtl(t, "get", server.URL, "others", "one")
+    require.ErrorContains(t, err, "400 Bad Request")
+
+    _, err = runCtl(t, "oldest", server.URL, "missing")
+    require.ErrorContains(t, err, `cache "missing" not found`)
+
+    out, err = runCtl(t, "clear", server.URL, "accounts")
+    require.NoError(t, err)
+    require.Equal(t, "2\n", out)
+
+    out, err = runCtl(t, "clear", server.URL)
+    require.NoError(t, err)
+    require.Equal(t, "1\n", out)
+    require.Equal(t, 0, others.Len())
+
+}
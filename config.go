@@ -0,0 +1,317 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// declarative settings of a cache, meant to be decoded from a configuration file
+// (JSON or YAML), so tuning can ship as configuration instead of code.
+// Settings needing code (filters, indexes, aggregates, hash functions) are still given as options
+type Config struct {
+	MaxRows int `json:"maxRows" yaml:"maxRows"`
+
+	// "oldest" (default): oldest refreshed item first; "lru": least recently used;
+	// "clock": approximate least recently used (ClockPolicy); "arc": adaptive replacement (ARCPolicy)
+	Policy string `json:"policy,omitempty" yaml:"policy,omitempty"`
+
+	// "evict-oldest" (default), "reject-new" or "drop-newest-if-older" (see OverflowPolicy)
+	Overflow string `json:"overflow,omitempty" yaml:"overflow,omitempty"`
+
+	// both set: WithAsyncTrim(TrimHardRows, TrimInterval)
+	TrimHardRows int      `json:"trimHardRows,omitempty" yaml:"trimHardRows,omitempty"`
+	TrimInterval Duration `json:"trimInterval,omitempty" yaml:"trimInterval,omitempty"`
+
+	// WithTTL when set
+	TTL Duration `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+
+	// WithConsistencyAudit without report when set
+	AuditInterval Duration `json:"auditInterval,omitempty" yaml:"auditInterval,omitempty"`
+
+	RecorderSize int `json:"recorderSize,omitempty" yaml:"recorderSize,omitempty"`
+	HotKeys      int `json:"hotKeys,omitempty" yaml:"hotKeys,omitempty"`
+
+	Metrics MetricsConfig `json:"metrics,omitempty" yaml:"metrics,omitempty"`
+
+	Persistence PersistenceConfig `json:"persistence,omitempty" yaml:"persistence,omitempty"`
+}
+
+// metrics reported by Stats
+type MetricsConfig struct {
+	Latency    bool `json:"latency,omitempty" yaml:"latency,omitempty"`       // WithLatencyHistograms
+	Contention bool `json:"contention,omitempty" yaml:"contention,omitempty"` // WithContentionProfiling
+}
+
+// files on the local disk the cache is persisted to, loaded by NewFromConfig.
+// Snapshots kept in an ObjectStore are given with WithShutdownStore and LoadSnapshot
+type PersistenceConfig struct {
+
+	// both set: Recover(snapshotPath, walPath), every change logged to walPath (OnShutdown checkpoints)
+	SnapshotPath string `json:"snapshotPath,omitempty" yaml:"snapshotPath,omitempty"`
+	WALPath      string `json:"walPath,omitempty" yaml:"walPath,omitempty"`
+
+	// WithShutdownSnapshot, the snapshot being loaded back when set (without a write-ahead log)
+	ShutdownSnapshot string `json:"shutdownSnapshot,omitempty" yaml:"shutdownSnapshot,omitempty"`
+}
+
+// duration written as a string in configuration files, as in "500ms" or "1m30s"
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+
+	return []byte(time.Duration(d).String()), nil
+
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+
+	parsed, err := time.ParseDuration(string(text))
+
+	if err != nil {
+		return fmt.Errorf("invalid duration %q (expected something like \"500ms\" or \"1m30s\")", text)
+	}
+
+	*d = Duration(parsed)
+
+	return nil
+
+}
+
+var overflowPolicies = map[string]OverflowPolicy{
+	"":                     EvictOldest,
+	"evict-oldest":         EvictOldest,
+	"reject-new":           RejectNew,
+	"drop-newest-if-older": DropNewestIfOlder,
+}
+
+// returns every problem found in the configuration, or nil
+func (c Config) Validate() error {
+
+	var errs []error
+
+	if c.MaxRows <= 0 {
+		errs = append(errs, fmt.Errorf("maxRows must be positive, got %d", c.MaxRows))
+	}
+
+	if c.Policy != "" && c.Policy != "oldest" && c.Policy != "lru" && c.Policy != "clock" && c.Policy != "arc" {
+		errs = append(errs, fmt.Errorf("unknown policy %q (expected \"oldest\", \"lru\", \"clock\" or \"arc\")", c.Policy))
+	}
+
+	if _, ok := overflowPolicies[c.Overflow]; !ok {
+		errs = append(errs, fmt.Errorf("unknown overflow %q (expected \"evict-oldest\", \"reject-new\" or \"drop-newest-if-older\")", c.Overflow))
+	}
+
+	if (c.TrimHardRows == 0) != (c.TrimInterval == 0) {
+		errs = append(errs, errors.New("trimHardRows and trimInterval must be set together"))
+	} else if c.TrimHardRows != 0 && c.TrimHardRows <= c.MaxRows {
+		errs = append(errs, fmt.Errorf("trimHardRows (%d) must be greater than maxRows (%d)", c.TrimHardRows, c.MaxRows))
+	}
+
+	if c.TrimInterval < 0 {
+		errs = append(errs, fmt.Errorf("trimInterval must not be negative, got %s", time.Duration(c.TrimInterval)))
+	}
+
+	if c.TTL < 0 {
+		errs = append(errs, fmt.Errorf("ttl must not be negative, got %s", time.Duration(c.TTL)))
+	}
+
+	if c.AuditInterval < 0 {
+		errs = append(errs, fmt.Errorf("auditInterval must not be negative, got %s", time.Duration(c.AuditInterval)))
+	}
+
+	if c.RecorderSize < 0 {
+		errs = append(errs, fmt.Errorf("recorderSize must not be negative, got %d", c.RecorderSize))
+	}
+
+	if c.HotKeys < 0 {
+		errs = append(errs, fmt.Errorf("hotKeys must not be negative, got %d", c.HotKeys))
+	}
+
+	errs = append(errs, c.Persistence.validate()...)
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("heapedcache: invalid config: %w", err)
+	}
+
+	return nil
+
+}
+
+// returns the problems found in the persistence settings
+func (p PersistenceConfig) validate() []error {
+
+	var errs []error
+
+	if (p.SnapshotPath == "") != (p.WALPath == "") {
+		errs = append(errs, errors.New("persistence.snapshotPath and persistence.walPath must be set together"))
+	} else if p.SnapshotPath != "" && p.SnapshotPath == p.WALPath {
+		errs = append(errs, fmt.Errorf("persistence.snapshotPath and persistence.walPath must be different files, got %q for both", p.WALPath))
+	}
+
+	if p.ShutdownSnapshot != "" && p.WALPath != "" {
+		errs = append(errs, errors.New("persistence.shutdownSnapshot cannot be set with a write-ahead log, which OnShutdown checkpoints to persistence.snapshotPath"))
+	}
+
+	return errs
+
+}
+
+// builds a cache from a configuration; options are applied after the ones derived from it.
+// The persisted items are loaded before it is returned: with a write-ahead log through Recover
+// (call Recover yourself for its RecoveryReport), otherwise from the shutdown snapshot
+// returns the validation errors when the configuration is invalid, and the error of loading the persisted items
+func NewFromConfig[TId comparable, TObj any](cfg Config, options ...Option[TId, TObj]) (*HeapedCache[TId, TObj], error) {
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	var configured []Option[TId, TObj]
+
+	switch cfg.Policy {
+	case "lru":
+		configured = append(configured, WithEvictionPolicy[TId, TObj](NewLRUPolicy[TId]()))
+	case "clock":
+		configured = append(configured, WithEvictionPolicy[TId, TObj](NewClockPolicy[TId]()))
+	case "arc":
+		configured = append(configured, WithEvictionPolicy[TId, TObj](NewARCPolicy[TId](cfg.MaxRows)))
+	}
+
+	configured = append(configured, WithOverflowPolicy[TId, TObj](overflowPolicies[cfg.Overflow]))
+
+	if cfg.TrimHardRows > 0 {
+		configured = append(configured, WithAsyncTrim[TId, TObj](cfg.TrimHardRows, time.Duration(cfg.TrimInterval)))
+	}
+
+	if cfg.TTL > 0 {
+		configured = append(configured, WithTTL[TId, TObj](time.Duration(cfg.TTL)))
+	}
+
+	if cfg.AuditInterval > 0 {
+		configured = append(configured, WithConsistencyAudit[TId, TObj](time.Duration(cfg.AuditInterval), nil))
+	}
+
+	if cfg.RecorderSize > 0 {
+		configured = append(configured, WithRecorder[TId, TObj](cfg.RecorderSize))
+	}
+
+	if cfg.HotKeys > 0 {
+		configured = append(configured, WithHotKeys[TId, TObj](cfg.HotKeys))
+	}
+
+	if cfg.Metrics.Latency {
+		configured = append(configured, WithLatencyHistograms[TId, TObj]())
+	}
+
+	if cfg.Metrics.Contention {
+		configured = append(configured, WithContentionProfiling[TId, TObj]())
+	}
+
+	if cfg.Persistence.ShutdownSnapshot != "" {
+		configured = append(configured, WithShutdownSnapshot[TId, TObj](cfg.Persistence.ShutdownSnapshot))
+	}
+
+	t := NewHeapedCache(cfg.MaxRows, append(configured, options...)...)
+	t.config = &cfg
+
+	var err error
+
+	if cfg.Persistence.WALPath != "" {
+		_, err = t.Recover(cfg.Persistence.SnapshotPath, cfg.Persistence.WALPath)
+	} else if cfg.Persistence.ShutdownSnapshot != "" {
+		_, err = t.LoadSnapshot(context.Background(), t.shutdownStore, t.shutdownName)
+	}
+
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+
+	return t, nil
+
+}
+
+// applies a new configuration at runtime. Only maxRows, overflow, ttl, trimHardRows and
+// the trim and audit intervals can change; the other settings (persistence included) must keep the values
+// the cache was built with (zero values for caches not built by NewFromConfig),
+// and async trim or the audit cannot be turned on or off.
+// Nothing is applied when the configuration is rejected. Otherwise every setting changes
+// at once, under the lock, and items beyond a smaller maxRows are evicted right after,
+// in batches (see Trim)
+func (t *HeapedCache[TId, TObj]) ApplyConfig(cfg Config) error {
+
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	t.lock(opOther)
+
+	if err := t.reloadable(cfg); err != nil {
+		t.unlock()
+		return err
+	}
+
+	t.maxRows = cfg.MaxRows
+	t.overflow = overflowPolicies[cfg.Overflow]
+	t.ttl = time.Duration(cfg.TTL)
+
+	// the items following the ttl of the cache expire at other times
+	if t.expiries != nil {
+		t.expiries.rebuild(t.sliceItems)
+	}
+
+	if t.trimmer != nil {
+		t.trimmer.hardRows = cfg.TrimHardRows
+		t.task(taskTrim).resetInterval(time.Duration(cfg.TrimInterval))
+	}
+
+	if task := t.task(taskAudit); task != nil {
+		task.resetInterval(time.Duration(cfg.AuditInterval))
+	}
+
+	t.config = &cfg
+
+	t.unlock()
+
+	t.Trim()
+
+	return nil
+
+}
+
+// returns an error when cfg changes a setting that cannot change at runtime
+func (t *HeapedCache[TId, TObj]) reloadable(cfg Config) error {
+
+	var current Config
+
+	if t.config != nil {
+		current = *t.config
+	}
+
+	var errs []error
+
+	if cfg.Policy != current.Policy || cfg.RecorderSize != current.RecorderSize || cfg.HotKeys != current.HotKeys || cfg.Metrics != current.Metrics {
+		errs = append(errs, errors.New("policy, recorderSize, hotKeys and metrics cannot change at runtime"))
+	}
+
+	if cfg.Persistence != current.Persistence {
+		errs = append(errs, errors.New("persistence cannot change at runtime"))
+	}
+
+	if (t.trimmer != nil) != (cfg.TrimHardRows > 0) {
+		errs = append(errs, errors.New("async trim cannot be turned on or off at runtime"))
+	}
+
+	if (t.task(taskAudit) != nil) != (cfg.AuditInterval > 0) {
+		errs = append(errs, errors.New("the consistency audit cannot be turned on or off at runtime"))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("heapedcache: config cannot be applied: %w", err)
+	}
+
+	return nil
+
+}
@@ -0,0 +1,202 @@
+package utils
+
+import (
+    "context"
+    "encoding/json"
+    "github.com/stretchr/testify/require"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+func TestNewFromConfig(t *testing.T) {
+
+    t.Log("validating TestNewFromConfig")
+
+    var cfg Config
+
+    err := json.Unmarshal([]byte(`{
+        "maxRows": 3,
+        "policy": "lru",
+        "overflow": "evict-oldest",
+        "trimHardRows": 6,
+        "trimInterval": "1h",
+        "recorderSize": 10,
+        "metrics": {"latency": true}
+    }`), &cfg)
+    require.NoError(t, err)
+    require.Equal(t, Duration(time.Hour), cfg.TrimInterval)
+
+    heapedCache, err := NewFromConfig[int, AccountTest](cfg)
+    require.NoError(t, err)
+    defer heapedCache.Close()
+
+    for i := range 6 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    // trimmed in the background only, down to maxRows
+    require.Equal(t, 6, heapedCache.Len())
+    require.Equal(t, 3, heapedCache.Trim())
+    require.Len(t, heapedCache.RecentOps(), 9)
+    require.NotNil(t, heapedCache.Stats().Latency)
+
+}
+
+func TestConfigValidate(t *testing.T) {
+
+    t.Log("validating TestConfigValidate")
+
+    err := Config{MaxRows: 0, Policy: "fifo", Overflow: "drop", TrimHardRows: 10, HotKeys: -1}.Validate()
+    require.Error(t, err)
+
+    for _, message := range []string{
+        "maxRows must be positive, got 0",
+        "unknown policy \"fifo\"",
+        "unknown overflow \"drop\"",
+        "trimHardRows and trimInterval must be set together",
+        "hotKeys must not be negative",
+    } {
+
+        require.ErrorContains(t, err, message)
+
+    }
+
+    var cfg Config
+    require.ErrorContains(t, json.Unmarshal([]byte(`{"trimInterval": "5 minutes"}`), &cfg), "invalid duration")
+
+    _, err = NewFromConfig[int, AccountTest](Config{MaxRows: 10, TrimHardRows: 5, TrimInterval: Duration(time.Second)})
+    require.ErrorContains(t, err, "trimHardRows (5) must be greater than maxRows (10)")
+
+}
+
+func TestApplyConfig(t *testing.T) {
+
+    t.Log("validating TestApplyConfig")
+
+    heapedCache, err := NewFromConfig[int, AccountTest](Config{MaxRows: 5000, RecorderSize: 1})
+    require.NoError(t, err)
+
+    for i := range 5000 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    // shrinking evicts the oldest items right away
+    require.NoError(t, heapedCache.ApplyConfig(Config{MaxRows: 1500, Overflow: "reject-new", RecorderSize: 1}))
+    require.Equal(t, 1500, heapedCache.Len())
+    require.Nil(t, heapedCache.Get(3499))
+    require.NotNil(t, heapedCache.Get(3500))
+
+    // the new overflow policy applies
+    _, err = heapedCache.TryPush(5000, NewAccountTest(5000))
+    require.ErrorIs(t, err, ErrFull)
+
+    // rejected configurations change nothing
+    err = heapedCache.ApplyConfig(Config{MaxRows: 10, Policy: "lru", RecorderSize: 1})
+    require.ErrorContains(t, err, "cannot change at runtime")
+
+    err = heapedCache.ApplyConfig(Config{MaxRows: 10, RecorderSize: 1, TrimHardRows: 20, TrimInterval: Duration(time.Second)})
+    require.ErrorContains(t, err, "async trim cannot be turned on or off")
+
+    require.Error(t, heapedCache.ApplyConfig(Config{}))
+    require.Equal(t, 1500, heapedCache.Len())
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
+
+func TestApplyConfigIntervals(t *testing.T) {
+
+    t.Log("validating TestApplyConfigIntervals")
+
+    heapedCache, err := NewFromConfig[int, AccountTest](Config{MaxRows: 10, TrimHardRows: 100, TrimInterval: Duration(time.Hour)})
+    require.NoError(t, err)
+    defer heapedCache.Close()
+
+    for i := range 50 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    require.Equal(t, 50, heapedCache.Len())
+
+    // the trimmer now runs every millisecond, and evicts as soon as 20 items are reached
+    require.NoError(t, heapedCache.ApplyConfig(Config{MaxRows: 20, TrimHardRows: 30, TrimInterval: Duration(time.Millisecond)}))
+    require.Equal(t, 20, heapedCache.Len())
+
+    for i := 50; i < 60; i++ {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    require.Eventually(t, func() bool { return heapedCache.Len() == 20 }, time.Second, time.Millisecond)
+
+}
+
+func TestConfigPersistence(t *testing.T) {
+
+    t.Log("validating TestConfigPersistence")
+
+    dir := t.TempDir()
+    ctx := context.Background()
+
+    var cfg Config
+
+    err := json.Unmarshal([]byte(`{
+        "maxRows": 10,
+        "persistence": {"snapshotPath": "`+filepath.Join(dir, "cache.ndjson")+`", "walPath": "`+filepath.Join(dir, "cache.wal")+`"}
+    }`), &cfg)
+    require.NoError(t, err)
+
+    heapedCache, err := NewFromConfig[int, AccountTest](cfg)
+    require.NoError(t, err)
+
+    heapedCache.Push(1, NewAccountTest(1))
+    require.NoError(t, heapedCache.WALError())
+
+    // recovered from the log, without any snapshot written in between
+    recovered, err := NewFromConfig[int, AccountTest](cfg)
+    require.NoError(t, err)
+    require.Equal(t, "PHONE 1", recovered.Get(1).Phone)
+
+    // persistence is set once
+    cfg.Persistence.WALPath = filepath.Join(dir, "other.wal")
+    require.ErrorContains(t, recovered.ApplyConfig(cfg), "persistence cannot change at runtime")
+
+    shutdown := Config{MaxRows: 10, Persistence: PersistenceConfig{ShutdownSnapshot: filepath.Join(dir, "shutdown.ndjson")}}
+
+    heapedCache, err = NewFromConfig[int, AccountTest](shutdown)
+    require.NoError(t, err)
+    require.Equal(t, 0, heapedCache.Len())
+
+    heapedCache.Push(2, NewAccountTest(2))
+
+    report, err := heapedCache.OnShutdown(ctx)
+    require.NoError(t, err)
+    require.Equal(t, 1, report.Persisted)
+
+    restarted, err := NewFromConfig[int, AccountTest](shutdown)
+    require.NoError(t, err)
+    require.Equal(t, "PHONE 2", restarted.Get(2).Phone)
+
+    // an unreadable snapshot fails the creation
+    require.NoError(t, os.WriteFile(filepath.Join(dir, "dir.ndjson.wal"), nil, 0o600))
+    require.NoError(t, os.Mkdir(filepath.Join(dir, "dir.ndjson"), 0o700))
+
+    _, err = NewFromConfig[int, AccountTest](Config{MaxRows: 10, Persistence: PersistenceConfig{SnapshotPath: filepath.Join(dir, "dir.ndjson"), WALPath: filepath.Join(dir, "dir.ndjson.wal")}})
+    require.Error(t, err)
+
+    err = Config{MaxRows: 10, Persistence: PersistenceConfig{SnapshotPath: "a", ShutdownSnapshot: "b"}}.Validate()
+    require.ErrorContains(t, err, "persistence.snapshotPath and persistence.walPath must be set together")
+
+    err = Config{MaxRows: 10, Persistence: PersistenceConfig{SnapshotPath: "a", WALPath: "a", ShutdownSnapshot: "b"}}.Validate()
+    require.ErrorContains(t, err, "must be different files")
+    require.ErrorContains(t, err, "persistence.shutdownSnapshot cannot be set with a write-ahead log")
+
+}
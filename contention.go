@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// operations timed by the contention accounting, besides OpGet, OpPush, OpPop and OpRemove
+const (
+	OpGetOrAdd = "get_or_add"
+	OpScan     = "scan" // chunks of ForEach, RemoveIf and AgeHistogram
+)
+
+// every other acquisition of the cache lock: only its hold time is accounted
+const opOther = "other"
+
+// time spent waiting for the cache lock by an operation
+type LockWait struct {
+	Acquisitions uint64        // times the lock was taken
+	Contended    uint64        // times it was already held by someone else
+	Wait         time.Duration // total time spent waiting for it
+}
+
+type lockWait struct {
+	acquisitions atomic.Uint64
+	contended    atomic.Uint64
+	wait         atomic.Int64
+}
+
+// lock wait accounting of the main operations
+type contention struct {
+	ops      map[string]*lockWait // fixed when the option is applied, so read without locking
+	acquired time.Time            // when the lock was last taken, guarded by the lock
+	maxHold  atomic.Int64         // longest time the lock was held
+}
+
+// accounts the time Get, GetOrAdd, Push, Pop, Remove and the scans spend waiting for the cache lock,
+// and the longest time the lock was held by anyone, reported by Stats. Uncontended acquisitions cost a TryLock; contended ones, two clock reads.
+// Useful to know whether a deployment would benefit from sharding before adopting it
+func WithContentionProfiling[TId comparable, TObj any]() Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		t.contention = &contention{ops: map[string]*lockWait{
+			OpGet:      {},
+			OpGetOrAdd: {},
+			OpPush:     {},
+			OpPop:      {},
+			OpRemove:   {},
+			OpScan:     {},
+		}}
+
+	}
+
+}
+
+// takes the cache lock on behalf of op, accounting the wait when enabled, puts the items
+// of the expired leases back (see Lease), forgets the soft removals whose window is over
+// (see SoftRemove) and removes the expired items (see WithTTL)
+func (t *HeapedCache[TId, TObj]) lock(op string) {
+
+	t.acquire(op)
+	t.expireLeases()
+	t.expireSoftRemovals()
+	t.purgeExpired()
+
+}
+
+// takes the cache lock (see lock)
+func (t *HeapedCache[TId, TObj]) acquire(op string) {
+
+	if t.reentrancy != nil {
+		gid := goroutineID()
+		t.reentrancy.check(gid, op)
+		defer t.reentrancy.owner.Store(gid)
+	}
+
+	if t.watchdog != nil {
+		defer t.watchdog.held(op)
+	}
+
+	if t.contention == nil {
+		t.mu.Lock()
+		return
+	}
+
+	defer func() { t.contention.acquired = time.Now() }()
+
+	w := t.contention.ops[op]
+
+	if w == nil {
+		t.mu.Lock()
+		return
+	}
+
+	w.acquisitions.Add(1)
+
+	if t.mu.TryLock() {
+		return
+	}
+
+	start := time.Now()
+	t.mu.Lock()
+
+	w.contended.Add(1)
+	w.wait.Add(int64(time.Since(start)))
+
+}
+
+// releases the cache lock taken by lock, accounting how long it was held when enabled,
+// then delivers the evictions of the critical section to the eviction callbacks
+// its records to the audit sink and its pressure changes to the pressure callback (WithPressure)
+func (t *HeapedCache[TId, TObj]) unlock() {
+
+	t.assertInvariants()
+
+	if t.reentrancy != nil {
+		t.reentrancy.owner.Store(0)
+	}
+
+	if t.watchdog != nil {
+		t.watchdog.released()
+	}
+
+	if t.contention != nil {
+
+		held := int64(time.Since(t.contention.acquired))
+
+		for longest := t.contention.maxHold.Load(); held > longest; longest = t.contention.maxHold.Load() {
+
+			if t.contention.maxHold.CompareAndSwap(longest, held) {
+				break
+			}
+
+		}
+
+	}
+
+	callbacks, queue := t.takeDispatchQueue()
+	records := t.takeAuditRecords()
+	change, crossed := t.takePressureChange()
+	logged := t.takeWAL()
+	t.ctx = nil
+
+	t.mu.Unlock()
+
+	t.writeWAL(logged)
+	t.dispatch(callbacks, queue)
+	t.deliverAudit(records)
+	t.deliverPressure(change, crossed)
+
+}
+
+// returns a snapshot of the wait of every operation
+func (c *contention) snapshot() map[string]LockWait {
+
+	result := make(map[string]LockWait, len(c.ops))
+
+	for op, w := range c.ops {
+		result[op] = LockWait{
+			Acquisitions: w.acquisitions.Load(),
+			Contended:    w.contended.Load(),
+			Wait:         time.Duration(w.wait.Load()),
+		}
+	}
+
+	return result
+
+}
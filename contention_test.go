@@ -0,0 +1,47 @@
+package utils
+
+import (
+    "bytes"
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestContentionProfiling(t *testing.T) {
+
+    t.Log("validating TestContentionProfiling")
+
+    heapedCache := NewHeapedCache(10, WithContentionProfiling[int, AccountTest]())
+
+    heapedCache.Push(1, NewAccountTest(1))
+    heapedCache.Get(1)
+
+    // holds the lock while a Get waits for it
+    heapedCache.mu.Lock()
+
+    done := make(chan struct{})
+
+    go func() {
+        heapedCache.Get(1)
+        close(done)
+    }()
+
+    time.Sleep(20 * time.Millisecond)
+    heapedCache.mu.Unlock()
+    <-done
+
+    stats := heapedCache.Stats()
+
+    require.Equal(t, uint64(1), stats.Contention[OpPush].Acquisitions)
+    require.Equal(t, uint64(0), stats.Contention[OpPush].Contended)
+    require.Equal(t, uint64(2), stats.Contention[OpGet].Acquisitions)
+    require.Equal(t, uint64(1), stats.Contention[OpGet].Contended)
+    require.GreaterOrEqual(t, stats.Contention[OpGet].Wait, 10*time.Millisecond)
+
+    var buffer bytes.Buffer
+    require.NoError(t, stats.WritePrometheus(&buffer, "test_cache"))
+    require.Contains(t, buffer.String(), "test_cache_lock_contended_total{op=\"get\"} 1\n")
+
+    require.Nil(t, NewHeapedCache[int, AccountTest](10).Stats().Contention)
+
+}
@@ -0,0 +1,21 @@
+package utils
+
+import "context"
+
+// sets the context of the running operation, given to the audit sink (WithAudit) and to the
+// eviction callbacks of OnEvictContext. ctx may be nil (no context)
+// must be called under the lock; the context is forgotten when the lock is released
+func (t *HeapedCache[TId, TObj]) operationContext(ctx context.Context) {
+
+	t.ctx = ctx
+
+}
+
+// same as TryGetOrAdd, with a loader receiving ctx (e.g. to query the database with the
+// deadline and trace of the request) and ctx given to the audit sink (see WithAudit) and to the
+// eviction callbacks of the items it evicts (see OnEvictContext)
+func (t *HeapedCache[TId, TObj]) GetOrAddContext(ctx context.Context, id TId, fn func(ctx context.Context, id TId) *TObj) (*TObj, error) {
+
+	return t.getOrAdd(ctx, id, func(id TId) *TObj { return fn(ctx, id) }, EntryOptions{})
+
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+    "context"
+    "github.com/stretchr/testify/require"
+    "testing"
+)
+
+type traceKey struct{}
+
+func TestOperationContext(t *testing.T) {
+
+    t.Log("validating TestOperationContext")
+
+    var records []AuditRecord[int]
+
+    heapedCache := NewHeapedCache(1, WithAudit[int, AccountTest](AuditSinkFunc[int](func(record AuditRecord[int]) {
+        records = append(records, record)
+    }), nil))
+
+    var traces []any
+
+    heapedCache.OnEvictContext(func(ctx context.Context, id int, obj *AccountTest) {
+        traces = append(traces, ctx.Value(traceKey{}))
+    })
+
+    ctx := context.WithValue(context.Background(), traceKey{}, "trace-1")
+
+    heapedCache.Push(1, NewAccountTest(1))
+    _, err := heapedCache.PushContext(ctx, 2, NewAccountTest(2))
+    require.NoError(t, err)
+
+    // the eviction of 1 was caused by the operation of ctx
+    require.Equal(t, []any{"trace-1"}, traces)
+    require.Nil(t, records[0].Context)
+    require.Equal(t, "trace-1", records[len(records)-1].Context.Value(traceKey{}))
+
+    // the loader gets the context too
+    loadCtx := context.WithValue(context.Background(), traceKey{}, "trace-2")
+
+    obj, err := heapedCache.GetOrAddContext(loadCtx, 3, func(ctx context.Context, id int) *AccountTest {
+        require.Equal(t, "trace-2", ctx.Value(traceKey{}))
+        return NewAccountTest(id)
+    })
+
+    require.NoError(t, err)
+    require.Equal(t, 3, obj.Id)
+    require.Equal(t, []any{"trace-1", "trace-2"}, traces)
+
+    // operations without a context give context.Background() to the callbacks
+    heapedCache.Push(4, NewAccountTest(4))
+    require.Equal(t, []any{"trace-1", "trace-2", nil}, traces)
+    require.Nil(t, records[len(records)-1].Context)
+
+}
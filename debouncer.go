@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// runs an action for an id at most once per window: the first Trigger schedules it
+// window later, and the triggers received until then are absorbed.
+// Pending ids are kept in a HeapedCache, so they fire in due-time order
+type Debouncer[TId comparable] struct {
+	pending  *HeapedCache[TId, struct{}]
+	window   time.Duration
+	action   func(id TId)
+	wake     chan struct{}
+	done     chan struct{}
+	clock    *FakeClock // fires the actions instead of the goroutine, see NewDeterministicDebouncer
+	stopOnce sync.Once
+	panics   atomic.Uint64 // panics of the action, contained
+
+	mu      sync.Mutex
+	onPanic func(id TId, err *PanicError)
+}
+
+// conctructor of the Debouncer
+// at most maxPending ids can be scheduled at once; Stop must be called to release it
+func NewDebouncer[TId comparable](maxPending int, window time.Duration, action func(id TId)) *Debouncer[TId] {
+
+	d := &Debouncer[TId]{
+		pending: NewHeapedCache(maxPending, WithOverflowPolicy[TId, struct{}](RejectNew)),
+		window:  window,
+		action:  action,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	go d.run()
+
+	return d
+
+}
+
+// conctructor of a Debouncer driven by a virtual clock: the actions are fired by clock.Advance,
+// on its goroutine, once their window is over, so tests see them in the same order on every run.
+// Stop still discards the actions not due yet
+func NewDeterministicDebouncer[TId comparable](maxPending int, window time.Duration, clock *FakeClock, action func(id TId)) *Debouncer[TId] {
+
+	return &Debouncer[TId]{
+		pending: NewDeterministicHeapedCache(maxPending, 0, clock, WithOverflowPolicy[TId, struct{}](RejectNew)),
+		window:  window,
+		action:  action,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		clock:   clock,
+	}
+
+}
+
+// schedules the action for id, unless it is already scheduled
+// returns ErrFull when maxPending ids are already scheduled
+func (d *Debouncer[TId]) Trigger(id TId) error {
+
+	d.pending.lock(opOther)
+
+	if d.pending.mapItems[id] != nil {
+		d.pending.unlock()
+		return nil
+	}
+
+	_, err := d.pending.push(id, &struct{}{})
+
+	d.pending.unlock()
+
+	if err != nil {
+		return err
+	}
+
+	if d.clock != nil {
+		d.clock.after(d.window, d.fireDue)
+		return nil
+	}
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+
+}
+
+// returns the number of scheduled ids
+func (d *Debouncer[TId]) Pending() int {
+
+	return d.pending.Len()
+
+}
+
+// returns the number of times the action panicked (the Debouncer keeps running)
+func (d *Debouncer[TId]) Panics() uint64 {
+
+	return d.panics.Load()
+
+}
+
+// registers fn to be called with the id and the *PanicError of every panic of the action,
+// on the goroutine of the Debouncer (the one calling FakeClock.Advance for NewDeterministicDebouncer),
+// right after the action panicked
+func (d *Debouncer[TId]) OnPanic(fn func(id TId, err *PanicError)) {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.onPanic = fn
+
+}
+
+// runs the action for id, containing its panic
+func (d *Debouncer[TId]) fire(id TId) {
+
+	var panicked *PanicError
+
+	if !errors.As(contain(&d.panics, func() { d.action(id) }), &panicked) {
+		return
+	}
+
+	d.mu.Lock()
+	onPanic := d.onPanic
+	d.mu.Unlock()
+
+	if onPanic != nil {
+		contain(&d.panics, func() { onPanic(id, panicked) })
+	}
+
+}
+
+// stops the Debouncer; scheduled actions that are not due yet are discarded
+func (d *Debouncer[TId]) Stop() {
+
+	d.stopOnce.Do(func() { close(d.done) })
+
+}
+
+// fires the due actions, sleeping until the next one is due or a new id is scheduled
+func (d *Debouncer[TId]) run() {
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+
+		id, wait, ok := d.next()
+
+		if ok {
+			d.fire(id)
+			continue
+		}
+
+		timer.Reset(wait)
+
+		select {
+		case <-d.done:
+			return
+		case <-d.wake:
+		case <-timer.C:
+		}
+
+	}
+
+}
+
+// removes and returns the first scheduled id when it is due,
+// otherwise returns how long until it is (an hour when none is scheduled)
+func (d *Debouncer[TId]) next() (TId, time.Duration, bool) {
+
+	d.pending.lock(opOther)
+	defer d.pending.unlock()
+
+	var none TId
+
+	if len(d.pending.sliceItems) == 0 {
+		return none, time.Hour, false
+	}
+
+	first := d.pending.sliceItems[0]
+
+	if wait := first.Refreshed.Add(d.window).Sub(d.pending.now()); wait > 0 {
+		return none, wait, false
+	}
+
+	d.pending.pop()
+
+	return first.Id, 0, true
+
+}
+
+// fires the due actions of a Debouncer driven by a FakeClock, unless it was stopped
+func (d *Debouncer[TId]) fireDue() {
+
+	for {
+
+		select {
+		case <-d.done:
+			return
+		default:
+		}
+
+		id, _, ok := d.next()
+
+		if !ok {
+			return
+		}
+
+		d.fire(id)
+
+	}
+
+}
@@ -0,0 +1,144 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestDebouncer(t *testing.T) {
+
+    t.Log("validating TestDebouncer")
+
+    var mu sync.Mutex
+    var fired []int
+
+    debouncer := NewDebouncer(10, 50*time.Millisecond, func(id int) {
+        mu.Lock()
+        defer mu.Unlock()
+        fired = append(fired, id)
+    })
+    defer debouncer.Stop()
+
+    for range 5 {
+
+        require.NoError(t, debouncer.Trigger(1))
+        require.NoError(t, debouncer.Trigger(2))
+
+    }
+
+    require.Equal(t, 2, debouncer.Pending())
+
+    require.Eventually(t, func() bool {
+        mu.Lock()
+        defer mu.Unlock()
+        return len(fired) == 2
+    }, time.Second, 5*time.Millisecond)
+
+    mu.Lock()
+    require.Equal(t, []int{1, 2}, fired)
+    mu.Unlock()
+
+    // once fired, the id can be scheduled again
+    require.NoError(t, debouncer.Trigger(1))
+
+    require.Eventually(t, func() bool {
+        mu.Lock()
+        defer mu.Unlock()
+        return len(fired) == 3
+    }, time.Second, 5*time.Millisecond)
+
+}
+
+func TestDebouncerFull(t *testing.T) {
+
+    t.Log("validating TestDebouncerFull")
+
+    debouncer := NewDebouncer(1, time.Hour, func(id int) {})
+    defer debouncer.Stop()
+
+    require.NoError(t, debouncer.Trigger(1))
+    require.NoError(t, debouncer.Trigger(1))
+    require.ErrorIs(t, debouncer.Trigger(2), ErrFull)
+
+}
+
+func TestDebouncerPanic(t *testing.T) {
+
+    t.Log("validating TestDebouncerPanic")
+
+    var mu sync.Mutex
+    var fired []int
+    var panicked []*PanicError
+
+    debouncer := NewDebouncer(10, 10*time.Millisecond, func(id int) {
+        if id == 1 {
+            panic("action failed")
+        }
+        mu.Lock()
+        defer mu.Unlock()
+        fired = append(fired, id)
+    })
+    defer debouncer.Stop()
+
+    debouncer.OnPanic(func(id int, err *PanicError) {
+        mu.Lock()
+        defer mu.Unlock()
+        require.Equal(t, 1, id)
+        panicked = append(panicked, err)
+    })
+
+    require.NoError(t, debouncer.Trigger(1))
+    require.NoError(t, debouncer.Trigger(2))
+
+    // the panic does not stop the debouncer
+    require.Eventually(t, func() bool {
+        mu.Lock()
+        defer mu.Unlock()
+        return len(fired) == 1 && len(panicked) == 1
+    }, time.Second, 5*time.Millisecond)
+
+    mu.Lock()
+    require.Equal(t, "action failed", panicked[0].Value)
+    require.NotEmpty(t, panicked[0].Stack)
+    mu.Unlock()
+
+    require.Equal(t, uint64(1), debouncer.Panics())
+
+}
+
+func TestDeterministicDebouncer(t *testing.T) {
+
+    t.Log("validating TestDeterministicDebouncer")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+    var fired []int
+
+    debouncer := NewDeterministicDebouncer(10, time.Minute, clock, func(id int) { fired = append(fired, id) })
+
+    require.NoError(t, debouncer.Trigger(1))
+    clock.Advance(30 * time.Second)
+    require.NoError(t, debouncer.Trigger(2))
+    require.NoError(t, debouncer.Trigger(1))
+
+    // fired by the clock once their window is over, in due order
+    clock.Advance(29 * time.Second)
+    require.Empty(t, fired)
+
+    clock.Advance(time.Second)
+    require.Equal(t, []int{1}, fired)
+
+    clock.Advance(time.Hour)
+    require.Equal(t, []int{1, 2}, fired)
+    require.Equal(t, 0, debouncer.Pending())
+
+    // stopped, the pending ones are discarded
+    require.NoError(t, debouncer.Trigger(3))
+    debouncer.Stop()
+
+    clock.Advance(time.Hour)
+    require.Equal(t, []int{1, 2}, fired)
+
+}
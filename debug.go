@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Building with the heapedcache_debug tag (go build -tags heapedcache_debug) turns on heavy
+// internal checking, meant for staging and tests:
+//   - the map and heap invariants (CheckInvariants, capacity aside) are verified every time
+//     the cache lock is released, panicking as soon as an operation breaks them.
+//     Big caches are checked debugCheckWindow positions at a time, in turns, so a check
+//     stays bounded and the whole heap is still covered every len/debugCheckWindow operations.
+//     Caches that fit in a window also get their total cost checked (WithCost, UpdateCost)
+//   - items leaving the cache are poisoned, so internal code still using them panics
+//     instead of corrupting the heap
+//   - reentrant calls from callbacks are detected (WithReentrancyDetection) on every cache
+// Without the tag, debugMode is a false constant and the checks compile away
+
+// number of heap positions verified every time the lock is released in debug mode
+const debugCheckWindow = 64
+
+// heap position given to the items that left the cache in debug mode
+const poisonedIndex = -0xdead
+
+// panics when the invariants of the cache are broken (debug mode only)
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) assertInvariants() {
+
+	if !debugMode {
+		return
+	}
+
+	if t.unchecked {
+		t.unchecked = false
+		return
+	}
+
+	from, to := 0, len(t.sliceItems)
+
+	if to > debugCheckWindow {
+
+		if t.debugCursor >= to {
+			t.debugCursor = 0
+		}
+
+		from = t.debugCursor
+		to = min(from+debugCheckWindow, to)
+		t.debugCursor = to
+
+	}
+
+	errs := t.structuralDiscrepancies(from, to)
+
+	// the total cost is checked when the whole heap is (small caches)
+	if from == 0 && to == len(t.sliceItems) {
+
+		if err := t.costDrift(); err != nil {
+			errs = append(errs, err)
+		}
+
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		panic(fmt.Errorf("heapedcache: invariants broken: %w", err))
+	}
+
+}
+
+// marks an item that left the cache (debug mode only)
+func (item *HeapedCacheItem[TId, TObj]) poison() {
+
+	if debugMode {
+		item.index = poisonedIndex
+		item.nsIndex = poisonedIndex
+	}
+
+}
+
+// panics when an item that left the cache is used as a cached one (debug mode only)
+func (item *HeapedCacheItem[TId, TObj]) assertLive() {
+
+	if debugMode && item.index == poisonedIndex {
+		panic(fmt.Sprintf("heapedcache: item %v used after leaving the cache", item.Id))
+	}
+
+}
+
+// releases the lock without asserting the invariants, for CheckInvariants,
+// which reports broken invariants as an error instead
+func (t *HeapedCache[TId, TObj]) uncheckedUnlock() {
+
+	t.unchecked = true
+	t.unlock()
+
+}
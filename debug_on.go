@@ -0,0 +1,6 @@
+//go:build heapedcache_debug
+
+package utils
+
+// built with the heapedcache_debug tag: see debug.go
+const debugMode = true
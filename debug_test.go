@@ -0,0 +1,40 @@
+//go:build heapedcache_debug
+
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+)
+
+func TestDebugMode(t *testing.T) {
+
+    t.Log("validating TestDebugMode")
+
+    // reentrancy detection is on without the option
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    _, err := heapedCache.TryGetOrAdd(1, func(id int) *AccountTest {
+        return heapedCache.Get(2)
+    })
+    require.ErrorIs(t, err, ErrReentrantCall)
+
+    // removed items are poisoned
+    heapedCache.Push(1, NewAccountTest(1))
+    item := heapedCache.mapItems[1]
+    heapedCache.Remove(1)
+
+    require.Equal(t, poisonedIndex, item.index)
+    require.Panics(t, func() { item.assertLive() })
+
+    // the invariants are verified when the lock is released
+    heapedCache.Push(2, NewAccountTest(2))
+
+    heapedCache.mu.Lock()
+    delete(heapedCache.mapItems, 2)
+    heapedCache.mu.Unlock()
+
+    require.Error(t, heapedCache.CheckInvariants())
+    require.Panics(t, func() { heapedCache.Push(3, NewAccountTest(3)) })
+
+}
@@ -0,0 +1,73 @@
+package utils
+
+import "time"
+
+// heap.Fix calls of updated items deferred by WithDeferredFix
+type deferredFix struct {
+	pending bool // some items moved forward in time since the heap was last rebuilt
+}
+
+// defers the heap.Fix of items updated in place (Push on a cached id, Patch): the heap is
+// rebuilt once (heap.Init) at most maxDelay later, or before the next operation that
+// needs its order (Pop, eviction, Queue, Lease, Between, ...), whichever comes first.
+// Bursts of updates to existing keys then cost a single rebuild instead of one Fix each,
+// at the price of the rebuild being linear in the number of items. Close stops it
+func WithDeferredFix[TId comparable, TObj any](maxDelay time.Duration) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		if maxDelay <= 0 {
+			return
+		}
+
+		t.deferredFix = &deferredFix{}
+
+		t.every(taskFix, maxDelay, func() {
+
+			t.lock(opOther)
+			t.settle()
+			t.unlock()
+
+		})
+
+	}
+
+}
+
+// repositions an item whose refreshed time grew, now or deferred
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) fix(item *HeapedCacheItem[TId, TObj]) {
+
+	item.assertLive()
+
+	// the time it expires moved with its refreshed time
+	if t.expiries != nil {
+		t.expiries.fix(item)
+	}
+
+	if t.deferredFix != nil {
+		t.deferredFix.pending = true
+		return
+	}
+
+	t.sliceItems.fix(item.index)
+
+}
+
+// applies the deferred fixes, so the heap order can be relied upon
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) settle() {
+
+	if t.fixPending() {
+		t.sliceItems.init()
+		t.deferredFix.pending = false
+	}
+
+}
+
+// returns true while the heap order is not guaranteed
+func (t *HeapedCache[TId, TObj]) fixPending() bool {
+
+	return t.deferredFix != nil && t.deferredFix.pending
+
+}
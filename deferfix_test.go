@@ -0,0 +1,62 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestDeferredFix(t *testing.T) {
+
+    t.Log("validating TestDeferredFix")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache(5, 1, clock, WithDeferredFix[int, AccountTest](time.Hour))
+    defer heapedCache.Close()
+
+    for i := range 5 {
+        clock.Advance(time.Second)
+        heapedCache.Push(i, NewAccountTest(i))
+    }
+
+    // updates of 0 and 1 leave the heap unsettled
+    clock.Advance(time.Second)
+    heapedCache.Push(0, NewAccountTest(0))
+    heapedCache.Patch(1, func(obj *AccountTest) {})
+
+    require.True(t, heapedCache.fixPending())
+    require.NoError(t, heapedCache.CheckInvariants())
+
+    // evicting settles it first, so 2 is the oldest
+    heapedCache.Push(5, NewAccountTest(5))
+    require.False(t, heapedCache.fixPending())
+    require.Nil(t, heapedCache.Get(2))
+
+    heapedCache.Push(3, NewAccountTest(3))
+    require.Equal(t, 4, heapedCache.Pop().Id)
+    require.Equal(t, 0, heapedCache.Pop().Id)
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
+
+func TestDeferredFixInterval(t *testing.T) {
+
+    t.Log("validating TestDeferredFixInterval")
+
+    heapedCache := NewHeapedCache(5, WithDeferredFix[int, AccountTest](10*time.Millisecond))
+    defer heapedCache.Close()
+
+    heapedCache.Push(0, NewAccountTest(0))
+    heapedCache.Push(1, NewAccountTest(1))
+    heapedCache.Push(0, NewAccountTest(0))
+
+    require.Eventually(t, func() bool {
+
+        heapedCache.lock(opOther)
+        defer heapedCache.unlock()
+
+        return !heapedCache.fixPending()
+
+    }, time.Second, 5*time.Millisecond)
+
+}
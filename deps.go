@@ -0,0 +1,113 @@
+package utils
+
+import "slices"
+
+// dependency graph between cached items
+type dependencies[TId comparable] struct {
+	dependents map[TId]map[TId]struct{} // dependency -> items derived from it
+	of         map[TId][]TId            // item -> its dependencies
+}
+
+// Adds or updates an item (like Push) that is derived from the items of the given ids.
+// When any of them is updated or leaves the cache, this item is removed too,
+// and so on transitively. Replaces the dependencies registered before for the same id
+func (t *HeapedCache[TId, TObj]) PushWithDeps(id TId, item *TObj, deps ...TId) *TObj {
+
+	id = t.key(id)
+
+	// kept after the call, so the caller may reuse its slice
+	deps = slices.Clone(deps)
+
+	for i, dep := range deps {
+		deps[i] = t.key(dep)
+	}
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	result, _ := t.push(id, item)
+
+	if result == nil || t.mapItems[id] == nil {
+		return result
+	}
+
+	if t.deps == nil {
+		t.deps = &dependencies[TId]{
+			dependents: make(map[TId]map[TId]struct{}),
+			of:         make(map[TId][]TId),
+		}
+	}
+
+	t.deps.forget(id)
+
+	for _, dep := range deps {
+
+		if dep == id {
+			continue
+		}
+
+		if t.deps.dependents[dep] == nil {
+			t.deps.dependents[dep] = make(map[TId]struct{})
+		}
+
+		t.deps.dependents[dep][id] = struct{}{}
+
+	}
+
+	t.deps.of[id] = deps
+
+	return result
+
+}
+
+// unregisters the dependencies of an item
+func (d *dependencies[TId]) forget(id TId) {
+
+	for _, dep := range d.of[id] {
+
+		delete(d.dependents[dep], id)
+
+		if len(d.dependents[dep]) == 0 {
+			delete(d.dependents, dep)
+		}
+
+	}
+
+	delete(d.of, id)
+
+}
+
+// removes the items derived from the given id (transitively, through itemRemoved)
+func (t *HeapedCache[TId, TObj]) invalidateDependents(id TId) {
+
+	dependents := make([]TId, 0, len(t.deps.dependents[id]))
+
+	for dependent := range t.deps.dependents[id] {
+		dependents = append(dependents, dependent)
+	}
+
+	seededOrder(t.random, dependents, func(dependent TId) uint64 {
+
+		if item := t.mapItems[dependent]; item != nil {
+			return item.seq
+		}
+
+		return 0
+
+	})
+
+	for _, dependent := range dependents {
+
+		item := t.mapItems[dependent]
+
+		if item == nil {
+			continue
+		}
+
+		t.removeItem(item)
+		t.record(OpRemove, dependent, OutcomeInvalidated)
+		t.itemRemoved(item)
+
+	}
+
+}
@@ -0,0 +1,143 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "sync"
+    "testing"
+)
+
+func TestPushWithDeps(t *testing.T) {
+
+    t.Log("validating TestPushWithDeps")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    heapedCache.Push(1, NewAccountTest(1))
+    heapedCache.Push(2, NewAccountTest(2))
+    heapedCache.PushWithDeps(3, NewAccountTest(3), 1, 2)
+    heapedCache.PushWithDeps(4, NewAccountTest(4), 3)
+    heapedCache.PushWithDeps(5, NewAccountTest(5), 2)
+
+    // updating 1 invalidates 3 and, transitively, 4
+    heapedCache.Push(1, NewAccountTest(1))
+
+    require.Nil(t, heapedCache.Get(3))
+    require.Nil(t, heapedCache.Get(4))
+    require.NotNil(t, heapedCache.Get(5))
+    require.NoError(t, heapedCache.CheckInvariants())
+
+    // removing 2 invalidates 5
+    require.True(t, heapedCache.Remove(2))
+    require.Nil(t, heapedCache.Get(5))
+    require.Equal(t, 1, heapedCache.Len())
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
+
+func TestPushWithDepsEviction(t *testing.T) {
+
+    t.Log("validating TestPushWithDepsEviction")
+
+    heapedCache := NewHeapedCache[int, AccountTest](3)
+
+    heapedCache.Push(1, NewAccountTest(1))
+    heapedCache.PushWithDeps(2, NewAccountTest(2), 1)
+    heapedCache.Push(3, NewAccountTest(3))
+
+    // evicting 1 invalidates 2
+    heapedCache.Push(4, NewAccountTest(4))
+
+    require.Nil(t, heapedCache.Get(1))
+    require.Nil(t, heapedCache.Get(2))
+    require.Equal(t, 2, heapedCache.Len())
+
+}
+
+func TestPushWithDepsReplaced(t *testing.T) {
+
+    t.Log("validating TestPushWithDepsReplaced")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    heapedCache.Push(1, NewAccountTest(1))
+    heapedCache.Push(2, NewAccountTest(2))
+    heapedCache.PushWithDeps(3, NewAccountTest(3), 1)
+    heapedCache.PushWithDeps(3, NewAccountTest(3), 2)
+
+    heapedCache.Remove(1)
+    require.NotNil(t, heapedCache.Get(3))
+
+    heapedCache.Remove(2)
+    require.Nil(t, heapedCache.Get(3))
+
+}
+
+func TestPushWithDepsCycle(t *testing.T) {
+
+    t.Log("validating TestPushWithDepsCycle")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    heapedCache.PushWithDeps(1, NewAccountTest(1), 2)
+    heapedCache.PushWithDeps(2, NewAccountTest(2), 1)
+
+    heapedCache.Remove(1)
+
+    require.Equal(t, 0, heapedCache.Len())
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
+
+func TestPushWithDepsSliceReuse(t *testing.T) {
+
+    t.Log("validating TestPushWithDepsSliceReuse")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    heapedCache.Push(1, NewAccountTest(1))
+    heapedCache.Push(2, NewAccountTest(2))
+
+    deps := []int{1}
+    heapedCache.PushWithDeps(3, NewAccountTest(3), deps...)
+
+    // the caller reusing its slice does not change the dependencies
+    deps[0] = 2
+    heapedCache.Push(2, NewAccountTest(2))
+    require.NotNil(t, heapedCache.Get(3))
+
+    heapedCache.Push(1, NewAccountTest(1))
+    require.Nil(t, heapedCache.Get(3))
+
+}
+
+func TestClearDependents(t *testing.T) {
+
+    t.Log("validating TestClearDependents")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    var mu sync.Mutex
+    wiped := make(map[int]bool)
+
+    heapedCache.OnEvict(func(id int, obj *AccountTest) {
+        mu.Lock()
+        defer mu.Unlock()
+        wiped[id] = true
+    })
+
+    // the dependents are older, so the dependency is wiped first
+    heapedCache.PushWithDeps(1, NewAccountTest(1), 3)
+    heapedCache.PushWithDeps(2, NewAccountTest(2), 3)
+    heapedCache.Push(3, NewAccountTest(3))
+
+    // the dependents are wiped as the others, counted and reported
+    require.Equal(t, 3, heapedCache.Clear())
+    require.Equal(t, map[int]bool{1: true, 2: true, 3: true}, wiped)
+
+    // the dependencies are gone with them
+    heapedCache.Push(1, NewAccountTest(1))
+    heapedCache.Push(3, NewAccountTest(3))
+    heapedCache.Push(3, NewAccountTest(3))
+    require.NotNil(t, heapedCache.Get(1))
+
+}
@@ -0,0 +1,150 @@
+package utils
+
+// number of children of every node of the heap of the cache: a 4-ary heap is half as deep
+// as a binary one, so pushes sift up through fewer levels, and the children compared when
+// sifting down sit next to each other in memory
+const heapArity = 4
+
+// returns the position of the parent of the item at position i (i > 0)
+func heapParent(i int) int {
+
+	return (i - 1) / heapArity
+
+}
+
+// returns the position of the first child of the item at position i
+func heapFirstChild(i int) int {
+
+	return heapArity*i + 1
+
+}
+
+// the methods below implement the d-ary heap used by the cache itself. They work on the items
+// directly, without the interface conversions of container/heap (whose Push and Pop box
+// every item), while Len, Less, Swap, Push and Pop remain for heap.Interface users
+
+// adds an item to the heap
+func (h *HeapedCacheItems[TId, TObj]) push(item *HeapedCacheItem[TId, TObj]) {
+
+	item.index = len(*h)
+	*h = append(*h, item)
+	h.up(item.index)
+
+}
+
+// removes and returns the oldest item
+func (h *HeapedCacheItems[TId, TObj]) pop() *HeapedCacheItem[TId, TObj] {
+
+	n := len(*h) - 1
+	h.Swap(0, n)
+	h.down(0, n)
+
+	return h.removeLast()
+
+}
+
+// removes and returns the item at position i
+func (h *HeapedCacheItems[TId, TObj]) remove(i int) *HeapedCacheItem[TId, TObj] {
+
+	n := len(*h) - 1
+
+	if n != i {
+
+		h.Swap(i, n)
+
+		if !h.down(i, n) {
+			h.up(i)
+		}
+
+	}
+
+	return h.removeLast()
+
+}
+
+// restores the heap order after the item at position i changed
+func (h *HeapedCacheItems[TId, TObj]) fix(i int) {
+
+	if !h.down(i, len(*h)) {
+		h.up(i)
+	}
+
+}
+
+// establishes the heap order of all the items, in linear time
+func (h *HeapedCacheItems[TId, TObj]) init() {
+
+	n := len(*h)
+
+	for i := heapParent(n - 1); i >= 0; i-- {
+		h.down(i, n)
+	}
+
+}
+
+func (h *HeapedCacheItems[TId, TObj]) up(j int) {
+
+	for j > 0 {
+
+		i := heapParent(j)
+
+		if !h.Less(j, i) {
+			break
+		}
+
+		h.Swap(i, j)
+		j = i
+
+	}
+
+}
+
+// sifts the item at position i0 down among the first n items
+// returns true when it moved
+func (h *HeapedCacheItems[TId, TObj]) down(i0 int, n int) bool {
+
+	i := i0
+
+	for {
+
+		first := heapFirstChild(i)
+
+		if first >= n || first < 0 { // first < 0 after an int overflow
+			break
+		}
+
+		j := first
+
+		for child := first + 1; child < min(first+heapArity, n); child++ {
+
+			if h.Less(child, j) {
+				j = child
+			}
+
+		}
+
+		if !h.Less(j, i) {
+			break
+		}
+
+		h.Swap(i, j)
+		i = j
+
+	}
+
+	return i > i0
+
+}
+
+// removes the last item of the slice and returns it
+func (h *HeapedCacheItems[TId, TObj]) removeLast() *HeapedCacheItem[TId, TObj] {
+
+	n := len(*h) - 1
+	item := (*h)[n]
+	(*h)[n] = nil   // don't stop the GC from reclaiming the item eventually
+	item.index = -1 // for safety
+	*h = (*h)[0:n]
+
+	return item
+
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+    "container/heap"
+    "fmt"
+    "github.com/stretchr/testify/require"
+    "math/rand"
+    "testing"
+    "time"
+)
+
+func newHeapItems(n int, random *rand.Rand) HeapedCacheItems[int, AccountTest] {
+
+    start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+    items := make(HeapedCacheItems[int, AccountTest], n)
+
+    for i := range items {
+        items[i] = &HeapedCacheItem[int, AccountTest]{Id: i, index: i, Refreshed: start.Add(time.Duration(random.Intn(n)) * time.Second), seq: uint64(i)}
+    }
+
+    return items
+
+}
+
+func TestDaryHeap(t *testing.T) {
+
+    t.Log("validating TestDaryHeap")
+
+    random := rand.New(rand.NewSource(1))
+    items := newHeapItems(1000, random)
+    items.init()
+
+    for range 200 {
+        items.remove(random.Intn(len(items)))
+    }
+
+    for i := range 100 {
+        item := items[random.Intn(len(items))]
+        item.Refreshed = item.Refreshed.Add(time.Duration(random.Intn(1000)) * time.Second)
+        item.seq = uint64(1000 + i)
+        items.fix(item.index)
+    }
+
+    for i, item := range items {
+        require.Equal(t, i, item.index)
+    }
+
+    previous := items.pop()
+
+    for len(items) > 0 {
+        item := items.pop()
+        require.False(t, item.Refreshed.Before(previous.Refreshed))
+        previous = item
+    }
+
+}
+
+// pops the oldest item and pushes it back as the newest, the churn of a full cache
+func BenchmarkHeap(b *testing.B) {
+
+    for _, n := range []int{10_000, 1_000_000} {
+
+        b.Run(fmt.Sprintf("container/heap/%d", n), func(b *testing.B) {
+
+            items := newHeapItems(n, rand.New(rand.NewSource(1)))
+            heap.Init(&items)
+            b.ResetTimer()
+
+            for i := range b.N {
+                item := heap.Pop(&items).(*HeapedCacheItem[int, AccountTest])
+                item.Refreshed = item.Refreshed.Add(time.Duration(n) * time.Second)
+                item.seq = uint64(n + i)
+                heap.Push(&items, item)
+            }
+
+        })
+
+        b.Run(fmt.Sprintf("4-ary/%d", n), func(b *testing.B) {
+
+            items := newHeapItems(n, rand.New(rand.NewSource(1)))
+            items.init()
+            b.ResetTimer()
+
+            for i := range b.N {
+                item := items.pop()
+                item.Refreshed = item.Refreshed.Add(time.Duration(n) * time.Second)
+                item.seq = uint64(n + i)
+                items.push(item)
+            }
+
+        })
+
+    }
+
+}
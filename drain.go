@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// destination of the items drained by DrainAll
+type Sink[TObj any] interface {
+	Write(ctx context.Context, obj *TObj) error
+}
+
+// adapter allowing a plain function to be used as a Sink
+type SinkFunc[TObj any] func(ctx context.Context, obj *TObj) error
+
+func (f SinkFunc[TObj]) Write(ctx context.Context, obj *TObj) error {
+
+	return f(ctx, obj)
+
+}
+
+// attempts of a write to the sink of DrainAll, and the pause before the second one
+// (doubled before each of the next ones)
+const (
+	drainAttempts = 3
+	drainBackoff  = 10 * time.Millisecond
+)
+
+// pops every item, oldest first, and writes it to sink from parallelism goroutines
+// (items are handed out in order; with a parallelism of 1 they are also written in order).
+// A failed write is retried up to drainAttempts times; when it still fails, the item is
+// put back in its original position and the drain stops with the error, so no item is
+// dropped: each one is either written once or left in the cache. A panicking sink counts
+// as a failed write. Stops as well when ctx is done, returning its error.
+// returns the number of items written
+func (t *HeapedCache[TId, TObj]) DrainAll(ctx context.Context, sink Sink[TObj], parallelism int) (int, error) {
+
+	drainCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	var written atomic.Int64
+	var failure error
+	var failureOnce sync.Once
+	var wg sync.WaitGroup
+
+	for range max(parallelism, 1) {
+
+		wg.Add(1)
+
+		go t.labeled(drainCtx, "drain", func() {
+
+			defer wg.Done()
+
+			for drainCtx.Err() == nil {
+
+				item := t.take()
+
+				if item == nil {
+					return
+				}
+
+				if err := t.write(drainCtx, sink, item.obj); err != nil {
+
+					t.lock(OpPush)
+					t.restore(item)
+					t.unlock()
+
+					failureOnce.Do(func() { failure = err })
+					stop()
+
+					return
+
+				}
+
+				written.Add(1)
+
+			}
+
+		})
+
+	}
+
+	wg.Wait()
+
+	if failure == nil {
+		failure = ctx.Err()
+	}
+
+	return int(written.Load()), failure
+
+}
+
+// pops the oldest item, returning nil when the cache is empty
+func (t *HeapedCache[TId, TObj]) take() *HeapedCacheItem[TId, TObj] {
+
+	t.lock(OpPop)
+	defer t.unlock()
+
+	if len(t.sliceItems) == 0 {
+		return nil
+	}
+
+	t.settle()
+	item := t.sliceItems.pop()
+	delete(t.mapItems, item.Id)
+	t.record(OpPop, item.Id, OutcomePopped)
+	t.itemRemoved(item)
+
+	return item
+
+}
+
+// writes obj to sink, retrying with backoff
+func (t *HeapedCache[TId, TObj]) write(ctx context.Context, sink Sink[TObj], obj *TObj) error {
+
+	var err error
+	backoff := drainBackoff
+
+	for attempt := 1; ; attempt++ {
+
+		if panicErr := contain(&t.panics, func() { err = sink.Write(ctx, obj) }); panicErr != nil {
+			err = panicErr
+		}
+
+		if err == nil || attempt == drainAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+
+	}
+
+}
@@ -0,0 +1,128 @@
+package utils
+
+import (
+    "context"
+    "errors"
+    "github.com/stretchr/testify/require"
+    "sync"
+    "testing"
+)
+
+func TestDrainAll(t *testing.T) {
+
+    t.Log("validating TestDrainAll")
+
+    heapedCache := NewHeapedCache[int, AccountTest](100)
+
+    for i := range 100 {
+        heapedCache.Push(i, NewAccountTest(i))
+    }
+
+    var mu sync.Mutex
+    written := make(map[int]int)
+    failed := make(map[int]bool)
+
+    sink := SinkFunc[AccountTest](func(ctx context.Context, obj *AccountTest) error {
+
+        mu.Lock()
+        defer mu.Unlock()
+
+        // every tenth item fails once, then succeeds when retried
+        if obj.Id%10 == 0 && !failed[obj.Id] {
+            failed[obj.Id] = true
+            return errors.New("temporary failure")
+        }
+
+        written[obj.Id]++
+        return nil
+
+    })
+
+    count, err := heapedCache.DrainAll(context.Background(), sink, 4)
+    require.NoError(t, err)
+    require.Equal(t, 100, count)
+    require.Len(t, written, 100)
+    require.Len(t, failed, 10)
+    require.Equal(t, 0, heapedCache.Len())
+
+    for _, times := range written {
+        require.Equal(t, 1, times)
+    }
+
+}
+
+func TestDrainAllFailure(t *testing.T) {
+
+    t.Log("validating TestDrainAllFailure")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    for i := range 10 {
+        heapedCache.Push(i, NewAccountTest(i))
+    }
+
+    var written []int
+
+    count, err := heapedCache.DrainAll(context.Background(), SinkFunc[AccountTest](func(ctx context.Context, obj *AccountTest) error {
+
+        if obj.Id == 3 {
+            panic("sink is down")
+        }
+
+        written = append(written, obj.Id)
+        return nil
+
+    }), 1)
+
+    var panicErr *PanicError
+    require.ErrorAs(t, err, &panicErr)
+    require.Equal(t, 3, count)
+    require.Equal(t, []int{0, 1, 2}, written)
+
+    // the failed item is back in front, nothing was dropped
+    require.Equal(t, 7, heapedCache.Len())
+    id, _, _ := heapedCache.Queue().Peek()
+    require.Equal(t, 3, id)
+    require.NoError(t, heapedCache.CheckInvariants())
+
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+
+    count, err = heapedCache.DrainAll(ctx, SinkFunc[AccountTest](func(ctx context.Context, obj *AccountTest) error { return nil }), 2)
+    require.ErrorIs(t, err, context.Canceled)
+    require.Equal(t, 0, count)
+
+}
+
+func TestDrainAllAfterShutdown(t *testing.T) {
+
+    t.Log("validating TestDrainAllAfterShutdown")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    for i := range 5 {
+        heapedCache.Push(i, NewAccountTest(i))
+    }
+
+    _, err := heapedCache.OnShutdown(context.Background())
+    require.NoError(t, err)
+
+    count, err := heapedCache.DrainAll(context.Background(), SinkFunc[AccountTest](func(ctx context.Context, obj *AccountTest) error {
+
+        if obj.Id == 2 {
+            return errors.New("sink is down")
+        }
+
+        return nil
+
+    }), 1)
+
+    require.EqualError(t, err, "sink is down")
+    require.Equal(t, 2, count)
+
+    // the item of the failed write is back, even though intake is stopped
+    require.Equal(t, 3, heapedCache.Len())
+    require.NotNil(t, heapedCache.Get(2))
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
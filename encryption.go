@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// source of the AES keys sealing what the cache persists (see WithEncryption), e.g. backed by a KMS.
+// A key id must always name the same key: ids are written with the sealed lines to open them
+type KeyProvider interface {
+
+	// returns the key sealing new lines (16, 24 or 32 bytes, for AES-128, AES-192 or AES-256) and its id.
+	// Called for every snapshot and every write of the write-ahead log, so it should return a key kept in memory
+	CurrentKey() (id string, key []byte, err error)
+
+	// returns the key of an id, to open the lines sealed with it (keys rotated out included)
+	Key(id string) ([]byte, error)
+}
+
+// KeyProvider of a single key
+type staticKey struct {
+	id  string
+	key []byte
+}
+
+// returns a KeyProvider sealing and opening with a single key
+func StaticKey(id string, key []byte) KeyProvider {
+
+	return &staticKey{id: id, key: bytes.Clone(key)}
+
+}
+
+func (k *staticKey) CurrentKey() (string, []byte, error) {
+
+	return k.id, k.key, nil
+
+}
+
+func (k *staticKey) Key(id string) ([]byte, error) {
+
+	if id != k.id {
+		return nil, fmt.Errorf("heapedcache: unknown key %q", id)
+	}
+
+	return k.key, nil
+
+}
+
+// seals every line the cache persists with AES-GCM, using the keys of keys: snapshots (WriteSnapshot,
+// SaveSnapshot, SnapshotAll, Checkpoint, the shutdown snapshot), the write-ahead log of Recover and
+// the snapshot chains of SaveIncremental, so no object is written to disk or to a bucket in plaintext.
+// Each line is sealed on its own with a random nonce, so a log torn by a crash loses its last line only.
+// Recover, LoadSnapshot, LoadSnapshotChain and HandoffHandler open them, along with the plaintext lines
+// written before encryption was enabled (sealed by the next snapshot); pass it to ReadSnapshot as well.
+// Handoff streams plaintext to its peer: use an https url
+func WithEncryption[TId comparable, TObj any](keys KeyProvider) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		t.sealer = newSealer(keys)
+
+	}
+
+}
+
+// start of a sealed line, told apart from the plaintext ones (which start with "id" or "op")
+var sealedPrefix = []byte(`{"sealed":`)
+
+// line persisted by WithEncryption
+type sealedLine struct {
+	Sealed []byte `json:"sealed"` // nonce followed by the sealed plaintext line
+	Key    string `json:"key"`    // id of the key it was sealed with
+}
+
+// seals and opens the persisted lines (see WithEncryption)
+type sealer struct {
+	keys  KeyProvider
+	mu    sync.Mutex
+	aeads map[string]cipher.AEAD // by key id
+}
+
+// conctructor of the sealer
+func newSealer(keys KeyProvider) *sealer {
+
+	return &sealer{keys: keys, aeads: make(map[string]cipher.AEAD)}
+
+}
+
+// returns the cipher of a key, created once per key id
+func (s *sealer) aead(id string, key []byte) (cipher.AEAD, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if aead, ok := s.aeads[id]; ok {
+		return aead, nil
+	}
+
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("heapedcache: key %q: %w", id, err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.aeads[id] = aead
+
+	return aead, nil
+
+}
+
+// returns the cipher of the current key and its id
+func (s *sealer) current() (string, cipher.AEAD, error) {
+
+	id, key, err := s.keys.CurrentKey()
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	aead, err := s.aead(id, key)
+
+	return id, aead, err
+
+}
+
+// seals every line of lines (each one ending with a newline) with the cipher of key id
+func (s *sealer) seal(id string, aead cipher.AEAD, lines []byte) ([]byte, error) {
+
+	var result bytes.Buffer
+
+	encoder := json.NewEncoder(&result)
+
+	for len(lines) > 0 {
+
+		end := bytes.IndexByte(lines, '\n') + 1
+
+		if end == 0 {
+			end = len(lines)
+		}
+
+		nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+end+aead.Overhead())
+
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+
+		if err := encoder.Encode(sealedLine{Sealed: aead.Seal(nonce, nonce, lines[:end], []byte(id)), Key: id}); err != nil {
+			return nil, err
+		}
+
+		lines = lines[end:]
+
+	}
+
+	return result.Bytes(), nil
+
+}
+
+// returns the plaintext of a sealed line
+func (s *sealer) open(line []byte) ([]byte, error) {
+
+	var sealed sealedLine
+
+	if err := json.Unmarshal(line, &sealed); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	aead, ok := s.aeads[sealed.Key]
+	s.mu.Unlock()
+
+	if !ok {
+
+		key, err := s.keys.Key(sealed.Key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if aead, err = s.aead(sealed.Key, key); err != nil {
+			return nil, err
+		}
+
+	}
+
+	if len(sealed.Sealed) < aead.NonceSize() {
+		return nil, errors.New("heapedcache: sealed line too short")
+	}
+
+	nonce, ciphertext := sealed.Sealed[:aead.NonceSize()], sealed.Sealed[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, ciphertext, []byte(sealed.Key))
+
+}
+
+// writer sealing the lines written to it with a single key
+type sealingWriter struct {
+	sealer  *sealer
+	id      string
+	aead    cipher.AEAD
+	w       io.Writer
+	pending []byte // start of a line not complete yet
+}
+
+func (w *sealingWriter) Write(p []byte) (int, error) {
+
+	w.pending = append(w.pending, p...)
+	end := bytes.LastIndexByte(w.pending, '\n') + 1
+
+	if end == 0 {
+		return len(p), nil
+	}
+
+	sealed, err := w.sealer.seal(w.id, w.aead, w.pending[:end])
+
+	if err != nil {
+		return 0, err
+	}
+
+	w.pending = append(w.pending[:0], w.pending[end:]...)
+
+	if _, err := w.w.Write(sealed); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+
+}
+
+// returns a writer sealing the lines written to w with the current key (see WithEncryption),
+// w itself when the cache does not encrypt
+func (t *HeapedCache[TId, TObj]) sealing(w io.Writer) (io.Writer, error) {
+
+	return sealingTo(t.sealer, w)
+
+}
+
+// returns a writer sealing the lines written to w with the current key of s, w itself when s is nil
+func sealingTo(s *sealer, w io.Writer) (io.Writer, error) {
+
+	if s == nil {
+		return w, nil
+	}
+
+	id, aead, err := s.current()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &sealingWriter{sealer: s, id: id, aead: aead, w: w}, nil
+
+}
+
+// returns the plaintext of a persisted line: the line itself when it is not sealed
+// returns false when it is sealed and cannot be opened (no encryption, unknown key or tampered line)
+func openLine(s *sealer, line []byte) ([]byte, bool) {
+
+	if !bytes.HasPrefix(line, sealedPrefix) {
+		return line, true
+	}
+
+	if s == nil {
+		return nil, false
+	}
+
+	opened, err := s.open(line)
+
+	return opened, err == nil
+
+}
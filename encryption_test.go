@@ -0,0 +1,204 @@
+package utils
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "github.com/stretchr/testify/require"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+// KeyProvider keeping every key, sealing with the last one added
+type rotatingKeys struct {
+    current string
+    keys    map[string][]byte
+}
+
+func (k *rotatingKeys) add(id string) {
+
+    if k.keys == nil {
+        k.keys = make(map[string][]byte)
+    }
+
+    k.keys[id] = bytes.Repeat([]byte(id[:1]), 32)
+    k.current = id
+
+}
+
+func (k *rotatingKeys) CurrentKey() (string, []byte, error) {
+
+    return k.current, k.keys[k.current], nil
+
+}
+
+func (k *rotatingKeys) Key(id string) ([]byte, error) {
+
+    if key, ok := k.keys[id]; ok {
+        return key, nil
+    }
+
+    return nil, fmt.Errorf("unknown key %q", id)
+
+}
+
+// requires a persisted file without any plaintext of the objects
+func requireSealed(t *testing.T, content []byte) {
+
+    require.NotEmpty(t, content)
+    require.NotContains(t, string(content), "EMERSON")
+    require.NotContains(t, string(content), "PHONE")
+
+}
+
+func TestEncryptedRecover(t *testing.T) {
+
+    t.Log("validating TestEncryptedRecover")
+
+    dir := t.TempDir()
+    snapshotPath := filepath.Join(dir, "cache.ndjson")
+    walPath := filepath.Join(dir, "cache.wal")
+
+    keys := &rotatingKeys{}
+    keys.add("a-2024")
+
+    heapedCache := NewHeapedCache(10, WithEncryption[int, AccountTest](keys))
+
+    _, err := heapedCache.Recover(snapshotPath, walPath)
+    require.NoError(t, err)
+
+    heapedCache.Push(1, NewAccountTest(1))
+    require.NoError(t, heapedCache.Checkpoint(context.Background()))
+
+    // rotated: the log is sealed with the new key, the snapshot keeps the old one
+    keys.add("b-2025")
+
+    heapedCache.Push(2, NewAccountTest(2))
+    require.NoError(t, heapedCache.WALError())
+
+    for _, path := range []string{snapshotPath, walPath} {
+
+        content, err := os.ReadFile(path)
+        require.NoError(t, err)
+        requireSealed(t, content)
+
+    }
+
+    recovered := NewHeapedCache(10, WithEncryption[int, AccountTest](keys))
+
+    report, err := recovered.Recover(snapshotPath, walPath)
+    require.NoError(t, err)
+    require.Equal(t, RecoveryReport{Restored: 1, Replayed: 1}, report)
+    require.Equal(t, "PHONE 2", recovered.Get(2).Phone)
+
+    // without the keys, nothing can be read (on copies, as Recover checkpoints what it loaded)
+    for _, other := range []*HeapedCache[int, AccountTest]{
+        NewHeapedCache[int, AccountTest](10),
+        NewHeapedCache(10, WithEncryption[int, AccountTest](StaticKey("b-2025", bytes.Repeat([]byte("x"), 32)))),
+    } {
+
+        content, err := os.ReadFile(snapshotPath)
+        require.NoError(t, err)
+
+        copied := filepath.Join(t.TempDir(), "cache.ndjson")
+        require.NoError(t, os.WriteFile(copied, content, 0o600))
+
+        report, err = other.Recover(copied, copied+".wal")
+        require.NoError(t, err)
+        require.Equal(t, RecoveryReport{Corrupt: 2}, report)
+
+    }
+
+}
+
+func TestEncryptedSnapshot(t *testing.T) {
+
+    t.Log("validating TestEncryptedSnapshot")
+
+    ctx := context.Background()
+    keys := StaticKey("k1", bytes.Repeat([]byte("k"), 16))
+    store := &memoryStore{}
+
+    heapedCache := NewHeapedCache(10, WithEncryption[int, AccountTest](keys))
+
+    for i := range 3 {
+        heapedCache.Push(i, NewAccountTest(i))
+    }
+
+    // plaintext written before encryption was enabled is still read
+    plain := NewHeapedCache[int, AccountTest](10)
+    plain.Push(3, NewAccountTest(3))
+    require.NoError(t, plain.SaveSnapshot(ctx, store, "plain.ndjson"))
+
+    require.NoError(t, heapedCache.SaveSnapshot(ctx, store, "accounts.ndjson"))
+    requireSealed(t, store.objects["accounts.ndjson"])
+
+    report, err := heapedCache.LoadSnapshot(ctx, store, "plain.ndjson")
+    require.NoError(t, err)
+    require.Equal(t, RecoveryReport{Restored: 1}, report)
+
+    loaded := NewHeapedCache(10, WithEncryption[int, AccountTest](keys))
+
+    report, err = loaded.LoadSnapshot(ctx, store, "accounts.ndjson")
+    require.NoError(t, err)
+    require.Equal(t, RecoveryReport{Restored: 3}, report)
+
+    var buf bytes.Buffer
+
+    require.NoError(t, heapedCache.WriteSnapshot(&buf))
+    requireSealed(t, buf.Bytes())
+
+    entries, err := ReadSnapshot(bytes.NewReader(buf.Bytes()), WithEncryption[int, AccountTest](keys))
+    require.NoError(t, err)
+    require.Len(t, entries, 4)
+
+    _, err = ReadSnapshot[int, AccountTest](bytes.NewReader(buf.Bytes()))
+    require.EqualError(t, err, "snapshot item 1: invalid line")
+
+    // the registry writes the snapshots of the caches sealed
+    registry := NewRegistry()
+    require.NoError(t, registry.Register("accounts", heapedCache))
+    require.NoError(t, registry.SnapshotAllTo(ctx, store, "snapshots/"))
+    requireSealed(t, store.objects["snapshots/accounts.ndjson"])
+
+}
+
+func TestEncryptedSnapshotChain(t *testing.T) {
+
+    t.Log("validating TestEncryptedSnapshotChain")
+
+    dir := t.TempDir()
+    ctx := context.Background()
+    keys := StaticKey("k1", bytes.Repeat([]byte("k"), 24))
+
+    heapedCache := NewHeapedCache(10, WithIncrementalSnapshots[int, AccountTest](), WithEncryption[int, AccountTest](keys))
+
+    heapedCache.Push(1, NewAccountTest(1))
+
+    _, err := heapedCache.SaveIncremental(ctx, dir)
+    require.NoError(t, err)
+
+    heapedCache.Push(2, NewAccountTest(2))
+
+    delta, err := heapedCache.SaveIncremental(ctx, dir)
+    require.NoError(t, err)
+
+    content, err := os.ReadFile(delta)
+    require.NoError(t, err)
+    requireSealed(t, content)
+
+    require.Error(t, CompactSnapshotChain[int](dir))
+    require.NoError(t, CompactSealedSnapshotChain[int](dir, keys))
+
+    content, err = os.ReadFile(filepath.Join(dir, "base.ndjson"))
+    require.NoError(t, err)
+    requireSealed(t, content)
+
+    loaded := NewHeapedCache(10, WithIncrementalSnapshots[int, AccountTest](), WithEncryption[int, AccountTest](keys))
+
+    report, err := loaded.LoadSnapshotChain(dir)
+    require.NoError(t, err)
+    require.Equal(t, RecoveryReport{Restored: 2}, report)
+
+}
@@ -0,0 +1,49 @@
+package utils
+
+import "time"
+
+// per-call settings of GetOrAddOpts, for call paths of the same cache needing different ones
+type EntryOptions struct {
+	Cost    int64 // cost of the loaded item, instead of the one computed by WithCost (0: computed)
+	NoStore bool  // the loaded object is returned without being cached
+
+	// time to live of the loaded item, instead of the one of the cache (0: the one of the cache, see PushWithTTL)
+	TTL time.Duration
+
+	// tags of the loaded item, so items loaded by different call paths can be removed together by InvalidateTag
+	// (kept until the item leaves the cache, not persisted)
+	Tags []string
+
+	loaded *loadResult // set by the loader of GetOrAddWithTTL or GetOrAddLoad, instead of the fields above
+}
+
+// same as TryGetOrAdd, with per-call settings applied to the item loaded by fn
+// (not to an item already cached, which is returned as is)
+func (t *HeapedCache[TId, TObj]) GetOrAddOpts(id TId, fn func(id TId) *TObj, opts EntryOptions) (*TObj, error) {
+
+	return t.getOrAdd(nil, id, fn, opts)
+
+}
+
+// applies the settings of GetOrAddOpts to a freshly loaded item (nil when it was not cached)
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) applyEntryOptions(item *HeapedCacheItem[TId, TObj], opts EntryOptions) {
+
+	if item == nil {
+		return
+	}
+
+	if opts.Cost > 0 {
+		t.charge(opts.Cost - item.cost)
+		item.cost = opts.Cost
+	}
+
+	if opts.TTL > 0 {
+		t.setTTL(item, opts.TTL)
+	}
+
+	if len(opts.Tags) > 0 {
+		t.tagItem(item, opts.Tags)
+	}
+
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+)
+
+func TestGetOrAddOpts(t *testing.T) {
+
+    t.Log("validating TestGetOrAddOpts")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    loads := 0
+    load := func(id int) *AccountTest {
+        loads++
+        return NewAccountTest(id)
+    }
+
+    // not stored: every call loads
+    for range 2 {
+
+        obj, err := heapedCache.GetOrAddOpts(1, load, EntryOptions{NoStore: true})
+        require.NoError(t, err)
+        require.Equal(t, 1, obj.Id)
+
+    }
+
+    require.Equal(t, 2, loads)
+    require.Equal(t, 0, heapedCache.Len())
+
+    obj, err := heapedCache.GetOrAddOpts(2, load, EntryOptions{Cost: 50})
+    require.NoError(t, err)
+    require.Equal(t, 2, obj.Id)
+    require.Equal(t, int64(50), heapedCache.Cost())
+
+    // the settings only apply to loaded items
+    _, err = heapedCache.GetOrAddOpts(2, load, EntryOptions{Cost: 10, NoStore: true})
+    require.NoError(t, err)
+    require.Equal(t, 3, loads)
+    require.Equal(t, int64(50), heapedCache.Cost())
+
+}
+
+func TestGetOrAddOptsTags(t *testing.T) {
+
+    t.Log("validating TestGetOrAddOptsTags")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    load := func(id int) *AccountTest {
+        return NewAccountTest(id)
+    }
+
+    tags := []string{"tenant-a", "upstream", "tenant-a"}
+
+    for id := range 3 {
+        _, err := heapedCache.GetOrAddOpts(id, load, EntryOptions{Tags: tags})
+        require.NoError(t, err)
+    }
+
+    // the caller may reuse its slice
+    tags[0] = "tenant-b"
+
+    _, err := heapedCache.GetOrAddOpts(3, load, EntryOptions{Tags: tags[:1]})
+    require.NoError(t, err)
+
+    heapedCache.Push(4, NewAccountTest(4))
+    heapedCache.PushWithDeps(5, NewAccountTest(5), 0)
+
+    // the tags only apply to loaded items
+    _, err = heapedCache.GetOrAddOpts(4, load, EntryOptions{Tags: []string{"tenant-a"}})
+    require.NoError(t, err)
+
+    // a rekeyed item keeps its tags
+    require.True(t, heapedCache.Rekey(2, 20))
+
+    require.Equal(t, 3, heapedCache.InvalidateTag("tenant-a"))
+    require.Equal(t, 0, heapedCache.InvalidateTag("tenant-a"))
+    require.Equal(t, 0, heapedCache.InvalidateTag("upstream"))
+
+    // the dependents went along
+    for _, id := range []int{0, 1, 20, 5} {
+        require.Nil(t, heapedCache.Get(id))
+    }
+
+    require.Equal(t, 2, heapedCache.Len())
+
+    // a removed item is untagged
+    require.True(t, heapedCache.Remove(3))
+    heapedCache.Push(3, NewAccountTest(3))
+    require.Equal(t, 0, heapedCache.InvalidateTag("tenant-b"))
+    require.Equal(t, 2, heapedCache.Len())
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// layout of the expiry wheel: levels of 64 slots over ticks of a millisecond, a slot of a level
+// spanning a whole turn of the level below. The 6 levels cover 2^36 ms (about 795 days) ahead;
+// items expiring later wait in an overflow slot, spread again when the wheel gets there
+const (
+	wheelBits     = 6
+	wheelSlots    = 1 << wheelBits
+	wheelLevels   = 6
+	wheelOverflow = wheelLevels * wheelSlots
+)
+
+// hierarchical timer wheel of the items that expire, kept once items override the ttl of the cache
+// (with a single ttl, the oldest items expire first and the main heap is enough).
+// Adding, moving and removing an item cost O(1), and so does expiring one (amortized: an item is
+// moved down at most once per level), whatever the number of items; empty slots are skipped
+// through a bitmap per level, so the wheel never walks the cache to find what expired.
+// An item of level k expires in the same turn of level k+1 as the current tick, in a later
+// slot of level k (in the slot of the current tick for level 0)
+type expiryWheel[TId comparable, TObj any] struct {
+	cache    *HeapedCache[TId, TObj]
+	started  bool  // current was set from the clock
+	current  int64 // tick (milliseconds since the Unix epoch) the wheel is at
+	count    int
+	occupied [wheelLevels]uint64 // bitmap of the non empty slots of each level
+	slots    [wheelOverflow + 1][]*HeapedCacheItem[TId, TObj]
+}
+
+// returns the tick of a time
+func wheelTick(t time.Time) int64 {
+
+	return t.UnixMilli()
+
+}
+
+// replaces the items of the wheel by the given ones
+func (w *expiryWheel[TId, TObj]) rebuild(items []*HeapedCacheItem[TId, TObj]) {
+
+	for _, item := range items {
+		item.expirySlot = 0
+	}
+
+	w.slots = [wheelOverflow + 1][]*HeapedCacheItem[TId, TObj]{}
+	w.occupied = [wheelLevels]uint64{}
+	w.count = 0
+	w.started = false
+
+	for _, item := range items {
+		w.add(item)
+	}
+
+}
+
+// adds an item, unless it does not expire
+func (w *expiryWheel[TId, TObj]) add(item *HeapedCacheItem[TId, TObj]) {
+
+	deadline := w.cache.expiresAt(item)
+
+	if deadline.IsZero() {
+		return
+	}
+
+	if !w.started {
+		w.current = wheelTick(w.cache.now())
+		w.started = true
+	}
+
+	// an item already due goes to the slot of the current tick
+	slot := w.slotOf(max(wheelTick(deadline), w.current))
+
+	item.expirySlot = slot + 1
+	item.expiryIndex = len(w.slots[slot])
+	w.slots[slot] = append(w.slots[slot], item)
+	w.count++
+
+	if slot < wheelOverflow {
+		w.occupied[slot/wheelSlots] |= 1 << (slot % wheelSlots)
+	}
+
+}
+
+// removes an item, if it is in the wheel
+func (w *expiryWheel[TId, TObj]) remove(item *HeapedCacheItem[TId, TObj]) {
+
+	if item.expirySlot == 0 {
+		return
+	}
+
+	slot := item.expirySlot - 1
+	items := w.slots[slot]
+	last := len(items) - 1
+
+	items[item.expiryIndex] = items[last]
+	items[item.expiryIndex].expiryIndex = item.expiryIndex
+	items[last] = nil
+	w.slots[slot] = items[:last]
+	w.count--
+
+	item.expirySlot = 0
+
+	if last == 0 && slot < wheelOverflow {
+		w.occupied[slot/wheelSlots] &^= 1 << (slot % wheelSlots)
+	}
+
+}
+
+// moves an item whose expiry time changed
+func (w *expiryWheel[TId, TObj]) fix(item *HeapedCacheItem[TId, TObj]) {
+
+	w.remove(item)
+	w.add(item)
+
+}
+
+// returns the slot of a tick, not before the current one: the level is the first one
+// whose turn holds both the tick and the current one
+func (w *expiryWheel[TId, TObj]) slotOf(tick int64) int {
+
+	for level := range wheelLevels {
+
+		turn := wheelBits * (level + 1)
+
+		if tick>>turn == w.current>>turn {
+			return level*wheelSlots + int(tick>>(wheelBits*level)&(wheelSlots-1))
+		}
+
+	}
+
+	return wheelOverflow
+
+}
+
+// returns the first tick an item of the wheel may expire at (exact for level 0, the start of
+// its slot for the others), math.MaxInt64 when the wheel is empty
+func (w *expiryWheel[TId, TObj]) next() int64 {
+
+	for level := range wheelLevels {
+
+		shift := wheelBits * level
+		from := w.current>>shift&(wheelSlots-1) + 1
+
+		// the slot of the current tick only holds items at level 0
+		if level == 0 {
+			from--
+		}
+
+		if pending := w.occupied[level] >> from << from; pending != 0 {
+			turn := shift + wheelBits
+			return w.current>>turn<<turn | int64(bits.TrailingZeros64(pending))<<shift
+		}
+
+	}
+
+	if len(w.slots[wheelOverflow]) > 0 {
+		turn := wheelBits * wheelLevels
+		return (w.current>>turn + 1) << turn
+	}
+
+	return math.MaxInt64
+
+}
+
+// moves the wheel to a later tick, spreading to the levels below the slots it enters
+// (the slots it skips are empty)
+func (w *expiryWheel[TId, TObj]) moveTo(tick int64) {
+
+	previous := w.current
+	w.current = tick
+
+	if turn := wheelBits * wheelLevels; tick>>turn != previous>>turn {
+		w.spread(wheelOverflow)
+	}
+
+	for level := wheelLevels - 1; level > 0; level-- {
+		w.spread(level*wheelSlots + int(tick>>(wheelBits*level)&(wheelSlots-1)))
+	}
+
+}
+
+// adds again the items of a slot, which go to lower levels
+func (w *expiryWheel[TId, TObj]) spread(slot int) {
+
+	items := w.slots[slot]
+
+	if len(items) == 0 {
+		return
+	}
+
+	w.slots[slot] = nil
+	w.count -= len(items)
+
+	if slot < wheelOverflow {
+		w.occupied[slot/wheelSlots] &^= 1 << (slot % wheelSlots)
+	}
+
+	for _, item := range items {
+		item.expirySlot = 0
+		w.add(item)
+	}
+
+}
+
+// expires the items of the wheel expired at now, moving the wheel up to it
+// must be called under the lock
+func (w *expiryWheel[TId, TObj]) advance(now time.Time) {
+
+	target := wheelTick(now)
+
+	for {
+
+		next := w.next()
+
+		if next > target {
+
+			if w.started && target > w.current {
+				w.moveTo(target)
+			}
+
+			return
+
+		}
+
+		if next > w.current {
+			w.moveTo(next)
+		}
+
+		slot := int(w.current & (wheelSlots - 1))
+
+		var due []*HeapedCacheItem[TId, TObj]
+
+		for _, item := range w.slots[slot] {
+
+			if w.cache.expired(item, now) {
+				due = append(due, item)
+			}
+
+		}
+
+		for _, item := range due {
+
+			// expiring an item may remove others (see PushWithDeps)
+			if item.expirySlot == slot+1 {
+				w.cache.expireItem(item)
+			}
+
+		}
+
+		// the items left expire later in the current tick
+		if len(w.slots[slot]) > 0 {
+			return
+		}
+
+	}
+
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+    "fmt"
+    "github.com/stretchr/testify/require"
+    "math/rand"
+    "testing"
+    "time"
+)
+
+func TestExpiryWheel(t *testing.T) {
+
+    t.Log("validating TestExpiryWheel")
+
+    start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+    clock := NewFakeClock(start)
+    heapedCache := NewDeterministicHeapedCache[int, AccountTest](1000, 1, clock)
+    random := rand.New(rand.NewSource(1))
+
+    // ttls from milliseconds to beyond the levels of the wheel (about 795 days)
+    ttls := []time.Duration{time.Millisecond, 50 * time.Millisecond, time.Second, time.Minute, time.Hour, 24 * time.Hour, 1000 * 24 * time.Hour}
+    deadlines := make(map[int]time.Time)
+
+    for round := range 200 {
+
+        for range 20 {
+
+            id := random.Intn(500)
+            ttl := ttls[random.Intn(len(ttls))] + time.Duration(random.Intn(1000))*time.Microsecond
+
+            heapedCache.PushWithTTL(id, NewAccountTest(id), ttl)
+            deadlines[id] = clock.Now().Add(ttl)
+
+        }
+
+        // from a millisecond to a few days, so the wheel spreads its upper levels
+        steps := []time.Duration{time.Millisecond, time.Second, time.Minute, time.Hour, 72 * time.Hour}
+        clock.Advance(time.Duration(random.Int63n(int64(steps[round%len(steps)]))) + 1)
+
+        now := clock.Now()
+
+        for id, deadline := range deadlines {
+
+            if !now.Before(deadline) {
+                delete(deadlines, id)
+            }
+
+        }
+
+        // everything expired was purged when the lock was taken, nothing else was
+        require.Equal(t, len(deadlines), heapedCache.Len(), "round %d", round)
+        require.NoError(t, heapedCache.CheckInvariants())
+
+    }
+
+    // the items beyond the levels come back from the overflow slot in time
+    clock.Advance(2000 * 24 * time.Hour)
+
+    require.Equal(t, 0, heapedCache.Len())
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
+
+func TestExpiryWheelTTLChange(t *testing.T) {
+
+    t.Log("validating TestExpiryWheelTTLChange")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache(10, 1, clock, WithTTL[int, AccountTest](time.Hour))
+
+    heapedCache.Push(1, NewAccountTest(1))
+    heapedCache.PushWithTTL(2, NewAccountTest(2), time.Minute)
+
+    // shortening the ttl of the cache moves the items following it
+    require.NoError(t, heapedCache.ApplyConfig(Config{MaxRows: 10, TTL: Duration(2 * time.Minute)}))
+    require.NoError(t, heapedCache.CheckInvariants())
+
+    clock.Advance(time.Minute)
+    require.Equal(t, 1, heapedCache.Len())
+
+    clock.Advance(time.Minute)
+    require.Equal(t, 0, heapedCache.Len())
+
+}
+
+// adds items with their own ttl to a full cache while the older ones expire
+func BenchmarkExpiryWheel(b *testing.B) {
+
+    for _, n := range []int{10_000, 1_000_000} {
+
+        b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+
+            clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+            heapedCache := NewDeterministicHeapedCache[int, AccountTest](n, 1, clock)
+            obj := NewAccountTest(0)
+
+            for i := range n {
+                heapedCache.PushWithTTL(i, obj, time.Duration(n+i%1000)*time.Millisecond)
+                clock.Advance(time.Millisecond)
+            }
+
+            b.ResetTimer()
+
+            for i := range b.N {
+                heapedCache.PushWithTTL(n+i, obj, time.Duration(n+i%1000)*time.Millisecond)
+                clock.Advance(time.Millisecond)
+            }
+
+        })
+
+    }
+
+}
@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// default line written by ExportNDJSON when no projection is given
+type exportedItem[TId any, TObj any] struct {
+	Id        TId       `json:"id"`
+	Refreshed time.Time `json:"refreshed"`
+	Version   int       `json:"version,omitempty"` // see WithFormatVersion
+	Obj       *TObj     `json:"obj"`
+}
+
+// copies the cached items so they can be processed outside the lock
+func (t *HeapedCache[TId, TObj]) snapshot() []HeapedCacheItem[TId, TObj] {
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	return t.copyItems()
+
+}
+
+// copies the cached items
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) copyItems() []HeapedCacheItem[TId, TObj] {
+
+	result := make([]HeapedCacheItem[TId, TObj], len(t.sliceItems))
+
+	for i, item := range t.sliceItems {
+		result[i] = *item
+	}
+
+	return result
+
+}
+
+// writes every cached item to w as newline delimited JSON (one item per line).
+// project decides what is written for each item; when it is nil, the id,
+// the refreshed time and the object are written.
+// The items are copied under the lock and encoded after releasing it,
+// so a slow writer does not block the cache. Items come in heap order, not sorted.
+// Every item is written: unlike WriteSnapshot, the export ignores WithPersistFilter
+// and is not reported as a snapshot by Health. Ids and objects are masked by WithRedactor
+func (t *HeapedCache[TId, TObj]) ExportNDJSON(w io.Writer, project func(id TId, obj *TObj, refreshed time.Time) any) error {
+
+	_, err := t.exportNDJSON(context.Background(), w, project)
+	return err
+
+}
+
+// same as ExportNDJSON, stopping with the error of ctx when it is done
+// returns the number of items written
+func (t *HeapedCache[TId, TObj]) exportNDJSON(ctx context.Context, w io.Writer, project func(id TId, obj *TObj, refreshed time.Time) any) (int, error) {
+
+	return t.exportItems(ctx, w, t.snapshot(), project)
+
+}
+
+// writes copies of cached items to w, redacted (see exportNDJSON)
+func (t *HeapedCache[TId, TObj]) exportItems(ctx context.Context, w io.Writer, items []HeapedCacheItem[TId, TObj], project func(id TId, obj *TObj, refreshed time.Time) any) (int, error) {
+
+	return t.encodeItems(ctx, w, items, func(item *HeapedCacheItem[TId, TObj]) (any, bool) {
+
+		id, obj := t.redactedID(item.Id), t.redactedObj(item.obj)
+
+		if project == nil {
+			return exportedItem[TId, TObj]{Id: id, Refreshed: item.Refreshed, Version: t.formatVersion, Obj: obj}, true
+		}
+
+		return project(id, obj, item.Refreshed), true
+
+	})
+
+}
+
+// writes copies of cached items to w as a snapshot: the items left out by WithPersistFilter are skipped,
+// the lines are sealed under WithEncryption, and the time of the snapshot is kept for Health once it is complete
+func (t *HeapedCache[TId, TObj]) persistItems(ctx context.Context, w io.Writer, items []HeapedCacheItem[TId, TObj]) (int, error) {
+
+	w, err := t.sealing(w)
+
+	if err != nil {
+		return 0, err
+	}
+
+	written, err := t.encodeItems(ctx, w, items, func(item *HeapedCacheItem[TId, TObj]) (any, bool) {
+
+		return t.exportedLine(item), t.persisted(item.Id, item.obj)
+
+	})
+
+	if err == nil {
+		t.lastSnapshot.Store(t.now().UnixNano())
+	}
+
+	return written, err
+
+}
+
+// returns the line of an item in the snapshot format
+func (t *HeapedCache[TId, TObj]) exportedLine(item *HeapedCacheItem[TId, TObj]) exportedItem[TId, TObj] {
+
+	return exportedItem[TId, TObj]{Id: item.Id, Refreshed: item.Refreshed, Version: t.formatVersion, Obj: item.obj}
+
+}
+
+// writes the line returned by line for every copied item to w, skipping the items it returns false for
+// returns the number of lines written
+func (t *HeapedCache[TId, TObj]) encodeItems(ctx context.Context, w io.Writer, items []HeapedCacheItem[TId, TObj], line func(item *HeapedCacheItem[TId, TObj]) (any, bool)) (int, error) {
+
+	encoder := json.NewEncoder(w)
+	written := 0
+
+	for i := range items {
+
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+
+		value, ok := line(&items[i])
+
+		if !ok {
+			continue
+		}
+
+		if err := encoder.Encode(value); err != nil {
+			return written, err
+		}
+
+		written++
+
+	}
+
+	return written, nil
+
+}
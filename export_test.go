@@ -0,0 +1,95 @@
+package utils
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/json"
+    "errors"
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestExportNDJSON(t *testing.T) {
+
+    t.Log("validating TestExportNDJSON")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    for i := range 5 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    var buffer bytes.Buffer
+
+    err := heapedCache.ExportNDJSON(&buffer, func(id int, obj *AccountTest, refreshed time.Time) any {
+        return map[string]any{"id": id, "name": obj.Name}
+    })
+    require.NoError(t, err)
+
+    names := make(map[int]string)
+    scanner := bufio.NewScanner(&buffer)
+
+    for scanner.Scan() {
+
+        var line struct {
+            Id   int    `json:"id"`
+            Name string `json:"name"`
+        }
+
+        require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+        names[line.Id] = line.Name
+
+    }
+
+    require.Equal(t, 5, len(names))
+    require.Equal(t, "EMERSON 3", names[3])
+
+}
+
+func TestExportNDJSONDefault(t *testing.T) {
+
+    t.Log("validating TestExportNDJSONDefault")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    heapedCache.Push(7, NewAccountTest(7))
+
+    var buffer bytes.Buffer
+
+    require.NoError(t, heapedCache.ExportNDJSON(&buffer, nil))
+
+    var line struct {
+        Id        int          `json:"id"`
+        Refreshed time.Time    `json:"refreshed"`
+        Obj       *AccountTest `json:"obj"`
+    }
+
+    require.NoError(t, json.Unmarshal(buffer.Bytes(), &line))
+    require.Equal(t, 7, line.Id)
+    require.False(t, line.Refreshed.IsZero())
+    require.Equal(t, "PHONE 7", line.Obj.Phone)
+
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+
+    return 0, errors.New("write failed")
+
+}
+
+func TestExportNDJSONWriterError(t *testing.T) {
+
+    t.Log("validating TestExportNDJSONWriterError")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    heapedCache.Push(1, NewAccountTest(1))
+
+    require.Error(t, heapedCache.ExportNDJSON(failingWriter{}, nil))
+
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"container/heap"
+	"time"
+)
+
+// default hard cap multiplier of WithEvictionFilter
+const defaultHardCapMultiplier = 2
+
+// decides which items may be evicted
+type evictionFilter[TId any, TObj any] struct {
+	allow      func(id TId, obj *TObj, refreshed time.Time) bool
+	multiplier float64
+}
+
+// defers the eviction of the items for which allow returns false
+// (e.g. dirty entries not yet flushed): the oldest item allowed is evicted instead, and once exempt
+// items may be evicted again, the next addition evicts until the cache is back to its capacity.
+// As a safeguard, once the cache holds hardCapMultiplier times its capacity,
+// the oldest item is evicted regardless of the filter (multipliers below 1 fall back to 2)
+func WithEvictionFilter[TId comparable, TObj any](allow func(id TId, obj *TObj, refreshed time.Time) bool, hardCapMultiplier float64) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		if allow == nil {
+			return
+		}
+
+		if hardCapMultiplier < 1 {
+			hardCapMultiplier = defaultHardCapMultiplier
+		}
+
+		t.evictionFilter = &evictionFilter[TId, TObj]{allow: allow, multiplier: hardCapMultiplier}
+
+	}
+
+}
+
+// returns the number of items above which the filter is ignored
+func (f *evictionFilter[TId, TObj]) hardCap(capacity int) int {
+
+	return int(float64(capacity) * f.multiplier)
+
+}
+
+// returns the number of items the cache may hold
+func (t *HeapedCache[TId, TObj]) maxLen() int {
+
+	if t.evictionFilter != nil {
+		return t.evictionFilter.hardCap(t.capacity())
+	}
+
+	return t.capacity()
+
+}
+
+// number of the oldest items oldestEvictable asks the filter about before giving up: past it,
+// nothing is evicted and the cache grows towards its hard cap, where the filter is ignored
+const filterScanLimit = 64
+
+// returns the oldest item the filter allows to evict among the filterScanLimit oldest ones,
+// nil when they are all exempt
+func (t *HeapedCache[TId, TObj]) oldestEvictable() *HeapedCacheItem[TId, TObj] {
+
+	return t.oldestEvictableIn(t.sliceItems, heapArity)
+
+}
+
+// oldestEvictable in a heap of the given arity (the heaps of the namespaces are binary).
+// The heap is walked in order without being changed: the next oldest item is always a child of
+// one already seen, so candidates are kept in a small heap of positions, costing O(k log k)
+// for k items asked about, independently of the size of the heap
+func (t *HeapedCache[TId, TObj]) oldestEvictableIn(items HeapedCacheItems[TId, TObj], arity int) *HeapedCacheItem[TId, TObj] {
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	candidates := &heapPositions[TId, TObj]{items: items, positions: []int{0}}
+
+	for range filterScanLimit {
+
+		if candidates.Len() == 0 {
+			return nil
+		}
+
+		i := heap.Pop(candidates).(int)
+		item := items[i]
+
+		if t.evictable(item) {
+			return item
+		}
+
+		for child := arity*i + 1; child < min(arity*i+1+arity, len(items)); child++ {
+			heap.Push(candidates, child)
+		}
+
+	}
+
+	return nil
+
+}
+
+// positions of items of a heap of the cache, ordered as the items are
+type heapPositions[TId any, TObj any] struct {
+	items     HeapedCacheItems[TId, TObj]
+	positions []int
+}
+
+func (h *heapPositions[TId, TObj]) Len() int {
+
+	return len(h.positions)
+
+}
+
+func (h *heapPositions[TId, TObj]) Less(i int, j int) bool {
+
+	return h.items.Less(h.positions[i], h.positions[j])
+
+}
+
+func (h *heapPositions[TId, TObj]) Swap(i int, j int) {
+
+	h.positions[i], h.positions[j] = h.positions[j], h.positions[i]
+
+}
+
+func (h *heapPositions[TId, TObj]) Push(x any) {
+
+	h.positions = append(h.positions, x.(int))
+
+}
+
+func (h *heapPositions[TId, TObj]) Pop() any {
+
+	last := len(h.positions) - 1
+	position := h.positions[last]
+	h.positions = h.positions[:last]
+
+	return position
+
+}
+
+// returns true when the eviction filter allows evicting an item
+// (an item the filter could not judge is kept)
+func (t *HeapedCache[TId, TObj]) evictable(item *HeapedCacheItem[TId, TObj]) bool {
+
+	allowed := false
+
+	contain(&t.panics, func() { allowed = t.evictionFilter.allow(item.Id, item.obj, item.Refreshed) })
+
+	return allowed
+
+}
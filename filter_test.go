@@ -0,0 +1,143 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestEvictionFilter(t *testing.T) {
+
+    t.Log("validating TestEvictionFilter")
+
+    dirty := map[int]bool{0: true, 1: true}
+
+    heapedCache := NewHeapedCache(3, WithEvictionFilter(func(id int, obj *AccountTest, refreshed time.Time) bool {
+        return !dirty[id]
+    }, 2))
+
+    for i := range 5 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+        require.NoError(t, heapedCache.CheckInvariants())
+
+    }
+
+    // 0 and 1 are dirty, so 2 and 3 were evicted instead
+    require.Equal(t, 3, heapedCache.Len())
+    require.NotNil(t, heapedCache.Get(0))
+    require.NotNil(t, heapedCache.Get(1))
+    require.Nil(t, heapedCache.Get(2))
+    require.Nil(t, heapedCache.Get(3))
+    require.NotNil(t, heapedCache.Get(4))
+
+    // once flushed, they are evicted as usual
+    dirty[0] = false
+    heapedCache.Push(5, NewAccountTest(5))
+
+    require.Nil(t, heapedCache.Get(0))
+    require.NotNil(t, heapedCache.Get(1))
+
+}
+
+func TestEvictionFilterHardCap(t *testing.T) {
+
+    t.Log("validating TestEvictionFilterHardCap")
+
+    heapedCache := NewHeapedCache(5, WithEvictionFilter(func(id int, obj *AccountTest, refreshed time.Time) bool {
+        return false
+    }, 2))
+
+    for i := range 20 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+        require.NoError(t, heapedCache.CheckInvariants())
+
+    }
+
+    // nothing may be evicted, so the cache grows up to the hard cap, then the oldest go
+    require.Equal(t, 10, heapedCache.Len())
+    require.Nil(t, heapedCache.Get(9))
+    require.NotNil(t, heapedCache.Get(10))
+
+    require.Equal(t, 0, heapedCache.Trim())
+
+}
+
+func TestEvictionFilterScanLimit(t *testing.T) {
+
+    t.Log("validating TestEvictionFilterScanLimit")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    exempt := filterScanLimit + 10
+    asked := 0
+
+    heapedCache := NewHeapedCache(1000, WithClock[int, AccountTest](clock.Now), WithEvictionFilter(func(id int, obj *AccountTest, refreshed time.Time) bool {
+        asked++
+        return id >= exempt
+    }, 2))
+
+    for i := range 1000 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+        clock.Advance(time.Second)
+
+    }
+
+    // the oldest allowed item is past the scan limit: nothing is evicted
+    heapedCache.Push(1000, NewAccountTest(1000))
+
+    require.Equal(t, 1001, heapedCache.Len())
+    require.Equal(t, filterScanLimit, asked)
+
+    // within the limit, the oldest allowed items are found, asking about the older ones only,
+    // and the cache is back to capacity
+    exempt = filterScanLimit - 5
+    asked = 0
+
+    heapedCache.Push(1001, NewAccountTest(1001))
+
+    require.Equal(t, 1000, heapedCache.Len())
+    require.Nil(t, heapedCache.Get(exempt))
+    require.Nil(t, heapedCache.Get(exempt+1))
+    require.NotNil(t, heapedCache.Get(exempt-1))
+    require.NotNil(t, heapedCache.Get(exempt+2))
+    require.Equal(t, 2*(exempt+1), asked)
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
+
+func TestEvictionFilterBackToCapacity(t *testing.T) {
+
+    t.Log("validating TestEvictionFilterBackToCapacity")
+
+    dirty := true
+    evicted := 0
+
+    heapedCache := NewHeapedCache(5, WithEvictionFilter(func(id int, obj *AccountTest, refreshed time.Time) bool {
+        return !dirty
+    }, 3))
+    heapedCache.OnEvict(func(id int, obj *AccountTest) { evicted++ })
+
+    // every item is exempt: the cache grows past its capacity
+    for i := range 12 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    require.Equal(t, 12, heapedCache.Len())
+    require.Equal(t, 0, evicted)
+
+    // once flushed, the next addition brings it back to capacity at once, oldest first
+    dirty = false
+    heapedCache.Push(12, NewAccountTest(12))
+
+    require.Equal(t, 5, heapedCache.Len())
+    require.Equal(t, 8, evicted)
+    require.Nil(t, heapedCache.Get(7))
+    require.NotNil(t, heapedCache.Get(8))
+    require.NotNil(t, heapedCache.Get(12))
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
@@ -0,0 +1,96 @@
+package utils
+
+import "container/list"
+
+// bounded list of keys that recently left the cache, most recent first
+// adding a key beyond size forgets the oldest one
+type ghostList[TId comparable] struct {
+	size     int
+	order    *list.List
+	elements map[TId]*list.Element
+}
+
+func newGhostList[TId comparable](size int) *ghostList[TId] {
+
+	return &ghostList[TId]{size: size, order: list.New(), elements: make(map[TId]*list.Element)}
+
+}
+
+// adds a key (moving it to the front when it is already there)
+func (g *ghostList[TId]) add(id TId) {
+
+	if element := g.elements[id]; element != nil {
+		g.order.MoveToFront(element)
+		return
+	}
+
+	g.elements[id] = g.order.PushFront(id)
+
+	if g.order.Len() > g.size {
+		g.removeOldest()
+	}
+
+}
+
+// forgets a key, returning false when it was not in the list
+func (g *ghostList[TId]) remove(id TId) bool {
+
+	element := g.elements[id]
+
+	if element == nil {
+		return false
+	}
+
+	g.order.Remove(element)
+	delete(g.elements, id)
+
+	return true
+
+}
+
+// forgets the oldest key
+func (g *ghostList[TId]) removeOldest() {
+
+	if element := g.order.Back(); element != nil {
+		g.order.Remove(element)
+		delete(g.elements, element.Value.(TId))
+	}
+
+}
+
+func (g *ghostList[TId]) contains(id TId) bool {
+
+	return g.elements[id] != nil
+
+}
+
+func (g *ghostList[TId]) len() int {
+
+	return g.order.Len()
+
+}
+
+// remembers the last size evicted keys and counts the ghost hits, the misses of keys evicted
+// recently, reported in Stats. A high ghost hit rate (GhostHits against Misses) is the precise
+// signal that maxRows is too small: those reads would have hit with a bigger cache
+func WithGhost[TId comparable, TObj any](size int) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		if size > 0 {
+			t.ghost = newGhostList[TId](size)
+		}
+
+	}
+
+}
+
+// counts a miss of an evicted key (the key is forgotten, it is usually loaded again)
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) ghostMiss(id any) {
+
+	if key, ok := id.(TId); ok && t.ghost.remove(key) {
+		t.ghostHits.Add(1)
+	}
+
+}
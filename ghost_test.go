@@ -0,0 +1,65 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+)
+
+func TestGhost(t *testing.T) {
+
+    t.Log("validating TestGhost")
+
+    heapedCache := NewHeapedCache(2, WithGhost[int, AccountTest](2))
+
+    load := func(id int) *AccountTest { return NewAccountTest(id) }
+
+    // cycling over 3 keys in a cache of 2: every miss is a ghost hit
+    for range 3 {
+
+        for id := range 3 {
+
+            heapedCache.GetOrAdd(id, load)
+
+        }
+
+    }
+
+    stats := heapedCache.Stats()
+
+    require.Equal(t, uint64(9), stats.Misses)
+    require.Equal(t, uint64(6), stats.GhostHits)
+
+    // keys evicted too long ago are forgotten
+    for id := 10; id < 13; id++ {
+
+        heapedCache.Push(id, NewAccountTest(id))
+
+    }
+
+    heapedCache.Get(0)
+    heapedCache.Get(11)
+    require.Equal(t, uint64(6), heapedCache.Stats().GhostHits)
+
+    heapedCache.Get(10)
+    require.Equal(t, uint64(7), heapedCache.Stats().GhostHits)
+
+}
+
+func TestGhostList(t *testing.T) {
+
+    t.Log("validating TestGhostList")
+
+    ghost := newGhostList[int](2)
+
+    ghost.add(1)
+    ghost.add(2)
+    ghost.add(1)
+    ghost.add(3)
+
+    require.Equal(t, 2, ghost.len())
+    require.True(t, ghost.contains(1))
+    require.False(t, ghost.contains(2))
+    require.True(t, ghost.remove(3))
+    require.False(t, ghost.remove(3))
+
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// makes OnShutdown stream the cache to a peer instance, such as the pod replacing this one,
+// by POSTing a snapshot to url, served on the peer by HandoffHandler, so the peer starts warm
+// without a disk shared between nodes. client is http.DefaultClient when nil.
+// The snapshot set by WithShutdownSnapshot or WithShutdownStore, if any, is written as well
+func WithShutdownHandoff[TId comparable, TObj any](url string, client *http.Client) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		t.handoffURL = url
+		t.handoffClient = client
+
+	}
+
+}
+
+// streams a snapshot of the cache (see WriteSnapshot) to the HandoffHandler of a peer at url
+// in the body of a POST, encoding it while it is sent. client is http.DefaultClient when nil.
+// Stops with the error of ctx when it is done. returns the report of the peer
+func (t *HeapedCache[TId, TObj]) Handoff(ctx context.Context, client *http.Client, url string) (RecoveryReport, error) {
+
+	_, report, err := t.handoff(ctx, client, url)
+	return report, err
+
+}
+
+// Handoff, returning the number of items sent as well
+func (t *HeapedCache[TId, TObj]) handoff(ctx context.Context, client *http.Client, url string) (int, RecoveryReport, error) {
+
+	var report RecoveryReport
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	reader, writer := io.Pipe()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, reader)
+
+	if err != nil {
+		return 0, report, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	done := make(chan int, 1)
+
+	go func() {
+
+		// every item, as it is cached (neither filtered nor redacted)
+		written, err := t.encodeItems(ctx, writer, t.snapshot(), func(item *HeapedCacheItem[TId, TObj]) (any, bool) {
+			return t.exportedLine(item), true
+		})
+
+		writer.CloseWithError(err)
+		done <- written
+
+	}()
+
+	resp, err := client.Do(req)
+
+	// unblocks the encoder when the request ended before sending everything
+	reader.Close()
+	written := <-done
+
+	if err != nil {
+		return 0, report, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, report, fmt.Errorf("heapedcache: handoff to %s: %s: %s", url, resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return 0, report, err
+	}
+
+	return written, report, nil
+
+}
+
+// returns the handler receiving the handoff of a peer (see Handoff) into the cache: the items of the
+// snapshot in the body of a POST are loaded oldest first, as Recover does, and the RecoveryReport is
+// returned as JSON. It changes the cache, so serve it on an internal port or behind authentication
+func (t *HeapedCache[TId, TObj]) HandoffHandler() http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var report RecoveryReport
+
+		entries, err := t.readLines(req.Body, false, &report)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		slices.SortStableFunc(entries, func(a, b walRecord[TId, TObj]) int { return a.Refreshed.Compare(b.Refreshed) })
+
+		t.lock(opOther)
+		t.replay(entries, nil, &report)
+		t.unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(report)
+
+	})
+
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+    "context"
+    "github.com/stretchr/testify/require"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestHandoff(t *testing.T) {
+
+    t.Log("validating TestHandoff")
+
+    peer := NewHeapedCache[int, AccountTest](3)
+    server := httptest.NewServer(peer.HandoffHandler())
+    defer server.Close()
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    for i := range 5 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    report, err := heapedCache.Handoff(context.Background(), nil, server.URL)
+    require.NoError(t, err)
+    require.Equal(t, RecoveryReport{Restored: 5}, report)
+
+    // oldest first, so the newest ones are kept
+    require.Equal(t, 3, peer.Len())
+    require.Nil(t, peer.Get(1))
+    require.Equal(t, 4, peer.Get(4).Id)
+
+    resp, err := http.Get(server.URL)
+    require.NoError(t, err)
+    resp.Body.Close()
+    require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+
+    _, err = heapedCache.Handoff(ctx, nil, server.URL)
+    require.ErrorIs(t, err, context.Canceled)
+
+}
+
+func TestShutdownHandoff(t *testing.T) {
+
+    t.Log("validating TestShutdownHandoff")
+
+    peer := NewHeapedCache[int, AccountTest](10)
+    server := httptest.NewServer(peer.HandoffHandler())
+    defer server.Close()
+
+    path := filepath.Join(t.TempDir(), "accounts.ndjson")
+
+    heapedCache := NewHeapedCache(10,
+        WithShutdownHandoff[int, AccountTest](server.URL, server.Client()),
+        WithShutdownSnapshot[int, AccountTest](path))
+
+    heapedCache.Push(1, NewAccountTest(1))
+    heapedCache.Push(2, NewAccountTest(2))
+
+    report, err := heapedCache.OnShutdown(context.Background())
+    require.NoError(t, err)
+    require.Equal(t, ShutdownReport{Persisted: 2, HandedOff: 2}, report)
+    require.Equal(t, 2, peer.Len())
+
+    // a peer refusing the handoff does not prevent the snapshot
+    refusing := httptest.NewServer(http.NotFoundHandler())
+    defer refusing.Close()
+
+    require.NoError(t, os.Remove(path))
+
+    heapedCache = NewHeapedCache(10,
+        WithShutdownHandoff[int, AccountTest](refusing.URL, nil),
+        WithShutdownSnapshot[int, AccountTest](path))
+
+    heapedCache.Push(1, NewAccountTest(1))
+
+    report, err = heapedCache.OnShutdown(context.Background())
+    require.ErrorContains(t, err, "404 Not Found")
+    require.Equal(t, ShutdownReport{Persisted: 1}, report)
+    require.FileExists(t, path)
+
+}
@@ -0,0 +1,81 @@
+package utils
+
+import "time"
+
+// a background task is reported stale when it did not run for staleIntervals intervals
+const staleIntervals = 3
+
+// status of the cache, for health endpoints (see Health)
+type HealthReport struct {
+	Healthy      bool                  // consistent, and no background task is stale
+	Invariants   error                 // result of CheckInvariants
+	Tasks        map[string]TaskHealth // background tasks (trim, audit, watchdog, reaper, fix) by name
+	LastSnapshot time.Time             // last snapshot written (WriteSnapshot, SnapshotAll, OnShutdown), zero when none
+	SnapshotLag  time.Duration         // time since LastSnapshot, zero when none
+	Panics       uint64                // see Stats
+	LockStalls   uint64                // see Stats
+}
+
+// status of a background task of the cache
+type TaskHealth struct {
+	Interval time.Duration
+	LastRun  time.Time // zero before the first run
+	Running  bool      // false once the cache was closed
+	Stale    bool      // running, but did not complete a run within staleIntervals intervals (stuck or dead)
+}
+
+// returns the status of the cache: invariants, liveness of the background goroutines,
+// time since the last snapshot and error counters.
+// Checking the invariants goes through every item under the lock, so it should not be called
+// more often than a health endpoint is polled
+func (t *HeapedCache[TId, TObj]) Health() HealthReport {
+
+	report := HealthReport{Invariants: t.CheckInvariants(), Panics: t.panics.Load()}
+
+	if t.watchdog != nil {
+		report.LockStalls = t.watchdog.stalls.Load()
+	}
+
+	if last := t.lastSnapshot.Load(); last > 0 {
+		report.LastSnapshot = time.Unix(0, last)
+		report.SnapshotLag = t.now().Sub(report.LastSnapshot)
+	}
+
+	report.Healthy = report.Invariants == nil
+
+	if len(t.background) > 0 {
+		report.Tasks = make(map[string]TaskHealth, len(t.background))
+	}
+
+	// intervals change under the lock (ApplyConfig)
+	t.lock(opOther)
+
+	for _, task := range t.background {
+
+		health := task.health(t.now())
+		report.Tasks[task.name] = health
+		report.Healthy = report.Healthy && !health.Stale
+
+	}
+
+	t.unlock()
+
+	return report
+
+}
+
+func (task *backgroundTask) health(now time.Time) TaskHealth {
+
+	result := TaskHealth{Interval: task.interval, Running: !task.stopped.Load()}
+	since := task.started
+
+	if last := task.lastRun.Load(); last > 0 {
+		result.LastRun = time.Unix(0, last)
+		since = result.LastRun
+	}
+
+	result.Stale = result.Running && now.Sub(since) > staleIntervals*task.interval
+
+	return result
+
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "io"
+    "testing"
+    "time"
+)
+
+func TestHealth(t *testing.T) {
+
+    t.Log("validating TestHealth")
+
+    stuck := make(chan struct{})
+
+    heapedCache := NewHeapedCache(10, WithUnreadReaper[int, AccountTest](10*time.Millisecond))
+    defer heapedCache.Close()
+
+    health := heapedCache.Health()
+    require.True(t, health.Healthy)
+    require.NoError(t, health.Invariants)
+    require.True(t, health.LastSnapshot.IsZero())
+    require.Equal(t, 5*time.Millisecond, health.Tasks[taskReaper].Interval)
+    require.True(t, health.Tasks[taskReaper].Running)
+
+    require.NoError(t, heapedCache.WriteSnapshot(io.Discard))
+    require.False(t, heapedCache.Health().LastSnapshot.IsZero())
+
+    // the reaper gets stuck reporting the item it evicted
+    heapedCache.OnEvict(func(id int, obj *AccountTest) {
+        <-stuck
+    })
+
+    heapedCache.Push(1, NewAccountTest(1))
+
+    require.Eventually(t, func() bool { return heapedCache.Health().Tasks[taskReaper].Stale }, time.Second, time.Millisecond)
+    require.False(t, heapedCache.Health().Healthy)
+
+    close(stuck)
+
+    require.Eventually(t, func() bool { return heapedCache.Health().Healthy }, time.Second, time.Millisecond)
+
+    heapedCache.Close()
+
+    require.Eventually(t, func() bool { return !heapedCache.Health().Tasks[taskReaper].Running }, time.Second, time.Millisecond)
+    require.True(t, heapedCache.Health().Healthy)
+
+}
+
+func TestHealthSnapshotLag(t *testing.T) {
+
+    t.Log("validating TestHealthSnapshotLag")
+
+    start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+    clock := NewFakeClock(start)
+    heapedCache := NewDeterministicHeapedCache[int, AccountTest](10, 1, clock)
+
+    require.Zero(t, heapedCache.Health().SnapshotLag)
+
+    // the lag is measured by the clock of the cache
+    require.NoError(t, heapedCache.WriteSnapshot(io.Discard))
+    require.True(t, heapedCache.Health().LastSnapshot.Equal(start))
+
+    clock.Advance(time.Hour)
+
+    health := heapedCache.Health()
+    require.Equal(t, time.Hour, health.SnapshotLag)
+    require.True(t, health.LastSnapshot.Equal(start))
+
+}
@@ -1,8 +1,11 @@
 package utils
 
 import (
-	"container/heap"
+	"context"
+	"math/rand"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,6 +15,18 @@ type HeapedCacheItem[TId any, TObj any] struct {
 	index     int
 	Refreshed time.Time
 	obj       *TObj
+	seq       uint64 // breaks ties between equal Refreshed times, keeping FIFO order
+	secondary []secondaryPosition
+	namespace *namespace[TId, TObj] // nil when the id belongs to no namespace
+	nsIndex   int                   // position in the heap of the namespace
+
+	accesses     uint64 // reads, counted under WithAccessTracking
+	lastAccessed time.Time
+	cost         int64         // see WithCost
+	ttl          time.Duration // overrides the ttl of the cache when not 0 (see PushWithTTL)
+	expirySlot   int           // slot of the expiry wheel + 1, 0 when not in it (see expiryWheel)
+	expiryIndex  int           // position in the slot
+	tags         []string      // sorted, see EntryOptions.Tags
 }
 
 // this type wraps the array of HeapedCacheItem
@@ -19,40 +34,172 @@ type HeapedCacheItem[TId any, TObj any] struct {
 type HeapedCacheItems[TId any, TObj any] []*HeapedCacheItem[TId, TObj]
 
 // type that represents the cache
-type HeapedCache[TId any, TObj any] struct {
+type HeapedCache[TId comparable, TObj any] struct {
 	mu         sync.RWMutex
 	maxRows    int
 	mapItems   map[any]*HeapedCacheItem[TId, TObj]
 	sliceItems HeapedCacheItems[TId, TObj]
+	recorder   *recorder[TId]
+	audit      *auditor[TId]
+	now        func() time.Time
+	overflow   OverflowPolicy
+	trimmer    *trimmer
+
+	evictionFilter *evictionFilter[TId, TObj]
+	deps           *dependencies[TId]
+	tags           map[string]map[TId]struct{} // tag -> ids of the items tagged with it
+	aggregates     map[string]*aggregate[TObj]
+	indexes        map[string]*secondaryIndex[TId, TObj]
+	seq            uint64
+	bloom          *bloomFilter[TId]
+	hotKeys        *hotKeys[TId]
+	nilPolicy      NilPolicy
+	negatives      map[TId]time.Time
+	policy         EvictionPolicy[TId]
+	onEvict        []func(ctx context.Context, id TId, obj *TObj)
+	ctx            context.Context      // of the running operation (see operationContext), nil when it has none
+	dispatchQueue  []evicted[TId, TObj] // evictions to deliver once the lock is released
+	epoch          atomic.Uint64        // bumped when an item is replaced or leaves the cache (see ReadCache)
+	panics         atomic.Uint64        // panics of user callbacks contained (see contain)
+	cost           atomic.Int64         // total cost of the items (see WithCost)
+	hits           atomic.Uint64
+	misses         atomic.Uint64
+	evictions      atomic.Uint64
+	expirations    atomic.Uint64
+	ttl            time.Duration           // see WithTTL
+	expiries       *expiryWheel[TId, TObj] // nil until an item overrides the ttl
+	ttlRules       []ttlRule[TId]
+	adaptiveTTL    *adaptiveTTL // see WithAdaptiveTTL
+	lastSnapshot   atomic.Int64 // unix nanoseconds of the last snapshot written, 0 when none
+	costFn         func(id TId, obj *TObj) int64
+	budget         *Budget
+	pressure       *pressure
+	shadows        []*shadow[TId]
+	ghost          *ghostList[TId]
+	ghostHits      atomic.Uint64
+	latencies      *latencies
+	contention     *contention
+	watchdog       *lockWatchdog
+	reentrancy     *reentrancy
+	trackAccess    bool
+	debugCursor    int  // next heap position checked by assertInvariants
+	unchecked      bool // the next unlock skips assertInvariants
+	deferredFix    *deferredFix
+	namespaces     map[string]*namespace[TId, TObj]
+	config         *Config // set by NewFromConfig and ApplyConfig
+	shutdown       bool    // set by OnShutdown: new and updated items are refused
+	shutdownStore  ObjectStore
+	shutdownName   string
+	handoffURL     string // see WithShutdownHandoff
+	handoffClient  *http.Client
+	leases         map[uint64]*lease[TId, TObj] // items handed out by Lease, by lease id
+	leaseSeq       uint64
+	keyTransform   func(id TId) TId
+	name           string // see WithName
+	wal            *wal   // see Recover
+	formatVersion  int    // see WithFormatVersion
+	migrators      map[int]func(raw []byte) (*TObj, error)
+	persistFilter  func(id TId, obj *TObj) bool
+	sealer         *sealer                         // see WithEncryption
+	redactor       Redactor[TId, TObj]             // see WithRedactor
+	dirty          map[TId]struct{}                // ids changed since the last SaveIncremental, nil unless WithIncrementalSnapshots
+	softRemoved    map[TId]*softRemoval[TId, TObj] // items removed by SoftRemove, by id
+	softRemovedDue time.Time                       // earliest deadline of softRemoved
+	aliases        *aliases[TId]
+	aliased        atomic.Int64 // number of aliases, read without the lock by certainlyMissing
+	background     []*backgroundTask
+	done           chan struct{}
+	closeOnce      sync.Once
+	fakeClock      *FakeClock // drives the background tasks, see NewDeterministicHeapedCache
+	random         *rand.Rand // seeded order of the items taken from maps, see NewDeterministicHeapedCache
 }
 
+// optional setting applied by the constructor
+type Option[TId comparable, TObj any] func(*HeapedCache[TId, TObj])
+
 // conctructor of the HeapedCache
 // this cache is meant to have a fixed sized in memory.
 // The higher the data volume, the lower the range of the cache
-func NewHeapedCache[TId any, TObj any](maxRows int) *HeapedCache[TId, TObj] {
+func NewHeapedCache[TId comparable, TObj any](maxRows int, options ...Option[TId, TObj]) *HeapedCache[TId, TObj] {
 
-	return &HeapedCache[TId, TObj]{
+	t := &HeapedCache[TId, TObj]{
 		maxRows:    maxRows,
 		mapItems:   make(map[any]*HeapedCacheItem[TId, TObj], maxRows+1),
 		sliceItems: make(HeapedCacheItems[TId, TObj], 0, maxRows+1),
+		now:        time.Now,
 	}
 
+	t.policy = &recencyPolicy[TId, TObj]{cache: t}
+
+	if debugMode {
+		t.reentrancy = &reentrancy{}
+	}
+
+	for _, option := range options {
+		option(t)
+	}
+
+	t.startBackground()
+
+	return t
+
 }
 
 // removes the oldest cached item from the list (private)
 func (t *HeapedCache[Tid, TObj]) pop() *TObj {
 
-	item := heap.Pop(&t.sliceItems).(*HeapedCacheItem[Tid, TObj])
-	delete(t.mapItems, item.Id)
-	return item.obj
+	obj, _ := t.popWithRefreshed()
+	return obj
+
+}
+
+// removes the item chosen by the eviction policy to make room for a new one
+// (by default the oldest one)
+// returns false when no item could be evicted
+func (t *HeapedCache[Tid, TObj]) evict() bool {
+
+	item := t.victim()
+
+	if item == nil {
+		return false
+	}
+
+	t.evictItem(item)
+
+	return true
+
+}
+
+// evicts items until the cache is back to its capacity, or no item can be evicted.
+// Items exempted by the eviction filter let the cache grow beyond it, so once they may be evicted,
+// the next addition evicts as many items as needed instead of one
+// must be called under the lock
+func (t *HeapedCache[Tid, TObj]) evictOverflow() {
+
+	for len(t.sliceItems) > t.capacity() && t.evict() {
+	}
+
+}
+
+// removes an item to make room, notifying the eviction callbacks
+func (t *HeapedCache[Tid, TObj]) evictItem(item *HeapedCacheItem[Tid, TObj]) {
+
+	t.removeItem(item)
+	t.record(OpEvict, item.Id, OutcomeEvicted)
+	t.itemRemoved(item)
+	t.itemEvicted(item)
 
 }
 
 // removes the oldest cached item from the list (public)
 func (t *HeapedCache[TId, TObj]) Pop() *TObj {
 
-	t.mu.Lock()
-	defer t.mu.Unlock()
+	if t.latencies != nil {
+		defer t.latencies.observe(OpPop, time.Now())
+	}
+
+	t.lock(OpPop)
+	defer t.unlock()
 
 	return t.pop()
 
@@ -60,8 +207,12 @@ func (t *HeapedCache[TId, TObj]) Pop() *TObj {
 
 func (t *HeapedCache[TId, TObj]) PopWithRefreshed() (*TObj, time.Time) {
 
-	t.mu.Lock()
-	defer t.mu.Unlock()
+	if t.latencies != nil {
+		defer t.latencies.observe(OpPop, time.Now())
+	}
+
+	t.lock(OpPop)
+	defer t.unlock()
 
 	return t.popWithRefreshed()
 
@@ -69,8 +220,11 @@ func (t *HeapedCache[TId, TObj]) PopWithRefreshed() (*TObj, time.Time) {
 
 func (t *HeapedCache[Tid, TObj]) popWithRefreshed() (*TObj, time.Time) {
 
-	item := heap.Pop(&t.sliceItems).(*HeapedCacheItem[Tid, TObj])
+	t.settle()
+	item := t.sliceItems.pop()
 	delete(t.mapItems, item.Id)
+	t.record(OpPop, item.Id, OutcomePopped)
+	t.itemRemoved(item)
 	return item.obj, item.Refreshed
 
 }
@@ -79,42 +233,123 @@ func (t *HeapedCache[Tid, TObj]) popWithRefreshed() (*TObj, time.Time) {
 // returns nil if it does not exist
 func (t *HeapedCache[Tid, TObj]) Get(id any) *TObj {
 
-	t.mu.Lock()
-	defer t.mu.Unlock()
+	if t.latencies != nil {
+		defer t.latencies.observe(OpGet, time.Now())
+	}
+
+	id = t.keyOf(id)
+
+	if t.certainlyMissing(id) {
+		t.itemMissed(id)
+		return nil
+	}
+
+	t.lock(OpGet)
+	defer t.unlock()
 
-	item := t.mapItems[id]
+	t.touchKey(id)
+
+	item := t.unexpired(t.mapItems[t.resolve(id)])
 
 	if item == nil {
+		t.itemMissed(id)
 		return nil
 	}
 
+	t.itemRead(item)
+
 	return item.obj
 
 }
 
+// returns the cached item of a given id along with its age (time since it was refreshed),
+// e.g. for the Age header of a response built from it
+// returns false if it does not exist
+func (t *HeapedCache[TId, TObj]) GetWithAge(id TId) (*TObj, time.Duration, bool) {
+
+	id = t.key(id)
+
+	if t.certainlyMissing(id) {
+		t.itemMissed(id)
+		return nil, 0, false
+	}
+
+	t.lock(OpGet)
+	defer t.unlock()
+
+	t.touchKey(id)
+
+	item := t.unexpired(t.mapItems[t.resolve(id)])
+
+	if item == nil {
+		t.itemMissed(id)
+		return nil, 0, false
+	}
+
+	t.itemRead(item)
+
+	return item.obj, max(t.now().Sub(item.Refreshed), 0), true
+
+}
+
 // returns the cached item of a given id
 // if it does not exist, fn is executed and returned in the function
 // while the new item is placed on the cache
+// (when the overflow policy refuses it, the result is returned without being cached)
 func (t *HeapedCache[TId, TObj]) GetOrAdd(id TId, fn func(id TId) *TObj) *TObj {
 
-	t.mu.Lock()
-	defer t.mu.Unlock()
+	result, _ := t.TryGetOrAdd(id, fn)
+	return result
 
-	findItem := t.mapItems[id]
+}
+
+// same as GetOrAdd, but returns ErrNil when fn returns nil under the ReturnErrNil policy,
+// and ErrFull (along with the loaded item) when the overflow policy refuses to cache it
+func (t *HeapedCache[TId, TObj]) TryGetOrAdd(id TId, fn func(id TId) *TObj) (*TObj, error) {
+
+	return t.getOrAdd(nil, id, fn, EntryOptions{})
+
+}
+
+// ctx is the context of the operation, nil when it has none (see operationContext)
+func (t *HeapedCache[TId, TObj]) getOrAdd(ctx context.Context, id TId, fn func(id TId) *TObj, opts EntryOptions) (*TObj, error) {
+
+	id = t.key(id)
+
+	if t.certainlyMissing(id) {
+		t.itemMissed(id)
+		return t.loadAndAdd(ctx, id, fn, opts)
+	}
+
+	t.lock(OpGetOrAdd)
+	defer t.unlock()
+
+	t.operationContext(ctx)
+	t.touchKey(id)
+
+	findItem := t.unexpired(t.mapItems[t.resolve(id)])
 
 	if findItem == nil {
 
-		result := fn(id)
+		t.itemMissed(id)
+
+		if t.negativeHit(id) {
+			return nil, nil
+		}
+
+		result, err := t.load(ctx, id, fn)
 
-		if result == nil {
-			return nil
+		if err != nil {
+			return nil, err
 		}
 
-		return t.push(id, result)
+		return t.addLoaded(id, result, opts)
 
 	} else {
 
-		return findItem.obj
+		t.itemRead(findItem)
+
+		return findItem.obj, nil
 
 	}
 
@@ -122,58 +357,120 @@ func (t *HeapedCache[TId, TObj]) GetOrAdd(id TId, fn func(id TId) *TObj) *TObj {
 
 func (t *HeapedCache[TId, TObj]) Len() int {
 
-	t.mu.Lock()
-	defer t.mu.Unlock()
+	t.lock(opOther)
+	defer t.unlock()
 
 	return len(t.mapItems)
 
 }
 
-// Adds new item to the cache when it does not exist (public)
+// Adds new item to the cache when it does not exist (private)
 // Updates the item when it does exist
-func (t *HeapedCache[TId, TObj]) push(id TId, item *TObj) *TObj {
+// returns ErrFull when the overflow policy refuses a new item, ErrShutdown after OnShutdown
+func (t *HeapedCache[TId, TObj]) push(id TId, item *TObj) (*TObj, error) {
+
+	return t.pushAt(id, item, t.now())
+
+}
+
+// same as push, with the given refreshed time
+func (t *HeapedCache[TId, TObj]) pushAt(id TId, item *TObj, refreshed time.Time) (*TObj, error) {
 
 	if item == nil {
-		return nil
+		return nil, nil
+	}
+
+	id = t.key(id)
+
+	if t.shutdown {
+		t.record(OpPush, id, OutcomeRejected)
+		return nil, ErrShutdown
 	}
 
 	findItem := t.mapItems[id]
 
 	if findItem == nil {
 
+		t.clearNegative(id)
+
+		if !t.admit(refreshed) {
+			t.record(OpPush, id, OutcomeRejected)
+			return nil, ErrFull
+		}
+
+		t.enforceQuota(id)
+
 		newItem := &HeapedCacheItem[TId, TObj]{
 			Id:        id,
 			index:     len(t.sliceItems),
-			Refreshed: time.Now(),
+			Refreshed: refreshed,
 			obj:       item,
+			seq:       t.nextSeq(),
 		}
 
 		t.mapItems[id] = newItem
 
-		heap.Push(&t.sliceItems, newItem)
+		t.sliceItems.push(newItem)
+		t.record(OpPush, id, OutcomeAdded)
+		t.itemAdded(newItem)
 
-		if len(t.sliceItems) > t.maxRows {
-			t.pop()
-		}
+		t.evictOverflow()
 
 	} else {
 
+		old := findItem.obj
 		findItem.obj = item
-		findItem.Refreshed = time.Now()
-		heap.Fix(&t.sliceItems, findItem.index)
+		findItem.Refreshed = refreshed
+		findItem.seq = t.nextSeq()
+		t.fix(findItem)
+		t.record(OpPush, id, OutcomeUpdated)
+		t.itemUpdated(findItem, old)
 
 	}
 
-	return item
+	return item, nil
 
 }
 
 // Adds new item to the cache when it does not exist (public)
 // Updates the item when it does exist
+// returns nil when the overflow policy refuses the item
 func (t *HeapedCache[TId, TObj]) Push(id TId, item *TObj) *TObj {
 
-	t.mu.Lock()
-	defer t.mu.Unlock()
+	if t.latencies != nil {
+		defer t.latencies.observe(OpPush, time.Now())
+	}
+
+	t.lock(OpPush)
+	defer t.unlock()
+
+	result, _ := t.push(id, item)
+	return result
+
+}
+
+// same as Push, with the refreshed time given by the caller instead of the clock (e.g. the time
+// the object was last modified at its source), placing the item in the heap by it.
+// Under DropNewestIfOlder, a new item older than the oldest cached one is refused (returns nil)
+func (t *HeapedCache[TId, TObj]) PushAt(id TId, item *TObj, refreshed time.Time) *TObj {
+
+	t.lock(OpPush)
+	defer t.unlock()
+
+	result, _ := t.pushAt(id, item, refreshed)
+	return result
+
+}
+
+// same as Push, but returns ErrFull when the overflow policy refuses the item
+func (t *HeapedCache[TId, TObj]) TryPush(id TId, item *TObj) (*TObj, error) {
+
+	if t.latencies != nil {
+		defer t.latencies.observe(OpPush, time.Now())
+	}
+
+	t.lock(OpPush)
+	defer t.unlock()
 
 	return t.push(id, item)
 
@@ -182,30 +479,91 @@ func (t *HeapedCache[TId, TObj]) Push(id TId, item *TObj) *TObj {
 // Remove items from the list (cache invalidation)
 func (t *HeapedCache[TId, TObj]) Remove(id TId) bool {
 
-	t.mu.Lock()
-	defer t.mu.Unlock()
+	t.lock(OpRemove)
+	defer t.unlock()
+
+	return t.remove(id)
+
+}
+
+// removes the item of a given id, returning false when it was not cached
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) remove(id TId) bool {
+
+	id = t.key(id)
+
+	t.clearNegative(id)
 
 	findItem := t.mapItems[id]
 
 	if findItem != nil {
 
-		// removes item from the slice
-		t.sliceItems.Swap(findItem.index, len(t.sliceItems)-1)
-		t.sliceItems[len(t.sliceItems)-1] = nil // don't stop the GC from reclaiming the item eventually
-		heap.Fix(&t.sliceItems, findItem.index)
-		t.sliceItems = t.sliceItems[:len(t.sliceItems)-1]
-
-		// remove item from the map
-		delete(t.mapItems, id)
+		t.removeItem(findItem)
+		t.record(OpRemove, id, OutcomeRemoved)
+		t.itemRemoved(findItem)
 
 		return true
 
 	}
 
+	t.record(OpRemove, id, OutcomeNotFound)
+
 	return false
 
 }
 
+// removes every item from the cache, returning how many were removed
+// the eviction callbacks (OnEvict) are called with them once the lock is released
+func (t *HeapedCache[TId, TObj]) Clear() int {
+
+	t.lock(opOther)
+
+	callbacks := t.wipeCallbacks()
+
+	var items []evicted[TId, TObj]
+
+	if callbacks != nil {
+		items = make([]evicted[TId, TObj], 0, len(t.sliceItems))
+	}
+
+	removed := 0
+
+	// every item goes: the dependents are wiped (counted and reported) as the others, not invalidated
+	t.deps = nil
+
+	// removing the last item of the heap needs no sifting
+	for len(t.sliceItems) > 0 {
+
+		item := t.sliceItems[len(t.sliceItems)-1]
+
+		t.removeItem(item)
+		t.record(OpRemove, item.Id, OutcomeRemoved)
+		t.itemRemoved(item)
+		removed++
+
+		if callbacks != nil {
+			items = append(items, evicted[TId, TObj]{id: item.Id, obj: item.obj})
+		}
+
+	}
+
+	t.unlock()
+
+	streamWiped(callbacks, items, &t.panics)
+
+	return removed
+
+}
+
+// removes a cached item from the slice and from the map
+func (t *HeapedCache[TId, TObj]) removeItem(item *HeapedCacheItem[TId, TObj]) {
+
+	item.assertLive()
+	t.sliceItems.remove(item.index)
+	delete(t.mapItems, item.Id)
+
+}
+
 // returns the size of the cache in lines
 func (h *HeapedCacheItems[TId, TObj]) Len() int {
 
@@ -213,10 +571,23 @@ func (h *HeapedCacheItems[TId, TObj]) Len() int {
 
 }
 
+// returns the sequence number of the next added or updated item
+func (t *HeapedCache[TId, TObj]) nextSeq() uint64 {
+
+	t.seq++
+	return t.seq
+
+}
+
 // returns true if the cached item from the second index is smaller than the first one
+// items refreshed at the same time are ordered by sequence (first in, first out)
 func (h *HeapedCacheItems[TId, TObj]) Less(i int, j int) bool {
 
-	return (*h)[i].Refreshed.Compare((*h)[j].Refreshed) < 0
+	if c := (*h)[i].Refreshed.Compare((*h)[j].Refreshed); c != 0 {
+		return c < 0
+	}
+
+	return (*h)[i].seq < (*h)[j].seq
 
 }
 
@@ -237,19 +608,15 @@ func (h *HeapedCacheItems[TId, TObj]) Swap(i int, j int) {
 // Adds item in the cache
 func (h *HeapedCacheItems[TId, TObj]) Push(x any) {
 
-	*h = append(*h, x.(*HeapedCacheItem[TId, TObj]))
+	item := x.(*HeapedCacheItem[TId, TObj])
+	item.index = len(*h)
+	*h = append(*h, item)
 
 }
 
 // Removes last item (older) from the cache and returns it
 func (h *HeapedCacheItems[TId, TObj]) Pop() any {
 
-	n := len(*h)
-	item := (*h)[n-1]
-	(*h)[n-1] = nil // don't stop the GC from reclaiming the item eventually
-	item.index = -1 // for safety
-	*h = (*h)[0 : n-1]
-
-	return item
+	return h.removeLast()
 
 }
@@ -334,8 +334,7 @@ func TestDuration(t *testing.T) {
     for i := range 1000000 {
 
         item := NewAccountTest(i)
-        item.Id = item.Id
-        item = nil
+        _ = item
 
     }
 
@@ -360,12 +359,33 @@ func TestDuration(t *testing.T) {
 
 }
 
+func TestCachedHeapRemove(t *testing.T) {
+
+    t.Log("validating TestCachedHeapRemove")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    for i := range 10 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    for _, id := range []int{0, 9, 4} {
+
+        require.True(t, heapedCache.Remove(id))
+        require.NoError(t, heapedCache.CheckInvariants())
+        require.Nil(t, heapedCache.Get(id))
+
+    }
+
+    require.False(t, heapedCache.Remove(4))
+    require.Equal(t, 7, heapedCache.Len())
+
+}
+
 // Test Cases to be implemented
-// - Remove any positions
-// - Remove first and last position
+// (removes, same ID pushes and map/slice consistency are covered by cachetest.RunConformance)
 // - See if removes can be done with pop (safer)
 // - Pop Order Assert
-// - Add same ID (check len)
-// - compare slice len to map len
 // - remove performance
-// - sync lenght between map and slice after each operation
@@ -0,0 +1,339 @@
+package utils
+
+import (
+	"container/heap"
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// called after a new item was placed on the cache
+func (t *HeapedCache[TId, TObj]) itemAdded(item *HeapedCacheItem[TId, TObj]) {
+
+	t.policy.OnAdd(item.Id)
+
+	item.cost = t.costOf(item)
+	t.charge(item.cost)
+
+	if t.ghost != nil {
+		t.ghost.remove(item.Id)
+	}
+
+	if t.aliases != nil {
+		t.unalias(item.Id)
+	}
+
+	if t.pressure != nil {
+		t.pressure.observe(t.now(), 1, 0)
+	}
+
+	if ns := t.namespaceOf(item.Id); ns != nil {
+		item.namespace = ns
+		heap.Push(ns, item)
+	}
+
+	if t.bloom != nil {
+		t.bloom.add(item.Id)
+	}
+
+	if t.expiries != nil {
+		t.expiries.add(item)
+	}
+
+	// an item keeps its ttl when rekeyed
+	if len(t.ttlRules) > 0 && item.ttl == 0 {
+		t.setTTL(item, 0)
+	}
+
+	for _, aggregate := range t.aggregates {
+		aggregate.add(item.obj)
+	}
+
+	if len(item.tags) > 0 {
+		t.indexTags(item)
+	}
+
+	if len(t.indexes) > 0 {
+
+		item.secondary = make([]secondaryPosition, len(t.indexes))
+
+		for _, index := range t.indexes {
+			index.add(item)
+		}
+
+	}
+
+}
+
+// called after an item left the cache (popped, evicted or removed)
+func (t *HeapedCache[TId, TObj]) itemRemoved(item *HeapedCacheItem[TId, TObj]) {
+
+	t.policy.OnRemove(item.Id)
+	t.epoch.Add(1)
+	t.charge(-item.cost)
+
+	if item.namespace != nil {
+		heap.Remove(item.namespace, item.nsIndex)
+		item.namespace = nil
+	}
+
+	if t.bloom != nil {
+		t.bloom.remove(item.Id)
+	}
+
+	if t.expiries != nil {
+		t.expiries.remove(item)
+	}
+
+	for _, aggregate := range t.aggregates {
+		aggregate.remove(item.obj)
+	}
+
+	for _, index := range t.indexes {
+		index.remove(item)
+	}
+
+	if len(item.tags) > 0 {
+		t.untagItem(item)
+	}
+
+	if t.deps != nil {
+		t.deps.forget(item.Id)
+		t.invalidateDependents(item.Id)
+	}
+
+	t.dropAliases(item.Id)
+
+	item.poison()
+
+}
+
+// called after the object of a cached item was replaced by a new one
+func (t *HeapedCache[TId, TObj]) itemUpdated(item *HeapedCacheItem[TId, TObj], old *TObj) {
+
+	for _, aggregate := range t.aggregates {
+		aggregate.remove(old)
+		aggregate.add(item.obj)
+	}
+
+	cost := t.costOf(item)
+	t.charge(cost - item.cost)
+	item.cost = cost
+
+	t.itemChanged(item)
+
+}
+
+// called when a cached item is read (Get, GetOrAdd and similar hits)
+func (t *HeapedCache[TId, TObj]) itemRead(item *HeapedCacheItem[TId, TObj]) {
+
+	t.policy.OnAccess(item.Id)
+	t.hits.Add(1)
+
+	for _, s := range t.shadows {
+		s.read(item.Id)
+	}
+
+	if t.trackAccess {
+		item.accesses++
+		item.lastAccessed = t.now()
+	}
+
+	// the read lengthened its time to live
+	if t.adaptiveTTL != nil && t.expiries != nil {
+		t.expiries.fix(item)
+	}
+
+}
+
+// called when a read (Get, GetOrAdd and similar) finds no cached item for id
+// may be called without the lock, for the misses detected by the Bloom filter
+// (only without shadows or ghost list, see certainlyMissing)
+func (t *HeapedCache[TId, TObj]) itemMissed(id any) {
+
+	t.misses.Add(1)
+
+	if t.ghost != nil {
+		t.ghostMiss(id)
+	}
+
+	if key, ok := id.(TId); ok && len(t.shadows) > 0 {
+
+		for _, s := range t.shadows {
+			s.read(key)
+		}
+
+	}
+
+}
+
+// called after the object of a cached item changed (replaced or patched in place),
+// once the aggregates are up to date
+func (t *HeapedCache[TId, TObj]) itemChanged(item *HeapedCacheItem[TId, TObj]) {
+
+	t.policy.OnAccess(item.Id)
+	t.epoch.Add(1)
+
+	if item.namespace != nil {
+		heap.Fix(item.namespace, item.nsIndex)
+	}
+
+	for _, index := range t.indexes {
+		index.update(item)
+	}
+
+	if t.deps != nil {
+		t.invalidateDependents(item.Id)
+	}
+
+}
+
+// called after an item was evicted to make room (after itemRemoved)
+// the eviction callbacks are queued, to be run once the lock is released (see dispatch)
+func (t *HeapedCache[TId, TObj]) itemEvicted(item *HeapedCacheItem[TId, TObj]) {
+
+	t.evictions.Add(1)
+
+	if t.ghost != nil {
+		t.ghost.add(item.Id)
+	}
+
+	if t.pressure != nil {
+		t.pressure.observe(t.now(), 0, 1)
+	}
+
+	if len(t.onEvict) > 0 {
+		t.dispatchQueue = append(t.dispatchQueue, evicted[TId, TObj]{id: item.Id, obj: item.obj, ctx: t.ctx})
+	}
+
+}
+
+// takes the queued evictions along with the callbacks to deliver them to
+// must be called under the lock, right before releasing it
+func (t *HeapedCache[TId, TObj]) takeDispatchQueue() ([]func(ctx context.Context, id TId, obj *TObj), []evicted[TId, TObj]) {
+
+	if len(t.dispatchQueue) == 0 {
+		return nil, nil
+	}
+
+	queue := t.dispatchQueue
+	t.dispatchQueue = nil
+
+	return t.onEvict, queue
+
+}
+
+// delivers the evictions of a critical section to the eviction callbacks, in order,
+// on the goroutine that caused them and after it released the lock, so callbacks may call the cache
+func (t *HeapedCache[TId, TObj]) dispatch(callbacks []func(ctx context.Context, id TId, obj *TObj), queue []evicted[TId, TObj]) {
+
+	for _, item := range queue {
+
+		for _, fn := range callbacks {
+			contain(&t.panics, func() { fn(item.context(), item.id, item.obj) })
+		}
+
+	}
+
+}
+
+// registers fn to be called with every item evicted to make room, and with every item
+// wiped by Clear or DropNamespace (popped and removed items are not reported).
+// fn runs after the cache lock is released, so it may call back into the cache: for evictions,
+// on the goroutine that caused them, before its operation returns; for wipes, from up to
+// wipeWorkers goroutines at once, so it must be safe for concurrent use
+func (t *HeapedCache[TId, TObj]) OnEvict(fn func(id TId, obj *TObj)) {
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	t.onEvict = append(t.onEvict, func(_ context.Context, id TId, obj *TObj) { fn(id, obj) })
+
+}
+
+// same as OnEvict, with the context of the operation that caused the eviction (PushContext,
+// GetOrAddContext, Warm...), so trace and tenant ids reach logging and write-backs;
+// context.Background() for the operations without one and for the items wiped by Clear or DropNamespace
+func (t *HeapedCache[TId, TObj]) OnEvictContext(fn func(ctx context.Context, id TId, obj *TObj)) {
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	t.onEvict = append(t.onEvict, fn)
+
+}
+
+// number of goroutines calling the eviction callbacks of a wipe
+const wipeWorkers = 4
+
+// item evicted, or wiped by Clear or DropNamespace, reported to the eviction callbacks
+type evicted[TId any, TObj any] struct {
+	id  TId
+	obj *TObj
+	ctx context.Context // of the operation that evicted the item, nil when it had none
+}
+
+// returns the context given to the eviction callbacks
+func (e evicted[TId, TObj]) context() context.Context {
+
+	if e.ctx == nil {
+		return context.Background()
+	}
+
+	return e.ctx
+
+}
+
+// returns the eviction callbacks when there are any, so wiped items are collected for them
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) wipeCallbacks() []func(ctx context.Context, id TId, obj *TObj) {
+
+	if len(t.onEvict) == 0 {
+		return nil
+	}
+
+	return slices.Clone(t.onEvict)
+
+}
+
+// calls the eviction callbacks with the wiped items, outside the lock,
+// from a bounded pool of goroutines; returns once every call returned
+func streamWiped[TId any, TObj any](callbacks []func(ctx context.Context, id TId, obj *TObj), items []evicted[TId, TObj], panics *atomic.Uint64) {
+
+	if len(callbacks) == 0 || len(items) == 0 {
+		return
+	}
+
+	next := make(chan evicted[TId, TObj])
+
+	var wg sync.WaitGroup
+
+	for range min(wipeWorkers, len(items)) {
+
+		wg.Add(1)
+
+		go func() {
+
+			defer wg.Done()
+
+			for item := range next {
+
+				for _, fn := range callbacks {
+					contain(panics, func() { fn(item.context(), item.id, item.obj) })
+				}
+
+			}
+
+		}()
+
+	}
+
+	for _, item := range items {
+		next <- item
+	}
+
+	close(next)
+	wg.Wait()
+
+}
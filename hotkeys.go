@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// approximate access count of an id, as reported by TopKeys.
+// The real count is between Count-Error and Count
+type KeyCount[TId any] struct {
+	Id    TId
+	Count uint64
+	Error uint64
+	index int
+}
+
+// space-saving sketch: keeps the most accessed ids in a fixed number of counters,
+// the least counted one being replaced when a new id shows up
+type hotKeys[TId comparable] struct {
+	counters map[TId]*KeyCount[TId]
+	heap     hotKeysHeap[TId]
+	size     int
+}
+
+// tracks the approximate access frequency of the ids read by Get and GetOrAdd,
+// keeping size counters (the more counters, the more accurate), reported by TopKeys
+func WithHotKeys[TId comparable, TObj any](size int) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		if size > 0 {
+			t.hotKeys = &hotKeys[TId]{counters: make(map[TId]*KeyCount[TId], size), size: size}
+		}
+
+	}
+
+}
+
+// counts an access to the id
+func (h *hotKeys[TId]) touch(id TId) {
+
+	if counter := h.counters[id]; counter != nil {
+		counter.Count++
+		heap.Fix(&h.heap, counter.index)
+		return
+	}
+
+	if len(h.heap) < h.size {
+		counter := &KeyCount[TId]{Id: id, Count: 1}
+		h.counters[id] = counter
+		heap.Push(&h.heap, counter)
+		return
+	}
+
+	// replaces the least counted id, inheriting its count as error
+	counter := h.heap[0]
+	delete(h.counters, counter.Id)
+
+	counter.Id = id
+	counter.Error = counter.Count
+	counter.Count++
+
+	h.counters[id] = counter
+	heap.Fix(&h.heap, 0)
+
+}
+
+// counts an access to the id when hot keys are tracked
+func (t *HeapedCache[TId, TObj]) touchKey(id any) {
+
+	if t.hotKeys == nil {
+		return
+	}
+
+	if key, ok := id.(TId); ok {
+		t.hotKeys.touch(key)
+	}
+
+}
+
+// returns the n most accessed ids, the most accessed first (none when n is not positive)
+// returns nil when the cache was not created with WithHotKeys
+func (t *HeapedCache[TId, TObj]) TopKeys(n int) []KeyCount[TId] {
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	if t.hotKeys == nil {
+		return nil
+	}
+
+	result := make([]KeyCount[TId], len(t.hotKeys.heap))
+
+	for i, counter := range t.hotKeys.heap {
+		result[i] = KeyCount[TId]{Id: counter.Id, Count: counter.Count, Error: counter.Error}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Count > result[j].Count })
+
+	return result[:min(max(n, 0), len(result))]
+
+}
+
+// min-heap of counters, the least counted on top
+type hotKeysHeap[TId any] []*KeyCount[TId]
+
+func (h *hotKeysHeap[TId]) Len() int {
+
+	return len(*h)
+
+}
+
+func (h *hotKeysHeap[TId]) Less(i int, j int) bool {
+
+	return (*h)[i].Count < (*h)[j].Count
+
+}
+
+func (h *hotKeysHeap[TId]) Swap(i int, j int) {
+
+	(*h)[i], (*h)[j] = (*h)[j], (*h)[i]
+	(*h)[i].index = i
+	(*h)[j].index = j
+
+}
+
+func (h *hotKeysHeap[TId]) Push(x any) {
+
+	counter := x.(*KeyCount[TId])
+	counter.index = len(*h)
+	*h = append(*h, counter)
+
+}
+
+func (h *hotKeysHeap[TId]) Pop() any {
+
+	n := len(*h)
+	counter := (*h)[n-1]
+	(*h)[n-1] = nil
+	*h = (*h)[0 : n-1]
+
+	return counter
+
+}
+
+// TopKeys for the admin handler of the Registry, redacted
+func (t *HeapedCache[TId, TObj]) topKeys(n int) any {
+
+	result := t.TopKeys(n)
+
+	for i := range result {
+		result[i].Id = t.redactedID(result[i].Id)
+	}
+
+	return result
+
+}
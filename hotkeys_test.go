@@ -0,0 +1,52 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+)
+
+func TestTopKeys(t *testing.T) {
+
+    t.Log("validating TestTopKeys")
+
+    heapedCache := NewHeapedCache(100, WithHotKeys[int, AccountTest](10))
+
+    for i := range 100 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    // 7 and 3 are hot, everything else is read once
+    for i := range 100 {
+
+        heapedCache.Get(i)
+        heapedCache.Get(7)
+        heapedCache.Get(7)
+        heapedCache.GetOrAdd(3, NewAccountTest)
+
+    }
+
+    top := heapedCache.TopKeys(2)
+
+    require.Equal(t, 2, len(top))
+    require.Equal(t, 7, top[0].Id)
+    require.Equal(t, 3, top[1].Id)
+    require.GreaterOrEqual(t, top[0].Count-top[0].Error, uint64(200))
+
+    require.Equal(t, 10, len(heapedCache.TopKeys(50)))
+    require.Empty(t, heapedCache.TopKeys(-1))
+
+}
+
+func TestTopKeysDisabled(t *testing.T) {
+
+    t.Log("validating TestTopKeysDisabled")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    heapedCache.Get(1)
+
+    require.Nil(t, heapedCache.TopKeys(10))
+
+}
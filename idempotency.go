@@ -0,0 +1,87 @@
+package utils
+
+import "time"
+
+// state of an idempotency key: in flight until its result is captured
+type idempotencyEntry[R any] struct {
+	done   bool
+	result *R
+}
+
+// cache of idempotency keys (e.g. the Idempotency-Key header of HTTP requests) and the
+// results of the requests that carried them. Begin claims a key atomically, so exactly one
+// of several concurrent requests with the same key gets to run; the others see it in flight,
+// and the later ones get the captured result. Keys are forgotten ttl after they were claimed
+// or completed (a zero ttl keeps them until evicted) and, when the cache is full, the oldest
+// ones are evicted, in flight or not. Keys expire as the items of WithTTL do: once a key is
+// found expired, no Begin returns its result nor sees it in flight again
+type IdempotencyCache[K comparable, R any] struct {
+	cache *HeapedCache[K, idempotencyEntry[R]]
+}
+
+func NewIdempotencyCache[K comparable, R any](maxRows int, ttl time.Duration) *IdempotencyCache[K, R] {
+
+	return &IdempotencyCache[K, R]{cache: NewHeapedCache(maxRows, WithTTL[K, idempotencyEntry[R]](ttl))}
+
+}
+
+// claims key for the caller, who must then call Complete (or Abandon).
+// returns the captured result when the key was already completed, and inFlight
+// when another caller claimed it and did not complete it yet; the caller may only
+// proceed when both are zero
+func (c *IdempotencyCache[K, R]) Begin(key K) (prior *R, inFlight bool) {
+
+	c.cache.lock(OpGetOrAdd)
+	defer c.cache.unlock()
+
+	if item := c.cache.unexpired(c.cache.mapItems[key]); item != nil {
+
+		if item.obj.done {
+			return item.obj.result, false
+		}
+
+		return nil, true
+
+	}
+
+	c.cache.push(key, &idempotencyEntry[R]{})
+
+	return nil, false
+
+}
+
+// captures the result of the request that claimed key, returned by the next calls of Begin
+func (c *IdempotencyCache[K, R]) Complete(key K, result R) {
+
+	c.cache.Push(key, &idempotencyEntry[R]{done: true, result: &result})
+
+}
+
+// releases a key claimed by Begin without capturing a result (e.g. the request failed
+// in a way that may be retried), so the next Begin claims it again
+// returns false when the key is not in flight
+func (c *IdempotencyCache[K, R]) Abandon(key K) bool {
+
+	c.cache.lock(OpRemove)
+	defer c.cache.unlock()
+
+	item := c.cache.unexpired(c.cache.mapItems[key])
+
+	if item == nil || item.obj.done {
+		return false
+	}
+
+	c.cache.removeItem(item)
+	c.cache.record(OpRemove, key, OutcomeRemoved)
+	c.cache.itemRemoved(item)
+
+	return true
+
+}
+
+// returns the number of keys kept, in flight or completed (expired ones are removed)
+func (c *IdempotencyCache[K, R]) Len() int {
+
+	return c.cache.Len()
+
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestIdempotencyCache(t *testing.T) {
+
+    t.Log("validating TestIdempotencyCache")
+
+    idempotency := NewIdempotencyCache[string, int](10, time.Hour)
+
+    prior, inFlight := idempotency.Begin("a")
+    require.Nil(t, prior)
+    require.False(t, inFlight)
+
+    prior, inFlight = idempotency.Begin("a")
+    require.Nil(t, prior)
+    require.True(t, inFlight)
+
+    idempotency.Complete("a", 201)
+
+    prior, inFlight = idempotency.Begin("a")
+    require.Equal(t, 201, *prior)
+    require.False(t, inFlight)
+
+    idempotency.Begin("b")
+    require.True(t, idempotency.Abandon("b"))
+    require.False(t, idempotency.Abandon("a"))
+
+    prior, inFlight = idempotency.Begin("b")
+    require.Nil(t, prior)
+    require.False(t, inFlight)
+
+}
+
+func TestIdempotencyCacheConcurrentBegin(t *testing.T) {
+
+    t.Log("validating TestIdempotencyCacheConcurrentBegin")
+
+    idempotency := NewIdempotencyCache[string, int](10, 0)
+
+    var claimed atomic.Int32
+    var wg sync.WaitGroup
+
+    for range 50 {
+
+        wg.Add(1)
+
+        go func() {
+
+            defer wg.Done()
+
+            if prior, inFlight := idempotency.Begin("key"); prior == nil && !inFlight {
+                claimed.Add(1)
+            }
+
+        }()
+
+    }
+
+    wg.Wait()
+    require.Equal(t, int32(1), claimed.Load())
+
+}
+
+func TestIdempotencyCacheTTL(t *testing.T) {
+
+    t.Log("validating TestIdempotencyCacheTTL")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    idempotency := NewIdempotencyCache[string, int](10, time.Minute)
+    idempotency.cache.now = clock.Now
+
+    idempotency.Begin("a")
+    idempotency.Complete("a", 1)
+
+    clock.Advance(time.Minute)
+
+    // expired keys are removed, not kept until evicted
+    require.Equal(t, 0, idempotency.Len())
+
+    prior, inFlight := idempotency.Begin("a")
+    require.Nil(t, prior)
+    require.False(t, inFlight)
+
+}
@@ -0,0 +1,397 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// files of a snapshot chain (see SaveIncremental)
+const (
+	chainBase   = "base.ndjson"
+	chainDelta  = "delta-%06d.ndjson"
+	deltaPrefix = "delta-"
+)
+
+// tracks the ids changed since the last SaveIncremental, so it writes only them
+// (a map entry per changed id, kept until the next save)
+func WithIncrementalSnapshots[TId comparable, TObj any]() Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		t.dirty = make(map[TId]struct{})
+
+	}
+
+}
+
+// persists the cache to the snapshot chain in dir: a full snapshot (base.ndjson) the first time,
+// and then only the items added, updated or removed since the previous call, in numbered delta
+// files (delta-000001.ndjson, ...) in the format of the write-ahead log, so a periodic save of a
+// large cache writes what changed instead of everything. LoadSnapshotChain reads the chain back,
+// CompactSnapshotChain folds the deltas into the base. Needs WithIncrementalSnapshots.
+// Changes made while the files are written go to the next delta; when writing fails they are
+// kept for the next call as well. returns the path of the file written, empty when nothing changed
+func (t *HeapedCache[TId, TObj]) SaveIncremental(ctx context.Context, dir string) (string, error) {
+
+	if t.dirty == nil {
+		return "", fmt.Errorf("heapedcache: incremental snapshots need WithIncrementalSnapshots")
+	}
+
+	deltas, err := chainDeltas(dir)
+
+	if err != nil {
+		return "", err
+	}
+
+	_, err = os.Stat(filepath.Join(dir, chainBase))
+	full := os.IsNotExist(err)
+
+	if err != nil && !full {
+		return "", err
+	}
+
+	t.lock(opOther)
+
+	var items []HeapedCacheItem[TId, TObj]
+	var removed []TId
+
+	changed := t.dirty
+	t.dirty = make(map[TId]struct{})
+
+	if full {
+		items = t.copyItems()
+	} else {
+
+		for id := range changed {
+
+			if item := t.mapItems[id]; item != nil {
+				items = append(items, *item)
+			} else {
+				removed = append(removed, id)
+			}
+
+		}
+
+	}
+
+	t.unlock()
+
+	path := filepath.Join(dir, chainBase)
+
+	if !full {
+
+		if len(changed) == 0 {
+			return "", nil
+		}
+
+		path = filepath.Join(dir, fmt.Sprintf(chainDelta, len(deltas)+1))
+
+	}
+
+	err = writeSnapshotFile(path, func(w io.Writer) error {
+
+		if full {
+			_, err := t.persistItems(ctx, w, items)
+			return err
+		}
+
+		return t.writeDelta(ctx, w, items, removed)
+
+	})
+
+	if err != nil {
+
+		// written with the next delta
+		t.lock(opOther)
+
+		for id := range changed {
+			t.dirty[id] = struct{}{}
+		}
+
+		t.unlock()
+
+		return "", err
+
+	}
+
+	return path, nil
+
+}
+
+// loads the snapshot chain written by SaveIncremental in dir: the base, then every delta in order.
+// The loaded items are not written again by the next SaveIncremental.
+// The report counts the items of the base as restored and the lines of the deltas as replayed
+func (t *HeapedCache[TId, TObj]) LoadSnapshotChain(dir string) (RecoveryReport, error) {
+
+	var report RecoveryReport
+
+	entries, err := t.readPersisted(filepath.Join(dir, chainBase), false, &report)
+
+	if err != nil {
+		return report, err
+	}
+
+	slices.SortStableFunc(entries, func(a, b walRecord[TId, TObj]) int { return a.Refreshed.Compare(b.Refreshed) })
+
+	deltas, err := chainDeltas(dir)
+
+	if err != nil {
+		return report, err
+	}
+
+	var records []walRecord[TId, TObj]
+
+	for _, delta := range deltas {
+
+		read, err := t.readPersisted(filepath.Join(dir, delta), true, &report)
+
+		if err != nil {
+			return report, err
+		}
+
+		records = append(records, read...)
+
+	}
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	t.replay(entries, records, &report)
+
+	// the chain already has what was loaded
+	if t.dirty != nil {
+		clear(t.dirty)
+	}
+
+	return report, nil
+
+}
+
+// folds the deltas of the snapshot chain in dir into its base, keeping the last state of every id,
+// and deletes them. The objects are not decoded, so it can run offline, without the cache.
+// A crash before the deltas are deleted is harmless: replaying them on the new base changes nothing
+func CompactSnapshotChain[TId comparable](dir string) error {
+
+	return CompactSealedSnapshotChain[TId](dir, nil)
+
+}
+
+// same as CompactSnapshotChain for a chain sealed by WithEncryption: keys opens its lines
+// and seals the new base with its current key
+func CompactSealedSnapshotChain[TId comparable](dir string, keys KeyProvider) error {
+
+	var s *sealer
+
+	if keys != nil {
+		s = newSealer(keys)
+	}
+
+	deltas, err := chainDeltas(dir)
+
+	if err != nil || len(deltas) == 0 {
+		return err
+	}
+
+	var order []TId
+	lines := make(map[TId]persistedLine[TId])
+
+	// keeps the last state of the id of a line
+	apply := func(name string, text []byte, log bool) error {
+
+		var line persistedLine[TId]
+
+		if text, ok := openLine(s, text); !ok {
+			return fmt.Errorf("%s: sealed line that cannot be opened", name)
+		} else if err := json.Unmarshal(text, &line); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		if (line.Op == "") == log {
+			return fmt.Errorf("%s: unexpected line of id %v", name, line.Id)
+		}
+
+		if line.Op == walRemove {
+			delete(lines, line.Id)
+			return nil
+		}
+
+		if _, ok := lines[line.Id]; !ok {
+			order = append(order, line.Id)
+		}
+
+		line.Op = ""
+		lines[line.Id] = line
+
+		return nil
+
+	}
+
+	fold := func(name string, log bool) error {
+
+		file, err := os.Open(filepath.Join(dir, name))
+
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		defer file.Close()
+
+		reader := bufio.NewReader(file)
+
+		for {
+
+			text, err := reader.ReadBytes('\n')
+
+			if err != nil && err != io.EOF {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+
+			if len(bytes.TrimSpace(text)) > 0 {
+
+				if err := apply(name, text, log); err != nil {
+					return err
+				}
+
+			}
+
+			if err == io.EOF {
+				return nil
+			}
+
+		}
+
+	}
+
+	if err := fold(chainBase, false); err != nil {
+		return err
+	}
+
+	for _, delta := range deltas {
+
+		if err := fold(delta, true); err != nil {
+			return err
+		}
+
+	}
+
+	err = writeSnapshotFile(filepath.Join(dir, chainBase), func(w io.Writer) error {
+
+		w, err := sealingTo(s, w)
+
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(w)
+
+		for _, id := range order {
+
+			// an id removed and added again is twice in order
+			if line, ok := lines[id]; ok {
+
+				if err := encoder.Encode(line); err != nil {
+					return err
+				}
+
+				delete(lines, id)
+
+			}
+
+		}
+
+		return nil
+
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, delta := range deltas {
+
+		if err := os.Remove(filepath.Join(dir, delta)); err != nil {
+			return err
+		}
+
+	}
+
+	return nil
+
+}
+
+// writes the changed items and the removed ids in the format of the write-ahead log
+func (t *HeapedCache[TId, TObj]) writeDelta(ctx context.Context, w io.Writer, items []HeapedCacheItem[TId, TObj], removed []TId) error {
+
+	w, err := t.sealing(w)
+
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+
+	for _, item := range items {
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		record := walRecord[TId, TObj]{Op: walPush, Id: item.Id, Refreshed: item.Refreshed, Version: t.formatVersion, Obj: item.obj}
+
+		if !t.persisted(item.Id, item.obj) {
+			record = walRecord[TId, TObj]{Op: walRemove, Id: item.Id}
+		}
+
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+
+	}
+
+	for _, id := range removed {
+
+		if err := encoder.Encode(walRecord[TId, TObj]{Op: walRemove, Id: id}); err != nil {
+			return err
+		}
+
+	}
+
+	return nil
+
+}
+
+// returns the names of the delta files of the chain in dir, in order
+func chainDeltas(dir string) ([]string, error) {
+
+	files, err := os.ReadDir(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+
+	for _, file := range files {
+
+		if strings.HasPrefix(file.Name(), deltaPrefix) && strings.HasSuffix(file.Name(), ".ndjson") {
+			result = append(result, file.Name())
+		}
+
+	}
+
+	// zero padded, so the names sort in order
+	slices.Sort(result)
+
+	return result, nil
+
+}
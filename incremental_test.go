@@ -0,0 +1,101 @@
+package utils
+
+import (
+    "context"
+    "github.com/stretchr/testify/require"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestSaveIncremental(t *testing.T) {
+
+    t.Log("validating TestSaveIncremental")
+
+    dir := t.TempDir()
+    ctx := context.Background()
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache(10, 1, clock, WithIncrementalSnapshots[int, AccountTest]())
+
+    for i := range 5 {
+        heapedCache.Push(i, NewAccountTest(i))
+        clock.Advance(time.Second)
+    }
+
+    path, err := heapedCache.SaveIncremental(ctx, dir)
+    require.NoError(t, err)
+    require.Equal(t, filepath.Join(dir, "base.ndjson"), path)
+
+    // nothing changed
+    path, err = heapedCache.SaveIncremental(ctx, dir)
+    require.NoError(t, err)
+    require.Empty(t, path)
+
+    heapedCache.Push(1, NewAccountTest(10))
+    heapedCache.Remove(2)
+
+    path, err = heapedCache.SaveIncremental(ctx, dir)
+    require.NoError(t, err)
+    require.Equal(t, filepath.Join(dir, "delta-000001.ndjson"), path)
+
+    entries, _ := os.ReadFile(path)
+    require.Len(t, splitLines(entries), 2)
+
+    heapedCache.Push(5, NewAccountTest(5))
+    clock.Advance(time.Second)
+    heapedCache.Push(2, NewAccountTest(2))
+
+    path, err = heapedCache.SaveIncremental(ctx, dir)
+    require.NoError(t, err)
+    require.Equal(t, filepath.Join(dir, "delta-000002.ndjson"), path)
+
+    loaded := NewDeterministicHeapedCache(10, 1, clock, WithIncrementalSnapshots[int, AccountTest]())
+
+    report, err := loaded.LoadSnapshotChain(dir)
+    require.NoError(t, err)
+    require.Equal(t, RecoveryReport{Restored: 5, Replayed: 4}, report)
+    require.Equal(t, heapedCache.Snapshot(), loaded.Snapshot())
+
+    // the loaded items are not saved again
+    path, err = loaded.SaveIncremental(ctx, dir)
+    require.NoError(t, err)
+    require.Empty(t, path)
+
+    // compaction folds the deltas into the base
+    require.NoError(t, CompactSnapshotChain[int](dir))
+
+    deltas, err := chainDeltas(dir)
+    require.NoError(t, err)
+    require.Empty(t, deltas)
+
+    compacted := NewDeterministicHeapedCache[int, AccountTest](10, 1, clock)
+
+    report, err = compacted.LoadSnapshotChain(dir)
+    require.NoError(t, err)
+    require.Equal(t, RecoveryReport{Restored: 6}, report)
+    require.Equal(t, heapedCache.Snapshot(), compacted.Snapshot())
+
+    _, err = compacted.SaveIncremental(ctx, dir)
+    require.Error(t, err)
+
+}
+
+// returns the non empty lines of a file
+func splitLines(data []byte) []string {
+
+    var result []string
+
+    for _, line := range strings.Split(string(data), "\n") {
+
+        if line != "" {
+            result = append(result, line)
+        }
+
+    }
+
+    return result
+
+}
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"container/heap"
+	"sync/atomic"
+	"time"
+)
+
+// position and ordering key of an item in a secondary index
+type secondaryPosition struct {
+	index int
+	key   time.Time
+}
+
+// second heap over the same items, ordered by a key extracted from the objects
+type secondaryIndex[TId any, TObj any] struct {
+	slot   int // position of this index in HeapedCacheItem.secondary
+	key    func(obj *TObj) time.Time
+	items  []*HeapedCacheItem[TId, TObj]
+	panics *atomic.Uint64
+}
+
+// maintains a second ordering of the items by a key extracted from the objects
+// (e.g. a business event time), so they can be consumed in that order with PopByIndex(name)
+func WithIndex[TId comparable, TObj any](name string, key func(obj *TObj) time.Time) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		if key == nil {
+			return
+		}
+
+		if t.indexes == nil {
+			t.indexes = make(map[string]*secondaryIndex[TId, TObj])
+		}
+
+		if _, ok := t.indexes[name]; ok {
+			return
+		}
+
+		t.indexes[name] = &secondaryIndex[TId, TObj]{slot: len(t.indexes), key: key, panics: &t.panics}
+
+	}
+
+}
+
+func (s *secondaryIndex[TId, TObj]) add(item *HeapedCacheItem[TId, TObj]) {
+
+	item.secondary[s.slot].key = s.keyOf(item)
+	heap.Push(s, item)
+
+}
+
+func (s *secondaryIndex[TId, TObj]) remove(item *HeapedCacheItem[TId, TObj]) {
+
+	heap.Remove(s, item.secondary[s.slot].index)
+
+}
+
+func (s *secondaryIndex[TId, TObj]) update(item *HeapedCacheItem[TId, TObj]) {
+
+	item.secondary[s.slot].key = s.keyOf(item)
+	heap.Fix(s, item.secondary[s.slot].index)
+
+}
+
+// returns the key of an item, the zero time (first in the index) when key panics
+func (s *secondaryIndex[TId, TObj]) keyOf(item *HeapedCacheItem[TId, TObj]) time.Time {
+
+	var key time.Time
+
+	contain(s.panics, func() { key = s.key(item.obj) })
+
+	return key
+
+}
+
+// returns the size of the index
+func (s *secondaryIndex[TId, TObj]) Len() int {
+
+	return len(s.items)
+
+}
+
+// returns true if the key of the first item is smaller than the key of the second one
+func (s *secondaryIndex[TId, TObj]) Less(i int, j int) bool {
+
+	return s.items[i].secondary[s.slot].key.Before(s.items[j].secondary[s.slot].key)
+
+}
+
+// swaps items of given indexes
+func (s *secondaryIndex[TId, TObj]) Swap(i int, j int) {
+
+	s.items[i], s.items[j] = s.items[j], s.items[i]
+	s.items[i].secondary[s.slot].index = i
+	s.items[j].secondary[s.slot].index = j
+
+}
+
+// Adds item in the index
+func (s *secondaryIndex[TId, TObj]) Push(x any) {
+
+	item := x.(*HeapedCacheItem[TId, TObj])
+	item.secondary[s.slot].index = len(s.items)
+	s.items = append(s.items, item)
+
+}
+
+// Removes last item from the index and returns it
+func (s *secondaryIndex[TId, TObj]) Pop() any {
+
+	n := len(s.items)
+	item := s.items[n-1]
+	s.items[n-1] = nil // don't stop the GC from reclaiming the item eventually
+	s.items = s.items[0 : n-1]
+
+	return item
+
+}
+
+// removes from the cache the item with the smallest key in the given index and returns it
+// returns false when the index does not exist or the cache is empty
+func (t *HeapedCache[TId, TObj]) PopByIndex(name string) (*TObj, bool) {
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	index := t.indexes[name]
+
+	if index == nil || len(index.items) == 0 {
+		return nil, false
+	}
+
+	item := index.items[0]
+
+	t.removeItem(item)
+	t.record(OpPop, item.Id, OutcomePopped)
+	t.itemRemoved(item)
+
+	return item.obj, true
+
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+type EventTest struct {
+    Id        int
+    EventTime time.Time
+}
+
+func TestPopByIndex(t *testing.T) {
+
+    t.Log("validating TestPopByIndex")
+
+    start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+    heapedCache := NewHeapedCache(10, WithIndex[int, EventTest]("eventTime", func(obj *EventTest) time.Time { return obj.EventTime }))
+
+    // pushed in the reverse order of their event times
+    for i := range 5 {
+
+        heapedCache.Push(i, &EventTest{Id: i, EventTime: start.Add(-time.Duration(i) * time.Minute)})
+
+    }
+
+    // 2 is moved to the end of the event order
+    heapedCache.Push(2, &EventTest{Id: 2, EventTime: start.Add(time.Hour)})
+    heapedCache.Remove(3)
+
+    var ids []int
+
+    for {
+
+        obj, ok := heapedCache.PopByIndex("eventTime")
+
+        if !ok {
+            break
+        }
+
+        ids = append(ids, obj.Id)
+        require.NoError(t, heapedCache.CheckInvariants())
+
+    }
+
+    require.Equal(t, []int{4, 1, 0, 2}, ids)
+    require.Equal(t, 0, heapedCache.Len())
+
+    _, ok := heapedCache.PopByIndex("unknown")
+    require.False(t, ok)
+
+}
+
+func TestPopByIndexEviction(t *testing.T) {
+
+    t.Log("validating TestPopByIndexEviction")
+
+    start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+    heapedCache := NewHeapedCache(3, WithIndex[int, EventTest]("eventTime", func(obj *EventTest) time.Time { return obj.EventTime }))
+
+    for i := range 5 {
+
+        heapedCache.Push(i, &EventTest{Id: i, EventTime: start.Add(-time.Duration(i) * time.Minute)})
+
+    }
+
+    // 0 and 1 were evicted (oldest refreshed), so they are gone from the index too
+    obj, ok := heapedCache.PopByIndex("eventTime")
+
+    require.True(t, ok)
+    require.Equal(t, 4, obj.Id)
+    require.Equal(t, 2, heapedCache.Len())
+
+}
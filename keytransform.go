@@ -0,0 +1,46 @@
+package utils
+
+// canonicalizes every id given to the cache (lowercasing, trimming, normalizing unicode...)
+// before it is used, so near-duplicate ids share one item instead of caching it several times.
+// transform must be idempotent and cheap: it runs on every operation, sometimes more than once,
+// and without the lock. Ids handed back by the cache (callbacks, snapshots, Items...) are the
+// canonical ones. A panic of transform is counted in Stats.Panics and the id is used as given
+func WithKeyTransform[TId comparable, TObj any](transform func(id TId) TId) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		t.keyTransform = transform
+
+	}
+
+}
+
+// returns the canonical form of id (see WithKeyTransform)
+func (t *HeapedCache[TId, TObj]) key(id TId) TId {
+
+	if t.keyTransform == nil {
+		return id
+	}
+
+	result := id
+
+	contain(&t.panics, func() { result = t.keyTransform(id) })
+
+	return result
+
+}
+
+// same as key, for the ids given as any (Get): ids of another type are returned as they are
+func (t *HeapedCache[TId, TObj]) keyOf(id any) any {
+
+	if t.keyTransform == nil {
+		return id
+	}
+
+	if key, ok := id.(TId); ok {
+		return t.key(key)
+	}
+
+	return id
+
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "strings"
+    "testing"
+)
+
+func TestKeyTransform(t *testing.T) {
+
+    t.Log("validating TestKeyTransform")
+
+    heapedCache := NewHeapedCache(10, WithKeyTransform[string, AccountTest](func(id string) string {
+        return strings.ToLower(strings.TrimSpace(id))
+    }))
+
+    account := heapedCache.Push("HTTPS://Example.com/A", NewAccountTest(1))
+
+    require.Same(t, account, heapedCache.Get("https://example.com/a"))
+    require.Same(t, account, heapedCache.Get(" https://EXAMPLE.com/a "))
+    require.Same(t, account, heapedCache.GetOrAdd("https://example.COM/a", func(id string) *AccountTest { return NewAccountTest(2) }))
+
+    // the loader gets the canonical id
+    loaded := ""
+    heapedCache.GetOrAdd("B", func(id string) *AccountTest { loaded = id; return NewAccountTest(2) })
+    require.Equal(t, "b", loaded)
+
+    heapedCache.Push("https://example.com/A", NewAccountTest(3))
+    require.Equal(t, 2, heapedCache.Len())
+    require.Equal(t, 3, heapedCache.Get("https://example.com/a").Id)
+
+    require.True(t, heapedCache.Remove("HTTPS://EXAMPLE.COM/A"))
+    require.Equal(t, 1, heapedCache.Len())
+    _, ok := heapedCache.ToMap()["b"]
+    require.True(t, ok)
+
+}
+
+func TestKeyTransformPanic(t *testing.T) {
+
+    t.Log("validating TestKeyTransformPanic")
+
+    heapedCache := NewHeapedCache(10, WithKeyTransform[string, AccountTest](func(id string) string {
+
+        if id == "bad" {
+            panic("boom")
+        }
+
+        return strings.ToLower(id)
+
+    }))
+
+    heapedCache.Push("bad", NewAccountTest(1))
+    require.NotNil(t, heapedCache.Get("bad"))
+    require.Equal(t, uint64(2), heapedCache.Stats().Panics)
+
+}
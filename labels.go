@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// keys of the pprof labels set on the goroutines doing the work of a cache
+const (
+	LabelCache = "heapedcache" // name of the cache (see WithName)
+	LabelOp    = "op"          // OpLoad, or the name of the background task (trim, audit, reaper...)
+)
+
+// names the cache, e.g. after the name it is registered under, for the pprof labels of its
+// loaders and background goroutines (LabelCache), so CPU profiles of a process running several
+// caches attribute the work to the right one (go tool pprof -tagfocus heapedcache=users)
+func WithName[TId comparable, TObj any](name string) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		t.name = name
+
+	}
+
+}
+
+// returns the name given with WithName, empty when none
+func (t *HeapedCache[TId, TObj]) Name() string {
+
+	return t.name
+
+}
+
+// runs fn with the pprof labels of the cache and of op added to those of ctx (nil for none)
+func (t *HeapedCache[TId, TObj]) labeled(ctx context.Context, op string, fn func()) {
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	pprof.Do(ctx, pprof.Labels(LabelCache, t.name, LabelOp, op), func(context.Context) { fn() })
+
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+    "bytes"
+    "github.com/stretchr/testify/require"
+    "runtime/pprof"
+    "strings"
+    "testing"
+    "time"
+)
+
+// returns the goroutine profile, with the labels of every goroutine
+func goroutineProfile(t *testing.T) string {
+
+    var buf bytes.Buffer
+
+    require.NoError(t, pprof.Lookup("goroutine").WriteTo(&buf, 1))
+
+    return buf.String()
+
+}
+
+func TestLabels(t *testing.T) {
+
+    t.Log("validating TestLabels")
+
+    heapedCache := NewHeapedCache(10,
+        WithName[int, AccountTest]("users"),
+        WithAsyncTrim[int, AccountTest](20, time.Hour),
+    )
+    defer heapedCache.Close()
+
+    require.Equal(t, "users", heapedCache.Name())
+
+    var profile string
+
+    heapedCache.GetOrAdd(1, func(id int) *AccountTest {
+        profile = goroutineProfile(t)
+        return NewAccountTest(id)
+    })
+
+    require.Contains(t, profile, `"heapedcache":"users"`)
+    require.Contains(t, profile, `"op":"load"`)
+
+    // the background goroutines are labeled too
+    require.Eventually(t, func() bool {
+        return strings.Contains(goroutineProfile(t), `"op":"trim"`)
+    }, time.Second, time.Millisecond)
+
+}
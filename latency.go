@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"context"
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// operations timed by the latency histograms, besides OpPush and OpPop
+const (
+	OpGet  = "get"
+	OpLoad = "load" // execution of the loading function of GetOrAdd
+)
+
+// 4 sub-buckets per power of two: bucket bounds are at most 25% apart
+const (
+	histogramSubBits = 2
+	histogramSub     = 1 << histogramSubBits
+	histogramBuckets = (64 - histogramSubBits + 1) * histogramSub
+)
+
+// log-linear histogram of durations (HDR style), safe for concurrent use without locks
+type histogram struct {
+	counts [histogramBuckets]atomic.Uint64
+	sum    atomic.Int64
+}
+
+// snapshot of a latency histogram
+type Histogram struct {
+	Count   uint64
+	Sum     time.Duration
+	Buckets []HistogramBucket // non-empty buckets only, ascending
+}
+
+// number of durations up to UpperBound (and above the previous bucket)
+type HistogramBucket struct {
+	UpperBound time.Duration
+	Count      uint64
+}
+
+// latency histograms of the timed operations
+type latencies struct {
+	histograms map[string]*histogram // fixed when the option is applied, so read without locking
+}
+
+// times Get, Push, Pop and the loading function of GetOrAdd into histograms
+// reported by Stats (a couple of clock reads per call when enabled)
+func WithLatencyHistograms[TId comparable, TObj any]() Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		t.latencies = &latencies{histograms: map[string]*histogram{
+			OpGet:  {},
+			OpPush: {},
+			OpLoad: {},
+			OpPop:  {},
+		}}
+
+	}
+
+}
+
+// records the time elapsed since start for op
+// meant to be deferred, as in: defer t.latencies.observe(OpGet, time.Now())
+func (l *latencies) observe(op string, start time.Time) {
+
+	l.histograms[op].observe(time.Since(start))
+
+}
+
+// returns a snapshot of every histogram
+func (l *latencies) snapshot() map[string]Histogram {
+
+	result := make(map[string]Histogram, len(l.histograms))
+
+	for op, h := range l.histograms {
+		result[op] = h.snapshot()
+	}
+
+	return result
+
+}
+
+// executes the loading function of GetOrAdd, timing it when enabled
+func (t *HeapedCache[TId, TObj]) load(ctx context.Context, id TId, fn func(id TId) *TObj) (*TObj, error) {
+
+	if t.latencies != nil {
+		defer t.latencies.observe(OpLoad, time.Now())
+	}
+
+	var result *TObj
+
+	var err error
+
+	t.labeled(ctx, OpLoad, func() { err = contain(&t.panics, func() { result = fn(id) }) })
+
+	return result, err
+
+}
+
+func (h *histogram) observe(d time.Duration) {
+
+	d = max(d, 0)
+	h.counts[bucketOf(uint64(d))].Add(1)
+	h.sum.Add(int64(d))
+
+}
+
+func (h *histogram) snapshot() Histogram {
+
+	result := Histogram{Sum: time.Duration(h.sum.Load())}
+
+	for i := range h.counts {
+
+		if count := h.counts[i].Load(); count > 0 {
+			result.Count += count
+			result.Buckets = append(result.Buckets, HistogramBucket{UpperBound: bucketUpperBound(i), Count: count})
+		}
+
+	}
+
+	return result
+
+}
+
+// returns the bucket of a value: values below histogramSub have their own bucket,
+// the others are split by power of two and then by their next histogramSubBits bits
+func bucketOf(v uint64) int {
+
+	if v < histogramSub {
+		return int(v)
+	}
+
+	exp := bits.Len64(v) - 1
+
+	return (exp-histogramSubBits+1)*histogramSub + int((v>>(exp-histogramSubBits))&(histogramSub-1))
+
+}
+
+// returns the greatest value of a bucket
+func bucketUpperBound(i int) time.Duration {
+
+	if i < histogramSub {
+		return time.Duration(i)
+	}
+
+	exp := i/histogramSub + histogramSubBits - 1
+	lower := uint64(histogramSub+i%histogramSub) << (exp - histogramSubBits)
+	upper := lower + 1<<(exp-histogramSubBits) - 1
+
+	return time.Duration(min(upper, math.MaxInt64))
+
+}
+
+// returns the duration below which a fraction q of the durations fall
+// (the upper bound of its bucket, so it overestimates by at most 25%)
+func (h Histogram) Quantile(q float64) time.Duration {
+
+	if h.Count == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(q * float64(h.Count)))
+	seen := uint64(0)
+
+	for _, bucket := range h.Buckets {
+
+		if seen += bucket.Count; seen >= rank {
+			return bucket.UpperBound
+		}
+
+	}
+
+	return h.Buckets[len(h.Buckets)-1].UpperBound
+
+}
+
+// returns the average duration
+func (h Histogram) Mean() time.Duration {
+
+	if h.Count == 0 {
+		return 0
+	}
+
+	return h.Sum / time.Duration(h.Count)
+
+}
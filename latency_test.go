@@ -0,0 +1,107 @@
+package utils
+
+import (
+    "bytes"
+    "github.com/stretchr/testify/require"
+    "math"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestHistogramBuckets(t *testing.T) {
+
+    t.Log("validating TestHistogramBuckets")
+
+    for _, v := range []uint64{0, 1, 3, 4, 5, 7, 8, 9, 1000, 123456789, math.MaxInt64} {
+
+        i := bucketOf(v)
+
+        require.LessOrEqual(t, v, uint64(bucketUpperBound(i)), "value %d", v)
+
+        if i > 0 {
+            require.Greater(t, v, uint64(bucketUpperBound(i-1)), "value %d", v)
+        }
+
+    }
+
+    var h histogram
+
+    for i := 1; i <= 100; i++ {
+
+        h.observe(time.Duration(i) * time.Microsecond)
+
+    }
+
+    snapshot := h.snapshot()
+
+    require.Equal(t, uint64(100), snapshot.Count)
+    require.Equal(t, 50500*time.Nanosecond, snapshot.Mean())
+
+    p50 := snapshot.Quantile(0.5)
+    require.GreaterOrEqual(t, p50, 50*time.Microsecond)
+    require.LessOrEqual(t, p50, 63*time.Microsecond)
+    require.GreaterOrEqual(t, snapshot.Quantile(1), 100*time.Microsecond)
+
+}
+
+func TestLatencyStats(t *testing.T) {
+
+    t.Log("validating TestLatencyStats")
+
+    heapedCache := NewHeapedCache(10, WithLatencyHistograms[int, AccountTest]())
+
+    for i := range 5 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+        heapedCache.Get(i)
+
+    }
+
+    heapedCache.GetOrAdd(5, func(id int) *AccountTest { time.Sleep(time.Millisecond); return NewAccountTest(id) })
+    heapedCache.Pop()
+
+    stats := heapedCache.Stats()
+
+    require.Equal(t, 5, stats.Len)
+    require.Equal(t, 10, stats.MaxRows)
+    require.Equal(t, uint64(5), stats.Latency[OpPush].Count)
+    require.Equal(t, uint64(5), stats.Latency[OpGet].Count)
+    require.Equal(t, uint64(1), stats.Latency[OpLoad].Count)
+    require.Equal(t, uint64(1), stats.Latency[OpPop].Count)
+    require.GreaterOrEqual(t, stats.Latency[OpLoad].Quantile(1), time.Millisecond)
+
+    var buffer bytes.Buffer
+    require.NoError(t, stats.WritePrometheus(&buffer, "test_cache"))
+
+    text := buffer.String()
+    require.Contains(t, text, "test_cache_items 5\n")
+    require.Contains(t, text, "# TYPE test_cache_operation_duration_seconds histogram\n")
+    require.Contains(t, text, "test_cache_operation_duration_seconds_bucket{op=\"get\",le=\"+Inf\"} 5\n")
+    require.Contains(t, text, "test_cache_operation_duration_seconds_count{op=\"load\"} 1\n")
+
+    // every bucket is written, empty or not, so they are the same on every scrape
+    for _, op := range []string{OpGet, OpPush, OpLoad, OpPop} {
+        require.Equal(t, len(prometheusBuckets)+1, strings.Count(text, "test_cache_operation_duration_seconds_bucket{op=\""+op+"\""))
+    }
+
+    require.Contains(t, text, "test_cache_operation_duration_seconds_bucket{op=\"load\",le=\"0.0005\"} 0\n")
+    require.Contains(t, text, "test_cache_operation_duration_seconds_bucket{op=\"load\",le=\"10\"} 1\n")
+
+    // durations above the last bound are only counted by +Inf
+    buffer.Reset()
+    stats = Stats{Latency: map[string]Histogram{OpGet: {Count: 2, Sum: 20 * time.Second, Buckets: []HistogramBucket{{UpperBound: 3, Count: 1}, {UpperBound: 20 * time.Second, Count: 1}}}}}
+    require.NoError(t, stats.WritePrometheus(&buffer, "test_cache"))
+    require.Contains(t, buffer.String(), "test_cache_operation_duration_seconds_bucket{op=\"get\",le=\"1e-06\"} 1\n")
+    require.Contains(t, buffer.String(), "test_cache_operation_duration_seconds_bucket{op=\"get\",le=\"10\"} 1\n")
+    require.Contains(t, buffer.String(), "test_cache_operation_duration_seconds_bucket{op=\"get\",le=\"+Inf\"} 2\n")
+
+    // without the option, no latency is reported
+    stats = NewHeapedCache[int, AccountTest](10).Stats()
+    require.Nil(t, stats.Latency)
+
+    buffer.Reset()
+    require.NoError(t, stats.WritePrometheus(&buffer, "test_cache"))
+    require.NotContains(t, buffer.String(), "duration")
+
+}
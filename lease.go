@@ -0,0 +1,166 @@
+package utils
+
+import "time"
+
+// item handed out by Lease, to be acknowledged with Ack before its deadline
+type LeasedEntry[TId any, TObj any] struct {
+	LeaseID   uint64
+	Id        TId
+	Obj       *TObj
+	Refreshed time.Time
+	Deadline  time.Time // when the item returns to the cache unless acknowledged
+}
+
+type lease[TId any, TObj any] struct {
+	item     *HeapedCacheItem[TId, TObj]
+	deadline time.Time
+}
+
+// pops up to n of the oldest items (as Pop does) for consumers that acknowledge them:
+// an item not acknowledged with Ack within visibilityTimeout returns to the cache in its
+// original position (same refreshed time), so it is handed out again, in order, when a
+// consumer crashes after taking it. If the id was pushed again meanwhile, the newer item wins.
+// Expired leases are returned by the next operation taking the cache lock, reads included
+// (Get, Len, ...), so they are back before anything looks at the cache. n <= 0 leases nothing
+func (t *HeapedCache[TId, TObj]) Lease(n int, visibilityTimeout time.Duration) []LeasedEntry[TId, TObj] {
+
+	n = max(n, 0)
+
+	t.lock(OpPop)
+	defer t.unlock()
+
+	t.settle()
+
+	if t.leases == nil {
+		t.leases = make(map[uint64]*lease[TId, TObj])
+	}
+
+	deadline := t.now().Add(visibilityTimeout)
+	result := make([]LeasedEntry[TId, TObj], 0, min(n, len(t.sliceItems)))
+
+	for len(result) < n && len(t.sliceItems) > 0 {
+
+		item := t.sliceItems.pop()
+		delete(t.mapItems, item.Id)
+		t.record(OpPop, item.Id, OutcomeLeased)
+		t.itemRemoved(item)
+
+		t.leaseSeq++
+		t.leases[t.leaseSeq] = &lease[TId, TObj]{item: item, deadline: deadline}
+
+		result = append(result, LeasedEntry[TId, TObj]{
+			LeaseID:   t.leaseSeq,
+			Id:        item.Id,
+			Obj:       item.obj,
+			Refreshed: item.Refreshed,
+			Deadline:  deadline,
+		})
+
+	}
+
+	return result
+
+}
+
+// acknowledges a leased item, which then leaves the cache for good
+// returns false when the lease is unknown, already acknowledged or expired
+// (an expired item went back to the cache and will be handed out again)
+func (t *HeapedCache[TId, TObj]) Ack(leaseID uint64) bool {
+
+	t.lock(OpPop)
+	defer t.unlock()
+
+	if _, ok := t.leases[leaseID]; !ok {
+		return false
+	}
+
+	delete(t.leases, leaseID)
+
+	return true
+
+}
+
+// returns the number of leased items not yet acknowledged
+func (t *HeapedCache[TId, TObj]) Leased() int {
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	return len(t.leases)
+
+}
+
+// puts the items of the expired leases back in the cache
+// must be called under the lock (lock calls it)
+func (t *HeapedCache[TId, TObj]) expireLeases() {
+
+	if len(t.leases) == 0 {
+		return
+	}
+
+	now := t.now()
+
+	var expired []uint64
+
+	for id, lease := range t.leases {
+
+		if !now.Before(lease.deadline) {
+			expired = append(expired, id)
+		}
+
+	}
+
+	t.restoreLeases(expired)
+
+}
+
+// puts the items of every lease back in the cache, expired or not, so the shutdown snapshot
+// keeps them: an Ack arriving afterwards returns false and the item is handed out again
+// after the restart (see OnShutdown)
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) requeueLeases() {
+
+	ids := make([]uint64, 0, len(t.leases))
+
+	for id := range t.leases {
+		ids = append(ids, id)
+	}
+
+	t.restoreLeases(ids)
+
+}
+
+// puts the items of the given leases back in the cache (in a seeded order on a deterministic cache)
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) restoreLeases(ids []uint64) {
+
+	seededOrder(t.random, ids, func(id uint64) uint64 { return id })
+
+	for _, id := range ids {
+
+		lease := t.leases[id]
+		delete(t.leases, id)
+		t.restore(lease.item)
+
+	}
+
+}
+
+// puts an item taken out of the cache (leased or being drained) back in its original position,
+// unless its id was pushed again meanwhile (the newer item wins). It is not new intake, so it is
+// put back after OnShutdown as well, instead of being lost with the write that failed
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) restore(item *HeapedCacheItem[TId, TObj]) {
+
+	if t.mapItems[item.Id] != nil {
+		return
+	}
+
+	t.mapItems[item.Id] = item
+	t.sliceItems.push(item)
+	t.record(OpPush, item.Id, OutcomeReturned)
+	t.itemAdded(item)
+
+	t.evictOverflow()
+
+}
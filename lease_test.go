@@ -0,0 +1,96 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestLease(t *testing.T) {
+
+    t.Log("validating TestLease")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache[int, AccountTest](10, 1, clock)
+
+    for i := range 5 {
+        heapedCache.Push(i, NewAccountTest(i))
+        clock.Advance(time.Second)
+    }
+
+    leased := heapedCache.Lease(2, time.Minute)
+    require.Len(t, leased, 2)
+    require.Equal(t, 0, leased[0].Id)
+    require.Equal(t, 1, leased[1].Id)
+    require.Equal(t, 3, heapedCache.Len())
+    require.Equal(t, 2, heapedCache.Leased())
+
+    // a second consumer gets the next items
+    other := heapedCache.Lease(1, time.Minute)
+    require.Equal(t, 2, other[0].Id)
+
+    require.True(t, heapedCache.Ack(leased[0].LeaseID))
+    require.False(t, heapedCache.Ack(leased[0].LeaseID))
+    require.True(t, heapedCache.Ack(other[0].LeaseID))
+
+    // the consumer of item 1 crashed: it comes back first once the lease expires
+    clock.Advance(time.Minute)
+
+    require.False(t, heapedCache.Ack(leased[1].LeaseID))
+    require.Equal(t, 0, heapedCache.Leased())
+    require.Equal(t, 3, heapedCache.Len())
+
+    again := heapedCache.Lease(10, time.Minute)
+    require.Len(t, again, 3)
+    require.Equal(t, 1, again[0].Id)
+    require.Equal(t, leased[1].Refreshed, again[0].Refreshed)
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
+
+func TestLeaseReturnsToNewerItem(t *testing.T) {
+
+    t.Log("validating TestLeaseReturnsToNewerItem")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache[int, AccountTest](10, 1, clock)
+
+    heapedCache.Push(1, NewAccountTest(1))
+    leased := heapedCache.Lease(1, time.Minute)
+
+    newer := NewAccountTest(1)
+    heapedCache.Push(1, newer)
+
+    clock.Advance(time.Minute)
+    require.Equal(t, 0, heapedCache.Leased())
+    require.Same(t, newer, heapedCache.Get(1))
+    require.NotSame(t, leased[0].Obj, heapedCache.Get(1))
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
+
+func TestLeaseReclaimedOnRead(t *testing.T) {
+
+    t.Log("validating TestLeaseReclaimedOnRead")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache[int, AccountTest](10, 1, clock)
+
+    heapedCache.Push(1, NewAccountTest(1))
+    heapedCache.Push(2, NewAccountTest(2))
+
+    require.Empty(t, heapedCache.Lease(-1, time.Minute))
+    require.Empty(t, heapedCache.Lease(0, time.Minute))
+    require.Equal(t, 2, heapedCache.Len())
+
+    require.Len(t, heapedCache.Lease(1, time.Minute), 1)
+    require.Nil(t, heapedCache.Get(1))
+
+    // the expired lease is back for the next read, without calling Lease, Ack or Leased
+    clock.Advance(time.Minute)
+
+    require.NotNil(t, heapedCache.Get(1))
+    require.Equal(t, 2, heapedCache.Len())
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
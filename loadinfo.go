@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// settings returned by the loader of GetOrAddLoad along with the object it loaded,
+// e.g. derived from the Cache-Control and Expires headers of the response it came from
+// (see HTTPLoadInfo), overriding the ones of the cache for this object
+type LoadInfo struct {
+	TTL     time.Duration // time to live of the object (0: the one of the cache, see PushWithTTL)
+	Cost    int64         // cost of the object (0: the one computed by WithCost)
+	NoStore bool          // the object is returned without being cached
+	Tags    []string      // tags of the object, removing it along with the others by InvalidateTag
+}
+
+// what the loader of GetOrAddWithTTL or GetOrAddLoad returned besides the object
+type loadResult struct {
+	LoadInfo
+	err error
+}
+
+// same as TryGetOrAdd, with fn returning the LoadInfo of the loaded object along with it, so
+// a loader backed by HTTP caches every object as long as its upstream allows. When fn returns
+// an error, it is returned and nothing is cached (nor remembered as a miss, see CacheNilAsNegative)
+func (t *HeapedCache[TId, TObj]) GetOrAddLoad(id TId, fn func(id TId) (*TObj, LoadInfo, error)) (*TObj, error) {
+
+	loaded := &loadResult{}
+
+	load := func(id TId) *TObj {
+
+		obj, info, err := fn(id)
+		loaded.LoadInfo, loaded.err = info, err
+
+		if err != nil {
+			return nil
+		}
+
+		return obj
+
+	}
+
+	return t.getOrAdd(nil, id, load, EntryOptions{loaded: loaded})
+
+}
+
+// returns the LoadInfo honouring the caching headers of an HTTP response: no-store and no-cache
+// (which would need revalidating) are not cached, s-maxage or max-age give the time to live, and
+// so does Expires, relative to the Date header (or now, without one), when there is neither.
+// Without any of them the ttl of the cache applies
+func HTTPLoadInfo(header http.Header, now time.Time) LoadInfo {
+
+	var info LoadInfo
+
+	maxAge, sharedMaxAge := -1, -1
+
+	for _, directive := range strings.Split(strings.ToLower(header.Get("Cache-Control")), ",") {
+
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		seconds, err := strconv.Atoi(strings.Trim(value, `"`))
+
+		switch {
+
+		case name == "no-store" || name == "no-cache":
+			info.NoStore = true
+
+		case name == "max-age" && err == nil:
+			maxAge = seconds
+
+		case name == "s-maxage" && err == nil:
+			sharedMaxAge = seconds
+
+		}
+
+	}
+
+	if info.NoStore {
+		return info
+	}
+
+	switch {
+
+	case sharedMaxAge >= 0:
+		info.TTL = time.Duration(sharedMaxAge) * time.Second
+
+	case maxAge >= 0:
+		info.TTL = time.Duration(maxAge) * time.Second
+
+	case header.Get("Expires") != "":
+
+		if date, err := http.ParseTime(header.Get("Date")); err == nil {
+			now = date
+		}
+
+		// an invalid date means already expired
+		expires, _ := http.ParseTime(header.Get("Expires"))
+		info.TTL = max(expires.Sub(now), 0)
+
+	default:
+		return info
+
+	}
+
+	// stale as soon as it is loaded
+	info.NoStore = info.TTL <= 0
+
+	return info
+
+}
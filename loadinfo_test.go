@@ -0,0 +1,131 @@
+package utils
+
+import (
+    "errors"
+    "github.com/stretchr/testify/require"
+    "net/http"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestGetOrAddLoad(t *testing.T) {
+
+    t.Log("validating TestGetOrAddLoad")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache(10, 1, clock,
+        WithTTL[int, AccountTest](time.Hour),
+        WithNilPolicy[int, AccountTest](CacheNilAsNegative(time.Hour)))
+
+    loads := 0
+    info := LoadInfo{TTL: time.Minute, Cost: 5}
+    var loadErr error
+
+    load := func(id int) (*AccountTest, LoadInfo, error) {
+
+        loads++
+
+        if loadErr != nil {
+            return nil, LoadInfo{}, loadErr
+        }
+
+        return NewAccountTest(id), info, nil
+
+    }
+
+    // the loaded ttl and cost override the ones of the cache
+    result, err := heapedCache.GetOrAddLoad(1, load)
+    require.NoError(t, err)
+    require.Equal(t, 1, result.Id)
+    require.Equal(t, int64(5), heapedCache.Cost())
+
+    clock.Advance(time.Minute)
+    require.Nil(t, heapedCache.Get(1))
+
+    // no-store returns the object without caching it
+    info = LoadInfo{NoStore: true}
+    result, err = heapedCache.GetOrAddLoad(2, load)
+    require.NoError(t, err)
+    require.Equal(t, 2, result.Id)
+    require.Equal(t, 0, heapedCache.Len())
+
+    // a failed load is returned, and the next call loads again
+    loadErr = errors.New("upstream unavailable")
+    _, err = heapedCache.GetOrAddLoad(3, load)
+    require.ErrorIs(t, err, loadErr)
+
+    loadErr = nil
+    info = LoadInfo{}
+    result, err = heapedCache.GetOrAddLoad(3, load)
+    require.NoError(t, err)
+    require.Equal(t, 3, result.Id)
+    require.Equal(t, 4, loads)
+
+    // without a ttl, the one of the cache applies
+    clock.Advance(59 * time.Minute)
+    require.NotNil(t, heapedCache.Get(3))
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
+
+func TestHTTPLoadInfo(t *testing.T) {
+
+    t.Log("validating TestHTTPLoadInfo")
+
+    now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+    header := func(pairs ...string) http.Header {
+
+        result := http.Header{}
+
+        for i := 0; i < len(pairs); i += 2 {
+            result.Set(pairs[i], pairs[i+1])
+        }
+
+        return result
+
+    }
+
+    require.Equal(t, LoadInfo{}, HTTPLoadInfo(header(), now))
+    require.Equal(t, LoadInfo{TTL: time.Minute}, HTTPLoadInfo(header("Cache-Control", "public, max-age=60"), now))
+    require.Equal(t, LoadInfo{TTL: 2 * time.Minute}, HTTPLoadInfo(header("Cache-Control", "max-age=60, s-maxage=120"), now))
+    require.Equal(t, LoadInfo{NoStore: true}, HTTPLoadInfo(header("Cache-Control", "No-Store"), now))
+    require.Equal(t, LoadInfo{NoStore: true}, HTTPLoadInfo(header("Cache-Control", "no-cache, max-age=60"), now))
+    require.Equal(t, LoadInfo{NoStore: true}, HTTPLoadInfo(header("Cache-Control", "max-age=0"), now))
+
+    // max-age wins over Expires, which is relative to Date
+    expires := now.Add(time.Hour).Format(http.TimeFormat)
+    require.Equal(t, LoadInfo{TTL: time.Minute}, HTTPLoadInfo(header("Cache-Control", "max-age=60", "Expires", expires), now))
+    require.Equal(t, LoadInfo{TTL: time.Hour}, HTTPLoadInfo(header("Expires", expires), now))
+    require.Equal(t, LoadInfo{TTL: 30 * time.Minute}, HTTPLoadInfo(header("Expires", expires, "Date", now.Add(30*time.Minute).Format(http.TimeFormat)), now))
+    require.Equal(t, LoadInfo{NoStore: true}, HTTPLoadInfo(header("Expires", "0"), now))
+
+}
+
+func TestGetOrAddLoadTags(t *testing.T) {
+
+    t.Log("validating TestGetOrAddLoadTags")
+
+    heapedCache := NewHeapedCache[string, AccountTest](10)
+
+    // the loader tags each object with the upstream it came from
+    load := func(id string) (*AccountTest, LoadInfo, error) {
+
+        upstream, _, _ := strings.Cut(id, "/")
+
+        return &AccountTest{Name: id}, LoadInfo{Tags: []string{upstream}}, nil
+
+    }
+
+    for _, id := range []string{"eu/1", "eu/2", "us/1"} {
+        _, err := heapedCache.GetOrAddLoad(id, load)
+        require.NoError(t, err)
+    }
+
+    require.Equal(t, 2, heapedCache.InvalidateTag("eu"))
+    require.Nil(t, heapedCache.Get("eu/1"))
+    require.NotNil(t, heapedCache.Get("us/1"))
+    require.Equal(t, 1, heapedCache.Len())
+
+}
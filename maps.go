@@ -0,0 +1,112 @@
+package utils
+
+import "time"
+
+// loads the items of a plain map into the cache, all of them with the given refreshed time.
+// Items already cached under the same id are replaced.
+// Every item is admitted as Push admits it: the overflow policy, the namespace quotas and
+// OnShutdown may refuse it, and the misses remembered for its id are forgotten. The items are
+// appended to the heap and its order is restored once (heapify) instead of pushing item by item,
+// and whatever goes beyond maxRows is evicted afterwards.
+// returns the number of items cached
+func (t *HeapedCache[TId, TObj]) FromMap(items map[TId]*TObj, refreshed time.Time) int {
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	if t.shutdown {
+
+		for id := range items {
+			t.record(OpPush, t.key(id), OutcomeRejected)
+		}
+
+		return 0
+
+	}
+
+	// the heap is out of order until the end of the import, so admit decides on the oldest item before it
+	t.settle()
+	older := len(t.sliceItems) > 0 && refreshed.Before(t.sliceItems[0].Refreshed)
+
+	cached := 0
+
+	for id, obj := range items {
+
+		if obj == nil {
+			continue
+		}
+
+		id = t.key(id)
+
+		if findItem := t.mapItems[id]; findItem != nil {
+
+			old := findItem.obj
+			findItem.obj = obj
+			findItem.Refreshed = refreshed
+			findItem.seq = t.nextSeq()
+
+			if t.expiries != nil {
+				t.expiries.fix(findItem)
+			}
+
+			t.record(OpPush, id, OutcomeUpdated)
+			t.itemUpdated(findItem, old)
+			cached++
+
+			continue
+
+		}
+
+		t.clearNegative(id)
+
+		if len(t.sliceItems) >= t.capacity() && (t.overflow == RejectNew || (t.overflow == DropNewestIfOlder && older)) {
+			t.record(OpPush, id, OutcomeRejected)
+			continue
+		}
+
+		t.enforceQuota(id)
+
+		newItem := &HeapedCacheItem[TId, TObj]{
+			Id:        id,
+			index:     len(t.sliceItems),
+			Refreshed: refreshed,
+			obj:       obj,
+			seq:       t.nextSeq(),
+		}
+
+		t.mapItems[id] = newItem
+		t.sliceItems = append(t.sliceItems, newItem)
+		t.record(OpPush, id, OutcomeAdded)
+		t.itemAdded(newItem)
+		cached++
+
+	}
+
+	t.sliceItems.init()
+
+	if t.deferredFix != nil {
+		t.deferredFix.pending = false
+	}
+
+	t.evictOverflow()
+
+	return cached
+
+}
+
+// returns a plain map with all the cached items
+// the map is a copy, but the objects are the same pointers stored in the cache
+func (t *HeapedCache[TId, TObj]) ToMap() map[TId]*TObj {
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	result := make(map[TId]*TObj, len(t.sliceItems))
+
+	for _, item := range t.sliceItems {
+		result[item.Id] = item.obj
+	}
+
+	return result
+
+}
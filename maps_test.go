@@ -0,0 +1,188 @@
+package utils
+
+import (
+    "context"
+    "github.com/stretchr/testify/require"
+    "strconv"
+    "testing"
+    "time"
+)
+
+func TestFromMap(t *testing.T) {
+
+    t.Log("validating TestFromMap")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    heapedCache.Push(1, NewAccountTest(100))
+
+    items := make(map[int]*AccountTest)
+
+    for i := range 5 {
+
+        items[i] = NewAccountTest(i)
+
+    }
+
+    refreshed := time.Now().Add(-time.Hour)
+
+    heapedCache.FromMap(items, refreshed)
+
+    require.Equal(t, 5, heapedCache.Len())
+    require.Equal(t, 1, heapedCache.Get(1).Id)
+
+    _, popRefreshed := heapedCache.PopWithRefreshed()
+    require.True(t, popRefreshed.Equal(refreshed))
+
+}
+
+func TestFromMapOverFlow(t *testing.T) {
+
+    t.Log("validating TestFromMapOverFlow")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    for i := range 5 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    items := make(map[int]*AccountTest)
+
+    for i := 100; i < 120; i++ {
+
+        items[i] = NewAccountTest(i)
+
+    }
+
+    // imported items are older than the pushed ones, so they are evicted first
+    heapedCache.FromMap(items, time.Now().Add(-time.Hour))
+
+    require.Equal(t, 10, heapedCache.Len())
+
+    for i := range 5 {
+
+        require.NotNil(t, heapedCache.Get(i))
+
+    }
+
+}
+
+func TestFromMapAdmission(t *testing.T) {
+
+    t.Log("validating TestFromMapAdmission")
+
+    // overflow policy
+    rejecting := NewHeapedCache(3, WithOverflowPolicy[int, AccountTest](RejectNew))
+
+    items := make(map[int]*AccountTest)
+
+    for i := range 5 {
+
+        items[i] = NewAccountTest(i)
+
+    }
+
+    require.Equal(t, 3, rejecting.FromMap(items, time.Now()))
+    require.Equal(t, 3, rejecting.Len())
+
+    // negative cache
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    negative := NewHeapedCache(10,
+        WithClock[int, AccountTest](clock.Now),
+        WithNilPolicy[int, AccountTest](CacheNilAsNegative(time.Minute)),
+    )
+
+    require.Nil(t, negative.GetOrAdd(0, func(id int) *AccountTest { return nil }))
+    require.Equal(t, 1, negative.FromMap(map[int]*AccountTest{0: NewAccountTest(0)}, clock.Now()))
+    require.Equal(t, 0, negative.GetOrAdd(0, func(id int) *AccountTest { return nil }).Id)
+
+    // namespace quota
+    quoted := NewHeapedCache[string, AccountTest](100)
+    sessions := Namespace(quoted, "session:")
+    sessions.SetQuota(2)
+
+    sessionItems := make(map[string]*AccountTest)
+
+    for i := range 5 {
+
+        sessionItems["session:"+strconv.Itoa(i)] = NewAccountTest(i)
+
+    }
+
+    require.Equal(t, 5, quoted.FromMap(sessionItems, time.Now()))
+    require.Equal(t, 2, sessions.Len())
+    require.Equal(t, 2, quoted.Len())
+
+    // shutdown
+    _, err := negative.OnShutdown(context.Background())
+    require.NoError(t, err)
+    require.Equal(t, 0, negative.FromMap(map[int]*AccountTest{1: NewAccountTest(1)}, clock.Now()))
+    require.Nil(t, negative.Get(1))
+
+    require.NoError(t, quoted.CheckInvariants())
+
+}
+
+func TestFromMapBulk(t *testing.T) {
+
+    t.Log("validating TestFromMapBulk")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache[int, AccountTest](100, 1, clock)
+
+    for i := range 50 {
+        heapedCache.Push(i, NewAccountTest(i))
+        clock.Advance(time.Second)
+    }
+
+    items := make(map[int]*AccountTest)
+
+    for i := 1000; i < 1500; i++ {
+        items[i] = NewAccountTest(i)
+    }
+
+    // newer than every cached item: the cached ones are evicted first, then imported ones
+    require.Equal(t, 500, heapedCache.FromMap(items, clock.Now()))
+    require.Equal(t, 100, heapedCache.Len())
+    require.Equal(t, uint64(450), heapedCache.Stats().Evictions)
+    require.Nil(t, heapedCache.Get(0))
+    require.NoError(t, heapedCache.CheckInvariants())
+
+    // older than the oldest cached item of a full cache
+    dropping := NewDeterministicHeapedCache(2, 1, clock, WithOverflowPolicy[int, AccountTest](DropNewestIfOlder))
+    dropping.Push(1, NewAccountTest(1))
+    dropping.Push(2, NewAccountTest(2))
+
+    require.Equal(t, 0, dropping.FromMap(map[int]*AccountTest{3: NewAccountTest(3)}, clock.Now().Add(-time.Hour)))
+    require.Equal(t, 1, dropping.FromMap(map[int]*AccountTest{3: NewAccountTest(3)}, clock.Now()))
+    require.Equal(t, 2, dropping.Len())
+    require.NoError(t, dropping.CheckInvariants())
+
+}
+
+func TestToMap(t *testing.T) {
+
+    t.Log("validating TestToMap")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    for i := range 20 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    items := heapedCache.ToMap()
+
+    require.Equal(t, 10, len(items))
+
+    for id, item := range items {
+
+        require.Equal(t, id, item.Id)
+        require.Same(t, heapedCache.Get(id), item)
+
+    }
+
+}
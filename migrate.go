@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// line of a snapshot or of a write-ahead log, with the object still encoded
+type persistedLine[TId any] struct {
+	Op        string          `json:"op,omitempty"` // empty in snapshots
+	Id        TId             `json:"id"`
+	Refreshed time.Time       `json:"refreshed"`
+	Version   int             `json:"version"`
+	Obj       json.RawMessage `json:"obj"`
+}
+
+// sets the format version of the objects of the cache, written with every item of its snapshots
+// and write-ahead log (0 by default). Bump it when the layout of TObj changes in a way its JSON
+// decoding cannot absorb, and register a WithMigrator for the previous versions, so a deploy
+// changing the struct converts the persisted cache instead of throwing it away
+func WithFormatVersion[TId comparable, TObj any](version int) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		t.formatVersion = version
+
+	}
+
+}
+
+// registers the conversion of the objects persisted with format version fromVersion (see
+// WithFormatVersion) into the current TObj, used by Recover. migrate gets the JSON of the object
+// and returns the object (or an error, skipping it). Items of another version without a migrator
+// are skipped; both are counted in RecoveryReport.Incompatible.
+// A migrator may decode the old layout into its own struct and convert it, or patch the JSON
+func WithMigrator[TId comparable, TObj any](fromVersion int, migrate func(raw []byte) (*TObj, error)) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		if t.migrators == nil {
+			t.migrators = make(map[int]func(raw []byte) (*TObj, error))
+		}
+
+		t.migrators[fromVersion] = migrate
+
+	}
+
+}
+
+// decodes a line of a snapshot (log false) or of a write-ahead log, opening it when it is sealed
+// (see WithEncryption) and migrating its object when it has another format version;
+// returns false, counting it in report, when it cannot be used
+func (t *HeapedCache[TId, TObj]) decodeLine(line []byte, log bool, report *RecoveryReport) (walRecord[TId, TObj], bool) {
+
+	var raw persistedLine[TId]
+
+	line, opened := openLine(t.sealer, line)
+
+	if !opened || json.Unmarshal(line, &raw) != nil || (raw.Op == "") == log {
+		report.Corrupt++
+		return walRecord[TId, TObj]{}, false
+	}
+
+	record := walRecord[TId, TObj]{Op: raw.Op, Id: raw.Id, Refreshed: raw.Refreshed}
+
+	if raw.Op == walRemove {
+		return record, true
+	}
+
+	record.Op = walPush
+
+	if raw.Version == t.formatVersion {
+
+		if json.Unmarshal(raw.Obj, &record.Obj) != nil || record.Obj == nil {
+			report.Corrupt++
+			return record, false
+		}
+
+		return record, true
+
+	}
+
+	var err error
+
+	if migrate := t.migrators[raw.Version]; migrate == nil {
+		err = fmt.Errorf("no migrator from version %d", raw.Version)
+	} else if panicked := contain(&t.panics, func() { record.Obj, err = migrate(raw.Obj) }); panicked != nil {
+		err = panicked
+	}
+
+	if err != nil || record.Obj == nil {
+		report.Incompatible++
+		return record, false
+	}
+
+	report.Migrated++
+
+	return record, true
+
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "github.com/stretchr/testify/require"
+    "path/filepath"
+    "testing"
+)
+
+// layout of AccountTest persisted with format version 1
+type accountTestV1 struct {
+    Id       int
+    FullName string
+}
+
+func TestMigrator(t *testing.T) {
+
+    t.Log("validating TestMigrator")
+
+    dir := t.TempDir()
+    snapshotPath := filepath.Join(dir, "cache.ndjson")
+    walPath := filepath.Join(dir, "cache.wal")
+
+    old := NewHeapedCache(10, WithFormatVersion[int, accountTestV1](1))
+
+    _, err := old.Recover(snapshotPath, walPath)
+    require.NoError(t, err)
+
+    old.Push(1, &accountTestV1{Id: 1, FullName: "Ann"})
+    require.NoError(t, old.Checkpoint(context.Background()))
+    old.Push(2, &accountTestV1{Id: 2, FullName: "Bob"})
+    old.Push(3, &accountTestV1{Id: 3, FullName: "Eve"})
+
+    migrated := NewHeapedCache(10,
+        WithFormatVersion[int, AccountTest](2),
+        WithMigrator[int, AccountTest](1, func(raw []byte) (*AccountTest, error) {
+
+            var v1 accountTestV1
+
+            if err := json.Unmarshal(raw, &v1); err != nil {
+                return nil, err
+            }
+
+            if v1.FullName == "Eve" {
+                return nil, errors.New("rejected")
+            }
+
+            return &AccountTest{Id: v1.Id, Name: v1.FullName}, nil
+
+        }),
+    )
+
+    report, err := migrated.Recover(snapshotPath, walPath)
+    require.NoError(t, err)
+    require.Equal(t, RecoveryReport{Restored: 1, Replayed: 1, Migrated: 2, Incompatible: 1}, report)
+    require.Equal(t, "Ann", migrated.Get(1).Name)
+    require.Equal(t, "Bob", migrated.Get(2).Name)
+    require.Nil(t, migrated.Get(3))
+
+    // the checkpoint of the recovery wrote the current version: no migrator needed anymore
+    again := NewHeapedCache(10, WithFormatVersion[int, AccountTest](2))
+
+    report, err = again.Recover(snapshotPath, walPath)
+    require.NoError(t, err)
+    require.Equal(t, RecoveryReport{Restored: 2}, report)
+
+    // without a migrator, the other versions are skipped
+    other := NewHeapedCache(10, WithFormatVersion[int, AccountTest](3))
+
+    report, err = other.Recover(snapshotPath, walPath)
+    require.NoError(t, err)
+    require.Equal(t, RecoveryReport{Incompatible: 2}, report)
+
+}
+
+func TestReadSnapshotMigrator(t *testing.T) {
+
+    t.Log("validating TestReadSnapshotMigrator")
+
+    old := NewHeapedCache(10, WithFormatVersion[int, accountTestV1](1))
+    old.Push(1, &accountTestV1{Id: 1, FullName: "Ann"})
+
+    var buf bytes.Buffer
+
+    require.NoError(t, old.WriteSnapshot(&buf))
+
+    // an older version without its migrator is not decoded as the current one
+    _, err := ReadSnapshot[int, AccountTest](bytes.NewReader(buf.Bytes()), WithFormatVersion[int, AccountTest](2))
+    require.EqualError(t, err, "snapshot item 1: format version not supported (see WithMigrator)")
+
+    entries, err := ReadSnapshot(bytes.NewReader(buf.Bytes()),
+        WithFormatVersion[int, AccountTest](2),
+        WithMigrator[int, AccountTest](1, func(raw []byte) (*AccountTest, error) {
+
+            var v1 accountTestV1
+
+            if err := json.Unmarshal(raw, &v1); err != nil {
+                return nil, err
+            }
+
+            return &AccountTest{Id: v1.Id, Name: v1.FullName}, nil
+
+        }),
+    )
+
+    require.NoError(t, err)
+    require.Len(t, entries, 1)
+    require.Equal(t, "Ann", entries[0].Obj.Name)
+
+    _, err = ReadSnapshot[int, AccountTest](bytes.NewReader([]byte("{\"id\":1,\n")))
+    require.EqualError(t, err, "snapshot item 1: invalid line")
+
+}
@@ -0,0 +1,325 @@
+package utils
+
+import (
+	"container/heap"
+	"strings"
+)
+
+// group of keys sharing a prefix, registered by Namespace
+// it keeps its own heap of items (same order as the cache) to evict from when over its quota
+type namespace[TId any, TObj any] struct {
+	prefix  string
+	quota   int // 0: no quota
+	evicted uint64
+	items   []*HeapedCacheItem[TId, TObj]
+}
+
+// statistics of a namespace
+type NamespaceStats struct {
+	Len     int
+	Quota   int    // 0 when the namespace has no quota
+	Evicted uint64 // items evicted to respect the quota
+}
+
+// view of a cache with string ids that transparently prefixes every key.
+// Namespaces share the capacity of the cache (unless given a quota with SetQuota); an item belongs to the namespace
+// with the longest prefix matching its id (so "user:" and "user:admin:" do not overlap)
+type Namespaced[TObj any] struct {
+	cache *HeapedCache[string, TObj]
+	ns    *namespace[string, TObj]
+}
+
+var _ Cache[string, struct{}] = (*Namespaced[struct{}])(nil)
+
+// returns the view of the keys starting with prefix (which should end with a separator, as in "session:")
+// registering the namespace on the first call (scanning the cached items once)
+func Namespace[TObj any](t *HeapedCache[string, TObj], prefix string) *Namespaced[TObj] {
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	return &Namespaced[TObj]{cache: t, ns: t.registerNamespace(prefix)}
+
+}
+
+// removes every item of the namespace, returning how many were removed
+// (the eviction callbacks are called with them once the lock is released, as in Clear).
+// The namespace stays registered, so its views keep working
+func DropNamespace[TObj any](t *HeapedCache[string, TObj], prefix string) int {
+
+	t.lock(opOther)
+
+	ns := t.registerNamespace(prefix)
+	callbacks := t.wipeCallbacks()
+
+	var items []evicted[string, TObj]
+
+	removed := 0
+
+	// removing the last item of the namespace heap needs no sifting in it
+	for len(ns.items) > 0 {
+
+		item := ns.items[len(ns.items)-1]
+
+		t.removeItem(item)
+		t.record(OpRemove, item.Id, OutcomeRemoved)
+		t.itemRemoved(item)
+		removed++
+
+		if callbacks != nil {
+			items = append(items, evicted[string, TObj]{id: item.Id, obj: item.obj})
+		}
+
+	}
+
+	t.unlock()
+
+	streamWiped(callbacks, items, &t.panics)
+
+	return removed
+
+}
+
+// registers a namespace, moving the items it now owns from a shorter namespace
+func (t *HeapedCache[TId, TObj]) registerNamespace(prefix string) *namespace[TId, TObj] {
+
+	if ns := t.namespaces[prefix]; ns != nil {
+		return ns
+	}
+
+	if t.namespaces == nil {
+		t.namespaces = make(map[string]*namespace[TId, TObj])
+	}
+
+	ns := &namespace[TId, TObj]{prefix: prefix}
+
+	for _, item := range t.sliceItems {
+
+		if !strings.HasPrefix(any(item.Id).(string), prefix) {
+			continue
+		}
+
+		if item.namespace != nil {
+
+			if len(item.namespace.prefix) > len(prefix) {
+				continue
+			}
+
+			heap.Remove(item.namespace, item.nsIndex)
+
+		}
+
+		item.namespace = ns
+		heap.Push(ns, item)
+
+	}
+
+	t.namespaces[prefix] = ns
+
+	return ns
+
+}
+
+// returns the namespace owning an id (the longest matching prefix), or nil
+func (t *HeapedCache[TId, TObj]) namespaceOf(id TId) *namespace[TId, TObj] {
+
+	if len(t.namespaces) == 0 {
+		return nil
+	}
+
+	key, ok := any(id).(string)
+
+	if !ok {
+		return nil
+	}
+
+	var result *namespace[TId, TObj]
+
+	for prefix, ns := range t.namespaces {
+
+		if strings.HasPrefix(key, prefix) && (result == nil || len(prefix) > len(result.prefix)) {
+			result = ns
+		}
+
+	}
+
+	return result
+
+}
+
+// evicts the oldest items of the namespace of id until one more fits in its quota
+func (t *HeapedCache[TId, TObj]) enforceQuota(id TId) {
+
+	ns := t.namespaceOf(id)
+
+	if ns == nil || ns.quota <= 0 {
+		return
+	}
+
+	for len(ns.items) >= ns.quota {
+
+		item := t.quotaVictim(ns, 1)
+
+		if item == nil {
+			return
+		}
+
+		ns.evicted++
+		t.evictItem(item)
+
+	}
+
+}
+
+// returns the item to evict from a namespace over its quota, before adding incoming items to it:
+// the oldest one or, with an eviction filter, the oldest one it allows (see oldestEvictable) as long
+// as the namespace holds no more than hardCapMultiplier times its quota. returns nil when the items
+// asked about are all exempt: the namespace grows past its quota instead
+func (t *HeapedCache[TId, TObj]) quotaVictim(ns *namespace[TId, TObj], incoming int) *HeapedCacheItem[TId, TObj] {
+
+	if t.evictionFilter == nil || len(ns.items)+incoming > t.evictionFilter.hardCap(ns.quota) {
+		return ns.items[0]
+	}
+
+	return t.oldestEvictableIn(ns.items, 2)
+
+}
+
+func (ns *namespace[TId, TObj]) stats() NamespaceStats {
+
+	return NamespaceStats{Len: len(ns.items), Quota: ns.quota, Evicted: ns.evicted}
+
+}
+
+// returns the number of items of the namespace
+func (ns *namespace[TId, TObj]) Len() int {
+
+	return len(ns.items)
+
+}
+
+// same order as the cache heap: oldest refreshed first, ties broken by sequence
+func (ns *namespace[TId, TObj]) Less(i int, j int) bool {
+
+	if c := ns.items[i].Refreshed.Compare(ns.items[j].Refreshed); c != 0 {
+		return c < 0
+	}
+
+	return ns.items[i].seq < ns.items[j].seq
+
+}
+
+// swaps items of given indexes
+func (ns *namespace[TId, TObj]) Swap(i int, j int) {
+
+	ns.items[i], ns.items[j] = ns.items[j], ns.items[i]
+	ns.items[i].nsIndex = i
+	ns.items[j].nsIndex = j
+
+}
+
+// Adds item in the namespace
+func (ns *namespace[TId, TObj]) Push(x any) {
+
+	item := x.(*HeapedCacheItem[TId, TObj])
+	item.nsIndex = len(ns.items)
+	ns.items = append(ns.items, item)
+
+}
+
+// Removes last item from the namespace and returns it
+func (ns *namespace[TId, TObj]) Pop() any {
+
+	n := len(ns.items)
+	item := ns.items[n-1]
+	ns.items[n-1] = nil // don't stop the GC from reclaiming the item eventually
+	ns.items = ns.items[0 : n-1]
+
+	return item
+
+}
+
+// returns the cached item of a given key of the namespace
+// returns nil if it does not exist
+func (n *Namespaced[TObj]) Get(id any) *TObj {
+
+	key, ok := id.(string)
+
+	if !ok {
+		return nil
+	}
+
+	return n.cache.Get(n.ns.prefix + key)
+
+}
+
+// same as GetOrAdd of the cache; fn receives the key without the prefix
+func (n *Namespaced[TObj]) GetOrAdd(id string, fn func(id string) *TObj) *TObj {
+
+	return n.cache.GetOrAdd(n.ns.prefix+id, func(string) *TObj { return fn(id) })
+
+}
+
+func (n *Namespaced[TObj]) Push(id string, item *TObj) *TObj {
+
+	return n.cache.Push(n.ns.prefix+id, item)
+
+}
+
+func (n *Namespaced[TObj]) Remove(id string) bool {
+
+	return n.cache.Remove(n.ns.prefix + id)
+
+}
+
+// returns the number of cached items of the namespace
+func (n *Namespaced[TObj]) Len() int {
+
+	n.cache.lock(opOther)
+	defer n.cache.unlock()
+
+	return len(n.ns.items)
+
+}
+
+// limits the namespace to maxRows items (0 removes the limit): adding a new key
+// to a full namespace evicts its oldest item instead of items of other namespaces.
+// Items beyond a lowered quota are evicted right away
+func (n *Namespaced[TObj]) SetQuota(maxRows int) {
+
+	n.cache.lock(opOther)
+	defer n.cache.unlock()
+
+	n.ns.quota = max(maxRows, 0)
+
+	for n.ns.quota > 0 && len(n.ns.items) > n.ns.quota {
+
+		item := n.cache.quotaVictim(n.ns, 0)
+
+		if item == nil {
+			return
+		}
+
+		n.ns.evicted++
+		n.cache.evictItem(item)
+
+	}
+
+}
+
+// returns the statistics of the namespace
+func (n *Namespaced[TObj]) Stats() NamespaceStats {
+
+	n.cache.lock(opOther)
+	defer n.cache.unlock()
+
+	return n.ns.stats()
+
+}
+
+// removes every item of the namespace (see DropNamespace)
+func (n *Namespaced[TObj]) Drop() int {
+
+	return DropNamespace(n.cache, n.ns.prefix)
+
+}
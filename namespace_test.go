@@ -0,0 +1,145 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "strconv"
+    "testing"
+    "time"
+)
+
+func TestNamespace(t *testing.T) {
+
+    t.Log("validating TestNamespace")
+
+    heapedCache := NewHeapedCache[string, AccountTest](100)
+
+    // items cached before the namespaces are registered are counted too
+    heapedCache.Push("user:0", NewAccountTest(0))
+
+    users := Namespace(heapedCache, "user:")
+    admins := Namespace(heapedCache, "user:admin:")
+    sessions := Namespace(heapedCache, "session:")
+
+    for i := 1; i < 10; i++ {
+
+        users.Push(strconv.Itoa(i), NewAccountTest(i))
+        sessions.Push(strconv.Itoa(i), NewAccountTest(i))
+
+    }
+
+    admins.GetOrAdd("1", func(id string) *AccountTest {
+        require.Equal(t, "1", id)
+        return NewAccountTest(100)
+    })
+
+    require.Equal(t, 10, users.Len())
+    require.Equal(t, 1, admins.Len())
+    require.Equal(t, 9, sessions.Len())
+    require.Equal(t, 20, heapedCache.Len())
+
+    require.Equal(t, 1, users.Get("1").Id)
+    require.Equal(t, 100, admins.Get("1").Id)
+    require.Equal(t, 100, heapedCache.Get("user:admin:1").Id)
+    require.Nil(t, users.Get(1))
+
+    require.True(t, sessions.Remove("9"))
+    require.Equal(t, 8, sessions.Len())
+
+    // dropping a namespace leaves the longer ones alone
+    require.Equal(t, 10, DropNamespace(heapedCache, "user:"))
+    require.Equal(t, 0, users.Len())
+    require.Equal(t, 1, admins.Len())
+    require.Equal(t, 9, heapedCache.Len())
+
+    require.Equal(t, 1, admins.Drop())
+    require.Equal(t, 8, heapedCache.Len())
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
+
+func TestNamespaceQuota(t *testing.T) {
+
+    t.Log("validating TestNamespaceQuota")
+
+    heapedCache := NewHeapedCache[string, AccountTest](100)
+
+    noisy := Namespace(heapedCache, "noisy:")
+    quiet := Namespace(heapedCache, "quiet:")
+
+    for i := range 5 {
+
+        quiet.Push(strconv.Itoa(i), NewAccountTest(i))
+
+    }
+
+    noisy.SetQuota(10)
+
+    for i := range 200 {
+
+        noisy.Push(strconv.Itoa(i), NewAccountTest(i))
+
+    }
+
+    // the noisy namespace evicted its own items, not the quiet ones
+    require.Equal(t, 10, noisy.Len())
+    require.Equal(t, 5, quiet.Len())
+    require.Nil(t, noisy.Get("189"))
+    require.Equal(t, 190, noisy.Get("190").Id)
+
+    // updating an item protects it from the quota evictions
+    noisy.Push("190", NewAccountTest(190))
+    noisy.Push("200", NewAccountTest(200))
+    require.NotNil(t, noisy.Get("190"))
+    require.Nil(t, noisy.Get("191"))
+
+    noisy.SetQuota(4)
+
+    require.Equal(t, NamespaceStats{Len: 4, Quota: 4, Evicted: 197}, noisy.Stats())
+    require.Equal(t, NamespaceStats{Len: 5}, heapedCache.Stats().Namespaces["quiet:"])
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
+
+func TestNamespaceQuotaEvictionFilter(t *testing.T) {
+
+    t.Log("validating TestNamespaceQuotaEvictionFilter")
+
+    dirty := map[string]bool{"session:0": true}
+
+    heapedCache := NewHeapedCache(100, WithEvictionFilter(func(id string, obj *AccountTest, refreshed time.Time) bool {
+        return !dirty[id]
+    }, 2))
+
+    sessions := Namespace(heapedCache, "session:")
+    sessions.SetQuota(2)
+
+    for i := range 3 {
+
+        sessions.Push(strconv.Itoa(i), NewAccountTest(i))
+
+    }
+
+    // the oldest is dirty, so the next one was evicted for the quota
+    require.NotNil(t, sessions.Get("0"))
+    require.Nil(t, sessions.Get("1"))
+    require.NotNil(t, sessions.Get("2"))
+
+    // a lowered quota spares it as well
+    sessions.SetQuota(1)
+    require.NotNil(t, sessions.Get("0"))
+    require.Nil(t, sessions.Get("2"))
+
+    // with every item dirty the namespace grows, up to twice its quota
+    for i := 3; i < 6; i++ {
+
+        dirty["session:"+strconv.Itoa(i)] = true
+        sessions.Push(strconv.Itoa(i), NewAccountTest(i))
+
+    }
+
+    require.Equal(t, 2, sessions.Len())
+    require.Nil(t, sessions.Get("0"))
+    require.Equal(t, uint64(4), sessions.Stats().Evicted)
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"errors"
+	"time"
+)
+
+// returned by TryGetOrAdd when the loader returns nil under the ReturnErrNil policy
+var ErrNil = errors.New("heapedcache: loader returned nil")
+
+type nilMode int
+
+const (
+	nilPassThrough nilMode = iota
+	nilReturnErr
+	nilNegative
+)
+
+// defines what GetOrAdd does when the loading function returns nil
+type NilPolicy struct {
+	mode nilMode
+	ttl  time.Duration
+}
+
+var (
+	// nothing is cached and nil is returned, so the next call loads again (default)
+	PassThrough = NilPolicy{mode: nilPassThrough}
+	// nothing is cached and TryGetOrAdd returns ErrNil
+	ReturnErrNil = NilPolicy{mode: nilReturnErr}
+)
+
+// the miss is remembered for ttl: GetOrAdd returns nil without calling
+// the loading function again until it expires or the id is pushed or removed.
+// Negative entries do not take room from the cached items, and at most maxRows of them are kept
+func CacheNilAsNegative(ttl time.Duration) NilPolicy {
+
+	return NilPolicy{mode: nilNegative, ttl: ttl}
+
+}
+
+// sets what GetOrAdd does when the loading function returns nil
+func WithNilPolicy[TId comparable, TObj any](policy NilPolicy) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		t.nilPolicy = policy
+
+		if policy.mode == nilNegative && t.negatives == nil {
+			t.negatives = make(map[TId]time.Time)
+		}
+
+	}
+
+}
+
+// caches the object returned by a loading function, applying the nil policy when it is nil
+func (t *HeapedCache[TId, TObj]) addLoaded(id TId, result *TObj, opts EntryOptions) (*TObj, error) {
+
+	if loaded := opts.loaded; loaded != nil {
+
+		// a failed load is neither cached nor remembered as a miss
+		if loaded.err != nil {
+			return nil, loaded.err
+		}
+
+		opts.TTL, opts.Cost, opts.NoStore, opts.Tags = loaded.TTL, loaded.Cost, loaded.NoStore, loaded.Tags
+
+	}
+
+	if result == nil {
+
+		switch t.nilPolicy.mode {
+
+		case nilReturnErr:
+			return nil, ErrNil
+
+		case nilNegative:
+			t.addNegative(id)
+
+		}
+
+		return nil, nil
+
+	}
+
+	if opts.NoStore {
+		return result, nil
+	}
+
+	_, err := t.push(id, result)
+
+	if err == nil {
+		t.applyEntryOptions(t.mapItems[id], opts)
+	}
+
+	return result, err
+
+}
+
+// returns true when the id is cached as negative and did not expire
+func (t *HeapedCache[TId, TObj]) negativeHit(id TId) bool {
+
+	expires, ok := t.negatives[id]
+
+	if !ok {
+		return false
+	}
+
+	if t.now().Before(expires) {
+		return true
+	}
+
+	t.clearNegative(id)
+
+	return false
+
+}
+
+// remembers that the loader returned nil for the id
+func (t *HeapedCache[TId, TObj]) addNegative(id TId) {
+
+	now := t.now()
+
+	if len(t.negatives) >= t.maxRows {
+
+		for negativeId, expires := range t.negatives {
+
+			if !now.Before(expires) {
+				t.clearNegative(negativeId)
+			}
+
+		}
+
+		if len(t.negatives) >= t.maxRows {
+			return
+		}
+
+	}
+
+	t.negatives[id] = now.Add(t.nilPolicy.ttl)
+
+	if t.bloom != nil {
+		t.bloom.add(id)
+	}
+
+}
+
+// forgets a negative entry
+func (t *HeapedCache[TId, TObj]) clearNegative(id TId) {
+
+	if _, ok := t.negatives[id]; !ok {
+		return
+	}
+
+	delete(t.negatives, id)
+
+	if t.bloom != nil {
+		t.bloom.remove(id)
+	}
+
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestNilPolicyPassThrough(t *testing.T) {
+
+    t.Log("validating TestNilPolicyPassThrough")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    calls := 0
+    load := func(id int) *AccountTest {
+        calls++
+        return nil
+    }
+
+    for range 3 {
+
+        result, err := heapedCache.TryGetOrAdd(0, load)
+        require.Nil(t, result)
+        require.NoError(t, err)
+
+    }
+
+    require.Equal(t, 3, calls)
+    require.Equal(t, 0, heapedCache.Len())
+
+}
+
+func TestNilPolicyReturnErrNil(t *testing.T) {
+
+    t.Log("validating TestNilPolicyReturnErrNil")
+
+    heapedCache := NewHeapedCache(10, WithNilPolicy[int, AccountTest](ReturnErrNil))
+
+    result, err := heapedCache.TryGetOrAdd(0, func(id int) *AccountTest { return nil })
+
+    require.Nil(t, result)
+    require.ErrorIs(t, err, ErrNil)
+
+    result, err = heapedCache.TryGetOrAdd(1, NewAccountTest)
+
+    require.NoError(t, err)
+    require.Equal(t, 1, result.Id)
+
+}
+
+func TestNilPolicyCacheNilAsNegative(t *testing.T) {
+
+    t.Log("validating TestNilPolicyCacheNilAsNegative")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewHeapedCache(10,
+        WithClock[int, AccountTest](clock.Now),
+        WithNilPolicy[int, AccountTest](CacheNilAsNegative(time.Minute)),
+        WithBloomFilter[int, AccountTest](10, 0.01, bloomHash()),
+    )
+
+    calls := 0
+    load := func(id int) *AccountTest {
+        calls++
+        if id == 0 {
+            return nil
+        }
+        return NewAccountTest(id)
+    }
+
+    for range 3 {
+
+        require.Nil(t, heapedCache.GetOrAdd(0, load))
+
+    }
+
+    require.Equal(t, 1, calls)
+    require.Equal(t, 0, heapedCache.Len())
+
+    // loads again once expired
+    clock.Advance(time.Minute)
+    require.Nil(t, heapedCache.GetOrAdd(0, load))
+    require.Equal(t, 2, calls)
+
+    // a push replaces the negative entry
+    heapedCache.Push(0, NewAccountTest(0))
+    require.Equal(t, 0, heapedCache.GetOrAdd(0, load).Id)
+
+    // a remove forgets it as well
+    heapedCache.Remove(0)
+    require.Nil(t, heapedCache.GetOrAdd(0, load))
+    require.Equal(t, 3, calls)
+
+}
@@ -0,0 +1,219 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// storage of named objects where snapshots are kept: a directory (DirStore) or, implemented
+// by the application with its SDK, an S3 or GCS bucket, so pods without a persistent disk can
+// persist their caches without this package importing any cloud SDK.
+// Names are slash separated paths, such as "snapshots/users.ndjson"
+type ObjectStore interface {
+
+	// stores the content of r under name, replacing the previous object.
+	// When reading r fails (e.g. the deadline of a snapshot is missed) Put must return
+	// an error and leave the previous object, if any, untouched
+	Put(ctx context.Context, name string, r io.Reader) error
+
+	// opens the object stored under name; the error wraps fs.ErrNotExist when there is none
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+
+	// returns the names of the objects starting with prefix, sorted
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// ObjectStore keeping every object in a file under a directory
+// (subdirectories are created for names with slashes)
+type DirStore struct {
+	dir string
+}
+
+var _ ObjectStore = (*DirStore)(nil)
+
+// conctructor of the DirStore
+func NewDirStore(dir string) *DirStore {
+
+	return &DirStore{dir: dir}
+
+}
+
+// writes r to the file of name through a temporary file renamed at the end,
+// so a failed Put leaves the previous file untouched
+func (s *DirStore) Put(ctx context.Context, name string, r io.Reader) error {
+
+	path, err := s.path(name)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	return writeSnapshotFile(path, func(w io.Writer) error {
+
+		if _, err := io.Copy(w, r); err != nil {
+			return err
+		}
+
+		return ctx.Err()
+
+	})
+
+}
+
+// opens the file of name
+func (s *DirStore) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+
+	path, err := s.path(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(path)
+
+}
+
+// returns the names of the files under the directory starting with prefix,
+// leaving out the temporary files of a Put in progress
+func (s *DirStore) List(ctx context.Context, prefix string) ([]string, error) {
+
+	var result []string
+
+	err := filepath.WalkDir(s.dir, func(path string, entry fs.DirEntry, err error) error {
+
+		if errors.Is(err, fs.ErrNotExist) && path == s.dir {
+			return fs.SkipAll
+		} else if err != nil {
+			return err
+		}
+
+		if entry.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+
+		name, err := filepath.Rel(s.dir, path)
+
+		if err != nil {
+			return err
+		}
+
+		if name = filepath.ToSlash(name); strings.HasPrefix(name, prefix) {
+			result = append(result, name)
+		}
+
+		return ctx.Err()
+
+	})
+
+	slices.Sort(result)
+
+	return result, err
+
+}
+
+// returns the path of the file of name, refusing names outside the directory
+func (s *DirStore) path(name string) (string, error) {
+
+	local := filepath.FromSlash(name)
+
+	if !filepath.IsLocal(local) {
+		return "", fmt.Errorf("heapedcache: invalid object name %q", name)
+	}
+
+	return filepath.Join(s.dir, local), nil
+
+}
+
+// stores under name what write writes, streaming it to the store through a pipe
+func putObject(ctx context.Context, store ObjectStore, name string, write func(w io.Writer) error) error {
+
+	reader, writer := io.Pipe()
+	done := make(chan error, 1)
+
+	go func() {
+
+		err := write(writer)
+		writer.CloseWithError(err)
+		done <- err
+
+	}()
+
+	err := store.Put(ctx, name, reader)
+
+	// unblocks the writer when Put returned before reading everything
+	reader.CloseWithError(fmt.Errorf("heapedcache: object %q: put returned", name))
+
+	if written := <-done; err == nil && written != nil {
+		return written
+	}
+
+	return err
+
+}
+
+// writes a snapshot of the cache (see WriteSnapshot) to store under name,
+// stopping with the error of ctx when it is done
+func (t *HeapedCache[TId, TObj]) SaveSnapshot(ctx context.Context, store ObjectStore, name string) error {
+
+	_, err := t.saveSnapshot(ctx, store, name)
+	return err
+
+}
+
+// SaveSnapshot, returning the number of items written
+func (t *HeapedCache[TId, TObj]) saveSnapshot(ctx context.Context, store ObjectStore, name string) (int, error) {
+
+	written := 0
+
+	err := putObject(ctx, store, name, func(w io.Writer) (err error) {
+		written, err = t.persistItems(ctx, w, t.snapshot())
+		return err
+	})
+
+	return written, err
+
+}
+
+// loads the snapshot stored under name (oldest items first, as Recover does); a missing snapshot
+// (first start) loads nothing. Undecodable lines are skipped and counted in the report
+func (t *HeapedCache[TId, TObj]) LoadSnapshot(ctx context.Context, store ObjectStore, name string) (RecoveryReport, error) {
+
+	var report RecoveryReport
+
+	object, err := store.Get(ctx, name)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return report, nil
+	} else if err != nil {
+		return report, err
+	}
+
+	defer object.Close()
+
+	entries, err := t.readLines(object, false, &report)
+
+	if err != nil {
+		return report, err
+	}
+
+	slices.SortStableFunc(entries, func(a, b walRecord[TId, TObj]) int { return a.Refreshed.Compare(b.Refreshed) })
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	t.replay(entries, nil, &report)
+
+	return report, nil
+
+}
@@ -0,0 +1,211 @@
+package utils
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "github.com/stretchr/testify/require"
+    "io"
+    "io/fs"
+    "slices"
+    "strings"
+    "sync"
+    "testing"
+)
+
+// ObjectStore keeping the objects in memory, as a bucket would
+type memoryStore struct {
+    mu      sync.Mutex
+    objects map[string][]byte
+}
+
+func (s *memoryStore) Put(ctx context.Context, name string, r io.Reader) error {
+
+    content, err := io.ReadAll(r)
+
+    if err != nil {
+        return err
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if s.objects == nil {
+        s.objects = make(map[string][]byte)
+    }
+
+    s.objects[name] = content
+
+    return nil
+
+}
+
+func (s *memoryStore) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    content, ok := s.objects[name]
+
+    if !ok {
+        return nil, fs.ErrNotExist
+    }
+
+    return io.NopCloser(bytes.NewReader(content)), nil
+
+}
+
+func (s *memoryStore) List(ctx context.Context, prefix string) ([]string, error) {
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    var result []string
+
+    for name := range s.objects {
+
+        if strings.HasPrefix(name, prefix) {
+            result = append(result, name)
+        }
+
+    }
+
+    slices.Sort(result)
+
+    return result, nil
+
+}
+
+// ObjectStore failing every Put without reading
+type failingStore struct {
+    memoryStore
+}
+
+func (s *failingStore) Put(ctx context.Context, name string, r io.Reader) error {
+
+    return errors.New("bucket unavailable")
+
+}
+
+func TestDirStore(t *testing.T) {
+
+    t.Log("validating TestDirStore")
+
+    ctx := context.Background()
+    store := NewDirStore(t.TempDir())
+
+    names, err := store.List(ctx, "")
+    require.NoError(t, err)
+    require.Empty(t, names)
+
+    require.NoError(t, store.Put(ctx, "b.ndjson", strings.NewReader("b")))
+    require.NoError(t, store.Put(ctx, "snapshots/a.ndjson", strings.NewReader("a")))
+
+    // a failed put leaves the previous object
+    require.Error(t, store.Put(ctx, "b.ndjson", io.MultiReader(strings.NewReader("partial"), deadlineReader{})))
+
+    object, err := store.Get(ctx, "b.ndjson")
+    require.NoError(t, err)
+    content, err := io.ReadAll(object)
+    require.NoError(t, err)
+    require.NoError(t, object.Close())
+    require.Equal(t, "b", string(content))
+
+    names, err = store.List(ctx, "")
+    require.NoError(t, err)
+    require.Equal(t, []string{"b.ndjson", "snapshots/a.ndjson"}, names)
+
+    names, err = store.List(ctx, "snapshots/")
+    require.NoError(t, err)
+    require.Equal(t, []string{"snapshots/a.ndjson"}, names)
+
+    _, err = store.Get(ctx, "missing.ndjson")
+    require.ErrorIs(t, err, fs.ErrNotExist)
+
+    require.Error(t, store.Put(ctx, "../outside.ndjson", strings.NewReader("x")))
+
+    // a directory not created yet has no object
+    names, err = NewDirStore(t.TempDir()+"/missing").List(ctx, "")
+    require.NoError(t, err)
+    require.Empty(t, names)
+
+}
+
+// reader failing as a snapshot missing its deadline
+type deadlineReader struct{}
+
+func (deadlineReader) Read(p []byte) (int, error) {
+
+    return 0, context.DeadlineExceeded
+
+}
+
+func TestSaveLoadSnapshot(t *testing.T) {
+
+    t.Log("validating TestSaveLoadSnapshot")
+
+    ctx := context.Background()
+    store := &memoryStore{}
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    for i := range 5 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    require.NoError(t, heapedCache.SaveSnapshot(ctx, store, "accounts.ndjson"))
+
+    restored := NewHeapedCache[int, AccountTest](3)
+
+    report, err := restored.LoadSnapshot(ctx, store, "accounts.ndjson")
+    require.NoError(t, err)
+    require.Equal(t, RecoveryReport{Restored: 5}, report)
+
+    // oldest first, so the newest ones are kept
+    require.Equal(t, 3, restored.Len())
+    require.Nil(t, restored.Get(1))
+    require.Equal(t, 4, restored.Get(4).Id)
+
+    // first start
+    report, err = restored.LoadSnapshot(ctx, store, "missing.ndjson")
+    require.NoError(t, err)
+    require.Zero(t, report)
+
+    require.EqualError(t, heapedCache.SaveSnapshot(ctx, &failingStore{}, "accounts.ndjson"), "bucket unavailable")
+
+}
+
+func TestShutdownStore(t *testing.T) {
+
+    t.Log("validating TestShutdownStore")
+
+    ctx := context.Background()
+    store := &memoryStore{}
+
+    heapedCache := NewHeapedCache(10, WithShutdownStore[int, AccountTest](store, "accounts.ndjson"))
+
+    heapedCache.Push(1, NewAccountTest(1))
+    heapedCache.Push(2, NewAccountTest(2))
+
+    report, err := heapedCache.OnShutdown(ctx)
+    require.NoError(t, err)
+    require.Equal(t, 2, report.Persisted)
+
+    restored := NewHeapedCache[int, AccountTest](10)
+    _, err = restored.LoadSnapshot(ctx, store, "accounts.ndjson")
+    require.NoError(t, err)
+    require.Equal(t, 2, restored.Len())
+
+    registry := NewRegistry()
+    require.NoError(t, registry.Register("accounts", restored))
+    require.NoError(t, registry.SnapshotAllTo(ctx, store, "snapshots/"))
+
+    names, err := store.List(ctx, "snapshots/")
+    require.NoError(t, err)
+    require.Equal(t, []string{"snapshots/accounts.ndjson"}, names)
+
+    require.ErrorContains(t, registry.SnapshotAllTo(ctx, &failingStore{}, ""), `cache "accounts": bucket unavailable`)
+
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"errors"
+	"time"
+)
+
+// returned when a new item is refused because the cache is full
+var ErrFull = errors.New("heapedcache: cache is full")
+
+// defines what happens when a new item is pushed into a full cache
+type OverflowPolicy int
+
+const (
+	// the oldest item is evicted to make room for the new one (default)
+	EvictOldest OverflowPolicy = iota
+	// the new item is refused, so older, still unprocessed items are kept
+	RejectNew
+	// the new item is refused when it is older than the oldest cached item,
+	// otherwise the oldest item is evicted. Items pushed now are never older: it is meant
+	// for items with the refreshed time of their source (PushAt, FromMap, Recover)
+	DropNewestIfOlder
+)
+
+// sets what happens when a new item is pushed into a full cache
+func WithOverflowPolicy[TId comparable, TObj any](policy OverflowPolicy) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		t.overflow = policy
+
+	}
+
+}
+
+// returns false when the overflow policy refuses a new item refreshed at the given time
+func (t *HeapedCache[TId, TObj]) admit(refreshed time.Time) bool {
+
+	if len(t.sliceItems) < t.capacity() {
+		return true
+	}
+
+	switch t.overflow {
+
+	case RejectNew:
+		return false
+
+	case DropNewestIfOlder:
+		t.settle()
+		return len(t.sliceItems) == 0 || !refreshed.Before(t.sliceItems[0].Refreshed)
+
+	}
+
+	return true
+
+}
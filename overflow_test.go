@@ -0,0 +1,106 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestOverflowEvictOldest(t *testing.T) {
+
+    t.Log("validating TestOverflowEvictOldest")
+
+    heapedCache := NewHeapedCache(2, WithOverflowPolicy[int, AccountTest](EvictOldest))
+
+    for i := range 3 {
+
+        _, err := heapedCache.TryPush(i, NewAccountTest(i))
+        require.NoError(t, err)
+
+    }
+
+    require.Nil(t, heapedCache.Get(0))
+    require.Equal(t, 2, heapedCache.Len())
+
+}
+
+func TestOverflowRejectNew(t *testing.T) {
+
+    t.Log("validating TestOverflowRejectNew")
+
+    heapedCache := NewHeapedCache(2, WithOverflowPolicy[int, AccountTest](RejectNew))
+
+    heapedCache.Push(0, NewAccountTest(0))
+    heapedCache.Push(1, NewAccountTest(1))
+
+    item, err := heapedCache.TryPush(2, NewAccountTest(2))
+    require.ErrorIs(t, err, ErrFull)
+    require.Nil(t, item)
+    require.Nil(t, heapedCache.Push(2, NewAccountTest(2)))
+
+    // updates of cached items are still accepted
+    _, err = heapedCache.TryPush(1, NewAccountTest(1))
+    require.NoError(t, err)
+
+    // the loaded item is returned even though it is not cached
+    require.Equal(t, 3, heapedCache.GetOrAdd(3, NewAccountTest).Id)
+    require.Nil(t, heapedCache.Get(3))
+
+    require.NotNil(t, heapedCache.Get(0))
+    require.Equal(t, 2, heapedCache.Len())
+
+    heapedCache.Pop()
+
+    _, err = heapedCache.TryPush(2, NewAccountTest(2))
+    require.NoError(t, err)
+
+}
+
+func TestOverflowDropNewestIfOlder(t *testing.T) {
+
+    t.Log("validating TestOverflowDropNewestIfOlder")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewHeapedCache(2, WithClock[int, AccountTest](clock.Now), WithOverflowPolicy[int, AccountTest](DropNewestIfOlder))
+
+    heapedCache.FromMap(map[int]*AccountTest{0: NewAccountTest(0), 1: NewAccountTest(1)}, clock.Now().Add(time.Hour))
+
+    // the clock is behind the oldest item, so the new one is dropped
+    _, err := heapedCache.TryPush(2, NewAccountTest(2))
+    require.ErrorIs(t, err, ErrFull)
+
+    clock.Advance(2 * time.Hour)
+
+    _, err = heapedCache.TryPush(2, NewAccountTest(2))
+    require.NoError(t, err)
+    require.Equal(t, 2, heapedCache.Len())
+    require.NotNil(t, heapedCache.Get(2))
+
+}
+
+func TestOverflowDropNewestIfOlderPushAt(t *testing.T) {
+
+    t.Log("validating TestOverflowDropNewestIfOlderPushAt")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewHeapedCache(2, WithClock[int, AccountTest](clock.Now), WithOverflowPolicy[int, AccountTest](DropNewestIfOlder))
+
+    modified := clock.Now().Add(-time.Hour)
+
+    heapedCache.PushAt(0, NewAccountTest(0), modified)
+    heapedCache.PushAt(1, NewAccountTest(1), modified.Add(time.Minute))
+
+    // older than the oldest cached item: dropped
+    require.Nil(t, heapedCache.PushAt(2, NewAccountTest(2), modified.Add(-time.Minute)))
+    require.Nil(t, heapedCache.Get(2))
+
+    // newer: the oldest item is evicted for it
+    require.NotNil(t, heapedCache.PushAt(3, NewAccountTest(3), modified.Add(2*time.Minute)))
+    require.Nil(t, heapedCache.Get(0))
+    require.Equal(t, 2, heapedCache.Len())
+
+    meta, ok := heapedCache.GetMeta(3)
+    require.True(t, ok)
+    require.Equal(t, modified.Add(2*time.Minute), meta.Refreshed)
+
+}
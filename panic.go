@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// error returned in place of the panic of a user callback (e.g. the loader of GetOrAdd)
+type PanicError struct {
+	Value any    // value given to panic
+	Stack []byte // stack trace of the panicking goroutine
+}
+
+func (e *PanicError) Error() string {
+
+	return fmt.Sprintf("heapedcache: callback panicked: %v", e.Value)
+
+}
+
+// returns the panic value when it is an error, so errors.Is and errors.As see through
+func (e *PanicError) Unwrap() error {
+
+	err, _ := e.Value.(error)
+	return err
+
+}
+
+// calls fn, a user callback, recovering from its panic: the panic is counted in panics
+// and returned as a *PanicError, so the cache lock is released and its state kept consistent
+func contain(panics *atomic.Uint64, fn func()) (err error) {
+
+	defer func() {
+
+		if r := recover(); r != nil {
+			panics.Add(1)
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+
+	}()
+
+	fn()
+
+	return nil
+
+}
@@ -0,0 +1,219 @@
+package utils
+
+import (
+    "context"
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestPanicContainment(t *testing.T) {
+
+    t.Log("validating TestPanicContainment")
+
+    heapedCache := NewHeapedCache[int, AccountTest](2)
+
+    obj, err := heapedCache.TryGetOrAdd(1, func(id int) *AccountTest {
+        panic("loader failed")
+    })
+    require.Nil(t, obj)
+
+    var panicErr *PanicError
+    require.ErrorAs(t, err, &panicErr)
+    require.Equal(t, "loader failed", panicErr.Value)
+    require.NotEmpty(t, panicErr.Stack)
+
+    require.Nil(t, heapedCache.GetOrAdd(1, func(id int) *AccountTest {
+        panic("loader failed")
+    }))
+
+    // the lock was released
+    heapedCache.Push(1, NewAccountTest(1))
+    heapedCache.Push(2, NewAccountTest(2))
+
+    heapedCache.OnEvict(func(id int, obj *AccountTest) {
+        panic("callback failed")
+    })
+
+    heapedCache.Push(3, NewAccountTest(3))
+    require.Nil(t, heapedCache.Get(1))
+
+    require.False(t, heapedCache.Patch(2, func(obj *AccountTest) {
+        obj.Name = "patched"
+        panic("patch failed")
+    }))
+    require.Equal(t, "patched", heapedCache.Get(2).Name)
+
+    require.Equal(t, 2, heapedCache.Clear())
+    require.NoError(t, heapedCache.CheckInvariants())
+    require.Equal(t, uint64(6), heapedCache.Stats().Panics)
+
+}
+
+func TestPanicContainmentEvictionFilter(t *testing.T) {
+
+    t.Log("validating TestPanicContainmentEvictionFilter")
+
+    heapedCache := NewHeapedCache(2, WithEvictionFilter[int, AccountTest](func(id int, obj *AccountTest, refreshed time.Time) bool {
+
+        if id == 1 {
+            panic("filter failed")
+        }
+
+        return true
+
+    }, 2))
+
+    for i := 1; i <= 3; i++ {
+        heapedCache.Push(i, NewAccountTest(i))
+    }
+
+    // the item the filter could not judge is kept
+    require.NotNil(t, heapedCache.Get(1))
+    require.Nil(t, heapedCache.Get(2))
+    require.NoError(t, heapedCache.CheckInvariants())
+    require.Equal(t, uint64(1), heapedCache.Stats().Panics)
+
+}
+
+func TestPanicContainmentPolicyFilter(t *testing.T) {
+
+    t.Log("validating TestPanicContainmentPolicyFilter")
+
+    heapedCache := NewHeapedCache(2,
+        WithEvictionPolicy[int, AccountTest](NewLRUPolicy[int]()),
+        WithEvictionFilter[int, AccountTest](func(id int, obj *AccountTest, refreshed time.Time) bool {
+
+            if id == 1 {
+                panic("filter failed")
+            }
+
+            return true
+
+        }, 2))
+
+    for i := 1; i <= 3; i++ {
+        heapedCache.Push(i, NewAccountTest(i))
+    }
+
+    // the victim of the policy the filter could not judge is kept
+    require.Equal(t, 3, heapedCache.Len())
+    require.Equal(t, uint64(1), heapedCache.Stats().Panics)
+    require.NotNil(t, heapedCache.Get(1))
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
+
+func TestPanicContainmentAggregate(t *testing.T) {
+
+    t.Log("validating TestPanicContainmentAggregate")
+
+    failing := false
+
+    project := func(obj *AccountTest) float64 {
+
+        if failing {
+            panic("projection failed")
+        }
+
+        return float64(obj.Id)
+
+    }
+
+    heapedCache := NewHeapedCache(10,
+        WithAggregate[int, AccountTest]("sum", AggregateSum, project),
+        WithAggregate[int, AccountTest]("min", AggregateMin, project))
+
+    for i := 1; i <= 3; i++ {
+        heapedCache.Push(i, NewAccountTest(i))
+    }
+
+    // the min leaves, so it is recomputed by the next query
+    require.True(t, heapedCache.Remove(1))
+
+    failing = true
+
+    // a projection that panics counts as 0
+    value, ok := heapedCache.Aggregate("min")
+    require.True(t, ok)
+    require.Zero(t, value)
+
+    heapedCache.Push(4, NewAccountTest(4))
+
+    value, ok = heapedCache.Aggregate("sum")
+    require.True(t, ok)
+    require.Equal(t, float64(5), value)
+
+    require.Equal(t, uint64(4), heapedCache.Stats().Panics)
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
+
+func TestPanicContainmentIndex(t *testing.T) {
+
+    t.Log("validating TestPanicContainmentIndex")
+
+    failing := false
+
+    heapedCache := NewHeapedCache(10, WithIndex[int, AccountTest]("due", func(obj *AccountTest) time.Time {
+
+        if failing {
+            panic("key failed")
+        }
+
+        return time.Date(2024, 1, 1, obj.Id, 0, 0, 0, time.UTC)
+
+    }))
+
+    heapedCache.Push(1, NewAccountTest(1))
+    heapedCache.Push(2, NewAccountTest(2))
+
+    failing = true
+
+    // a key that panics is the zero time, first in the index
+    heapedCache.Push(3, NewAccountTest(3))
+    require.True(t, heapedCache.Patch(2, func(obj *AccountTest) {}))
+
+    require.Equal(t, uint64(2), heapedCache.Stats().Panics)
+    require.NoError(t, heapedCache.CheckInvariants())
+
+    failing = false
+
+    popped := []int{}
+
+    for {
+
+        obj, ok := heapedCache.PopByIndex("due")
+
+        if !ok {
+            break
+        }
+
+        popped = append(popped, obj.Id)
+
+    }
+
+    require.ElementsMatch(t, []int{2, 3}, popped[:2])
+    require.Equal(t, 1, popped[2])
+
+}
+
+func TestPanicContainmentAuditActor(t *testing.T) {
+
+    t.Log("validating TestPanicContainmentAuditActor")
+
+    var records []AuditRecord[int]
+
+    heapedCache := NewHeapedCache(10, WithAudit[int, AccountTest](
+        AuditSinkFunc[int](func(record AuditRecord[int]) { records = append(records, record) }),
+        func(ctx context.Context) any { panic("actor failed") }))
+
+    _, err := heapedCache.PushContext(context.Background(), 1, NewAccountTest(1))
+    require.NoError(t, err)
+
+    // the operation is audited without an actor
+    require.Len(t, records, 1)
+    require.Nil(t, records[0].Actor)
+    require.Equal(t, uint64(1), heapedCache.Stats().Panics)
+
+}
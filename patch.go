@@ -0,0 +1,46 @@
+package utils
+
+// applies fn to the cached object of a given id in place, under the lock, and refreshes it
+// (as a Push would), for cheap incremental updates such as incrementing a counter field.
+// fn must not keep the pointer nor call back into the cache. Readers that got the object
+// before (Get, ReadCache) share it, so they see the change too.
+// returns false when the id is not cached (fn is not called) or after OnShutdown, and when fn
+// panicked: the panic is counted in Stats.Panics and the object, with whatever fn changed, refreshed
+func (t *HeapedCache[TId, TObj]) Patch(id TId, fn func(obj *TObj)) bool {
+
+	id = t.key(id)
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	item := t.unexpired(t.mapItems[id])
+
+	if item == nil || t.shutdown {
+		return false
+	}
+
+	// the aggregates see the object before and after the change
+	for _, aggregate := range t.aggregates {
+		aggregate.remove(item.obj)
+	}
+
+	err := contain(&t.panics, func() { fn(item.obj) })
+
+	for _, aggregate := range t.aggregates {
+		aggregate.add(item.obj)
+	}
+
+	// the object may have grown (or shrunk) in place
+	cost := t.costOf(item)
+	t.charge(cost - item.cost)
+	item.cost = cost
+
+	item.Refreshed = t.now()
+	item.seq = t.nextSeq()
+	t.fix(item)
+	t.record(OpPush, id, OutcomePatched)
+	t.itemChanged(item)
+
+	return err == nil
+
+}
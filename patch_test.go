@@ -0,0 +1,35 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+)
+
+func TestPatch(t *testing.T) {
+
+    t.Log("validating TestPatch")
+
+    heapedCache := NewHeapedCache(10,
+        WithAggregate[int, AccountTest]("ids", AggregateSum, func(obj *AccountTest) float64 { return float64(obj.Id) }),
+        WithRecorder[int, AccountTest](10),
+    )
+
+    for i := range 3 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    require.True(t, heapedCache.Patch(0, func(obj *AccountTest) { obj.Id += 10 }))
+    require.False(t, heapedCache.Patch(5, func(obj *AccountTest) { t.Fatal("called for a missing id") }))
+
+    // the patched item was refreshed, so it is now the newest one
+    require.Equal(t, 1, heapedCache.Pop().Id)
+    require.Equal(t, 2, heapedCache.Pop().Id)
+    require.Equal(t, 10, heapedCache.Get(0).Id)
+
+    requireAggregate(t, heapedCache, "ids", 10)
+    require.Equal(t, OutcomePatched, heapedCache.RecentOps()[3].Outcome)
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
@@ -0,0 +1,32 @@
+package utils
+
+// restricts what is persisted to the items for which keep returns true (e.g. expensive aggregates,
+// not session tokens): the others are left out of the snapshots (WriteSnapshot, SnapshotAll,
+// WithShutdownSnapshot, Checkpoint) and of the write-ahead log, where an item updated so that it
+// is no longer kept is logged as removed. ExportNDJSON and Handoff write every item.
+// keep runs under the lock for the log, so it must be cheap and must not call back into the cache;
+// when it panics, the item is not persisted
+func WithPersistFilter[TId comparable, TObj any](keep func(id TId, obj *TObj) bool) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		t.persistFilter = keep
+
+	}
+
+}
+
+// returns true when an item is to be persisted (see WithPersistFilter)
+func (t *HeapedCache[TId, TObj]) persisted(id TId, obj *TObj) bool {
+
+	if t.persistFilter == nil {
+		return true
+	}
+
+	keep := false
+
+	contain(&t.panics, func() { keep = t.persistFilter(id, obj) })
+
+	return keep
+
+}
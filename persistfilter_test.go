@@ -0,0 +1,95 @@
+package utils
+
+import (
+    "bytes"
+    "context"
+    "github.com/stretchr/testify/require"
+    "net/http/httptest"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestPersistFilter(t *testing.T) {
+
+    t.Log("validating TestPersistFilter")
+
+    keep := WithPersistFilter[string, AccountTest](func(id string, obj *AccountTest) bool {
+        return !strings.HasPrefix(id, "session:") && obj.Name != "secret"
+    })
+
+    heapedCache := NewHeapedCache(10, keep)
+
+    heapedCache.Push("account:1", NewAccountTest(1))
+    heapedCache.Push("session:1", NewAccountTest(2))
+
+    var buf bytes.Buffer
+
+    require.NoError(t, heapedCache.WriteSnapshot(&buf))
+
+    entries, err := ReadSnapshot[string, AccountTest](&buf)
+    require.NoError(t, err)
+    require.Len(t, entries, 1)
+    require.Equal(t, "account:1", entries[0].Id)
+
+    // the write-ahead log is filtered too
+    dir := t.TempDir()
+    snapshotPath := filepath.Join(dir, "cache.ndjson")
+    walPath := filepath.Join(dir, "cache.wal")
+
+    logged := NewHeapedCache(10, keep)
+
+    _, err = logged.Recover(snapshotPath, walPath)
+    require.NoError(t, err)
+
+    logged.Push("account:1", NewAccountTest(1))
+    logged.Push("account:2", NewAccountTest(2))
+    logged.Push("session:1", NewAccountTest(3))
+
+    // no longer kept once updated: logged as removed
+    logged.Patch("account:2", func(obj *AccountTest) { obj.Name = "secret" })
+
+    recovered := NewHeapedCache(10, keep)
+
+    report, err := recovered.Recover(snapshotPath, walPath)
+    require.NoError(t, err)
+    require.Equal(t, RecoveryReport{Replayed: 3}, report)
+    require.Equal(t, 1, recovered.Len())
+    require.NotNil(t, recovered.Get("account:1"))
+
+}
+
+func TestPersistFilterExport(t *testing.T) {
+
+    t.Log("validating TestPersistFilterExport")
+
+    heapedCache := NewHeapedCache(10, WithPersistFilter[string, AccountTest](func(id string, obj *AccountTest) bool {
+        return !strings.HasPrefix(id, "session:")
+    }))
+
+    heapedCache.Push("account:1", NewAccountTest(1))
+    heapedCache.Push("session:1", NewAccountTest(2))
+
+    // an export writes every item and is not a snapshot
+    var buf bytes.Buffer
+
+    require.NoError(t, heapedCache.ExportNDJSON(&buf, nil))
+    require.Equal(t, 2, strings.Count(buf.String(), "\n"))
+    require.Zero(t, heapedCache.Health().LastSnapshot)
+
+    peer := NewHeapedCache[string, AccountTest](10)
+    server := httptest.NewServer(peer.HandoffHandler())
+    defer server.Close()
+
+    report, err := heapedCache.Handoff(context.Background(), nil, server.URL)
+    require.NoError(t, err)
+    require.Equal(t, 2, report.Restored)
+    require.Zero(t, heapedCache.Health().LastSnapshot)
+
+    buf.Reset()
+
+    require.NoError(t, heapedCache.WriteSnapshot(&buf))
+    require.Equal(t, 1, strings.Count(buf.String(), "\n"))
+    require.NotZero(t, heapedCache.Health().LastSnapshot)
+
+}
@@ -0,0 +1,350 @@
+package utils
+
+import "container/list"
+
+// decides which item is evicted when the cache is full.
+// The cache calls OnAdd when an id is placed on the cache, OnAccess when it is read
+// or updated, and OnRemove when it leaves the cache for any reason (evicted included),
+// always under its lock, so implementations need no locking of their own.
+// Victim returns the id to evict next (false when it has none).
+// The heap ordering by refreshed time, used by Pop, Queue and Between, is kept
+// whatever the policy; only the choice of evicted items changes.
+// A policy instance must not be shared between caches
+type EvictionPolicy[TId comparable] interface {
+	OnAdd(id TId)
+	OnAccess(id TId)
+	OnRemove(id TId)
+	Victim() (TId, bool)
+}
+
+// replaces the default eviction policy (oldest refreshed first)
+func WithEvictionPolicy[TId comparable, TObj any](policy EvictionPolicy[TId]) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		if policy != nil {
+			t.policy = policy
+		}
+
+	}
+
+}
+
+// default policy: evicts the item with the oldest refreshed time, i.e. the top of the heap
+// the heap is maintained by the cache itself, so there is no bookkeeping to do here
+type recencyPolicy[TId comparable, TObj any] struct {
+	cache *HeapedCache[TId, TObj]
+}
+
+func (p *recencyPolicy[TId, TObj]) OnAdd(id TId) {}
+
+func (p *recencyPolicy[TId, TObj]) OnAccess(id TId) {}
+
+func (p *recencyPolicy[TId, TObj]) OnRemove(id TId) {}
+
+func (p *recencyPolicy[TId, TObj]) Victim() (TId, bool) {
+
+	if len(p.cache.sliceItems) == 0 {
+		var id TId
+		return id, false
+	}
+
+	return p.cache.sliceItems[0].Id, true
+
+}
+
+// returns the item to evict, or nil when there is none.
+// With an eviction filter, the default policy skips the exempt items, while
+// other policies evict nothing when their victim is exempt (until the hard cap)
+func (t *HeapedCache[TId, TObj]) victim() *HeapedCacheItem[TId, TObj] {
+
+	if len(t.sliceItems) == 0 {
+		return nil
+	}
+
+	t.settle()
+
+	filtered := t.evictionFilter != nil && len(t.sliceItems) <= t.evictionFilter.hardCap(t.capacity())
+
+	if _, ok := t.policy.(*recencyPolicy[TId, TObj]); ok && filtered {
+		return t.oldestEvictable()
+	}
+
+	id, ok := t.policy.Victim()
+	item := t.mapItems[id]
+
+	// a policy out of sync with the cache must not let it grow unbounded
+	if !ok || item == nil {
+		item = t.sliceItems[0]
+	}
+
+	if filtered && !t.evictable(item) {
+		return nil
+	}
+
+	return item
+
+}
+
+// least recently used policy: reads and updates protect an item from eviction
+type LRUPolicy[TId comparable] struct {
+	order    *list.List // front: most recently used
+	elements map[TId]*list.Element
+}
+
+// conctructor of the LRUPolicy
+func NewLRUPolicy[TId comparable]() *LRUPolicy[TId] {
+
+	return &LRUPolicy[TId]{order: list.New(), elements: make(map[TId]*list.Element)}
+
+}
+
+func (p *LRUPolicy[TId]) OnAdd(id TId) {
+
+	p.elements[id] = p.order.PushFront(id)
+
+}
+
+func (p *LRUPolicy[TId]) OnAccess(id TId) {
+
+	if element := p.elements[id]; element != nil {
+		p.order.MoveToFront(element)
+	}
+
+}
+
+func (p *LRUPolicy[TId]) OnRemove(id TId) {
+
+	if element := p.elements[id]; element != nil {
+		p.order.Remove(element)
+		delete(p.elements, id)
+	}
+
+}
+
+func (p *LRUPolicy[TId]) Victim() (TId, bool) {
+
+	element := p.order.Back()
+
+	if element == nil {
+		var id TId
+		return id, false
+	}
+
+	return element.Value.(TId), true
+
+}
+
+// approximate LRU (CLOCK, or second chance): a read only sets the referenced bit of the item,
+// instead of moving it in a list, and Victim sweeps the items in insertion order like the hand
+// of a clock, sparing (and clearing) the referenced ones once. Cheaper than LRUPolicy
+// on read-heavy workloads, at the cost of an approximate recency order
+type ClockPolicy[TId comparable] struct {
+	slots []clockSlot[TId]
+	index map[TId]int // slot of every id
+	free  []int       // slots left by removed ids
+	hand  int
+}
+
+type clockSlot[TId comparable] struct {
+	id         TId
+	used       bool
+	referenced bool
+}
+
+// conctructor of the ClockPolicy
+func NewClockPolicy[TId comparable]() *ClockPolicy[TId] {
+
+	return &ClockPolicy[TId]{index: make(map[TId]int)}
+
+}
+
+func (p *ClockPolicy[TId]) OnAdd(id TId) {
+
+	slot := clockSlot[TId]{id: id, used: true}
+
+	if n := len(p.free); n > 0 {
+		p.index[id] = p.free[n-1]
+		p.slots[p.free[n-1]] = slot
+		p.free = p.free[:n-1]
+		return
+	}
+
+	p.index[id] = len(p.slots)
+	p.slots = append(p.slots, slot)
+
+}
+
+func (p *ClockPolicy[TId]) OnAccess(id TId) {
+
+	if i, ok := p.index[id]; ok {
+		p.slots[i].referenced = true
+	}
+
+}
+
+func (p *ClockPolicy[TId]) OnRemove(id TId) {
+
+	if i, ok := p.index[id]; ok {
+		p.slots[i] = clockSlot[TId]{}
+		p.free = append(p.free, i)
+		delete(p.index, id)
+	}
+
+}
+
+func (p *ClockPolicy[TId]) Victim() (TId, bool) {
+
+	if len(p.index) == 0 {
+		var id TId
+		return id, false
+	}
+
+	// every referenced slot is cleared during the first turn, so the second one finds a victim
+	for {
+
+		p.hand %= len(p.slots)
+		slot := &p.slots[p.hand]
+		p.hand++
+
+		if !slot.used {
+			continue
+		}
+
+		if slot.referenced {
+			slot.referenced = false
+			continue
+		}
+
+		return slot.id, true
+
+	}
+
+}
+
+// adaptive replacement cache (ARC): items read or updated once are kept in a recency list (T1),
+// items used again move to a frequency list (T2), and the keys evicted from each are remembered
+// in a ghost list (B1, B2). A miss on a key of B1 means the recency side was too small, one on B2
+// that the frequency side was: the target size of T1 adapts accordingly, so the policy balances
+// recency and frequency on its own as the workload shifts (e.g. scans at night, hot sets by day).
+// capacity should be the maxRows of the cache; each ghost list keeps up to capacity keys
+type ARCPolicy[TId comparable] struct {
+	capacity      int
+	target        int // target size of T1 (p in the ARC paper)
+	recent        *LRUPolicy[TId]
+	frequent      *LRUPolicy[TId]
+	recentGhost   *ghostList[TId]
+	frequentGhost *ghostList[TId]
+	added         TId // last added id, never chosen before older items of T1
+	hasAdded      bool
+	victim        TId // last victim, moved to a ghost list when it is removed
+	hasVictim     bool
+}
+
+// conctructor of the ARCPolicy
+func NewARCPolicy[TId comparable](capacity int) *ARCPolicy[TId] {
+
+	capacity = max(capacity, 1)
+
+	return &ARCPolicy[TId]{
+		capacity:      capacity,
+		recent:        NewLRUPolicy[TId](),
+		frequent:      NewLRUPolicy[TId](),
+		recentGhost:   newGhostList[TId](capacity),
+		frequentGhost: newGhostList[TId](capacity),
+	}
+
+}
+
+func (p *ARCPolicy[TId]) OnAdd(id TId) {
+
+	p.added, p.hasAdded = id, true
+
+	switch {
+
+	// evicted too early from T1: favour recency
+	case p.recentGhost.remove(id):
+		p.target = min(p.capacity, p.target+max(p.frequentGhost.len()/max(p.recentGhost.len(), 1), 1))
+		p.frequent.OnAdd(id)
+
+	// evicted too early from T2: favour frequency
+	case p.frequentGhost.remove(id):
+		p.target = max(0, p.target-max(p.recentGhost.len()/max(p.frequentGhost.len(), 1), 1))
+		p.frequent.OnAdd(id)
+
+	default:
+		p.recent.OnAdd(id)
+
+	}
+
+}
+
+func (p *ARCPolicy[TId]) OnAccess(id TId) {
+
+	if element := p.recent.elements[id]; element != nil {
+		p.recent.OnRemove(id)
+		p.frequent.OnAdd(id)
+		return
+	}
+
+	p.frequent.OnAccess(id)
+
+}
+
+func (p *ARCPolicy[TId]) OnRemove(id TId) {
+
+	evicted := p.hasVictim && p.victim == id
+
+	if evicted {
+		p.hasVictim = false
+	}
+
+	if p.recent.elements[id] != nil {
+
+		p.recent.OnRemove(id)
+
+		if evicted {
+			p.recentGhost.add(id)
+		}
+
+		return
+
+	}
+
+	if p.frequent.elements[id] != nil {
+
+		p.frequent.OnRemove(id)
+
+		if evicted {
+			p.frequentGhost.add(id)
+		}
+
+	}
+
+}
+
+func (p *ARCPolicy[TId]) Victim() (TId, bool) {
+
+	// the item being added does not count in T1 (ARC makes room before inserting it)
+	recent := p.recent.order.Len()
+
+	if p.hasAdded && p.recent.elements[p.added] != nil {
+		recent--
+	}
+
+	var id TId
+	var ok bool
+
+	if recent > 0 && (recent > p.target || p.frequent.order.Len() == 0) {
+		id, ok = p.recent.Victim()
+	} else if p.frequent.order.Len() > 0 {
+		id, ok = p.frequent.Victim()
+	} else {
+		id, ok = p.recent.Victim()
+	}
+
+	p.victim, p.hasVictim = id, ok
+
+	return id, ok
+
+}
@@ -0,0 +1,172 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestLRUPolicy(t *testing.T) {
+
+    t.Log("validating TestLRUPolicy")
+
+    heapedCache := NewHeapedCache(3, WithEvictionPolicy[int, AccountTest](NewLRUPolicy[int]()))
+
+    for i := range 3 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    // reading 0 protects it, so 1 is the least recently used
+    heapedCache.Get(0)
+    heapedCache.Push(3, NewAccountTest(3))
+
+    require.NotNil(t, heapedCache.Get(0))
+    require.Nil(t, heapedCache.Get(1))
+    require.NoError(t, heapedCache.CheckInvariants())
+
+    heapedCache.Remove(2)
+    heapedCache.Push(4, NewAccountTest(4))
+    heapedCache.Push(5, NewAccountTest(5))
+
+    // 0 was read after 3 was pushed
+    require.Nil(t, heapedCache.Get(3))
+    require.Equal(t, 3, heapedCache.Len())
+
+    // Pop still follows the refreshed order
+    require.Equal(t, 0, heapedCache.Pop().Id)
+
+}
+
+func TestLRUPolicyWithFilter(t *testing.T) {
+
+    t.Log("validating TestLRUPolicyWithFilter")
+
+    heapedCache := NewHeapedCache(2,
+        WithEvictionPolicy[int, AccountTest](NewLRUPolicy[int]()),
+        WithEvictionFilter(func(id int, obj *AccountTest, refreshed time.Time) bool { return id != 0 }, 2),
+    )
+
+    for i := range 4 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    // the victim (0) is exempt, so nothing is evicted until the hard cap
+    require.Equal(t, 4, heapedCache.Len())
+
+    // past the hard cap, 0 is evicted regardless, and then the items allowed until back to capacity
+    heapedCache.Push(4, NewAccountTest(4))
+
+    require.Equal(t, 2, heapedCache.Len())
+    require.Nil(t, heapedCache.Get(0))
+    require.Nil(t, heapedCache.Get(2))
+    require.NotNil(t, heapedCache.Get(3))
+    require.NotNil(t, heapedCache.Get(4))
+
+}
+
+type brokenPolicy struct{}
+
+func (brokenPolicy) OnAdd(id int)    {}
+func (brokenPolicy) OnAccess(id int) {}
+func (brokenPolicy) OnRemove(id int) {}
+
+func (brokenPolicy) Victim() (int, bool) {
+
+    return -1, true
+
+}
+
+func TestPolicyOutOfSync(t *testing.T) {
+
+    t.Log("validating TestPolicyOutOfSync")
+
+    heapedCache := NewHeapedCache(3, WithEvictionPolicy[int, AccountTest](brokenPolicy{}))
+
+    for i := range 10 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    require.Equal(t, 3, heapedCache.Len())
+
+}
+
+func TestClockPolicy(t *testing.T) {
+
+    t.Log("validating TestClockPolicy")
+
+    heapedCache := NewHeapedCache(3, WithEvictionPolicy[int, AccountTest](NewClockPolicy[int]()))
+
+    for i := range 3 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    // 0 gets a second chance, so 1 is evicted
+    heapedCache.Get(0)
+    heapedCache.Push(3, NewAccountTest(3))
+
+    require.NotNil(t, heapedCache.Get(0))
+    require.Nil(t, heapedCache.Get(1))
+    require.NoError(t, heapedCache.CheckInvariants())
+
+    // 3 reuses the slot of 1; the hand is past it, at 2
+    heapedCache.Push(4, NewAccountTest(4))
+    require.Nil(t, heapedCache.Get(2))
+
+    heapedCache.Remove(0)
+    heapedCache.Push(5, NewAccountTest(5))
+    heapedCache.Push(6, NewAccountTest(6))
+
+    require.Equal(t, 3, heapedCache.Len())
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
+
+func TestARCPolicy(t *testing.T) {
+
+    t.Log("validating TestARCPolicy")
+
+    policy := NewARCPolicy[int](4)
+    heapedCache := NewHeapedCache(4, WithEvictionPolicy[int, AccountTest](policy))
+
+    // a hot set, used twice, moves to the frequency list
+    for i := range 2 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+        heapedCache.Get(i)
+
+    }
+
+    // a scan of keys used once churns the recency list only
+    for i := 100; i < 120; i++ {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    require.NotNil(t, heapedCache.Get(0))
+    require.NotNil(t, heapedCache.Get(1))
+    require.Nil(t, heapedCache.Get(100))
+    require.Equal(t, 4, heapedCache.Len())
+    require.Equal(t, 0, policy.target)
+
+    // a key of the scan coming back was evicted too early: the recency list gets more room
+    heapedCache.Push(116, NewAccountTest(116))
+
+    require.Equal(t, 1, policy.target)
+    require.NotNil(t, policy.frequent.elements[116])
+    require.NoError(t, heapedCache.CheckInvariants())
+
+    // removed items are not remembered as evicted
+    heapedCache.Remove(0)
+    require.False(t, policy.frequentGhost.contains(0))
+    require.Equal(t, 3, heapedCache.Len())
+
+}
@@ -0,0 +1,152 @@
+package utils
+
+import "time"
+
+// number of buckets of the rolling window of WithPressure
+const pressureBuckets = 10
+
+// evictions and inserts of a slice of the rolling window
+type pressureBucket struct {
+	start     time.Time
+	inserts   uint64
+	evictions uint64
+}
+
+// crossing of the pressure threshold, reported once the lock is released
+type pressureChange struct {
+	pressure float64
+	high     bool
+}
+
+// eviction pressure over a rolling window (see WithPressure)
+type pressure struct {
+	width     time.Duration // of a bucket
+	buckets   [pressureBuckets]pressureBucket
+	threshold float64
+	onChange  func(pressure float64, high bool)
+	high      bool
+	changed   bool // inserts or evictions since the threshold was last checked
+}
+
+// measures the eviction pressure of the cache, the number of evictions per insert over
+// the last window (see Pressure): close to 0 when the working set fits, close to 1 or more
+// when every new item pushes another one out, the sign of an undersized cache.
+// When onChange is not nil, it is called when the pressure rises to threshold or above
+// (high is true) and when it falls back below it, so producers can slow down or the service
+// can scale before the load shows downstream. It is called after the cache lock is released,
+// on the goroutine whose operation crossed the threshold, so it may call back into the cache
+func WithPressure[TId comparable, TObj any](window time.Duration, threshold float64, onChange func(pressure float64, high bool)) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		if window > 0 {
+			t.pressure = &pressure{width: max(window/pressureBuckets, 1), threshold: threshold, onChange: onChange}
+		}
+
+	}
+
+}
+
+// returns the evictions per insert over the window of WithPressure
+// returns 0 when the cache was not created with WithPressure
+func (t *HeapedCache[TId, TObj]) Pressure() float64 {
+
+	if t.pressure == nil {
+		return 0
+	}
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	return t.pressure.value(t.now())
+
+}
+
+// returns the bucket of now, emptied when it was last used for an older slice of time
+func (p *pressure) bucket(now time.Time) *pressureBucket {
+
+	start := now.Truncate(p.width)
+	slot := (start.UnixNano() / int64(p.width)) % pressureBuckets
+
+	if slot < 0 {
+		slot += pressureBuckets
+	}
+
+	bucket := &p.buckets[slot]
+
+	if !bucket.start.Equal(start) {
+		*bucket = pressureBucket{start: start}
+	}
+
+	return bucket
+
+}
+
+// returns the evictions per insert of the buckets within the window
+func (p *pressure) value(now time.Time) float64 {
+
+	oldest := now.Truncate(p.width).Add(-p.width * (pressureBuckets - 1))
+	inserts, evictions := uint64(0), uint64(0)
+
+	for _, bucket := range p.buckets {
+
+		if !bucket.start.Before(oldest) && !bucket.start.After(now) {
+			inserts += bucket.inserts
+			evictions += bucket.evictions
+		}
+
+	}
+
+	if inserts == 0 {
+		return float64(evictions)
+	}
+
+	return float64(evictions) / float64(inserts)
+
+}
+
+// accounts inserts and evictions
+// must be called under the lock
+func (p *pressure) observe(now time.Time, inserts uint64, evictions uint64) {
+
+	bucket := p.bucket(now)
+	bucket.inserts += inserts
+	bucket.evictions += evictions
+	p.changed = true
+
+}
+
+// returns the crossing of the threshold caused by the operations of a critical section, if any.
+// Checked once per critical section, so a Push evicting an item does not look like
+// two crossings (the insert lowering the pressure, then the eviction raising it)
+// must be called under the lock, right before releasing it
+func (t *HeapedCache[TId, TObj]) takePressureChange() (pressureChange, bool) {
+
+	p := t.pressure
+
+	if p == nil || p.onChange == nil || !p.changed {
+		return pressureChange{}, false
+	}
+
+	p.changed = false
+
+	value := p.value(t.now())
+
+	if (value >= p.threshold) == p.high {
+		return pressureChange{}, false
+	}
+
+	p.high = !p.high
+
+	return pressureChange{pressure: value, high: p.high}, true
+
+}
+
+// reports a crossing of the threshold, once the lock is released
+func (t *HeapedCache[TId, TObj]) deliverPressure(change pressureChange, crossed bool) {
+
+	if crossed {
+		contain(&t.panics, func() { t.pressure.onChange(change.pressure, change.high) })
+	}
+
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestPressure(t *testing.T) {
+
+    t.Log("validating TestPressure")
+
+    var changes []bool
+
+    clock := NewFakeClock(time.Unix(0, 0))
+    heapedCache := NewDeterministicHeapedCache(10, 1, clock, WithPressure[int, AccountTest](10*time.Second, 0.5, func(pressure float64, high bool) {
+        changes = append(changes, high)
+    }))
+
+    for i := range 10 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    require.Equal(t, 0.0, heapedCache.Pressure())
+    require.Empty(t, changes)
+
+    // every new item evicts another one
+    for i := 10; i < 30; i++ {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    require.Equal(t, 20.0/30.0, heapedCache.Pressure())
+    require.Equal(t, []bool{true}, changes)
+
+    // the window moves past the evictions
+    clock.Advance(11 * time.Second)
+
+    require.Equal(t, 0.0, heapedCache.Pressure())
+
+    heapedCache.Remove(29)
+    heapedCache.Push(29, NewAccountTest(29))
+
+    require.Equal(t, []bool{true, false}, changes)
+
+    require.Equal(t, 0.0, NewHeapedCache[int, AccountTest](10).Pressure())
+
+}
@@ -0,0 +1,68 @@
+package utils
+
+// view of the cache as a bounded, deduplicating FIFO queue.
+// Items come out in the order they were enqueued (ties on the refreshed time
+// are broken by sequence); enqueueing an id that is already queued replaces its
+// object and moves it to the back. When full, the overflow policy of the cache applies:
+// the oldest item is dropped by default, or Enqueue fails with ErrFull under RejectNew
+type Queue[TId comparable, TObj any] struct {
+	cache *HeapedCache[TId, TObj]
+}
+
+// returns the queue view of the cache
+func (t *HeapedCache[TId, TObj]) Queue() *Queue[TId, TObj] {
+
+	return &Queue[TId, TObj]{cache: t}
+
+}
+
+// adds the item to the back of the queue
+func (q *Queue[TId, TObj]) Enqueue(id TId, item *TObj) error {
+
+	_, err := q.cache.TryPush(id, item)
+	return err
+
+}
+
+// removes and returns the item at the front of the queue
+// returns false when the queue is empty
+func (q *Queue[TId, TObj]) Dequeue() (TId, *TObj, bool) {
+
+	q.cache.lock(OpPop)
+	defer q.cache.unlock()
+
+	if len(q.cache.sliceItems) == 0 {
+		var id TId
+		return id, nil, false
+	}
+
+	q.cache.settle()
+	id := q.cache.sliceItems[0].Id
+	return id, q.cache.pop(), true
+
+}
+
+// returns the item at the front of the queue without removing it
+// returns false when the queue is empty
+func (q *Queue[TId, TObj]) Peek() (TId, *TObj, bool) {
+
+	q.cache.lock(opOther)
+	defer q.cache.unlock()
+
+	if len(q.cache.sliceItems) == 0 {
+		var id TId
+		return id, nil, false
+	}
+
+	q.cache.settle()
+	item := q.cache.sliceItems[0]
+	return item.Id, item.obj, true
+
+}
+
+// returns the number of queued items
+func (q *Queue[TId, TObj]) Len() int {
+
+	return q.cache.Len()
+
+}
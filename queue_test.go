@@ -0,0 +1,63 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestQueueFIFO(t *testing.T) {
+
+    t.Log("validating TestQueueFIFO")
+
+    // a frozen clock gives every item the same refreshed time
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    queue := NewHeapedCache(100, WithClock[int, AccountTest](clock.Now)).Queue()
+
+    for i := range 50 {
+
+        require.NoError(t, queue.Enqueue(i, NewAccountTest(i)))
+
+    }
+
+    // re-enqueueing moves the item to the back
+    require.NoError(t, queue.Enqueue(0, NewAccountTest(0)))
+    require.Equal(t, 50, queue.Len())
+
+    id, obj, ok := queue.Peek()
+    require.True(t, ok)
+    require.Equal(t, 1, id)
+    require.Equal(t, 1, obj.Id)
+
+    for i := 1; i <= 50; i++ {
+
+        id, obj, ok := queue.Dequeue()
+
+        require.True(t, ok)
+        require.Equal(t, i%50, id)
+        require.Equal(t, i%50, obj.Id)
+
+    }
+
+    _, _, ok = queue.Dequeue()
+    require.False(t, ok)
+
+    _, _, ok = queue.Peek()
+    require.False(t, ok)
+
+}
+
+func TestQueueRejectNew(t *testing.T) {
+
+    t.Log("validating TestQueueRejectNew")
+
+    queue := NewHeapedCache(2, WithOverflowPolicy[int, AccountTest](RejectNew)).Queue()
+
+    require.NoError(t, queue.Enqueue(1, NewAccountTest(1)))
+    require.NoError(t, queue.Enqueue(2, NewAccountTest(2)))
+    require.ErrorIs(t, queue.Enqueue(3, NewAccountTest(3)), ErrFull)
+
+    id, _, _ := queue.Dequeue()
+    require.Equal(t, 1, id)
+
+}
@@ -0,0 +1,132 @@
+package utils
+
+import "time"
+
+// small front cache owned by a single worker goroutine (it is not safe for concurrent use),
+// serving hot reads without taking the lock of the shared cache.
+// Every replacement or removal on the shared cache (update, pop, eviction, remove) bumps its epoch;
+// the front cache compares epochs at most once per maxStaleness and drops everything
+// when they differ. So a read never returns an object replaced or removed more than
+// maxStaleness ago (with maxStaleness 0 the epoch is checked on every read and reads are never stale).
+// Items are not served past the time they expire (see WithTTL), whatever maxStaleness is.
+// Misses are not cached, and hits served by the front cache are not seen by
+// the eviction policy nor by the hot keys sketch
+type ReadCache[TId comparable, TObj any] struct {
+	cache        *HeapedCache[TId, TObj]
+	items        map[TId]readEntry[TObj]
+	size         int
+	maxStaleness time.Duration
+	epoch        uint64
+	checked      time.Time
+}
+
+// object kept by a ReadCache, with the time it expires (zero when it does not)
+type readEntry[TObj any] struct {
+	obj     *TObj
+	expires time.Time
+}
+
+// returns a new front cache holding up to size items
+// (it is reset when full, which keeps reads O(1) without any eviction bookkeeping)
+func (t *HeapedCache[TId, TObj]) ReadCache(size int, maxStaleness time.Duration) *ReadCache[TId, TObj] {
+
+	return &ReadCache[TId, TObj]{
+		cache:        t,
+		items:        make(map[TId]readEntry[TObj], size),
+		size:         size,
+		maxStaleness: maxStaleness,
+		epoch:        t.epoch.Load(),
+		checked:      t.now(),
+	}
+
+}
+
+// returns the cached item of a given id, from the front cache when possible
+// returns nil if it does not exist
+func (r *ReadCache[TId, TObj]) Get(id TId) *TObj {
+
+	now := r.cache.now()
+
+	if r.maxStaleness == 0 {
+		r.sync()
+	} else if now.Sub(r.checked) >= r.maxStaleness {
+		r.sync()
+		r.checked = now
+	}
+
+	if entry, ok := r.items[id]; ok {
+
+		if entry.expires.IsZero() || now.Before(entry.expires) {
+			return entry.obj
+		}
+
+		// expired: the shared cache decides, and reports it
+		delete(r.items, id)
+
+	}
+
+	obj, expires := r.cache.getExpiring(id)
+
+	if obj != nil {
+
+		if len(r.items) >= r.size {
+			clear(r.items)
+		}
+
+		r.items[id] = readEntry[TObj]{obj: obj, expires: expires}
+
+	}
+
+	return obj
+
+}
+
+// drops every item of the front cache when the shared cache changed since the last check
+// the epoch is loaded before any read from the shared cache, so no item older than it is kept
+func (r *ReadCache[TId, TObj]) sync() {
+
+	if epoch := r.cache.epoch.Load(); epoch != r.epoch {
+		clear(r.items)
+		r.epoch = epoch
+	}
+
+}
+
+// drops every item of the front cache
+func (r *ReadCache[TId, TObj]) Reset() {
+
+	clear(r.items)
+
+}
+
+// same as Get, returning as well the time the item expires (the zero time when it does not)
+func (t *HeapedCache[TId, TObj]) getExpiring(id TId) (*TObj, time.Time) {
+
+	if t.latencies != nil {
+		defer t.latencies.observe(OpGet, time.Now())
+	}
+
+	id = t.key(id)
+
+	if t.certainlyMissing(id) {
+		t.itemMissed(id)
+		return nil, time.Time{}
+	}
+
+	t.lock(OpGet)
+	defer t.unlock()
+
+	t.touchKey(id)
+
+	item := t.unexpired(t.mapItems[t.resolve(id)])
+
+	if item == nil {
+		t.itemMissed(id)
+		return nil, time.Time{}
+	}
+
+	t.itemRead(item)
+
+	return item.obj, t.expiresAt(item)
+
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestReadCacheStaleness(t *testing.T) {
+
+    t.Log("validating TestReadCacheStaleness")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache[int, AccountTest](10, 1, clock)
+    readCache := heapedCache.ReadCache(10, 50*time.Millisecond)
+
+    heapedCache.Push(1, NewAccountTest(1))
+    first := readCache.Get(1)
+    require.Equal(t, 1, first.Id)
+
+    // a replaced object may be served until maxStaleness has passed
+    heapedCache.Push(1, NewAccountTest(1))
+    require.Same(t, first, readCache.Get(1))
+
+    clock.Advance(50 * time.Millisecond)
+    require.NotSame(t, first, readCache.Get(1))
+
+    // removals as well
+    heapedCache.Remove(1)
+    require.NotNil(t, readCache.Get(1))
+
+    clock.Advance(50 * time.Millisecond)
+    require.Nil(t, readCache.Get(1))
+
+}
+
+func TestReadCacheNoStaleness(t *testing.T) {
+
+    t.Log("validating TestReadCacheNoStaleness")
+
+    heapedCache := NewHeapedCache[int, AccountTest](3)
+    readCache := heapedCache.ReadCache(2, 0)
+
+    for i := range 3 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+        require.Equal(t, i, readCache.Get(i).Id)
+
+    }
+
+    // evicting 0 invalidates the front cache right away
+    heapedCache.Push(3, NewAccountTest(3))
+    require.Nil(t, readCache.Get(0))
+    require.Equal(t, 2, readCache.Get(2).Id)
+
+    // misses are not cached
+    require.Nil(t, readCache.Get(4))
+    heapedCache.Push(4, NewAccountTest(4))
+    require.Equal(t, 4, readCache.Get(4).Id)
+
+}
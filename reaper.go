@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"context"
+	"time"
+)
+
+// evicts the items that were not read within after of being cached (or last refreshed),
+// so write-once-read-never rows do not crowd out the ones being read: they leave as soon as
+// they are found unread, instead of waiting for their turn as the oldest item.
+// Enables WithAccessTracking; the items are checked in the background every after/2
+// (in chunks, see ForEach), reported to OnEvict as any eviction. Close stops it
+func WithUnreadReaper[TId comparable, TObj any](after time.Duration) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		if after <= 0 {
+			return
+		}
+
+		t.trackAccess = true
+		t.every(taskReaper, max(after/2, time.Millisecond), func() { t.reapUnread(after) })
+
+	}
+
+}
+
+// evicts the items never read and refreshed at least after ago, returning how many
+func (t *HeapedCache[TId, TObj]) reapUnread(after time.Duration) int {
+
+	cutoff := t.now().Add(-after)
+	reaped := 0
+
+	t.scan(context.Background(), func(items []*HeapedCacheItem[TId, TObj]) bool {
+
+		for _, item := range items {
+
+			if item.accesses == 0 && !item.Refreshed.After(cutoff) {
+				t.evictItem(item)
+				reaped++
+			}
+
+		}
+
+		return true
+
+	})
+
+	return reaped
+
+}
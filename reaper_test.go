@@ -0,0 +1,40 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestUnreadReaper(t *testing.T) {
+
+    t.Log("validating TestUnreadReaper")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache(10, 1, clock, WithUnreadReaper[int, AccountTest](time.Hour))
+    defer heapedCache.Close()
+
+    var evicted []int
+    heapedCache.OnEvict(func(id int, obj *AccountTest) { evicted = append(evicted, id) })
+
+    for i := range 4 {
+        heapedCache.Push(i, NewAccountTest(i))
+    }
+
+    // the reaper runs every 30 minutes of the virtual clock
+    clock.Advance(30 * time.Minute)
+    heapedCache.Get(1)
+    heapedCache.Push(4, NewAccountTest(4))
+
+    require.Empty(t, evicted)
+
+    clock.Advance(30 * time.Minute)
+
+    // 1 was read, 4 is not old enough yet
+    require.ElementsMatch(t, []int{0, 2, 3}, evicted)
+    require.Equal(t, 0, heapedCache.reapUnread(time.Hour))
+    require.NotNil(t, heapedCache.Get(1))
+    require.NotNil(t, heapedCache.Get(4))
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
@@ -0,0 +1,137 @@
+package utils
+
+import "time"
+
+// operations tracked by the recorder
+const (
+	OpPush   = "push"
+	OpPop    = "pop"
+	OpRemove = "remove"
+	OpEvict  = "evict"
+	OpExpire = "expire" // see WithTTL
+)
+
+// outcomes tracked by the recorder
+const (
+	OutcomeAdded       = "added"
+	OutcomeUpdated     = "updated"
+	OutcomePatched     = "patched"
+	OutcomePopped      = "popped"
+	OutcomeRemoved     = "removed"
+	OutcomeNotFound    = "not found"
+	OutcomeEvicted     = "evicted"
+	OutcomeRejected    = "rejected"
+	OutcomeInvalidated = "invalidated"
+	OutcomeLeased      = "leased"
+	OutcomeReturned    = "returned" // leased without an Ack, or refused by the sink of DrainAll
+	OutcomeExpired     = "expired"
+)
+
+// struct to represent a recorded operation
+type RecordedOp[TId any] struct {
+	Op      string
+	Id      TId
+	Outcome string
+	Time    time.Time
+}
+
+// bounded ring buffer with the last operations done on the cache
+type recorder[TId any] struct {
+	ops  []RecordedOp[TId]
+	next int
+	full bool
+}
+
+// keeps the last size operations (push, pop, remove and evictions)
+// so they can be inspected with RecentOps
+func WithRecorder[TId comparable, TObj any](size int) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		if size > 0 {
+			t.recorder = &recorder[TId]{ops: make([]RecordedOp[TId], size)}
+		}
+
+	}
+
+}
+
+// records an operation, overwriting the oldest one when the buffer is full
+func (r *recorder[TId]) record(op string, id TId, outcome string, now time.Time) {
+
+	r.ops[r.next] = RecordedOp[TId]{Op: op, Id: id, Outcome: outcome, Time: now}
+	r.next++
+
+	if r.next == len(r.ops) {
+		r.next = 0
+		r.full = true
+	}
+
+}
+
+// records an operation when the recorder is enabled, audits it (WithAudit)
+// and replays it on the shadows (WithShadow)
+func (t *HeapedCache[TId, TObj]) record(op string, id TId, outcome string) {
+
+	if t.recorder != nil {
+		t.recorder.record(op, id, outcome, t.now())
+	}
+
+	if t.wal != nil {
+		t.walLog(id, outcome)
+	}
+
+	if _, changed := auditedOutcomes[outcome]; changed && t.dirty != nil {
+		t.dirty[id] = struct{}{}
+	}
+
+	if t.audit != nil {
+		t.audit.observe(op, id, outcome, t.now(), t.ctx)
+	}
+
+	for _, s := range t.shadows {
+		s.replay(id, outcome)
+	}
+
+}
+
+// returns the recorded operations from the oldest to the newest
+func (r *recorder[TId]) recent() []RecordedOp[TId] {
+
+	if r == nil {
+		return nil
+	}
+
+	if !r.full {
+		return append([]RecordedOp[TId](nil), r.ops[:r.next]...)
+	}
+
+	result := make([]RecordedOp[TId], 0, len(r.ops))
+	result = append(result, r.ops[r.next:]...)
+	return append(result, r.ops[:r.next]...)
+
+}
+
+// returns the last recorded operations from the oldest to the newest
+// returns nil when the cache was not created with WithRecorder
+func (t *HeapedCache[TId, TObj]) RecentOps() []RecordedOp[TId] {
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	return t.recorder.recent()
+
+}
+
+// RecentOps for the admin handler of the Registry, redacted
+func (t *HeapedCache[TId, TObj]) recentOps() any {
+
+	result := t.RecentOps()
+
+	for i := range result {
+		result[i].Id = t.redactedID(result[i].Id)
+	}
+
+	return result
+
+}
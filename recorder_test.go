@@ -0,0 +1,75 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+)
+
+func TestRecorder(t *testing.T) {
+
+    t.Log("validating TestRecorder")
+
+    heapedCache := NewHeapedCache[int, AccountTest](2, WithRecorder[int, AccountTest](10))
+
+    heapedCache.Push(1, NewAccountTest(1))
+    heapedCache.Push(2, NewAccountTest(2))
+    heapedCache.Push(2, NewAccountTest(2))
+    heapedCache.Push(3, NewAccountTest(3))
+    heapedCache.Pop()
+
+    ops := heapedCache.RecentOps()
+
+    require.Equal(t, []string{OpPush, OpPush, OpPush, OpPush, OpEvict, OpPop}, recordedOps(ops))
+    require.Equal(t, OutcomeUpdated, ops[2].Outcome)
+    require.Equal(t, 1, ops[4].Id)
+    require.Equal(t, 2, ops[5].Id)
+
+}
+
+func TestRecorderWrapAround(t *testing.T) {
+
+    t.Log("validating TestRecorderWrapAround")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10, WithRecorder[int, AccountTest](3))
+
+    for i := range 5 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    ops := heapedCache.RecentOps()
+
+    require.Equal(t, 3, len(ops))
+
+    for i, op := range ops {
+
+        require.Equal(t, i+2, op.Id)
+
+    }
+
+}
+
+func TestRecorderDisabled(t *testing.T) {
+
+    t.Log("validating TestRecorderDisabled")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    heapedCache.Push(1, NewAccountTest(1))
+
+    require.Nil(t, heapedCache.RecentOps())
+
+}
+
+func recordedOps(ops []RecordedOp[int]) []string {
+
+    result := make([]string, len(ops))
+
+    for i, op := range ops {
+        result[i] = op.Op
+    }
+
+    return result
+
+}
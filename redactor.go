@@ -0,0 +1,84 @@
+package utils
+
+// masks the sensitive parts of ids and objects (e.g. AccountTest.Phone) in human-readable output:
+// ExportNDJSON, the admin handler of the Registry and the lines of StartReporter (see WithRedactor)
+type Redactor[TId comparable, TObj any] interface {
+
+	// returns the id as it may be shown (e.g. an email with its local part masked)
+	RedactID(id TId) TId
+
+	// returns a copy of obj with its sensitive fields masked; obj itself must not be modified
+	RedactObj(obj *TObj) *TObj
+}
+
+// adapter allowing plain functions to be used as a Redactor
+// (a nil function leaves the ids or the objects as they are)
+type RedactorFuncs[TId comparable, TObj any] struct {
+	ID  func(id TId) TId
+	Obj func(obj *TObj) *TObj
+}
+
+func (f RedactorFuncs[TId, TObj]) RedactID(id TId) TId {
+
+	if f.ID == nil {
+		return id
+	}
+
+	return f.ID(id)
+
+}
+
+func (f RedactorFuncs[TId, TObj]) RedactObj(obj *TObj) *TObj {
+
+	if f.Obj == nil {
+		return obj
+	}
+
+	return f.Obj(obj)
+
+}
+
+// masks the ids and objects written by ExportNDJSON (the projection gets them redacted), served by
+// the admin handler of the Registry (recent operations, top keys, keys) and logged by StartReporter,
+// so the debug endpoints can be enabled on caches holding PII. Snapshots, the write-ahead log,
+// Handoff and the methods returning ids and objects to the application are not redacted.
+// When the redactor panics, the id is shown as its zero value and the object as null
+func WithRedactor[TId comparable, TObj any](redactor Redactor[TId, TObj]) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		t.redactor = redactor
+
+	}
+
+}
+
+// returns an id as shown in human-readable output (see WithRedactor)
+func (t *HeapedCache[TId, TObj]) redactedID(id TId) TId {
+
+	if t.redactor == nil {
+		return id
+	}
+
+	var result TId
+
+	contain(&t.panics, func() { result = t.redactor.RedactID(id) })
+
+	return result
+
+}
+
+// returns an object as shown in human-readable output (see WithRedactor)
+func (t *HeapedCache[TId, TObj]) redactedObj(obj *TObj) *TObj {
+
+	if t.redactor == nil || obj == nil {
+		return obj
+	}
+
+	var result *TObj
+
+	contain(&t.panics, func() { result = t.redactor.RedactObj(obj) })
+
+	return result
+
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+    "bytes"
+    "context"
+    "github.com/stretchr/testify/require"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+// masks the email of the ids and the phone of the objects
+var redactorTest = RedactorFuncs[string, AccountTest]{
+    ID: func(id string) string {
+        prefix, _, _ := strings.Cut(id, "@")
+        return prefix[:1] + "***"
+    },
+    Obj: func(obj *AccountTest) *AccountTest {
+        masked := *obj
+        masked.Phone = "***"
+        return &masked
+    },
+}
+
+func TestRedactor(t *testing.T) {
+
+    t.Log("validating TestRedactor")
+
+    heapedCache := NewHeapedCache(10,
+        WithRedactor[string, AccountTest](redactorTest),
+        WithRecorder[string, AccountTest](10),
+        WithHotKeys[string, AccountTest](10))
+
+    heapedCache.Push("ann@example.com", NewAccountTest(1))
+    heapedCache.Get("ann@example.com")
+
+    requireRedacted := func(output string) {
+        require.NotContains(t, output, "ann@example.com")
+        require.NotContains(t, output, "PHONE")
+    }
+
+    var buf bytes.Buffer
+
+    require.NoError(t, heapedCache.ExportNDJSON(&buf, nil))
+    require.Contains(t, buf.String(), `"id":"a***"`)
+    requireRedacted(buf.String())
+
+    buf.Reset()
+
+    // the projection gets them redacted
+    require.NoError(t, heapedCache.ExportNDJSON(&buf, func(id string, obj *AccountTest, _ time.Time) any { return id + " " + obj.Phone }))
+    require.Equal(t, "\"a*** ***\"\n", buf.String())
+
+    // the cached object is left untouched, and snapshots are not redacted
+    require.Equal(t, "PHONE 1", heapedCache.Get("ann@example.com").Phone)
+
+    buf.Reset()
+
+    require.NoError(t, heapedCache.WriteSnapshot(&buf))
+    require.Contains(t, buf.String(), "ann@example.com")
+
+    registry := NewRegistry()
+    require.NoError(t, registry.Register("accounts", heapedCache))
+
+    server := httptest.NewServer(registry.Handler())
+    defer server.Close()
+
+    for _, path := range []string{"/recent?cache=accounts", "/top?cache=accounts", "/oldest?cache=accounts", "/key?cache=accounts&id=ann@example.com"} {
+
+        resp, err := http.Get(server.URL + path)
+        require.NoError(t, err)
+        body, err := io.ReadAll(resp.Body)
+        resp.Body.Close()
+        require.NoError(t, err)
+        require.Equal(t, http.StatusOK, resp.StatusCode)
+        require.Contains(t, string(body), "a***")
+        requireRedacted(string(body))
+
+    }
+
+    var last reportCounters
+
+    require.Contains(t, heapedCache.report(&last), " hot_key=a***")
+
+    // a handoff moves the cache as it is
+    peer := NewHeapedCache[string, AccountTest](10)
+    peerServer := httptest.NewServer(peer.HandoffHandler())
+    defer peerServer.Close()
+
+    _, err := heapedCache.Handoff(context.Background(), nil, peerServer.URL)
+    require.NoError(t, err)
+    require.Equal(t, "PHONE 1", peer.Get("ann@example.com").Phone)
+
+}
+
+func TestRedactorPanic(t *testing.T) {
+
+    t.Log("validating TestRedactorPanic")
+
+    heapedCache := NewHeapedCache(10, WithRedactor[string, AccountTest](RedactorFuncs[string, AccountTest]{
+        ID: func(id string) string { panic("redactor") },
+    }))
+
+    heapedCache.Push("ann@example.com", NewAccountTest(1))
+
+    var buf bytes.Buffer
+
+    // nothing unredacted is written
+    require.NoError(t, heapedCache.ExportNDJSON(&buf, nil))
+    require.Contains(t, buf.String(), `"id":""`)
+    require.Equal(t, uint64(1), heapedCache.Stats().Panics)
+
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+)
+
+// panic value (wrapped with the operation) of a reentrant call detected by WithReentrancyDetection
+var ErrReentrantCall = errors.New("heapedcache: reentrant call, a callback run under the cache lock called back into the same cache")
+
+// goroutine holding the cache lock
+type reentrancy struct {
+	owner atomic.Int64 // id of the goroutine, 0 while the lock is free
+}
+
+// debug mode: detects callbacks run under the cache lock (loaders, Patch, eviction filters, ...)
+// that call back into the same cache on the same goroutine, which would deadlock on the
+// non-reentrant mutex. Such calls panic with ErrReentrantCall instead (contained like any other
+// panic of the callback, so a loader gets it back from TryGetOrAdd as a *PanicError).
+// Every acquisition of the lock reads the goroutine id from its stack, so it is meant for tests
+// and debugging sessions rather than production
+func WithReentrancyDetection[TId comparable, TObj any]() Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		t.reentrancy = &reentrancy{}
+
+	}
+
+}
+
+// panics when the goroutine gid already holds the lock
+func (r *reentrancy) check(gid int64, op string) {
+
+	if r.owner.Load() == gid {
+		panic(fmt.Errorf("%w (%s)", ErrReentrantCall, op))
+	}
+
+}
+
+// returns the id of the current goroutine, read from the header of its stack ("goroutine 42 [running]:")
+func goroutineID() int64 {
+
+	var buf [64]byte
+
+	header := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine "))
+	id, _ := strconv.ParseInt(string(header[:bytes.IndexByte(header, ' ')]), 10, 64)
+
+	return id
+
+}
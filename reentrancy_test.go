@@ -0,0 +1,28 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+)
+
+func TestReentrancyDetection(t *testing.T) {
+
+    t.Log("validating TestReentrancyDetection")
+
+    heapedCache := NewHeapedCache(1, WithReentrancyDetection[int, AccountTest]())
+
+    _, err := heapedCache.TryGetOrAdd(1, func(id int) *AccountTest {
+        return heapedCache.Get(2)
+    })
+    require.ErrorIs(t, err, ErrReentrantCall)
+
+    heapedCache.Push(1, NewAccountTest(1))
+
+    require.False(t, heapedCache.Patch(1, func(obj *AccountTest) {
+        heapedCache.Remove(1)
+    }))
+
+    require.NotNil(t, heapedCache.Get(1))
+    require.Equal(t, uint64(2), heapedCache.Stats().Panics)
+
+}
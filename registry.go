@@ -0,0 +1,424 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// what the registry needs from a cache (HeapedCache of any type implements it)
+type Managed interface {
+	Stats() Stats
+	Clear() int
+	WriteSnapshot(w io.Writer) error
+}
+
+var _ Managed = (*HeapedCache[int, struct{}])(nil)
+
+// what the admin handler serves for a cache beyond Managed (HeapedCache of any type implements it)
+type inspected interface {
+	recentOps() any
+	topKeys(n int) any
+	oldest(n int) any
+	lookupKey(id string) (any, bool, error)
+	removeKey(id string) (bool, error)
+}
+
+var _ inspected = (*HeapedCache[int, struct{}])(nil)
+
+// set of named caches managed together: stats, metrics, admin endpoint and global actions
+type Registry struct {
+	mu     sync.Mutex
+	caches map[string]Managed
+}
+
+// process-wide registry, for applications that do not need more than one
+var DefaultRegistry = NewRegistry()
+
+// conctructor of the Registry
+func NewRegistry() *Registry {
+
+	return &Registry{caches: make(map[string]Managed)}
+
+}
+
+// adds a cache under name, which must be unique and usable as a file name (see SnapshotAll)
+func (r *Registry) Register(name string, cache Managed) error {
+
+	if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+		return fmt.Errorf("invalid cache name %q", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.caches[name]; ok {
+		return fmt.Errorf("cache %q already registered", name)
+	}
+
+	r.caches[name] = cache
+
+	return nil
+
+}
+
+// removes a cache from the registry (the cache itself is left untouched)
+func (r *Registry) Unregister(name string) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.caches, name)
+
+}
+
+// returns the cache registered under name
+func (r *Registry) Get(name string) (Managed, bool) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cache, ok := r.caches[name]
+	return cache, ok
+
+}
+
+// returns the names of the registered caches, sorted
+func (r *Registry) Names() []string {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return sortedKeys(r.caches)
+
+}
+
+// copies the registered caches so they can be used outside the registry lock
+func (r *Registry) all() map[string]Managed {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make(map[string]Managed, len(r.caches))
+
+	for name, cache := range r.caches {
+		result[name] = cache
+	}
+
+	return result
+
+}
+
+// returns the statistics of every registered cache, by name
+func (r *Registry) Stats() map[string]Stats {
+
+	caches := r.all()
+	result := make(map[string]Stats, len(caches))
+
+	for name, cache := range caches {
+		result[name] = cache.Stats()
+	}
+
+	return result
+
+}
+
+// writes the statistics of every registered cache in the Prometheus text exposition format,
+// in the same metric families, told apart by a cache label
+func (r *Registry) WritePrometheus(w io.Writer, namespace string) error {
+
+	var families metricFamilies
+
+	stats := r.Stats()
+
+	for _, name := range sortedKeys(stats) {
+		stats[name].collect(&families, namespace, fmt.Sprintf("cache=%q", name))
+	}
+
+	return families.write(w)
+
+}
+
+// removes every item of every registered cache, returning how many were removed
+func (r *Registry) ClearAll() int {
+
+	removed := 0
+
+	for _, cache := range r.all() {
+		removed += cache.Clear()
+	}
+
+	return removed
+
+}
+
+// writes a snapshot of every registered cache to dir/<name>.ndjson (e.g. on SIGTERM).
+// Each file is written to a temporary file first and renamed, so a snapshot is never
+// left half written; every cache is attempted, and the errors are joined
+func (r *Registry) SnapshotAll(dir string) error {
+
+	return r.SnapshotAllTo(context.Background(), NewDirStore(dir), "")
+
+}
+
+// same as SnapshotAll, writing the snapshots to store under prefix<name>.ndjson
+// (e.g. "snapshots/" in a bucket)
+func (r *Registry) SnapshotAllTo(ctx context.Context, store ObjectStore, prefix string) error {
+
+	var errs []error
+
+	for name, cache := range r.all() {
+
+		if err := putObject(ctx, store, prefix+name+".ndjson", cache.WriteSnapshot); err != nil {
+			errs = append(errs, fmt.Errorf("cache %q: %w", name, err))
+		}
+
+	}
+
+	return errors.Join(errs...)
+
+}
+
+// writes a file through a temporary file renamed at the end, so it is never left half written
+func writeSnapshotFile(path string, write func(w io.Writer) error) error {
+
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(file.Name())
+
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	// on disk before it replaces the previous file, so a crash cannot leave an empty one
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		return err
+	}
+
+	// persists the rename itself (best effort: not every platform syncs directories)
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		_ = dir.Sync()
+		dir.Close()
+	}
+
+	return nil
+
+}
+
+// returns an admin handler for the registered caches:
+//
+//	GET    /metrics                Prometheus metrics of every cache (namespace "heapedcache")
+//	GET    /stats                  statistics of every cache, as JSON
+//	POST   /clear?cache=NAME       removes every item of a cache (of all of them without NAME)
+//	GET    /recent?cache=NAME      last operations of a cache, as JSON (see WithRecorder)
+//	GET    /top?cache=NAME&n=N     the N most read ids of a cache (10 by default), as JSON (see WithHotKeys)
+//	GET    /oldest?cache=NAME&n=N  the N oldest items of a cache (10 by default), as snapshot lines in a JSON array
+//	GET    /key?cache=NAME&id=ID   the item cached under ID, as a snapshot line (404 when it is not cached)
+//	DELETE /key?cache=NAME&id=ID   removes the item cached under ID, answering the number of items removed
+//
+// Ids are parsed as JSON, or taken as strings when they are not valid JSON (id=42 is the number 42
+// for int ids, the string "42" for string ids); ids and objects are shown redacted (see WithRedactor).
+// Paths are relative: mount it with http.StripPrefix when serving it under a prefix.
+// It shows the cached objects, and POST /clear and DELETE /key change the caches, so serve it on an internal
+// port or behind authentication, never on a public listener
+func (r *Registry) Handler() http.Handler {
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		_ = r.WritePrometheus(w, "heapedcache")
+	})
+
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(r.Stats())
+	})
+
+	mux.HandleFunc("POST /clear", func(w http.ResponseWriter, req *http.Request) {
+
+		name := req.URL.Query().Get("cache")
+
+		if name == "" {
+			fmt.Fprintf(w, "%d\n", r.ClearAll())
+			return
+		}
+
+		cache, ok := r.Get(name)
+
+		if !ok {
+			http.Error(w, fmt.Sprintf("cache %q not found", name), http.StatusNotFound)
+			return
+		}
+
+		fmt.Fprintf(w, "%d\n", cache.Clear())
+
+	})
+
+	mux.HandleFunc("GET /recent", func(w http.ResponseWriter, req *http.Request) {
+
+		cache, ok := r.inspected(w, req)
+
+		if !ok {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(cache.recentOps())
+
+	})
+
+	mux.HandleFunc("GET /top", func(w http.ResponseWriter, req *http.Request) {
+
+		n, ok := numberOfKeys(w, req)
+
+		if !ok {
+			return
+		}
+
+		cache, ok := r.inspected(w, req)
+
+		if !ok {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(cache.topKeys(n))
+
+	})
+
+	mux.HandleFunc("GET /oldest", func(w http.ResponseWriter, req *http.Request) {
+
+		n, ok := numberOfKeys(w, req)
+
+		if !ok {
+			return
+		}
+
+		cache, ok := r.inspected(w, req)
+
+		if !ok {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(cache.oldest(n))
+
+	})
+
+	mux.HandleFunc("GET /key", func(w http.ResponseWriter, req *http.Request) {
+
+		cache, ok := r.inspected(w, req)
+
+		if !ok {
+			return
+		}
+
+		id := req.URL.Query().Get("id")
+		item, found, err := cache.lookupKey(id)
+
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid id %q: %v", id, err), http.StatusBadRequest)
+			return
+		}
+
+		if !found {
+			http.Error(w, fmt.Sprintf("key %q not found", id), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(item)
+
+	})
+
+	mux.HandleFunc("DELETE /key", func(w http.ResponseWriter, req *http.Request) {
+
+		cache, ok := r.inspected(w, req)
+
+		if !ok {
+			return
+		}
+
+		id := req.URL.Query().Get("id")
+		removed, err := cache.removeKey(id)
+
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid id %q: %v", id, err), http.StatusBadRequest)
+			return
+		}
+
+		if removed {
+			fmt.Fprintln(w, 1)
+		} else {
+			fmt.Fprintln(w, 0)
+		}
+
+	})
+
+	return mux
+
+}
+
+// returns the cache named by the cache parameter of req, writing the error response when there is none
+func (r *Registry) inspected(w http.ResponseWriter, req *http.Request) (inspected, bool) {
+
+	name := req.URL.Query().Get("cache")
+	cache, ok := r.Get(name)
+
+	if !ok {
+		http.Error(w, fmt.Sprintf("cache %q not found", name), http.StatusNotFound)
+		return nil, false
+	}
+
+	result, ok := cache.(inspected)
+
+	if !ok {
+		http.Error(w, fmt.Sprintf("cache %q cannot be inspected", name), http.StatusNotImplemented)
+		return nil, false
+	}
+
+	return result, true
+
+}
+
+// returns the n parameter of req (10 by default), writing the error response when it is invalid
+func numberOfKeys(w http.ResponseWriter, req *http.Request) (int, bool) {
+
+	value := req.URL.Query().Get("n")
+
+	if value == "" {
+		return 10, true
+	}
+
+	n, err := strconv.Atoi(value)
+
+	if err != nil || n < 0 {
+		http.Error(w, fmt.Sprintf("invalid number of keys %q", value), http.StatusBadRequest)
+		return 0, false
+	}
+
+	return n, true
+
+}
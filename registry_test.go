@@ -0,0 +1,202 @@
+package utils
+
+import (
+    "bytes"
+    "encoding/json"
+    "io"
+    "github.com/stretchr/testify/require"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestRegistry(t *testing.T) {
+
+    t.Log("validating TestRegistry")
+
+    registry := NewRegistry()
+
+    accounts := NewHeapedCache[int, AccountTest](10)
+    names := NewHeapedCache[string, string](10)
+
+    require.NoError(t, registry.Register("accounts", accounts))
+    require.NoError(t, registry.Register("names", names))
+    require.Error(t, registry.Register("accounts", accounts))
+    require.Error(t, registry.Register("../etc", accounts))
+    require.Equal(t, []string{"accounts", "names"}, registry.Names())
+
+    for i := range 3 {
+
+        accounts.Push(i, NewAccountTest(i))
+
+    }
+
+    name := "EMERSON"
+    names.Push("a", &name)
+
+    require.Equal(t, 3, registry.Stats()["accounts"].Len)
+
+    var buffer bytes.Buffer
+    require.NoError(t, registry.WritePrometheus(&buffer, "app_cache"))
+
+    // one family for both caches
+    text := buffer.String()
+    require.Equal(t, 1, strings.Count(text, "# TYPE app_cache_items gauge\n"))
+    require.Contains(t, text, "app_cache_items{cache=\"accounts\"} 3\n")
+    require.Contains(t, text, "app_cache_items{cache=\"names\"} 1\n")
+
+    dir := t.TempDir()
+    require.NoError(t, registry.SnapshotAll(dir))
+
+    file, err := os.Open(filepath.Join(dir, "accounts.ndjson"))
+    require.NoError(t, err)
+    defer file.Close()
+
+    snapshot, err := ReadSnapshot[int, AccountTest](file)
+    require.NoError(t, err)
+    require.Len(t, snapshot, 3)
+
+    for i, entry := range accounts.Snapshot() {
+
+        require.Equal(t, entry.Id, snapshot[i].Id)
+        require.True(t, entry.Refreshed.Equal(snapshot[i].Refreshed))
+
+    }
+
+    entries, err := os.ReadDir(dir)
+    require.NoError(t, err)
+    require.Len(t, entries, 2)
+
+    require.Equal(t, 4, registry.ClearAll())
+    require.Equal(t, 0, accounts.Len())
+    require.NoError(t, accounts.CheckInvariants())
+
+}
+
+func TestRegistryHandler(t *testing.T) {
+
+    t.Log("validating TestRegistryHandler")
+
+    registry := NewRegistry()
+    accounts := NewHeapedCache[int, AccountTest](10)
+    require.NoError(t, registry.Register("accounts", accounts))
+
+    accounts.Push(1, NewAccountTest(1))
+
+    server := httptest.NewServer(registry.Handler())
+    defer server.Close()
+
+    response, err := http.Get(server.URL + "/metrics")
+    require.NoError(t, err)
+    body := new(bytes.Buffer)
+    _, _ = body.ReadFrom(response.Body)
+    response.Body.Close()
+    require.Contains(t, body.String(), "heapedcache_items{cache=\"accounts\"} 1\n")
+
+    response, err = http.Post(server.URL+"/clear?cache=missing", "", nil)
+    require.NoError(t, err)
+    response.Body.Close()
+    require.Equal(t, http.StatusNotFound, response.StatusCode)
+
+    response, err = http.Post(server.URL+"/clear?cache=accounts", "", nil)
+    require.NoError(t, err)
+    response.Body.Close()
+    require.Equal(t, http.StatusOK, response.StatusCode)
+    require.Equal(t, 0, accounts.Len())
+
+}
+
+func TestRegistryHandlerInspect(t *testing.T) {
+
+    t.Log("validating TestRegistryHandlerInspect")
+
+    registry := NewRegistry()
+    accounts := NewHeapedCache(10, WithRecorder[int, AccountTest](10), WithHotKeys[int, AccountTest](10))
+    require.NoError(t, registry.Register("accounts", accounts))
+
+    accounts.Push(1, NewAccountTest(1))
+    accounts.Push(2, NewAccountTest(2))
+    accounts.Get(2)
+    accounts.Get(2)
+    accounts.Get(1)
+
+    server := httptest.NewServer(registry.Handler())
+    defer server.Close()
+
+    get := func(endpoint string, status int, out any) {
+
+        response, err := http.Get(server.URL + endpoint)
+        require.NoError(t, err)
+        defer response.Body.Close()
+        require.Equal(t, status, response.StatusCode, endpoint)
+
+        if out != nil {
+            require.NoError(t, json.NewDecoder(response.Body).Decode(out))
+        }
+
+    }
+
+    var ops []RecordedOp[int]
+    get("/recent?cache=accounts", http.StatusOK, &ops)
+    require.Len(t, ops, 2)
+    require.Equal(t, OpPush, ops[1].Op)
+    require.Equal(t, 2, ops[1].Id)
+
+    var top []KeyCount[int]
+    get("/top?cache=accounts&n=1", http.StatusOK, &top)
+    require.Len(t, top, 1)
+    require.Equal(t, 2, top[0].Id)
+    require.Equal(t, uint64(2), top[0].Count)
+
+    get("/top?cache=accounts", http.StatusOK, &top)
+    require.Len(t, top, 2)
+
+    get("/top?cache=accounts&n=x", http.StatusBadRequest, nil)
+    get("/top?cache=accounts&n=-1", http.StatusBadRequest, nil)
+    get("/recent?cache=missing", http.StatusNotFound, nil)
+    get("/top?cache=missing", http.StatusNotFound, nil)
+
+    var oldest []exportedItem[int, AccountTest]
+    get("/oldest?cache=accounts&n=1", http.StatusOK, &oldest)
+    require.Len(t, oldest, 1)
+    require.Equal(t, 1, oldest[0].Id)
+    require.Equal(t, "PHONE 1", oldest[0].Obj.Phone)
+
+    var item exportedItem[int, AccountTest]
+    get("/key?cache=accounts&id=2", http.StatusOK, &item)
+    require.Equal(t, 2, item.Id)
+
+    // looking an item up is not a read
+    require.Equal(t, uint64(2), accounts.TopKeys(1)[0].Count)
+
+    get("/key?cache=accounts&id=3", http.StatusNotFound, nil)
+    get("/key?cache=accounts&id=x", http.StatusBadRequest, nil)
+    get("/oldest?cache=accounts&n=x", http.StatusBadRequest, nil)
+
+    del := func(endpoint string, status int, body string) {
+
+        request, err := http.NewRequest(http.MethodDelete, server.URL+endpoint, nil)
+        require.NoError(t, err)
+        response, err := http.DefaultClient.Do(request)
+        require.NoError(t, err)
+        defer response.Body.Close()
+        require.Equal(t, status, response.StatusCode, endpoint)
+
+        if body != "" {
+            content, err := io.ReadAll(response.Body)
+            require.NoError(t, err)
+            require.Equal(t, body, string(content))
+        }
+
+    }
+
+    del("/key?cache=accounts&id=2", http.StatusOK, "1\n")
+    del("/key?cache=accounts&id=2", http.StatusOK, "0\n")
+    del("/key?cache=accounts&id=x", http.StatusBadRequest, "")
+    del("/key?cache=missing&id=1", http.StatusNotFound, "")
+    require.Equal(t, 1, accounts.Len())
+
+}
@@ -0,0 +1,36 @@
+package utils
+
+// moves the item of oldID to newID in a single critical section, so no reader sees the item
+// missing under both ids. The object, the refreshed time (and so the position in the heap), the
+// time to live, the tags and the access counters of the item are kept; for the rest (eviction policy,
+// namespaces, indexes, dependencies, audit) the item leaves the cache under oldID and enters it under newID.
+// returns false when oldID is not cached, when newID already is, or after OnShutdown
+func (t *HeapedCache[TId, TObj]) Rekey(oldID TId, newID TId) bool {
+
+	oldID = t.key(oldID)
+	newID = t.key(newID)
+
+	t.lock(OpPush)
+	defer t.unlock()
+
+	item := t.mapItems[oldID]
+
+	if item == nil || t.mapItems[newID] != nil || t.shutdown {
+		return false
+	}
+
+	t.removeItem(item)
+	t.record(OpRemove, oldID, OutcomeRemoved)
+	t.itemRemoved(item)
+
+	t.enforceQuota(newID)
+
+	item.Id = newID
+	t.mapItems[newID] = item
+	t.sliceItems.push(item)
+	t.record(OpPush, newID, OutcomeAdded)
+	t.itemAdded(item)
+
+	return true
+
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestRekey(t *testing.T) {
+
+    t.Log("validating TestRekey")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache[int, AccountTest](10, 1, clock)
+
+    for i := range 3 {
+        heapedCache.Push(i, NewAccountTest(i))
+        clock.Advance(time.Second)
+    }
+
+    obj := heapedCache.Get(0)
+    _, age, _ := heapedCache.GetWithAge(0)
+
+    require.True(t, heapedCache.Rekey(0, 10))
+    require.Nil(t, heapedCache.Get(0))
+    require.Same(t, obj, heapedCache.Get(10))
+
+    _, rekeyedAge, _ := heapedCache.GetWithAge(10)
+    require.Equal(t, age, rekeyedAge)
+
+    require.False(t, heapedCache.Rekey(0, 11))
+    require.False(t, heapedCache.Rekey(10, 1))
+    require.Equal(t, 3, heapedCache.Len())
+
+    // still the oldest item
+    require.Same(t, obj, heapedCache.Pop())
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
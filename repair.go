@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"errors"
+	"time"
+)
+
+// rebuilds the internal structures when the map and the heap diverged:
+// nil slots are dropped, items found in only one of them are put back in both
+// (the map wins when they disagree on an id), indexes are renumbered and the heap is
+// rebuilt, then items beyond the capacity are evicted.
+// returns the number of discrepancies found and err describing them (nil when consistent)
+func (t *HeapedCache[TId, TObj]) Repair() (fixed int, err error) {
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	return t.repair()
+
+}
+
+func (t *HeapedCache[TId, TObj]) repair() (int, error) {
+
+	discrepancies := t.discrepancies()
+
+	if len(discrepancies) == 0 {
+		return 0, nil
+	}
+
+	items := make(HeapedCacheItems[TId, TObj], 0, len(t.mapItems))
+	seen := make(map[*HeapedCacheItem[TId, TObj]]bool, len(t.mapItems))
+
+	for _, item := range t.mapItems {
+		items = append(items, item)
+		seen[item] = true
+	}
+
+	for _, item := range t.sliceItems {
+
+		if item == nil || seen[item] {
+			continue
+		}
+
+		if t.mapItems[item.Id] == nil {
+			t.mapItems[item.Id] = item
+			items = append(items, item)
+			seen[item] = true
+		}
+
+	}
+
+	for i, item := range items {
+		item.index = i
+	}
+
+	t.sliceItems = items
+	t.sliceItems.init()
+
+	if t.expiries != nil {
+		t.expiries.rebuild(items)
+	}
+
+	total := int64(0)
+
+	for _, item := range items {
+		total += item.cost
+	}
+
+	t.charge(total - t.cost.Load())
+
+	t.evictOverflow()
+
+	return len(discrepancies), errors.Join(discrepancies...)
+
+}
+
+// runs Repair every interval in the background, calling report when discrepancies
+// were found and fixed. Close stops it
+func WithConsistencyAudit[TId comparable, TObj any](interval time.Duration, report func(fixed int, err error)) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		if interval <= 0 {
+			return
+		}
+
+		t.every(taskAudit, interval, func() {
+
+			if fixed, err := t.Repair(); fixed > 0 && report != nil {
+				report(fixed, err)
+			}
+
+		})
+
+	}
+
+}
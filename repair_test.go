@@ -0,0 +1,98 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestRepairConsistent(t *testing.T) {
+
+    t.Log("validating TestRepairConsistent")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    for i := range 5 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    fixed, err := heapedCache.Repair()
+
+    require.Equal(t, 0, fixed)
+    require.NoError(t, err)
+
+}
+
+func TestRepairDiverged(t *testing.T) {
+
+    t.Log("validating TestRepairDiverged")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    for i := range 5 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    // corrupts the structures: an item missing from the map, one missing from the slice,
+    // a nil slot and a broken index
+    delete(heapedCache.mapItems, 1)
+    missing := heapedCache.mapItems[3]
+    heapedCache.sliceItems[missing.index] = nil
+    heapedCache.sliceItems[0].index = 42
+
+    require.Error(t, heapedCache.CheckInvariants())
+
+    fixed, err := heapedCache.Repair()
+
+    require.Greater(t, fixed, 0)
+    require.Error(t, err)
+    require.NoError(t, heapedCache.CheckInvariants())
+    require.Equal(t, 5, heapedCache.Len())
+
+    for i := range 5 {
+
+        require.Equal(t, i, heapedCache.Get(i).Id)
+
+    }
+
+    // pop order is back
+    for i := range 5 {
+
+        require.Equal(t, i, heapedCache.Pop().Id)
+
+    }
+
+}
+
+func TestConsistencyAudit(t *testing.T) {
+
+    t.Log("validating TestConsistencyAudit")
+
+    reported := make(chan int, 1)
+
+    heapedCache := NewHeapedCache(10, WithConsistencyAudit[int, AccountTest](5*time.Millisecond, func(fixed int, err error) {
+        reported <- fixed
+    }))
+    defer heapedCache.Close()
+
+    heapedCache.Push(1, NewAccountTest(1))
+
+    heapedCache.mu.Lock()
+    delete(heapedCache.mapItems, 1)
+    heapedCache.mu.Unlock()
+
+    select {
+    case fixed := <-reported:
+        require.Greater(t, fixed, 0)
+    case <-time.After(time.Second):
+        t.Fatal("audit did not report")
+    }
+
+    require.NoError(t, heapedCache.CheckInvariants())
+    require.NotNil(t, heapedCache.Get(1))
+
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// destination of the lines of StartReporter (*log.Logger implements it)
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+// interval of StartReporter when the one given is not positive
+const defaultReportInterval = time.Minute
+
+// counters of the previous report, so every line covers its own interval
+type reportCounters struct {
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// logs a compact line about the cache every interval, for services without metrics:
+//
+//	heapedcache len=9500/10000 hit_ratio=0.912 evictions=120 oldest_age=4m12s memory=1.8MiB
+//
+// The hit ratio and evictions cover the interval since the previous line; memory is a shallow
+// estimate (items, map and heap slots, and the objects themselves, without the memory they point to).
+// Under WithHotKeys, the most read id is appended (hot_key=...), masked by WithRedactor.
+// On a cache of NewDeterministicHeapedCache, the lines are logged by FakeClock.Advance.
+// An interval <= 0 falls back to defaultReportInterval (a minute).
+// returns the function stopping the reporter
+func (t *HeapedCache[TId, TObj]) StartReporter(interval time.Duration, logger Logger) (stop func()) {
+
+	if interval <= 0 {
+		interval = defaultReportInterval
+	}
+
+	if t.fakeClock != nil {
+
+		var last reportCounters
+
+		return t.fakeClock.every(interval, func() { logger.Printf("%s", t.report(&last)) }).stop
+
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go t.labeled(nil, "reporter", func() {
+
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var last reportCounters
+
+		for {
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				logger.Printf("%s", t.report(&last))
+			}
+
+		}
+
+	})
+
+	var once sync.Once
+
+	return func() {
+
+		once.Do(func() { close(done) })
+		<-stopped
+
+	}
+
+}
+
+// returns the line of the reporter, updating last with the current counters
+func (t *HeapedCache[TId, TObj]) report(last *reportCounters) string {
+
+	current := reportCounters{hits: t.hits.Load(), misses: t.misses.Load(), evictions: t.evictions.Load()}
+
+	t.lock(opOther)
+
+	length, maxRows := len(t.sliceItems), t.maxRows
+	oldest := time.Duration(0)
+
+	if length > 0 {
+		t.settle()
+		oldest = max(t.now().Sub(t.sliceItems[0].Refreshed), 0)
+	}
+
+	t.unlock()
+
+	hits, misses := current.hits-last.hits, current.misses-last.misses
+	ratio := 0.0
+
+	if hits+misses > 0 {
+		ratio = float64(hits) / float64(hits+misses)
+	}
+
+	line := fmt.Sprintf("heapedcache len=%d/%d hit_ratio=%.3f evictions=%d oldest_age=%s memory=%s",
+		length, maxRows, ratio, current.evictions-last.evictions, oldest.Round(time.Second), formatBytes(memoryEstimate[TId, TObj](length)))
+
+	if top := t.TopKeys(1); len(top) > 0 {
+		line += fmt.Sprintf(" hot_key=%v", t.redactedID(top[0].Id))
+	}
+
+	*last = current
+
+	return line
+
+}
+
+// shallow estimate of the memory used by length items: the item, its object,
+// its heap slot and its map entry (key, pointer and about a byte of overhead per slot)
+func memoryEstimate[TId comparable, TObj any](length int) uint64 {
+
+	var item HeapedCacheItem[TId, TObj]
+	var obj TObj
+	var key any
+
+	perItem := unsafe.Sizeof(item) + unsafe.Sizeof(obj) + unsafe.Sizeof(&item)*2 + unsafe.Sizeof(key) + 1
+
+	return uint64(length) * uint64(perItem)
+
+}
+
+// formats a number of bytes with a binary unit (B, KiB, MiB, ...)
+func formatBytes(n uint64) string {
+
+	const unit = 1024
+
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+
+	div, exp := uint64(unit), 0
+
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+
+}
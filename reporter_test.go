@@ -0,0 +1,115 @@
+package utils
+
+import (
+    "fmt"
+    "github.com/stretchr/testify/require"
+    "sync"
+    "testing"
+    "time"
+)
+
+type loggerTest struct {
+    mu    sync.Mutex
+    lines []string
+}
+
+func (l *loggerTest) Printf(format string, v ...any) {
+
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    l.lines = append(l.lines, fmt.Sprintf(format, v...))
+
+}
+
+func TestReporter(t *testing.T) {
+
+    t.Log("validating TestReporter")
+
+    clock := NewFakeClock(time.Unix(0, 0))
+    heapedCache := NewDeterministicHeapedCache[int, AccountTest](2, 1, clock)
+
+    heapedCache.Push(1, NewAccountTest(1))
+    clock.Advance(time.Minute)
+    heapedCache.Push(2, NewAccountTest(2))
+    heapedCache.Push(3, NewAccountTest(3))
+
+    heapedCache.Get(2)
+    heapedCache.Get(3)
+    heapedCache.Get(3)
+    heapedCache.Get(1)
+
+    var last reportCounters
+
+    require.Regexp(t, `^heapedcache len=2/2 hit_ratio=0\.750 evictions=1 oldest_age=0s memory=\S+$`, heapedCache.report(&last))
+
+    // the next line only covers what happened since
+    clock.Advance(time.Minute)
+    heapedCache.Get(4)
+
+    require.Regexp(t, `^heapedcache len=2/2 hit_ratio=0\.000 evictions=0 oldest_age=1m0s `, heapedCache.report(&last))
+
+    require.Equal(t, Stats{Len: 2, MaxRows: 2, Cost: 2, Hits: 3, Misses: 2, Evictions: 1}, heapedCache.Stats())
+
+    // driven by the virtual clock: a line per interval
+    logger := &loggerTest{}
+    stop := heapedCache.StartReporter(time.Minute, logger)
+
+    clock.Advance(30 * time.Second)
+    require.Empty(t, logger.lines)
+
+    clock.Advance(30 * time.Second)
+    require.Len(t, logger.lines, 1)
+    require.Regexp(t, `^heapedcache len=2/2 hit_ratio=0\.600 evictions=1 oldest_age=2m0s `, logger.lines[0])
+
+    stop()
+    stop()
+
+    clock.Advance(time.Hour)
+    require.Len(t, logger.lines, 1)
+
+    // on a regular cache, from a goroutine
+    logger = &loggerTest{}
+    stop = NewHeapedCache[int, AccountTest](2).StartReporter(time.Millisecond, logger)
+
+    require.Eventually(t, func() bool {
+        logger.mu.Lock()
+        defer logger.mu.Unlock()
+        return len(logger.lines) > 0
+    }, time.Second, time.Millisecond)
+
+    stop()
+
+    require.Equal(t, "1.5KiB", formatBytes(1536))
+    require.Equal(t, "2.0MiB", formatBytes(2<<20))
+
+}
+
+func TestReporterInterval(t *testing.T) {
+
+    t.Log("validating TestReporterInterval")
+
+    clock := NewFakeClock(time.Unix(0, 0))
+    heapedCache := NewDeterministicHeapedCache[int, AccountTest](2, 1, clock)
+
+    // a non-positive interval logs every minute instead of panicking
+    for _, interval := range []time.Duration{0, -time.Second} {
+
+        logger := &loggerTest{}
+        stop := heapedCache.StartReporter(interval, logger)
+
+        clock.Advance(defaultReportInterval - time.Second)
+        require.Empty(t, logger.lines)
+
+        clock.Advance(time.Second)
+        require.Len(t, logger.lines, 1)
+
+        stop()
+
+    }
+
+    // nor on a regular cache
+    stop := NewHeapedCache[int, AccountTest](2).StartReporter(0, &loggerTest{})
+    stop()
+
+}
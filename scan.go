@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+)
+
+// copies the item pointers of the heap (a plain copy of the slice, the only work
+// done under the lock for the whole scan)
+func (t *HeapedCache[TId, TObj]) pointers() []*HeapedCacheItem[TId, TObj] {
+
+	t.lock(OpScan)
+	defer t.unlock()
+
+	return slices.Clone(t.sliceItems)
+
+}
+
+// keeps the items of chunk that are still cached, reusing its array
+// must be called with the lock held
+func (t *HeapedCache[TId, TObj]) live(chunk []*HeapedCacheItem[TId, TObj]) []*HeapedCacheItem[TId, TObj] {
+
+	return slices.DeleteFunc(chunk, func(item *HeapedCacheItem[TId, TObj]) bool {
+		return t.mapItems[item.Id] != item
+	})
+
+}
+
+// calls fn with the cached items in chunks of bulkChunk, holding the lock for one chunk at a time,
+// so other goroutines get to use the cache between chunks and the lock hold stays bounded.
+// The scan works on a copy of the item pointers taken at its start: items removed meanwhile
+// are skipped and items added meanwhile are not visited, so every item cached during
+// the whole scan is visited exactly once. Stops when fn returns false or with the error of ctx
+func (t *HeapedCache[TId, TObj]) scan(ctx context.Context, fn func(items []*HeapedCacheItem[TId, TObj]) bool) error {
+
+	items := t.pointers()
+
+	for start := 0; start < len(items); start += bulkChunk {
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		t.lock(OpScan)
+		more := fn(t.live(items[start:min(start+bulkChunk, len(items))]))
+		t.unlock()
+
+		if !more {
+			return nil
+		}
+
+	}
+
+	return nil
+
+}
+
+// calls fn with every cached item until it returns false, without copying the items
+// and without holding the lock for more than a chunk of them (see Range for a copy).
+// fn is called with the lock held, so it must not use the cache.
+// Items added during the iteration are not visited; returns the error of ctx when it is done
+func (t *HeapedCache[TId, TObj]) ForEach(ctx context.Context, fn func(id TId, obj *TObj) bool) error {
+
+	return t.scan(ctx, func(items []*HeapedCacheItem[TId, TObj]) bool {
+
+		for _, item := range items {
+
+			if !fn(item.Id, item.obj) {
+				return false
+			}
+
+		}
+
+		return true
+
+	})
+
+}
+
+// counts the cached items by age (time since they were refreshed): result[i] is the number
+// of items no older than bounds[i] (and older than bounds[i-1]), and the last element,
+// result[len(bounds)], the number of items older than every bound. bounds must be ascending.
+// Counted in chunks like ForEach; returns the error of ctx when it is done
+func (t *HeapedCache[TId, TObj]) AgeHistogram(ctx context.Context, bounds []time.Duration) ([]int, error) {
+
+	result := make([]int, len(bounds)+1)
+	now := t.now()
+
+	err := t.scan(ctx, func(items []*HeapedCacheItem[TId, TObj]) bool {
+
+		for _, item := range items {
+
+			age := now.Sub(item.Refreshed)
+			result[sort.Search(len(bounds), func(i int) bool { return age <= bounds[i] })]++
+
+		}
+
+		return true
+
+	})
+
+	return result, err
+
+}
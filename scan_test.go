@@ -0,0 +1,80 @@
+package utils
+
+import (
+    "context"
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestForEach(t *testing.T) {
+
+    t.Log("validating TestForEach")
+
+    heapedCache := NewHeapedCache[int, AccountTest](5000)
+
+    for i := range 3000 {
+        heapedCache.Push(i, NewAccountTest(i))
+    }
+
+    seen := make(map[int]bool)
+
+    require.NoError(t, heapedCache.ForEach(context.Background(), func(id int, obj *AccountTest) bool {
+        seen[id] = true
+        return true
+    }))
+    require.Len(t, seen, 3000)
+
+    visited := 0
+
+    require.NoError(t, heapedCache.ForEach(context.Background(), func(id int, obj *AccountTest) bool {
+        visited++
+        return visited < 10
+    }))
+    require.Equal(t, 10, visited)
+
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+
+    require.ErrorIs(t, heapedCache.ForEach(ctx, func(id int, obj *AccountTest) bool { return true }), context.Canceled)
+
+}
+
+func TestAgeHistogram(t *testing.T) {
+
+    t.Log("validating TestAgeHistogram")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache[int, AccountTest](100, 1, clock)
+
+    for i := range 10 {
+        heapedCache.Push(i, NewAccountTest(i))
+        clock.Advance(time.Minute)
+    }
+
+    histogram, err := heapedCache.AgeHistogram(context.Background(), []time.Duration{time.Minute, 5 * time.Minute})
+    require.NoError(t, err)
+    require.Equal(t, []int{1, 4, 5}, histogram)
+
+}
+
+func TestMaxLockHold(t *testing.T) {
+
+    t.Log("validating TestMaxLockHold")
+
+    heapedCache := NewHeapedCache(5000, WithContentionProfiling[int, AccountTest]())
+
+    for i := range 3000 {
+        heapedCache.Push(i, NewAccountTest(i))
+    }
+
+    require.NoError(t, heapedCache.ForEach(context.Background(), func(id int, obj *AccountTest) bool {
+        time.Sleep(time.Microsecond)
+        return true
+    }))
+
+    stats := heapedCache.Stats()
+    require.Greater(t, stats.MaxLockHold, time.Duration(0))
+    require.Equal(t, uint64(4), stats.Contention[OpScan].Acquisitions)
+
+}
@@ -0,0 +1,93 @@
+package utils
+
+// hit ratio a shadow configuration would have achieved (see WithShadow)
+type ShadowStats struct {
+	MaxRows  int
+	Hits     uint64
+	Misses   uint64
+	HitRatio float64 // 0 before the first read
+}
+
+// shadow configuration observing the operations of the cache, keeping only keys
+type shadow[TId comparable] struct {
+	name  string
+	cache *HeapedCache[TId, struct{}]
+}
+
+// runs a shadow configuration, maxRows items evicted by policy (nil for the default, oldest first),
+// next to the cache: it sees the same operations but keeps only keys, and reports with Shadows
+// the hit ratio it would have achieved, so a policy or a size can be evaluated on live traffic
+// without risk. Every read missing in the shadow counts as a load, adding the key to it.
+// Several shadows can run at once, under different names; each costs a key, an item and
+// the bookkeeping of its policy per shadowed key, updated under the lock of the cache
+func WithShadow[TId comparable, TObj any](name string, maxRows int, policy EvictionPolicy[TId]) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		t.shadows = append(t.shadows, &shadow[TId]{
+			name:  name,
+			cache: NewHeapedCache(maxRows, WithEvictionPolicy[TId, struct{}](policy)),
+		})
+
+	}
+
+}
+
+// returns the hit ratio achieved by every shadow configuration, by name
+// returns nil when the cache has no shadow
+func (t *HeapedCache[TId, TObj]) Shadows() map[string]ShadowStats {
+
+	if len(t.shadows) == 0 {
+		return nil
+	}
+
+	result := make(map[string]ShadowStats, len(t.shadows))
+
+	for _, s := range t.shadows {
+
+		stats := ShadowStats{MaxRows: s.cache.maxRows, Hits: s.cache.hits.Load(), Misses: s.cache.misses.Load()}
+
+		if reads := stats.Hits + stats.Misses; reads > 0 {
+			stats.HitRatio = float64(stats.Hits) / float64(reads)
+		}
+
+		result[s.name] = stats
+
+	}
+
+	return result
+
+}
+
+// the shadows are only used under the lock of the cache, so they are used without their own
+
+// replays a read of the cache: a hit, or a miss loading the key
+func (s *shadow[TId]) read(id TId) {
+
+	if item := s.cache.mapItems[id]; item != nil {
+		s.cache.itemRead(item)
+		return
+	}
+
+	s.cache.itemMissed(id)
+	s.cache.push(id, &struct{}{})
+
+}
+
+// replays an operation recorded by the cache (see record)
+func (s *shadow[TId]) replay(id TId, outcome string) {
+
+	switch outcome {
+
+	case OutcomeAdded, OutcomeUpdated, OutcomePatched, OutcomeReturned:
+		s.cache.push(id, &struct{}{})
+
+	case OutcomePopped, OutcomeLeased, OutcomeRemoved, OutcomeInvalidated, OutcomeExpired:
+		if item := s.cache.mapItems[id]; item != nil {
+			s.cache.removeItem(item)
+			s.cache.itemRemoved(item)
+		}
+
+	}
+
+}
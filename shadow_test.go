@@ -0,0 +1,42 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+)
+
+func TestShadow(t *testing.T) {
+
+    t.Log("validating TestShadow")
+
+    heapedCache := NewHeapedCache(2,
+        WithShadow[int, AccountTest]("oldest", 2, nil),
+        WithShadow[int, AccountTest]("lru", 2, NewLRUPolicy[int]()),
+        WithShadow[int, AccountTest]("bigger", 3, nil),
+    )
+
+    load := func(id int) *AccountTest { return NewAccountTest(id) }
+
+    // 1 is read over and over, between reads of other keys: LRU keeps it, oldest first does not
+    for _, id := range []int{1, 2, 1, 3, 1, 4, 1, 5, 1} {
+
+        heapedCache.GetOrAdd(id, load)
+
+    }
+
+    shadows := heapedCache.Shadows()
+
+    require.Equal(t, ShadowStats{MaxRows: 2, Hits: 4, Misses: 5, HitRatio: 4.0 / 9.0}, shadows["lru"])
+    require.Equal(t, heapedCache.Stats().Hits, shadows["oldest"].Hits)
+    require.Equal(t, heapedCache.Stats().Misses, shadows["oldest"].Misses)
+    require.Greater(t, shadows["bigger"].Hits, shadows["oldest"].Hits)
+
+    // removals are replayed, evictions are not
+    heapedCache.Remove(1)
+    heapedCache.Get(1)
+
+    require.Equal(t, uint64(4), heapedCache.Shadows()["lru"].Hits)
+
+    require.Nil(t, NewHeapedCache[int, AccountTest](2).Shadows())
+
+}
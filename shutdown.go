@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+)
+
+// returned by TryPush and TryGetOrAdd after OnShutdown
+var ErrShutdown = errors.New("heapedcache: cache is shut down")
+
+// result of OnShutdown
+type ShutdownReport struct {
+	Persisted int // items written to the shutdown snapshot
+	HandedOff int // items streamed to the peer of WithShutdownHandoff
+}
+
+// makes OnShutdown write a snapshot of the cache to path (see WriteSnapshot), loaded back on the
+// next start with LoadSnapshot(ctx, NewDirStore(dir), name), dir and name being the directory and
+// the base name of path, which keeps the refreshed times of the items and migrates older formats
+func WithShutdownSnapshot[TId comparable, TObj any](path string) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		t.shutdownStore = NewDirStore(filepath.Dir(path))
+		t.shutdownName = filepath.Base(path)
+
+	}
+
+}
+
+// makes OnShutdown write a snapshot of the cache to store under name (see SaveSnapshot),
+// e.g. to a bucket when the pod has no persistent disk, loaded back with LoadSnapshot
+func WithShutdownStore[TId comparable, TObj any](store ObjectStore, name string) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		t.shutdownStore = store
+		t.shutdownName = name
+
+	}
+
+}
+
+// tears the cache down in order, meant to be called from the signal handler of a service
+// with a deadline: intake is stopped first (Push and GetOrAdd no longer cache anything,
+// TryPush and TryGetOrAdd return ErrShutdown; reads keep working), then the background
+// tasks, then the cache is streamed to the peer set by WithShutdownHandoff, and then the snapshot
+// set by WithShutdownSnapshot or WithShutdownStore is written (or, after Recover, a Checkpoint is made).
+// A failed handoff does not prevent the snapshot: the errors of both are joined.
+// Items leased and not yet acknowledged are put back first, so they are persisted too.
+// When ctx is done before the snapshot is complete, its error is returned and
+// the previous snapshot, if any, is left untouched
+func (t *HeapedCache[TId, TObj]) OnShutdown(ctx context.Context) (ShutdownReport, error) {
+
+	var report ShutdownReport
+
+	t.lock(opOther)
+	t.requeueLeases()
+	t.shutdown = true
+	t.unlock()
+
+	t.Close()
+
+	var errs []error
+
+	if t.handoffURL != "" {
+
+		sent, _, err := t.handoff(ctx, t.handoffClient, t.handoffURL)
+
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			report.HandedOff = sent
+		}
+
+	}
+
+	if t.wal != nil {
+
+		written, err := t.checkpoint(ctx)
+		report.Persisted = written
+
+		return report, errors.Join(append(errs, err)...)
+
+	}
+
+	if t.shutdownStore == nil {
+		return report, errors.Join(errs...)
+	}
+
+	written, err := t.saveSnapshot(ctx, t.shutdownStore, t.shutdownName)
+
+	if err != nil {
+		return report, errors.Join(append(errs, err)...)
+	}
+
+	report.Persisted = written
+
+	return report, errors.Join(errs...)
+
+}
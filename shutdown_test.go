@@ -0,0 +1,113 @@
+package utils
+
+import (
+    "context"
+    "github.com/stretchr/testify/require"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+func TestOnShutdown(t *testing.T) {
+
+    t.Log("validating TestOnShutdown")
+
+    path := filepath.Join(t.TempDir(), "accounts.ndjson")
+    heapedCache := NewHeapedCache(10, WithShutdownSnapshot[int, AccountTest](path))
+
+    for i := range 5 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    report, err := heapedCache.OnShutdown(context.Background())
+    require.NoError(t, err)
+    require.Equal(t, 5, report.Persisted)
+
+    // intake is stopped, reads keep working
+    _, err = heapedCache.TryPush(5, NewAccountTest(5))
+    require.ErrorIs(t, err, ErrShutdown)
+
+    obj, err := heapedCache.TryGetOrAdd(6, NewAccountTest)
+    require.ErrorIs(t, err, ErrShutdown)
+    require.Equal(t, 6, obj.Id)
+
+    require.Nil(t, heapedCache.Push(0, NewAccountTest(0)))
+    require.Equal(t, 5, heapedCache.Len())
+    require.Equal(t, 1, heapedCache.Get(1).Id)
+
+    // loaded back as documented, with the refreshed times of the items
+    restored := NewHeapedCache[int, AccountTest](10)
+
+    loaded, err := restored.LoadSnapshot(context.Background(), NewDirStore(filepath.Dir(path)), filepath.Base(path))
+    require.NoError(t, err)
+    require.Equal(t, RecoveryReport{Restored: 5}, loaded)
+
+    before, after := heapedCache.Snapshot(), restored.Snapshot()
+    require.Len(t, after, len(before))
+
+    for i := range before {
+        require.Equal(t, before[i].Id, after[i].Id)
+        require.True(t, before[i].Refreshed.Equal(after[i].Refreshed))
+    }
+
+}
+
+func TestOnShutdownDeadline(t *testing.T) {
+
+    t.Log("validating TestOnShutdownDeadline")
+
+    dir := t.TempDir()
+    path := filepath.Join(dir, "accounts.ndjson")
+    heapedCache := NewHeapedCache(10, WithShutdownSnapshot[int, AccountTest](path))
+
+    heapedCache.Push(1, NewAccountTest(1))
+
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+
+    report, err := heapedCache.OnShutdown(ctx)
+    require.ErrorIs(t, err, context.Canceled)
+    require.Equal(t, 0, report.Persisted)
+
+    // no partial snapshot is left behind
+    entries, err := os.ReadDir(dir)
+    require.NoError(t, err)
+    require.Empty(t, entries)
+
+}
+
+func TestOnShutdownLeased(t *testing.T) {
+
+    t.Log("validating TestOnShutdownLeased")
+
+    ctx := context.Background()
+    store := &memoryStore{}
+    heapedCache := NewHeapedCache(10, WithShutdownStore[int, AccountTest](store, "accounts.ndjson"))
+
+    for i := range 3 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    leased := heapedCache.Lease(2, time.Hour)
+    require.Len(t, leased, 2)
+
+    report, err := heapedCache.OnShutdown(ctx)
+    require.NoError(t, err)
+    require.Equal(t, 3, report.Persisted)
+    require.Equal(t, 0, heapedCache.Leased())
+
+    // acknowledged too late, the item is handed out again after the restart
+    require.False(t, heapedCache.Ack(leased[0].LeaseID))
+
+    restored := NewHeapedCache[int, AccountTest](10)
+    _, err = restored.LoadSnapshot(ctx, store, "accounts.ndjson")
+    require.NoError(t, err)
+    require.Equal(t, 3, restored.Len())
+    require.Equal(t, 0, restored.Pop().Id)
+
+}
@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// key that differs between two snapshots
+type KeyDiff[TId any] struct {
+	Id     TId
+	Before time.Time // refreshed time in the first snapshot (zero when the key was added)
+	After  time.Time // refreshed time in the second snapshot (zero when the key was removed)
+}
+
+// returns how much later the key was refreshed in the second snapshot
+// returns 0 when the key is missing from one of them
+func (d KeyDiff[TId]) AgeDelta() time.Duration {
+
+	if d.Before.IsZero() || d.After.IsZero() {
+		return 0
+	}
+
+	return d.After.Sub(d.Before)
+
+}
+
+// result of DiffSnapshots
+type SnapshotDiff[TId any] struct {
+	Added   []KeyDiff[TId]
+	Removed []KeyDiff[TId]
+	Changed []KeyDiff[TId]
+}
+
+// returns a copy of every cached item, oldest first
+func (t *HeapedCache[TId, TObj]) Snapshot() []Entry[TId, TObj] {
+
+	items := t.snapshot()
+	result := make([]Entry[TId, TObj], len(items))
+
+	for i, item := range items {
+		result[i] = Entry[TId, TObj]{Id: item.Id, Obj: item.obj, Refreshed: item.Refreshed}
+	}
+
+	sortEntries(result)
+
+	return result
+
+}
+
+// writes every cached item to w in the snapshot format (ExportNDJSON without a projection),
+// leaving out the items of WithPersistFilter
+func (t *HeapedCache[TId, TObj]) WriteSnapshot(w io.Writer) error {
+
+	_, err := t.persistItems(context.Background(), w, t.snapshot())
+	return err
+
+}
+
+// reads a snapshot written by WriteSnapshot (or ExportNDJSON without a projection), oldest first.
+// options are the ones of the cache that reads it, so the objects of an older format version are
+// converted (WithFormatVersion, WithMigrator) as Recover and LoadSnapshot do; other options are ignored.
+// Unlike them, it fails on a line that cannot be decoded or migrated instead of skipping it
+func ReadSnapshot[TId comparable, TObj any](r io.Reader, options ...Option[TId, TObj]) ([]Entry[TId, TObj], error) {
+
+	// only holds the settings of the decoding
+	t := &HeapedCache[TId, TObj]{}
+
+	for _, option := range options {
+		option(t)
+	}
+
+	var result []Entry[TId, TObj]
+
+	reader := bufio.NewReader(r)
+
+	for number := 1; ; number++ {
+
+		line, err := reader.ReadBytes('\n')
+
+		if len(bytes.TrimSpace(line)) > 0 {
+
+			var report RecoveryReport
+
+			record, ok := t.decodeLine(line, false, &report)
+
+			if !ok && report.Incompatible > 0 {
+				return nil, fmt.Errorf("snapshot item %d: format version not supported (see WithMigrator)", number)
+			} else if !ok {
+				return nil, fmt.Errorf("snapshot item %d: invalid line", number)
+			}
+
+			result = append(result, Entry[TId, TObj]{Id: record.Id, Obj: record.Obj, Refreshed: record.Refreshed})
+
+		}
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+	}
+
+	sortEntries(result)
+
+	return result, nil
+
+}
+
+// compares two snapshots of a cache (a taken before b).
+// A key present in both is changed when its refreshed time differs or when equal
+// says its objects differ (with a nil equal, only the refreshed times are compared).
+// Added and changed keys come in the order of b, removed keys in the order of a
+func DiffSnapshots[TId comparable, TObj any](a []Entry[TId, TObj], b []Entry[TId, TObj], equal func(x *TObj, y *TObj) bool) SnapshotDiff[TId] {
+
+	var result SnapshotDiff[TId]
+
+	before := make(map[TId]Entry[TId, TObj], len(a))
+
+	for _, entry := range a {
+		before[entry.Id] = entry
+	}
+
+	for _, entry := range b {
+
+		old, ok := before[entry.Id]
+
+		if !ok {
+			result.Added = append(result.Added, KeyDiff[TId]{Id: entry.Id, After: entry.Refreshed})
+			continue
+		}
+
+		delete(before, entry.Id)
+
+		if !old.Refreshed.Equal(entry.Refreshed) || (equal != nil && !equal(old.Obj, entry.Obj)) {
+			result.Changed = append(result.Changed, KeyDiff[TId]{Id: entry.Id, Before: old.Refreshed, After: entry.Refreshed})
+		}
+
+	}
+
+	for _, entry := range a {
+
+		if _, ok := before[entry.Id]; ok {
+			result.Removed = append(result.Removed, KeyDiff[TId]{Id: entry.Id, Before: entry.Refreshed})
+		}
+
+	}
+
+	return result
+
+}
+
+// sorts entries oldest first
+func sortEntries[TId any, TObj any](entries []Entry[TId, TObj]) {
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Refreshed.Before(entries[j].Refreshed) })
+
+}
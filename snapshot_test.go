@@ -0,0 +1,53 @@
+package utils
+
+import (
+    "bytes"
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+
+    t.Log("validating TestDiffSnapshots")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache[int, AccountTest](4, 1, clock)
+
+    for i := range 4 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+        clock.Advance(time.Second)
+
+    }
+
+    var buffer bytes.Buffer
+    require.NoError(t, heapedCache.ExportNDJSON(&buffer, nil))
+
+    before, err := ReadSnapshot[int, AccountTest](&buffer)
+    require.NoError(t, err)
+    require.Equal(t, before, heapedCache.Snapshot())
+
+    // 0 is evicted by 4, 1 is refreshed, 2 is replaced at the same time
+    heapedCache.Push(4, NewAccountTest(4))
+    heapedCache.Push(1, NewAccountTest(1))
+    heapedCache.mu.Lock()
+    heapedCache.mapItems[2].obj = &AccountTest{Id: 2, Name: "OTHER"}
+    heapedCache.mu.Unlock()
+
+    after := heapedCache.Snapshot()
+
+    diff := DiffSnapshots(before, after, func(x *AccountTest, y *AccountTest) bool { return x.Name == y.Name })
+
+    require.Equal(t, []KeyDiff[int]{{Id: 4, After: clock.Now()}}, diff.Added)
+    require.Equal(t, []KeyDiff[int]{{Id: 0, Before: before[0].Refreshed}}, diff.Removed)
+    require.Len(t, diff.Changed, 2)
+    require.Equal(t, 2, diff.Changed[0].Id)
+    require.Equal(t, time.Duration(0), diff.Changed[0].AgeDelta())
+    require.Equal(t, 1, diff.Changed[1].Id)
+    require.Equal(t, 3*time.Second, diff.Changed[1].AgeDelta())
+
+    // without equal, only refreshed times matter
+    require.Len(t, DiffSnapshots(before, after, nil).Changed, 1)
+
+}
@@ -0,0 +1,115 @@
+package utils
+
+import "time"
+
+// item removed by SoftRemove, which can be restored until its deadline
+type softRemoval[TId any, TObj any] struct {
+	item     *HeapedCacheItem[TId, TObj]
+	deadline time.Time
+}
+
+// removes the item of a given id as Remove does (Get no longer finds it), but keeps it aside
+// for window, so Restore can undo the removal, e.g. an invalidation triggered by a false positive.
+// Once the window is over the removal is final. Removing the id again with SoftRemove restarts its window.
+// Expired removals are forgotten by the next operation taking the cache lock (reads included),
+// so the removed items are not kept in memory past their window
+// returns false when the id is not cached
+func (t *HeapedCache[TId, TObj]) SoftRemove(id TId, window time.Duration) bool {
+
+	id = t.key(id)
+
+	t.lock(OpRemove)
+	defer t.unlock()
+
+	item := t.mapItems[id]
+
+	if !t.remove(id) {
+		return false
+	}
+
+	if t.softRemoved == nil {
+		t.softRemoved = make(map[TId]*softRemoval[TId, TObj])
+	}
+
+	deadline := t.now().Add(window)
+	t.softRemoved[id] = &softRemoval[TId, TObj]{item: item, deadline: deadline}
+
+	if len(t.softRemoved) == 1 || deadline.Before(t.softRemovedDue) {
+		t.softRemovedDue = deadline
+	}
+
+	return true
+
+}
+
+// puts an item removed by SoftRemove back in the cache, in its original position (same refreshed time)
+// returns false when the window is over, when the id was not soft removed, or when it was pushed
+// again meanwhile (the newer item wins, and the removed one is forgotten)
+func (t *HeapedCache[TId, TObj]) Restore(id TId) bool {
+
+	id = t.key(id)
+
+	t.lock(OpPush)
+	defer t.unlock()
+
+	removal := t.softRemoved[id]
+
+	if removal == nil {
+		return false
+	}
+
+	delete(t.softRemoved, id)
+
+	if t.mapItems[id] != nil || t.shutdown {
+		return false
+	}
+
+	t.restore(removal.item)
+
+	return true
+
+}
+
+// returns the number of items removed by SoftRemove that can still be restored
+func (t *HeapedCache[TId, TObj]) SoftRemoved() int {
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	return len(t.softRemoved)
+
+}
+
+// forgets the soft removed items whose window is over, going through them only once the earliest
+// deadline is reached
+// must be called under the lock (lock calls it)
+func (t *HeapedCache[TId, TObj]) expireSoftRemovals() {
+
+	if len(t.softRemoved) == 0 {
+		return
+	}
+
+	now := t.now()
+
+	if now.Before(t.softRemovedDue) {
+		return
+	}
+
+	var due time.Time
+
+	for id, removal := range t.softRemoved {
+
+		if !now.Before(removal.deadline) {
+			delete(t.softRemoved, id)
+			continue
+		}
+
+		if due.IsZero() || removal.deadline.Before(due) {
+			due = removal.deadline
+		}
+
+	}
+
+	t.softRemovedDue = due
+
+}
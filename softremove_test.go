@@ -0,0 +1,85 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestSoftRemove(t *testing.T) {
+
+    t.Log("validating TestSoftRemove")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache[int, AccountTest](10, 1, clock)
+
+    for i := range 3 {
+        heapedCache.Push(i, NewAccountTest(i))
+        clock.Advance(time.Second)
+    }
+
+    require.True(t, heapedCache.SoftRemove(0, time.Minute))
+    require.False(t, heapedCache.SoftRemove(0, time.Minute))
+    require.Nil(t, heapedCache.Get(0))
+    require.Equal(t, 2, heapedCache.Len())
+    require.Equal(t, 1, heapedCache.SoftRemoved())
+
+    // restored in its original position: the oldest again
+    require.True(t, heapedCache.Restore(0))
+    require.False(t, heapedCache.Restore(0))
+    require.Equal(t, 0, heapedCache.Pop().Id)
+    require.NoError(t, heapedCache.CheckInvariants())
+
+    // the removal is final once the window is over
+    require.True(t, heapedCache.SoftRemove(1, time.Minute))
+    clock.Advance(time.Minute)
+
+    require.Equal(t, 0, heapedCache.SoftRemoved())
+    require.False(t, heapedCache.Restore(1))
+    require.Nil(t, heapedCache.Get(1))
+
+    // an id pushed again keeps the newer item
+    require.True(t, heapedCache.SoftRemove(2, time.Minute))
+
+    newer := NewAccountTest(2)
+    heapedCache.Push(2, newer)
+
+    require.False(t, heapedCache.Restore(2))
+    require.Same(t, newer, heapedCache.Get(2))
+    require.Equal(t, 0, heapedCache.SoftRemoved())
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
+
+func TestSoftRemoveReleased(t *testing.T) {
+
+    t.Log("validating TestSoftRemoveReleased")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache[int, AccountTest](10, 1, clock)
+
+    for i := range 3 {
+        heapedCache.Push(i, NewAccountTest(i))
+    }
+
+    require.True(t, heapedCache.SoftRemove(0, time.Minute))
+    require.True(t, heapedCache.SoftRemove(1, time.Hour))
+    require.True(t, heapedCache.SoftRemove(2, 2*time.Minute))
+
+    // any operation taking the lock lets go of the removals whose window is over
+    clock.Advance(time.Minute)
+    heapedCache.Get(0)
+
+    require.Len(t, heapedCache.softRemoved, 2)
+    require.NotContains(t, heapedCache.softRemoved, 0)
+
+    clock.Advance(time.Minute)
+    heapedCache.Len()
+
+    require.Len(t, heapedCache.softRemoved, 1)
+    require.True(t, heapedCache.Restore(1))
+
+    heapedCache.Len()
+    require.Empty(t, heapedCache.softRemoved)
+
+}
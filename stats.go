@@ -0,0 +1,262 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// point in time view of the cache
+type Stats struct {
+	Len        int
+	MaxRows    int
+	Cost       int64                     // total cost of the items (see WithCost), Len unless given
+	Hits       uint64                    // reads (Get, GetOrAdd and similar) that found the item
+	Misses     uint64                    // reads that did not
+	Evictions  uint64                    // items evicted to make room (quotas, budget and reaper included)
+	Expired    uint64                    // items removed when their time to live was over (see WithTTL)
+	GhostHits  uint64                    // misses of recently evicted keys, zero unless WithGhost
+	Latency    map[string]Histogram      // by operation (OpGet, OpPush, OpLoad, OpPop), nil unless WithLatencyHistograms
+	Contention map[string]LockWait       // by operation (OpGet, OpGetOrAdd, OpPush, OpPop, OpRemove), nil unless WithContentionProfiling
+	Namespaces map[string]NamespaceStats // by prefix, nil when no namespace was registered
+
+	MaxLockHold time.Duration // longest time the cache lock was held, zero unless WithContentionProfiling
+	LockStalls  uint64        // critical sections detected by WithLockWatchdog
+	Panics      uint64        // panics of user callbacks (loaders, OnEvict, eviction filter, Patch) contained
+}
+
+// returns the current statistics of the cache
+func (t *HeapedCache[TId, TObj]) Stats() Stats {
+
+	t.lock(opOther)
+
+	result := Stats{
+		Len:       len(t.mapItems),
+		MaxRows:   t.maxRows,
+		Cost:      t.cost.Load(),
+		Hits:      t.hits.Load(),
+		Misses:    t.misses.Load(),
+		Evictions: t.evictions.Load(),
+		Expired:   t.expirations.Load(),
+		GhostHits: t.ghostHits.Load(),
+		Panics:    t.panics.Load(),
+	}
+
+	if len(t.namespaces) > 0 {
+
+		result.Namespaces = make(map[string]NamespaceStats, len(t.namespaces))
+
+		for prefix, ns := range t.namespaces {
+			result.Namespaces[prefix] = ns.stats()
+		}
+
+	}
+
+	t.unlock()
+
+	if t.latencies != nil {
+		result.Latency = t.latencies.snapshot()
+	}
+
+	if t.watchdog != nil {
+		result.LockStalls = t.watchdog.stalls.Load()
+	}
+
+	if t.contention != nil {
+		result.Contention = t.contention.snapshot()
+		result.MaxLockHold = time.Duration(t.contention.maxHold.Load())
+	}
+
+	return result
+
+}
+
+// upper bounds of the buckets of the latency histograms written by WritePrometheus, from 1µs to 10s
+// in 1-2.5-5 steps: the same on every scrape, whatever was observed, so rates and quantiles can be
+// computed across scrapes and instances. A duration counts in the first bound at or above the upper
+// bound of its bucket in the histogram (at most 25% above the duration itself)
+var prometheusBuckets = []time.Duration{
+	time.Microsecond, 2500 * time.Nanosecond, 5 * time.Microsecond,
+	10 * time.Microsecond, 25 * time.Microsecond, 50 * time.Microsecond,
+	100 * time.Microsecond, 250 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2500 * time.Microsecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+// writes the statistics in the Prometheus text exposition format,
+// every metric name starting with namespace (e.g. "myapp_cache").
+// Latency histograms always have the same buckets, 1µs to 10s in 1-2.5-5 steps plus +Inf
+func (s Stats) WritePrometheus(w io.Writer, namespace string) error {
+
+	var families metricFamilies
+
+	s.collect(&families, namespace, "")
+
+	return families.write(w)
+
+}
+
+// adds the samples of the statistics to families, every sample carrying labels (may be empty)
+func (s Stats) collect(families *metricFamilies, namespace string, labels string) {
+
+	families.add(namespace+"_items", "Number of cached items.", "gauge", "", labels, strconv.Itoa(s.Len))
+	families.add(namespace+"_max_rows", "Maximum number of cached items.", "gauge", "", labels, strconv.Itoa(s.MaxRows))
+	families.add(namespace+"_hits_total", "Reads that found the item.", "counter", "", labels, strconv.FormatUint(s.Hits, 10))
+	families.add(namespace+"_misses_total", "Reads that did not find the item.", "counter", "", labels, strconv.FormatUint(s.Misses, 10))
+	families.add(namespace+"_evictions_total", "Items evicted to make room.", "counter", "", labels, strconv.FormatUint(s.Evictions, 10))
+	families.add(namespace+"_expired_total", "Items removed when their time to live was over.", "counter", "", labels, strconv.FormatUint(s.Expired, 10))
+	families.add(namespace+"_callback_panics_total", "Panics of user callbacks contained by the cache.", "counter", "", labels, strconv.FormatUint(s.Panics, 10))
+
+	name := namespace + "_operation_duration_seconds"
+
+	for _, op := range sortedKeys(s.Latency) {
+
+		h := s.Latency[op]
+		opLabels := joinLabels(labels, fmt.Sprintf("op=%q", op))
+		cumulative := uint64(0)
+		next := 0
+
+		for _, le := range prometheusBuckets {
+
+			for next < len(h.Buckets) && h.Buckets[next].UpperBound <= le {
+				cumulative += h.Buckets[next].Count
+				next++
+			}
+
+			families.add(name, "Latency of the cache operations.", "histogram", "_bucket", joinLabels(opLabels, fmt.Sprintf("le=%q", seconds(le))), strconv.FormatUint(cumulative, 10))
+
+		}
+
+		families.add(name, "", "", "_bucket", joinLabels(opLabels, `le="+Inf"`), strconv.FormatUint(h.Count, 10))
+		families.add(name, "", "", "_sum", opLabels, seconds(h.Sum))
+		families.add(name, "", "", "_count", opLabels, strconv.FormatUint(h.Count, 10))
+
+	}
+
+	for _, prefix := range sortedKeys(s.Namespaces) {
+
+		nsLabels := joinLabels(labels, fmt.Sprintf("namespace=%q", prefix))
+
+		families.add(namespace+"_namespace_items", "Number of cached items of a namespace.", "gauge", "", nsLabels, strconv.Itoa(s.Namespaces[prefix].Len))
+		families.add(namespace+"_namespace_quota_evictions_total", "Items evicted to respect the quota of a namespace.", "counter", "", nsLabels, strconv.FormatUint(s.Namespaces[prefix].Evicted, 10))
+
+	}
+
+	for _, op := range sortedKeys(s.Contention) {
+
+		opLabels := joinLabels(labels, fmt.Sprintf("op=%q", op))
+		wait := s.Contention[op]
+
+		families.add(namespace+"_lock_acquisitions_total", "Times the cache lock was taken.", "counter", "", opLabels, strconv.FormatUint(wait.Acquisitions, 10))
+		families.add(namespace+"_lock_contended_total", "Times the cache lock was already held.", "counter", "", opLabels, strconv.FormatUint(wait.Contended, 10))
+		families.add(namespace+"_lock_wait_seconds_total", "Time spent waiting for the cache lock.", "counter", "", opLabels, seconds(wait.Wait))
+
+	}
+
+	if s.GhostHits > 0 {
+		families.add(namespace+"_ghost_hits_total", "Misses of recently evicted keys.", "counter", "", labels, strconv.FormatUint(s.GhostHits, 10))
+	}
+
+	if s.LockStalls > 0 {
+		families.add(namespace+"_lock_stalls_total", "Critical sections that held the cache lock beyond the watchdog threshold.", "counter", "", labels, strconv.FormatUint(s.LockStalls, 10))
+	}
+
+	if s.Contention != nil {
+		families.add(namespace+"_lock_max_hold_seconds", "Longest time the cache lock was held.", "gauge", "", labels, seconds(s.MaxLockHold))
+	}
+
+}
+
+// metric family of the Prometheus text format: all its samples must be written together
+type metricFamily struct {
+	name    string
+	help    string
+	kind    string
+	samples []string
+}
+
+// metric families in the order they were first seen
+type metricFamilies struct {
+	order  []*metricFamily
+	byName map[string]*metricFamily
+}
+
+// adds a sample (name+suffix{labels} value) to the family name,
+// creating the family with help and kind when it is new
+func (m *metricFamilies) add(name string, help string, kind string, suffix string, labels string, value string) {
+
+	family := m.byName[name]
+
+	if family == nil {
+
+		if m.byName == nil {
+			m.byName = make(map[string]*metricFamily)
+		}
+
+		family = &metricFamily{name: name, help: help, kind: kind}
+		m.byName[name] = family
+		m.order = append(m.order, family)
+
+	}
+
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+
+	family.samples = append(family.samples, name+suffix+labels+" "+value)
+
+}
+
+func (m *metricFamilies) write(w io.Writer) error {
+
+	var b strings.Builder
+
+	for _, family := range m.order {
+
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", family.name, family.help, family.name, family.kind)
+
+		for _, sample := range family.samples {
+			b.WriteString(sample)
+			b.WriteByte('\n')
+		}
+
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+
+}
+
+// joins label pairs, skipping the empty ones
+func joinLabels(labels ...string) string {
+
+	return strings.Join(slices.DeleteFunc(labels, func(label string) bool { return label == "" }), ",")
+
+}
+
+// formats a duration in seconds, the unit of Prometheus
+func seconds(d time.Duration) string {
+
+	return strconv.FormatFloat(d.Seconds(), 'g', -1, 64)
+
+}
+
+// returns the keys of a map sorted, so the output is stable
+func sortedKeys[T any](m map[string]T) []string {
+
+	keys := make([]string, 0, len(m))
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	return keys
+
+}
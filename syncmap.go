@@ -0,0 +1,87 @@
+package utils
+
+// methods named after sync.Map, so the cache can replace one where bounded capacity is wanted.
+// They are aliases of the primary API (Get, Push, GetOrAdd, Remove)
+
+// returns the cached object of a given id (same as Get)
+func (t *HeapedCache[TId, TObj]) Load(id TId) (*TObj, bool) {
+
+	obj := t.Get(id)
+	return obj, obj != nil
+
+}
+
+// caches obj under id (same as Push)
+func (t *HeapedCache[TId, TObj]) Store(id TId, obj *TObj) {
+
+	t.Push(id, obj)
+
+}
+
+// returns the cached object of a given id when it exists (loaded is true);
+// otherwise caches obj and returns it (same as GetOrAdd with a constant loader)
+func (t *HeapedCache[TId, TObj]) LoadOrStore(id TId, obj *TObj) (actual *TObj, loaded bool) {
+
+	id = t.key(id)
+
+	t.lock(OpGetOrAdd)
+	defer t.unlock()
+
+	if item := t.unexpired(t.mapItems[id]); item != nil {
+		t.itemRead(item)
+		return item.obj, true
+	}
+
+	t.itemMissed(id)
+	t.push(id, obj)
+
+	return obj, false
+
+}
+
+// removes the item of a given id, returning its object (loaded is false when it was not cached)
+func (t *HeapedCache[TId, TObj]) LoadAndDelete(id TId) (obj *TObj, loaded bool) {
+
+	id = t.key(id)
+
+	t.lock(OpRemove)
+	defer t.unlock()
+
+	t.clearNegative(id)
+
+	item := t.mapItems[id]
+
+	if item == nil {
+		t.record(OpRemove, id, OutcomeNotFound)
+		return nil, false
+	}
+
+	t.removeItem(item)
+	t.record(OpRemove, id, OutcomeRemoved)
+	t.itemRemoved(item)
+
+	return item.obj, true
+
+}
+
+// removes the item of a given id (same as Remove)
+func (t *HeapedCache[TId, TObj]) Delete(id TId) {
+
+	t.Remove(id)
+
+}
+
+// calls fn for every cached item until it returns false.
+// As with sync.Map, the items do not come from a consistent view: they are copied first
+// and fn runs outside the lock, so it may call back into the cache
+func (t *HeapedCache[TId, TObj]) Range(fn func(id TId, obj *TObj) bool) {
+
+	for _, item := range t.snapshot() {
+
+		if !fn(item.Id, item.obj) {
+			return
+		}
+
+	}
+
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+)
+
+func TestSyncMapAPI(t *testing.T) {
+
+    t.Log("validating TestSyncMapAPI")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    heapedCache.Store(1, NewAccountTest(1))
+
+    obj, ok := heapedCache.Load(1)
+    require.True(t, ok)
+    require.Equal(t, 1, obj.Id)
+
+    _, ok = heapedCache.Load(2)
+    require.False(t, ok)
+
+    actual, loaded := heapedCache.LoadOrStore(1, NewAccountTest(100))
+    require.True(t, loaded)
+    require.Equal(t, 1, actual.Id)
+
+    actual, loaded = heapedCache.LoadOrStore(2, NewAccountTest(2))
+    require.False(t, loaded)
+    require.Equal(t, 2, actual.Id)
+
+    seen := 0
+    heapedCache.Range(func(id int, obj *AccountTest) bool {
+        // the cache can be used from fn
+        require.NotNil(t, heapedCache.Get(id))
+        seen++
+        return false
+    })
+    require.Equal(t, 1, seen)
+
+    obj, loaded = heapedCache.LoadAndDelete(1)
+    require.True(t, loaded)
+    require.Equal(t, 1, obj.Id)
+
+    _, loaded = heapedCache.LoadAndDelete(1)
+    require.False(t, loaded)
+
+    heapedCache.Delete(2)
+    require.Equal(t, 0, heapedCache.Len())
+
+}
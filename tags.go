@@ -0,0 +1,100 @@
+package utils
+
+import "slices"
+
+// Removes every cached item tagged with tag (see EntryOptions.Tags), e.g. all the objects loaded
+// from a tenant or an upstream whose data changed, along with the items derived from them (see PushWithDeps).
+// returns the number of items tagged with it that were removed
+func (t *HeapedCache[TId, TObj]) InvalidateTag(tag string) int {
+
+	t.lock(OpRemove)
+	defer t.unlock()
+
+	ids := make([]TId, 0, len(t.tags[tag]))
+
+	for id := range t.tags[tag] {
+		ids = append(ids, id)
+	}
+
+	seededOrder(t.random, ids, func(id TId) uint64 {
+
+		if item := t.mapItems[id]; item != nil {
+			return item.seq
+		}
+
+		return 0
+
+	})
+
+	removed := 0
+
+	for _, id := range ids {
+
+		// may have left the cache as a dependent of an item removed before
+		item := t.mapItems[id]
+
+		if item == nil {
+			continue
+		}
+
+		t.removeItem(item)
+		t.record(OpRemove, id, OutcomeInvalidated)
+		t.itemRemoved(item)
+		removed++
+
+	}
+
+	return removed
+
+}
+
+// tags a freshly loaded item, replacing the tags it had
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) tagItem(item *HeapedCacheItem[TId, TObj], tags []string) {
+
+	t.untagItem(item)
+
+	// kept after the call, so the caller may reuse its slice
+	tags = slices.Clone(tags)
+	slices.Sort(tags)
+	item.tags = slices.Compact(tags)
+
+	t.indexTags(item)
+
+}
+
+// registers the tags of an item entering the cache (kept by Rekey)
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) indexTags(item *HeapedCacheItem[TId, TObj]) {
+
+	if t.tags == nil {
+		t.tags = make(map[string]map[TId]struct{})
+	}
+
+	for _, tag := range item.tags {
+
+		if t.tags[tag] == nil {
+			t.tags[tag] = make(map[TId]struct{})
+		}
+
+		t.tags[tag][item.Id] = struct{}{}
+
+	}
+
+}
+
+// unregisters the tags of an item leaving the cache (the item keeps them, see indexTags)
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) untagItem(item *HeapedCacheItem[TId, TObj]) {
+
+	for _, tag := range item.tags {
+
+		delete(t.tags[tag], item.Id)
+
+		if len(t.tags[tag]) == 0 {
+			delete(t.tags, tag)
+		}
+
+	}
+
+}
@@ -0,0 +1,72 @@
+package utils
+
+import "time"
+
+// number of items evicted per lock acquisition by the background trimmer
+const trimBatch = 1000
+
+// settings of the background trimming
+type trimmer struct {
+	hardRows int
+}
+
+// makes Push never evict while the cache holds less than hardRows items:
+// new items go in right away, and a background goroutine evicts the oldest ones
+// every interval until the cache is back to maxRows (the soft cap).
+// This decouples write latency from eviction cost for spiky producers.
+// Close must be called to stop the goroutine
+func WithAsyncTrim[TId comparable, TObj any](hardRows int, interval time.Duration) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		if interval > 0 && hardRows > t.maxRows {
+			t.trimmer = &trimmer{hardRows: hardRows}
+			t.every(taskTrim, interval, func() { t.Trim() })
+		}
+
+	}
+
+}
+
+// returns the number of items above which Push evicts right away
+func (t *HeapedCache[TId, TObj]) capacity() int {
+
+	if t.trimmer != nil {
+		return t.trimmer.hardRows
+	}
+
+	return t.maxRows
+
+}
+
+// evicts the oldest items until the cache is back to maxRows,
+// releasing the lock between batches so writers are not blocked for long.
+// returns the number of evicted items
+func (t *HeapedCache[TId, TObj]) Trim() int {
+
+	evicted := 0
+
+	for {
+
+		t.lock(opOther)
+
+		batch := 0
+
+		for batch < trimBatch && len(t.sliceItems) > t.maxRows && t.evict() {
+			batch++
+		}
+
+		// stops when back to maxRows or when nothing else can be evicted
+		done := batch < trimBatch
+
+		t.unlock()
+
+		evicted += batch
+
+		if done {
+			return evicted
+		}
+
+	}
+
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestAsyncTrim(t *testing.T) {
+
+    t.Log("validating TestAsyncTrim")
+
+    heapedCache := NewHeapedCache(10, WithAsyncTrim[int, AccountTest](100, 10*time.Millisecond))
+    defer heapedCache.Close()
+
+    for i := range 50 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    // nothing is evicted while under the hard cap
+    require.LessOrEqual(t, heapedCache.Len(), 50)
+    require.Eventually(t, func() bool { return heapedCache.Len() == 10 }, time.Second, 5*time.Millisecond)
+
+    for i := 40; i < 50; i++ {
+
+        require.NotNil(t, heapedCache.Get(i))
+
+    }
+
+}
+
+func TestAsyncTrimHardCap(t *testing.T) {
+
+    t.Log("validating TestAsyncTrimHardCap")
+
+    heapedCache := NewHeapedCache(10, WithAsyncTrim[int, AccountTest](20, time.Hour))
+    defer heapedCache.Close()
+
+    for i := range 50 {
+
+        heapedCache.Push(i, NewAccountTest(i))
+
+    }
+
+    require.Equal(t, 20, heapedCache.Len())
+    require.NoError(t, heapedCache.CheckInvariants())
+
+    require.Equal(t, 10, heapedCache.Trim())
+    require.Equal(t, 10, heapedCache.Len())
+    require.NotNil(t, heapedCache.Get(49))
+
+}
+
+func TestCloseWithoutTrimmer(t *testing.T) {
+
+    t.Log("validating TestCloseWithoutTrimmer")
+
+    heapedCache := NewHeapedCache[int, AccountTest](10)
+
+    heapedCache.Close()
+    heapedCache.Close()
+
+    require.Equal(t, 0, heapedCache.Trim())
+
+}
@@ -0,0 +1,270 @@
+package utils
+
+import "time"
+
+// makes the items expire ttl after they were refreshed (added, updated or patched): Get, GetOrAdd
+// and the other reads no longer return an expired item (GetOrAdd loads it again), and expired
+// items are removed from the cache as soon as the lock is taken, oldest first, without waiting
+// for capacity to evict them. Expirations are counted in Stats and are not evictions: OnEvict
+// is not called for them. Without it (or with ttl 0), items never expire
+func WithTTL[TId comparable, TObj any](ttl time.Duration) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		t.ttl = max(ttl, 0)
+
+	}
+
+}
+
+// rule of WithTTLRule
+type ttlRule[TId any] struct {
+	matches func(id TId) bool
+	ttl     time.Duration
+}
+
+// gives the items whose id matches a time to live of their own, overriding the one of WithTTL,
+// so classes of keys get different lifetimes in one cache (e.g. "session:" 30m, "profile:" 24h).
+// The rules are evaluated in the order they were given when an item is added, the first match
+// winning; PushWithTTL and the loaders of GetOrAddWithTTL override them. matches runs under the lock
+func WithTTLRule[TId comparable, TObj any](matches func(id TId) bool, ttl time.Duration) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		if matches == nil || ttl <= 0 {
+			return
+		}
+
+		t.ttlRules = append(t.ttlRules, ttlRule[TId]{matches: matches, ttl: ttl})
+
+		// items expire out of the refreshed order from the start
+		if t.expiries == nil {
+			t.expiries = &expiryWheel[TId, TObj]{cache: t}
+		}
+
+	}
+
+}
+
+// returns the time to live the rules give to id, 0 when none matches (see WithTTLRule)
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) ruleTTL(id TId) time.Duration {
+
+	for _, rule := range t.ttlRules {
+
+		matched := false
+
+		// an id the rule could not judge does not match
+		contain(&t.panics, func() { matched = rule.matches(id) })
+
+		if matched {
+			return rule.ttl
+		}
+
+	}
+
+	return 0
+
+}
+
+// same as Push, with a time to live for this item overriding the one of the cache (see WithTTL),
+// e.g. to give auth tokens a much shorter lifetime than reference data. The item keeps it when
+// it is updated by Push or Patch, until PushWithTTL gives it another one (0: back to the ttl of
+// the cache, or of its WithTTLRule). It is not persisted: items loaded from a snapshot get the ttl of the cache
+func (t *HeapedCache[TId, TObj]) PushWithTTL(id TId, item *TObj, ttl time.Duration) *TObj {
+
+	t.lock(OpPush)
+	defer t.unlock()
+
+	result, _ := t.push(id, item)
+
+	if result != nil {
+		t.setTTL(t.mapItems[t.key(id)], ttl)
+	}
+
+	return result
+
+}
+
+// same as GetOrAdd, with fn returning the time to live of the loaded item along with it,
+// overriding the one of the cache as PushWithTTL does (0: the ttl of the cache)
+func (t *HeapedCache[TId, TObj]) GetOrAddWithTTL(id TId, fn func(id TId) (*TObj, time.Duration)) *TObj {
+
+	loaded := &loadResult{}
+
+	load := func(id TId) *TObj {
+
+		obj, ttl := fn(id)
+		loaded.TTL = ttl
+
+		return obj
+
+	}
+
+	result, _ := t.getOrAdd(nil, id, load, EntryOptions{loaded: loaded})
+	return result
+
+}
+
+// returns the time a cached item expires, e.g. to set the Expires header of a response built from it.
+// returns false when the id is not cached or the item does not expire (see WithTTL)
+func (t *HeapedCache[TId, TObj]) ExpiresAt(id TId) (time.Time, bool) {
+
+	id = t.key(id)
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	item := t.unexpired(t.mapItems[id])
+
+	if item == nil {
+		return time.Time{}, false
+	}
+
+	expires := t.expiresAt(item)
+
+	return expires, !expires.IsZero()
+
+}
+
+// returns the time a cached item has left before it expires, e.g. to set the max-age of the
+// Cache-Control header of a response built from it, so downstream caches do not keep it longer.
+// returns false when the id is not cached or the item does not expire (see WithTTL)
+func (t *HeapedCache[TId, TObj]) RemainingTTL(id TId) (time.Duration, bool) {
+
+	id = t.key(id)
+
+	t.lock(opOther)
+	defer t.unlock()
+
+	item := t.unexpired(t.mapItems[id])
+
+	if item == nil {
+		return 0, false
+	}
+
+	expires := t.expiresAt(item)
+
+	if expires.IsZero() {
+		return 0, false
+	}
+
+	// an unexpired item has time left
+	return expires.Sub(t.now()), true
+
+}
+
+// returns the time an item expires, the zero time when it does not
+func (t *HeapedCache[TId, TObj]) expiresAt(item *HeapedCacheItem[TId, TObj]) time.Time {
+
+	ttl := t.ttlOf(item)
+
+	if ttl <= 0 {
+		return time.Time{}
+	}
+
+	return item.Refreshed.Add(ttl)
+
+}
+
+// returns the time to live of an item, 0 when it does not expire
+func (t *HeapedCache[TId, TObj]) ttlOf(item *HeapedCacheItem[TId, TObj]) time.Duration {
+
+	ttl := t.ttl
+
+	if item.ttl > 0 {
+		ttl = item.ttl
+	}
+
+	if t.adaptiveTTL != nil && ttl > 0 {
+		return t.adaptiveTTL.adapt(ttl, item.accesses)
+	}
+
+	return ttl
+
+}
+
+// returns true when an item expired at now (see WithTTL)
+func (t *HeapedCache[TId, TObj]) expired(item *HeapedCacheItem[TId, TObj], now time.Time) bool {
+
+	ttl := t.ttlOf(item)
+
+	return ttl > 0 && now.Sub(item.Refreshed) >= ttl
+
+}
+
+// gives an item its own time to live (0: the ttl of its rule, see WithTTLRule, or of the cache)
+// the first override starts the expiry wheel, as the oldest items no longer expire first
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) setTTL(item *HeapedCacheItem[TId, TObj], ttl time.Duration) {
+
+	if item != nil && ttl <= 0 {
+		ttl = t.ruleTTL(item.Id)
+	}
+
+	if item == nil || item.ttl == ttl {
+		return
+	}
+
+	item.ttl = ttl
+
+	if t.expiries == nil {
+
+		t.expiries = &expiryWheel[TId, TObj]{cache: t}
+		t.expiries.rebuild(t.sliceItems)
+
+		return
+
+	}
+
+	t.expiries.fix(item)
+
+}
+
+// removes an expired item found by a read, returning nil, or returns the item
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) unexpired(item *HeapedCacheItem[TId, TObj]) *HeapedCacheItem[TId, TObj] {
+
+	if item == nil || !t.expired(item, t.now()) {
+		return item
+	}
+
+	t.expireItem(item)
+
+	return nil
+
+}
+
+// removes the expired items from the top of the heap, where the oldest ones are
+// (from the expiry wheel once items override the ttl)
+// must be called under the lock (lock calls it)
+func (t *HeapedCache[TId, TObj]) purgeExpired() {
+
+	if t.expiries != nil {
+		t.expiries.advance(t.now())
+		return
+	}
+
+	if t.ttl <= 0 || len(t.sliceItems) == 0 {
+		return
+	}
+
+	now := t.now()
+
+	// with deferred fixes pending the top may not be the oldest item: the others expire on read
+	for len(t.sliceItems) > 0 && t.expired(t.sliceItems[0], now) {
+		t.expireItem(t.sliceItems[0])
+	}
+
+}
+
+// removes an expired item
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) expireItem(item *HeapedCacheItem[TId, TObj]) {
+
+	t.removeItem(item)
+	t.record(OpExpire, item.Id, OutcomeExpired)
+	t.itemRemoved(item)
+	t.expirations.Add(1)
+
+}
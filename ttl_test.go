@@ -0,0 +1,293 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestTTL(t *testing.T) {
+
+    t.Log("validating TestTTL")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache(10, 1, clock, WithTTL[int, AccountTest](time.Minute))
+
+    evicted := 0
+    heapedCache.OnEvict(func(id int, obj *AccountTest) { evicted++ })
+
+    heapedCache.Push(1, NewAccountTest(1))
+    clock.Advance(30 * time.Second)
+    heapedCache.Push(2, NewAccountTest(2))
+
+    require.NotNil(t, heapedCache.Get(1))
+
+    clock.Advance(30 * time.Second)
+
+    // 1 expired, 2 has 30s left
+    require.Nil(t, heapedCache.Get(1))
+    require.NotNil(t, heapedCache.Get(2))
+    require.Equal(t, 1, heapedCache.Len())
+
+    // GetOrAdd loads an expired item again
+    loads := 0
+    load := func(id int) *AccountTest { loads++; return NewAccountTest(id) }
+
+    clock.Advance(30 * time.Second)
+    require.NotNil(t, heapedCache.GetOrAdd(2, load))
+    require.Equal(t, 1, loads)
+
+    // refreshing (update, patch) restarts the time to live
+    clock.Advance(50 * time.Second)
+    require.True(t, heapedCache.Patch(2, func(obj *AccountTest) {}))
+    clock.Advance(50 * time.Second)
+    require.NotNil(t, heapedCache.GetOrAdd(2, load))
+    require.Equal(t, 1, loads)
+
+    // expired items leave without being read, and are not evictions
+    heapedCache.Push(3, NewAccountTest(3))
+    clock.Advance(time.Minute)
+
+    require.Equal(t, 0, heapedCache.Len())
+    require.Equal(t, uint64(4), heapedCache.Stats().Expired)
+    require.Zero(t, evicted)
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
+
+func TestTTLConfig(t *testing.T) {
+
+    t.Log("validating TestTTLConfig")
+
+    heapedCache, err := NewFromConfig[int, AccountTest](Config{MaxRows: 10, TTL: Duration(time.Minute)})
+    require.NoError(t, err)
+    require.Equal(t, time.Minute, heapedCache.ttl)
+
+    require.NoError(t, heapedCache.ApplyConfig(Config{MaxRows: 10, TTL: Duration(time.Hour)}))
+    require.Equal(t, time.Hour, heapedCache.ttl)
+
+    require.Error(t, Config{MaxRows: 10, TTL: Duration(-time.Second)}.Validate())
+
+}
+
+func TestPushWithTTL(t *testing.T) {
+
+    t.Log("validating TestPushWithTTL")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache(10, 1, clock, WithTTL[int, AccountTest](time.Hour))
+
+    // the oldest item outlives the ones behind it
+    heapedCache.PushWithTTL(4, NewAccountTest(4), 2*time.Hour)
+    heapedCache.Push(1, NewAccountTest(1))
+    heapedCache.PushWithTTL(2, NewAccountTest(2), time.Minute)
+    heapedCache.Push(3, NewAccountTest(3))
+
+    clock.Advance(time.Minute)
+
+    require.Equal(t, 3, heapedCache.Len())
+    require.Nil(t, heapedCache.Get(2))
+
+    clock.Advance(59 * time.Minute)
+
+    require.Equal(t, 1, heapedCache.Len())
+    require.NotNil(t, heapedCache.Get(4))
+
+    clock.Advance(time.Hour)
+
+    require.Equal(t, 0, heapedCache.Len())
+    require.Equal(t, uint64(4), heapedCache.Stats().Expired)
+
+    // updates keep the ttl of the item, until it is given another one
+    heapedCache.PushWithTTL(5, NewAccountTest(5), time.Minute)
+    heapedCache.Push(5, NewAccountTest(5))
+    clock.Advance(time.Minute)
+    require.Nil(t, heapedCache.Get(5))
+
+    heapedCache.PushWithTTL(5, NewAccountTest(5), time.Minute)
+    heapedCache.PushWithTTL(5, NewAccountTest(5), 0)
+    clock.Advance(time.Minute)
+    require.NotNil(t, heapedCache.Get(5))
+
+    // a new ttl of the cache moves the deadlines of the items following it
+    require.NoError(t, heapedCache.ApplyConfig(Config{MaxRows: 10, TTL: Duration(time.Minute)}))
+    require.Nil(t, heapedCache.Get(5))
+
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
+
+func TestGetOrAddWithTTL(t *testing.T) {
+
+    t.Log("validating TestGetOrAddWithTTL")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+    // no ttl for the cache: only the items given one expire
+    heapedCache := NewDeterministicHeapedCache[int, AccountTest](10, 1, clock)
+
+    loads := 0
+    load := func(id int) (*AccountTest, time.Duration) {
+        loads++
+        return NewAccountTest(id), time.Minute
+    }
+
+    require.Equal(t, 1, heapedCache.GetOrAddWithTTL(1, load).Id)
+    require.Equal(t, 1, heapedCache.GetOrAddWithTTL(1, load).Id)
+    require.Equal(t, 1, loads)
+
+    _, err := heapedCache.GetOrAddOpts(2, NewAccountTest, EntryOptions{TTL: 2 * time.Minute})
+    require.NoError(t, err)
+
+    heapedCache.Push(3, NewAccountTest(3))
+
+    clock.Advance(time.Minute)
+
+    require.Equal(t, 1, heapedCache.GetOrAddWithTTL(1, load).Id)
+    require.Equal(t, 2, loads)
+    require.NotNil(t, heapedCache.Get(2))
+
+    clock.Advance(time.Minute)
+
+    require.Nil(t, heapedCache.Get(2))
+    require.NotNil(t, heapedCache.Get(3))
+
+    clock.Advance(24 * time.Hour)
+
+    require.Equal(t, 1, heapedCache.Len())
+    require.NoError(t, heapedCache.CheckInvariants())
+
+}
+
+func TestTTLRule(t *testing.T) {
+
+    t.Log("validating TestTTLRule")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    prefix := func(p string) func(id string) bool {
+        return func(id string) bool { return strings.HasPrefix(id, p) }
+    }
+
+    heapedCache := NewDeterministicHeapedCache(10, 1, clock,
+        WithTTL[string, AccountTest](time.Hour),
+        WithTTLRule[string, AccountTest](prefix("session:"), 30*time.Minute),
+        WithTTLRule[string, AccountTest](prefix("profile:"), 24*time.Hour),
+        WithTTLRule[string, AccountTest](func(id string) bool { panic("broken rule") }, time.Minute),
+    )
+
+    heapedCache.Push("profile:1", NewAccountTest(1))
+    heapedCache.Push("session:1", NewAccountTest(1))
+    heapedCache.Push("other:1", NewAccountTest(1))
+    heapedCache.PushWithTTL("session:2", NewAccountTest(2), 2*time.Hour)
+
+    clock.Advance(30 * time.Minute)
+
+    require.Nil(t, heapedCache.Get("session:1"))
+    require.NotNil(t, heapedCache.Get("other:1"))
+
+    clock.Advance(30 * time.Minute)
+
+    // the panicking rule matched nothing: other:1 follows the ttl of the cache
+    require.Nil(t, heapedCache.Get("other:1"))
+    require.NotNil(t, heapedCache.Get("profile:1"))
+    require.NotNil(t, heapedCache.Get("session:2"))
+
+    // back to the rule of its id
+    heapedCache.PushWithTTL("session:2", NewAccountTest(2), 0)
+
+    // a rekeyed item keeps its ttl
+    heapedCache.Push("session:3", NewAccountTest(3))
+    require.True(t, heapedCache.Rekey("session:3", "profile:3"))
+
+    clock.Advance(30 * time.Minute)
+
+    require.Nil(t, heapedCache.Get("session:2"))
+    require.Nil(t, heapedCache.Get("profile:3"))
+
+    require.Equal(t, 1, heapedCache.Len())
+    require.NoError(t, heapedCache.CheckInvariants())
+    require.Positive(t, heapedCache.Stats().Panics)
+
+}
+
+func TestRemainingTTL(t *testing.T) {
+
+    t.Log("validating TestRemainingTTL")
+
+    start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+    clock := NewFakeClock(start)
+    heapedCache := NewDeterministicHeapedCache(10, 1, clock, WithTTL[int, AccountTest](time.Minute))
+
+    heapedCache.Push(1, NewAccountTest(1))
+    heapedCache.PushWithTTL(2, NewAccountTest(2), time.Hour)
+
+    clock.Advance(20 * time.Second)
+
+    expires, ok := heapedCache.ExpiresAt(1)
+    require.True(t, ok)
+    require.Equal(t, start.Add(time.Minute), expires)
+
+    remaining, ok := heapedCache.RemainingTTL(1)
+    require.True(t, ok)
+    require.Equal(t, 40*time.Second, remaining)
+
+    remaining, ok = heapedCache.RemainingTTL(2)
+    require.True(t, ok)
+    require.Equal(t, time.Hour-20*time.Second, remaining)
+
+    // expired or never cached
+    clock.Advance(40 * time.Second)
+
+    _, ok = heapedCache.ExpiresAt(1)
+    require.False(t, ok)
+    _, ok = heapedCache.RemainingTTL(1)
+    require.False(t, ok)
+    _, ok = heapedCache.RemainingTTL(3)
+    require.False(t, ok)
+
+    // without a ttl, items do not expire
+    heapedCache = NewDeterministicHeapedCache[int, AccountTest](10, 1, clock)
+    heapedCache.Push(1, NewAccountTest(1))
+
+    _, ok = heapedCache.ExpiresAt(1)
+    require.False(t, ok)
+    _, ok = heapedCache.RemainingTTL(1)
+    require.False(t, ok)
+
+}
+
+func TestExpiryOrdering(t *testing.T) {
+
+    t.Log("validating TestExpiryOrdering")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache(10, 1, clock,
+        WithTTL[int, AccountTest](time.Minute),
+        WithRecorder[int, AccountTest](10))
+
+    // the front cache would serve it for an hour without the expiry
+    readCache := heapedCache.ReadCache(10, time.Hour)
+
+    heapedCache.Push(1, NewAccountTest(1))
+    require.NotNil(t, readCache.Get(1))
+
+    clock.Advance(time.Minute)
+
+    // the front cache does not serve it past its expiry, and the cache reports it
+    require.Nil(t, readCache.Get(1))
+
+    ops := heapedCache.RecentOps()
+    require.Equal(t, OpExpire, ops[len(ops)-1].Op)
+    require.Equal(t, uint64(1), heapedCache.Stats().Expired)
+
+    // once reported, no read returns it, even when the clock goes back
+    clock.Advance(-30 * time.Second)
+
+    require.Nil(t, heapedCache.Get(1))
+    require.Nil(t, readCache.Get(1))
+    _, ok := heapedCache.GetMeta(1)
+    require.False(t, ok)
+    require.Equal(t, uint64(1), heapedCache.Stats().Expired)
+
+}
@@ -0,0 +1,98 @@
+package utils
+
+import "encoding/json"
+
+// converts the objects of a TypedView to and from the bytes stored in the shared cache
+type Codec[T any] interface {
+	Marshal(obj *T) ([]byte, error)
+	Unmarshal(data []byte) (*T, error)
+}
+
+// Codec encoding the objects as JSON
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Marshal(obj *T) ([]byte, error) {
+
+	return json.Marshal(obj)
+
+}
+
+func (JSONCodec[T]) Unmarshal(data []byte) (*T, error) {
+
+	obj := new(T)
+
+	if err := json.Unmarshal(data, obj); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+
+}
+
+// typed view of a namespace of a shared untyped cache (string ids, encoded objects),
+// so modules storing different types can share the capacity of a single cache
+// instead of running one cache each. Objects are encoded on Push and decoded on every Get,
+// so the view returns a new copy each time and changes to it are not cached
+type TypedView[T any] struct {
+	ns    *Namespaced[[]byte]
+	codec Codec[T]
+}
+
+// returns the typed view of the keys of core starting with prefix (see Namespace),
+// encoding its objects with codec
+func NewTypedView[T any](core *HeapedCache[string, []byte], prefix string, codec Codec[T]) *TypedView[T] {
+
+	return &TypedView[T]{ns: Namespace(core, prefix), codec: codec}
+
+}
+
+// returns the cached object of a given key, or nil when it is not cached
+// returns the error of the codec when the stored bytes cannot be decoded
+func (v *TypedView[T]) Get(id string) (*T, error) {
+
+	data := v.ns.Get(id)
+
+	if data == nil {
+		return nil, nil
+	}
+
+	return v.codec.Unmarshal(*data)
+
+}
+
+// encodes obj and caches it under id
+func (v *TypedView[T]) Push(id string, obj *T) error {
+
+	data, err := v.codec.Marshal(obj)
+
+	if err != nil {
+		return err
+	}
+
+	v.ns.Push(id, &data)
+
+	return nil
+
+}
+
+// removes the object of a given key, returning false when it was not cached
+func (v *TypedView[T]) Remove(id string) bool {
+
+	return v.ns.Remove(id)
+
+}
+
+// returns the number of cached objects of the view
+func (v *TypedView[T]) Len() int {
+
+	return v.ns.Len()
+
+}
+
+// limits the number of objects of the view (see Namespaced.SetQuota)
+// without a quota, every view takes what it needs from the shared capacity
+func (v *TypedView[T]) SetQuota(maxRows int) {
+
+	v.ns.SetQuota(maxRows)
+
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+    "github.com/stretchr/testify/require"
+    "testing"
+)
+
+type sessionTest struct {
+    User  string
+    Admin bool
+}
+
+func TestTypedView(t *testing.T) {
+
+    t.Log("validating TestTypedView")
+
+    core := NewHeapedCache[string, []byte](3)
+
+    accounts := NewTypedView(core, "account:", JSONCodec[AccountTest]{})
+    sessions := NewTypedView(core, "session:", JSONCodec[sessionTest]{})
+
+    require.NoError(t, accounts.Push("1", NewAccountTest(1)))
+    require.NoError(t, sessions.Push("1", &sessionTest{User: "alice", Admin: true}))
+
+    account, err := accounts.Get("1")
+    require.NoError(t, err)
+    require.Equal(t, NewAccountTest(1), account)
+
+    session, err := sessions.Get("1")
+    require.NoError(t, err)
+    require.Equal(t, &sessionTest{User: "alice", Admin: true}, session)
+
+    missing, err := sessions.Get("2")
+    require.NoError(t, err)
+    require.Nil(t, missing)
+
+    // the views share the capacity of the core
+    require.NoError(t, sessions.Push("2", &sessionTest{User: "bob"}))
+    require.NoError(t, sessions.Push("3", &sessionTest{User: "carol"}))
+
+    require.Equal(t, 3, core.Len())
+    require.Equal(t, 0, accounts.Len())
+    require.Equal(t, 3, sessions.Len())
+
+    require.True(t, sessions.Remove("3"))
+    require.Equal(t, 2, core.Len())
+
+    // bytes that are not a valid encoding
+    core.Push("account:bad", &[]byte{'{'})
+
+    _, err = accounts.Get("bad")
+    require.Error(t, err)
+
+}
@@ -0,0 +1,360 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// returned by Checkpoint when the cache has no write-ahead log
+var ErrNoWAL = errors.New("heapedcache: no write-ahead log (see Recover)")
+
+// operations of the write-ahead log
+const (
+	walPush   = "push"
+	walRemove = "remove"
+)
+
+// result of Recover
+type RecoveryReport struct {
+	Restored int // items loaded from the snapshot
+	Replayed int // operations of the write-ahead log applied on top of it
+	Skipped  int // items and operations refused by the cache (overflow policy, shutdown)
+	Corrupt  int // lines of the snapshot or of the log that could not be decoded (e.g. torn by a crash)
+	Migrated int // items and operations of an older format version converted by a migrator (see WithMigrator)
+
+	// items and operations of another format version without a migrator, or that their migrator rejected
+	Incompatible int
+}
+
+// line of the write-ahead log: the state of an id after an operation
+type walRecord[TId any, TObj any] struct {
+	Op        string    `json:"op"`
+	Id        TId       `json:"id"`
+	Refreshed time.Time `json:"refreshed"`
+	Version   int       `json:"version,omitempty"` // see WithFormatVersion
+	Obj       *TObj     `json:"obj,omitempty"`
+}
+
+// write-ahead log of the cache (see Recover)
+type wal struct {
+	mu           sync.Mutex // held from the end of a critical section until its records are written, keeping their order
+	path         string
+	snapshotPath string
+	file         *os.File
+	pending      bytes.Buffer // records of the running critical section
+	err          error        // last write error
+}
+
+// loads the cache from the snapshot at snapshotPath, replays on top of it the operations logged
+// since then in the write-ahead log at walPath, and keeps logging every change to walPath from then on
+// (a line per added, updated, removed or evicted item, written right after each operation),
+// so a crash loses no more than the writes in flight instead of everything since the last snapshot.
+// Either file may be missing (first start). Undecodable lines, such as the last one of a log torn
+// by a crash, are skipped and counted in the report. Recover ends with a Checkpoint, so the next
+// start replays only what changed after it; OnShutdown checkpoints as well.
+// Meant to be called once, right after creating the cache and before using it.
+// The log is written outside the cache lock, but its records are encoded under it
+func (t *HeapedCache[TId, TObj]) Recover(snapshotPath string, walPath string) (RecoveryReport, error) {
+
+	var report RecoveryReport
+
+	entries, err := t.readPersisted(snapshotPath, false, &report)
+
+	if err != nil {
+		return report, err
+	}
+
+	// oldest first, so the oldest items are the ones evicted when they do not fit
+	slices.SortStableFunc(entries, func(a, b walRecord[TId, TObj]) int { return a.Refreshed.Compare(b.Refreshed) })
+
+	records, err := t.readPersisted(walPath, true, &report)
+
+	if err != nil {
+		return report, err
+	}
+
+	file, err := os.OpenFile(walPath, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+
+	if err != nil {
+		return report, err
+	}
+
+	t.lock(opOther)
+
+	t.replay(entries, records, &report)
+	t.wal = &wal{path: walPath, snapshotPath: snapshotPath, file: file}
+
+	t.unlock()
+
+	return report, t.Checkpoint(context.Background())
+
+}
+
+// writes a snapshot of the cache to the snapshot path given to Recover and drops the part of the
+// write-ahead log it covers. A crash in between is harmless: replaying operations already in the
+// snapshot leaves every id as the snapshot has it.
+// When ctx is done before the snapshot is complete, its error is returned and the previous
+// snapshot is left untouched. returns ErrNoWAL when Recover was not called
+func (t *HeapedCache[TId, TObj]) Checkpoint(ctx context.Context) error {
+
+	_, err := t.checkpoint(ctx)
+	return err
+
+}
+
+// returns the last error writing the write-ahead log, nil when none
+func (t *HeapedCache[TId, TObj]) WALError() error {
+
+	if t.wal == nil {
+		return nil
+	}
+
+	t.wal.mu.Lock()
+	defer t.wal.mu.Unlock()
+
+	return t.wal.err
+
+}
+
+// Checkpoint, returning the number of items written
+func (t *HeapedCache[TId, TObj]) checkpoint(ctx context.Context) (int, error) {
+
+	if t.wal == nil {
+		return 0, ErrNoWAL
+	}
+
+	// the items and the length of the log are taken at the same point
+	t.lock(opOther)
+	items := t.copyItems()
+	t.wal.mu.Lock()
+	covered, err := t.wal.file.Seek(0, io.SeekEnd)
+	t.wal.mu.Unlock()
+	t.unlock()
+
+	if err != nil {
+		return 0, err
+	}
+
+	written := 0
+
+	err = writeSnapshotFile(t.wal.snapshotPath, func(w io.Writer) (err error) {
+		written, err = t.persistItems(ctx, w, items)
+		return err
+	})
+
+	if err != nil {
+		return written, err
+	}
+
+	return written, t.wal.truncate(covered)
+
+}
+
+// drops the first n bytes of the log, rewriting the rest through a temporary file
+func (l *wal) truncate(n int64) error {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := writeSnapshotFile(l.path, func(w io.Writer) error {
+
+		rest := io.NewSectionReader(l.file, n, 1<<62)
+		_, err := io.Copy(w, rest)
+
+		return err
+
+	})
+
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+
+	if err != nil {
+		return err
+	}
+
+	l.file.Close()
+	l.file = file
+
+	return nil
+
+}
+
+// loads the items of a snapshot (oldest first), then applies the records of a log on top of them
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) replay(entries []walRecord[TId, TObj], records []walRecord[TId, TObj], report *RecoveryReport) {
+
+	for _, entry := range entries {
+
+		if t.put(entry.Id, entry.Obj, entry.Refreshed) {
+			report.Restored++
+		} else {
+			report.Skipped++
+		}
+
+	}
+
+	for _, record := range records {
+
+		switch {
+		case record.Op == walRemove:
+			t.remove(record.Id)
+		case record.Op == walPush && t.put(record.Id, record.Obj, record.Refreshed):
+		default:
+			report.Skipped++
+			continue
+		}
+
+		report.Replayed++
+
+	}
+
+}
+
+// caches obj under id with the given refreshed time, returning false when it is refused
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) put(id TId, obj *TObj, refreshed time.Time) bool {
+
+	result, _ := t.pushAt(id, obj, refreshed)
+
+	return result != nil
+
+}
+
+// logs the state of id after an operation changing the cache (see record)
+// must be called under the lock
+func (t *HeapedCache[TId, TObj]) walLog(id TId, outcome string) {
+
+	cached, ok := auditedOutcomes[outcome]
+
+	if !ok {
+		return
+	}
+
+	record := walRecord[TId, TObj]{Op: walRemove, Id: id}
+
+	if item := t.mapItems[id]; cached && item != nil {
+
+		if t.persisted(id, item.obj) {
+			record = walRecord[TId, TObj]{Op: walPush, Id: id, Refreshed: item.Refreshed, Version: t.formatVersion, Obj: item.obj}
+		} else if outcome == OutcomeAdded || outcome == OutcomeReturned {
+			// not in the log before either (it was not cached)
+			return
+		}
+
+	}
+
+	if err := json.NewEncoder(&t.wal.pending).Encode(record); err != nil {
+		t.wal.mu.Lock()
+		t.wal.err = err
+		t.wal.mu.Unlock()
+	}
+
+}
+
+// takes the records of a critical section, holding the log until they are written (see writeWAL)
+// must be called under the lock, right before releasing it
+func (t *HeapedCache[TId, TObj]) takeWAL() []byte {
+
+	if t.wal == nil || t.wal.pending.Len() == 0 {
+		return nil
+	}
+
+	records := bytes.Clone(t.wal.pending.Bytes())
+	t.wal.pending.Reset()
+
+	// taken before the cache lock is released, so the next critical section writes after this one
+	t.wal.mu.Lock()
+
+	return records
+
+}
+
+// writes the records of a critical section once the lock is released
+func (t *HeapedCache[TId, TObj]) writeWAL(records []byte) {
+
+	if records == nil {
+		return
+	}
+
+	defer t.wal.mu.Unlock()
+
+	if t.sealer != nil {
+
+		id, aead, err := t.sealer.current()
+
+		if err == nil {
+			records, err = t.sealer.seal(id, aead, records)
+		}
+
+		if err != nil {
+			t.wal.err = err
+			return
+		}
+
+	}
+
+	if _, err := t.wal.file.Write(records); err != nil {
+		t.wal.err = err
+	}
+
+}
+
+// reads the items of a snapshot file (or the records of a write-ahead log, when log is true),
+// counting in report the lines that could not be decoded or migrated (see decodeLine)
+// a missing file has no line
+func (t *HeapedCache[TId, TObj]) readPersisted(path string, log bool, report *RecoveryReport) ([]walRecord[TId, TObj], error) {
+
+	file, err := os.Open(filepath.Clean(path))
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return t.readLines(file, log, report)
+
+}
+
+// reads the lines of a snapshot or of a write-ahead log from r (see readPersisted)
+func (t *HeapedCache[TId, TObj]) readLines(r io.Reader, log bool, report *RecoveryReport) ([]walRecord[TId, TObj], error) {
+
+	var result []walRecord[TId, TObj]
+
+	reader := bufio.NewReader(r)
+
+	for {
+
+		line, err := reader.ReadBytes('\n')
+
+		if len(bytes.TrimSpace(line)) > 0 {
+
+			if record, ok := t.decodeLine(line, log, report); ok {
+				result = append(result, record)
+			}
+
+		}
+
+		if err == io.EOF {
+			return result, nil
+		} else if err != nil {
+			return result, err
+		}
+
+	}
+
+}
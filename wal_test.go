@@ -0,0 +1,75 @@
+package utils
+
+import (
+    "context"
+    "github.com/stretchr/testify/require"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+func TestRecover(t *testing.T) {
+
+    t.Log("validating TestRecover")
+
+    dir := t.TempDir()
+    snapshotPath := filepath.Join(dir, "cache.ndjson")
+    walPath := filepath.Join(dir, "cache.wal")
+
+    clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+    heapedCache := NewDeterministicHeapedCache[int, AccountTest](3, 1, clock)
+
+    report, err := heapedCache.Recover(snapshotPath, walPath)
+    require.NoError(t, err)
+    require.Equal(t, RecoveryReport{}, report)
+
+    for i := range 4 {
+        heapedCache.Push(i, NewAccountTest(i))
+        clock.Advance(time.Second)
+    }
+
+    // 0 was evicted
+    heapedCache.Remove(2)
+    heapedCache.Patch(3, func(obj *AccountTest) { obj.Name = "patched" })
+
+    // crash: no checkpoint, and the last line of the log is torn
+    file, err := os.OpenFile(walPath, os.O_WRONLY|os.O_APPEND, 0o644)
+    require.NoError(t, err)
+    _, err = file.WriteString(`{"op":"push","id":9,"refr`)
+    require.NoError(t, err)
+    require.NoError(t, file.Close())
+
+    recovered := NewDeterministicHeapedCache[int, AccountTest](3, 1, clock)
+
+    report, err = recovered.Recover(snapshotPath, walPath)
+    require.NoError(t, err)
+    require.Equal(t, RecoveryReport{Replayed: 7, Corrupt: 1}, report)
+    require.Equal(t, heapedCache.Snapshot(), recovered.Snapshot())
+    require.Equal(t, "patched", recovered.Get(3).Name)
+
+    // the recovery checkpointed: the log is empty and the snapshot has everything
+    info, err := os.Stat(walPath)
+    require.NoError(t, err)
+    require.Zero(t, info.Size())
+
+    recovered.Remove(1)
+
+    again := NewDeterministicHeapedCache[int, AccountTest](3, 1, clock)
+
+    report, err = again.Recover(snapshotPath, walPath)
+    require.NoError(t, err)
+    require.Equal(t, RecoveryReport{Restored: 2, Replayed: 1}, report)
+    require.Equal(t, recovered.Snapshot(), again.Snapshot())
+    require.NoError(t, again.WALError())
+
+    // OnShutdown checkpoints
+    again.Push(5, NewAccountTest(5))
+
+    shutdown, err := again.OnShutdown(context.Background())
+    require.NoError(t, err)
+    require.Equal(t, 2, shutdown.Persisted)
+
+    require.ErrorIs(t, NewHeapedCache[int, AccountTest](1).Checkpoint(context.Background()), ErrNoWAL)
+
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// critical section that held the cache lock for longer than the watchdog threshold
+type LockStall struct {
+	Op     string        // operation holding the lock (OpGet, OpPush, OpScan, ... or "other")
+	Held   time.Duration // how long it had held it when detected
+	Stacks []byte        // stack traces of every goroutine when detected, the holder among them
+}
+
+// watches the holder of the cache lock
+type lockWatchdog struct {
+	threshold time.Duration
+	report    func(stall LockStall)
+	stalls    atomic.Uint64
+
+	mu       sync.Mutex
+	op       string
+	acquired time.Time // zero while the lock is free
+
+	reported time.Time // acquisition of the last stall, only used by the watchdog goroutine
+}
+
+// starts a watchdog goroutine that detects any critical section holding the cache lock
+// for longer than threshold, which is what makes the cache freeze. Stalls are counted in
+// Stats.LockStalls and, when report is not nil, reported once per critical section
+// with the stack traces of every goroutine (e.g. to log them), so the holder can be found
+// while it is still stuck. Close stops it
+func WithLockWatchdog[TId comparable, TObj any](threshold time.Duration, report func(stall LockStall)) Option[TId, TObj] {
+
+	return func(t *HeapedCache[TId, TObj]) {
+
+		if threshold <= 0 {
+			return
+		}
+
+		t.watchdog = &lockWatchdog{threshold: threshold, report: report}
+		t.every(taskWatchdog, max(threshold/4, time.Millisecond), t.watchdog.check)
+
+	}
+
+}
+
+// called once the lock is taken on behalf of op
+func (w *lockWatchdog) held(op string) {
+
+	w.mu.Lock()
+	w.op = op
+	w.acquired = time.Now()
+	w.mu.Unlock()
+
+}
+
+// called before the lock is released
+func (w *lockWatchdog) released() {
+
+	w.mu.Lock()
+	w.acquired = time.Time{}
+	w.mu.Unlock()
+
+}
+
+func (w *lockWatchdog) check() {
+
+	w.mu.Lock()
+	op, acquired := w.op, w.acquired
+	w.mu.Unlock()
+
+	if acquired.IsZero() || acquired.Equal(w.reported) || time.Since(acquired) < w.threshold {
+		return
+	}
+
+	w.reported = acquired
+	w.stalls.Add(1)
+
+	if w.report == nil {
+		return
+	}
+
+	w.report(LockStall{Op: op, Held: time.Since(acquired), Stacks: stacks()})
+
+}
+
+// returns the stack traces of every goroutine
+func stacks() []byte {
+
+	buf := make([]byte, 64<<10)
+
+	for {
+
+		n := runtime.Stack(buf, true)
+
+		if n < len(buf) {
+			return buf[:n]
+		}
+
+		buf = make([]byte, 2*len(buf))
+
+	}
+
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+    "context"
+    "github.com/stretchr/testify/require"
+    "testing"
+    "time"
+)
+
+func TestLockWatchdog(t *testing.T) {
+
+    t.Log("validating TestLockWatchdog")
+
+    stalls := make(chan LockStall, 10)
+
+    heapedCache := NewHeapedCache(10, WithLockWatchdog[int, AccountTest](20*time.Millisecond, func(stall LockStall) {
+        stalls <- stall
+    }))
+    defer heapedCache.Close()
+
+    heapedCache.Push(1, NewAccountTest(1))
+
+    require.NoError(t, heapedCache.ForEach(context.Background(), func(id int, obj *AccountTest) bool {
+        time.Sleep(100 * time.Millisecond)
+        return true
+    }))
+
+    stall := <-stalls
+    require.Equal(t, OpScan, stall.Op)
+    require.GreaterOrEqual(t, stall.Held, 20*time.Millisecond)
+    require.Contains(t, string(stall.Stacks), "TestLockWatchdog")
+
+    time.Sleep(50 * time.Millisecond)
+    require.Empty(t, stalls)
+    require.Equal(t, uint64(1), heapedCache.Stats().LockStalls)
+
+}